@@ -17,32 +17,33 @@ limitations under the License.
 package controller
 
 import (
-	"strings"
+	"fmt"
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 
 	"github.com/hyperledger/fabric/consensus"
-	"github.com/hyperledger/fabric/consensus/noops"
-	"github.com/hyperledger/fabric/consensus/pbft"
+	// the plugins register themselves with consensus.Registry
+	_ "github.com/hyperledger/fabric/consensus/noops"
+	_ "github.com/hyperledger/fabric/consensus/pbft"
+	_ "github.com/hyperledger/fabric/consensus/raft"
 )
 
 var logger *logging.Logger // package-level logger
-var consenter consensus.Consenter
 
 func init() {
 	logger = logging.MustGetLogger("consensus/controller")
 }
 
-// NewConsenter constructs a Consenter object if not already present
-func NewConsenter(stack consensus.Stack) consensus.Consenter {
-
-	plugin := strings.ToLower(viper.GetString("peer.validator.consensus.plugin"))
-	if plugin == "pbft" {
-		logger.Infof("Creating consensus plugin %s", plugin)
-		return pbft.GetPlugin(stack)
+// NewConsenter constructs the Consenter of the plugin named by
+// 'peer.validator.consensus.plugin'. It returns an error listing the registered plugins
+// if the name is not registered with consensus.Registry
+func NewConsenter(stack consensus.Stack) (consensus.Consenter, error) {
+	plugin := viper.GetString("peer.validator.consensus.plugin")
+	logger.Infof("Creating consensus plugin %s", plugin)
+	consenter, err := consensus.Registry.New(plugin, stack)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid 'peer.validator.consensus.plugin': %s", err)
 	}
-	logger.Info("Creating default consensus plugin (noops)")
-	return noops.GetNoops(stack)
-
+	return consenter, nil
 }
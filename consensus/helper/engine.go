@@ -104,29 +104,40 @@ var engineOnce sync.Once
 
 var engine *EngineImpl
 
+// engineErr records why the engine could not be constructed, so that every
+// call to GetEngine reports it
+var engineErr error
+
 func getEngineImpl() *EngineImpl {
 	return engine
 }
 
 // GetEngine returns initialized peer.Engine
 func GetEngine(coord peer.MessageHandlerCoordinator) (peer.Engine, error) {
-	var err error
 	engineOnce.Do(func() {
-		engine = new(EngineImpl)
-		engine.helper = NewHelper(coord)
-		engine.consenter = controller.NewConsenter(engine.helper)
-		engine.helper.setConsenter(engine.consenter)
-		engine.peerEndpoint, err = coord.GetPeerEndpoint()
-		engine.consensusFan = util.NewMessageFan()
+		eng := new(EngineImpl)
+		eng.helper = NewHelper(coord)
+		eng.consenter, engineErr = controller.NewConsenter(eng.helper)
+		if engineErr != nil {
+			eng.helper.executor.Halt()
+			return
+		}
+		eng.helper.setConsenter(eng.consenter)
+		eng.peerEndpoint, engineErr = coord.GetPeerEndpoint()
+		eng.consensusFan = util.NewMessageFan()
 
 		go func() {
 			logger.Debug("Starting up message thread for consenter")
 
 			// The channel never closes, so this should never break
-			for msg := range engine.consensusFan.GetOutChannel() {
-				engine.consenter.RecvMsg(msg.Msg, msg.Sender)
+			for msg := range eng.consensusFan.GetOutChannel() {
+				eng.consenter.RecvMsg(msg.Msg, msg.Sender)
 			}
 		}()
+		engine = eng
 	})
-	return engine, err
+	if engine == nil {
+		return nil, engineErr
+	}
+	return engine, engineErr
 }
@@ -257,7 +257,7 @@ func (h *Helper) PreviewCommitTxBatch(id interface{}, metadata []byte) ([]byte,
 		return nil, fmt.Errorf("Failed to get the ledger: %v", err)
 	}
 	// TODO fix this once the underlying API is fixed
-	blockInfo, err := ledger.GetTXBatchPreviewBlockInfo(id, h.curBatch, metadata)
+	blockInfo, err := ledger.GetTXBatchPreviewBlockInfo(id, h.curBatch, h.curBatchErrs, metadata)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to preview commit: %v", err)
 	}
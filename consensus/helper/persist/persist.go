@@ -17,52 +17,63 @@ limitations under the License.
 package persist
 
 import (
-	"github.com/hyperledger/fabric/core/db"
-	"fmt"
 	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/op/go-logging"
 )
 
+var logger = logging.MustGetLogger("persist")
+
 // Helper provides an abstraction to access the Persist column family
 // in the database.
 type Helper struct{}
 
 // StoreState stores a key,value pair
 func (h *Helper) StoreState(key string, value []byte) error {
-
-	fmt.Println(db.Registry.Registered())
-	//db := db.GetDBHandle()
-	//str := db.OpenchainDB.Type()
-	db, _ := db.Registry.Get(comm.DbPluginName())
-	//fmt.Println(str)
-	//db,_ := db.Registry.Get(db.OpenchainDB.Type())
-	return db.PutPersistCF([]byte("consensus."+key), value)
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return err
+	}
+	return openchainDB.PutToPersistence([]byte("consensus."+key), value)
 }
 
 // DelState removes a key,value pair
 func (h *Helper) DelState(key string) {
-	db, _ := db.Registry.Get(comm.DbPluginName())
-	fmt.Println(db)
-	db.DeletePersist([]byte("consensus."+key))
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		logger.Errorf("Could not delete state [%s]: %s", key, err)
+		return
+	}
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Delete(db.PersistCF, []byte("consensus."+key))
+	if err := writeBatch.Commit(db.SyncWrite); err != nil {
+		logger.Errorf("Could not delete state [%s]: %s", key, err)
+	}
 }
 
 // ReadState retrieves a value to a key
 func (h *Helper) ReadState(key string) ([]byte, error) {
-	db, _ := db.Registry.Get(comm.DbPluginName())
-	return db.GetFromPersist([]byte("consensus."+key))
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	return openchainDB.GetFromPersistence([]byte("consensus." + key))
 }
 
 // ReadStateSet retrieves all key,value pairs where the key starts with prefix
 func (h *Helper) ReadStateSet(prefix string) (map[string][]byte, error) {
-	db, _ := db.Registry.Get(comm.DbPluginName())
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
 	prefixRaw := []byte("consensus." + prefix)
 
 	ret := make(map[string][]byte)
-	it := db.GetBlockchainIterator()
-
+	it := openchainDB.GetPersistenceIterator()
 	defer it.Close()
 	for it.Seek(prefixRaw); it.ValidForPrefix(prefixRaw); it.Next() {
 		key := string(it.KeyData())
-		//key := string(it.Key().Data())
 		key = key[len("consensus."):]
 		// copy data from the slice!
 		ret[key] = append([]byte(nil), it.ValueData()...)
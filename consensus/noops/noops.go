@@ -31,10 +31,14 @@ import (
 	pb "github.com/hyperledger/fabric/protos"
 )
 
+// Name is the name under which this plugin is registered with 'consensus.Registry'
+const Name = "noops"
+
 var logger *logging.Logger // package-level logger
 
 func init() {
 	logger = logging.MustGetLogger("consensus/noops")
+	consensus.Registry.Add(Name, GetNoops)
 }
 
 // Noops is a plugin object implementing the consensus.Consenter interface.
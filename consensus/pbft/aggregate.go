@@ -0,0 +1,201 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// signFunc signs a message with the key of this replica
+type signFunc func(message []byte) ([]byte, error)
+
+// verifyFunc checks the signature of a replica over a message
+type verifyFunc func(replicaID uint64, signature []byte, message []byte) error
+
+// publicKeyFunc returns the BLS public key of a replica
+type publicKeyFunc func(replicaID uint64) ([]byte, error)
+
+// signatureKeys are the keys the aggregation schemes sign and verify with;
+// a scheme only used to verify needs no key of this replica
+type signatureKeys struct {
+	sign          signFunc      // signs with the enrollment key of this replica
+	verify        verifyFunc    // verifies against the enrollment keys of the replicas
+	blsPrivateKey []byte        // BLS private key of this replica
+	blsPublicKey  publicKeyFunc // BLS public keys of the replicas
+}
+
+// signatureAggregator signs the signables of a replica and combines the
+// signatures of several replicas, each over its own message, into one
+// aggregate signature
+type signatureAggregator interface {
+	sign(message []byte) ([]byte, error)
+	verify(replicaID uint64, signature []byte, message []byte) error
+	aggregate(signers []uint64, signatures [][]byte) (*AggregateSignature, error)
+	verifyAggregate(agg *AggregateSignature, messages [][]byte) error
+	// compact reports whether an aggregate is smaller than the signatures it combines
+	compact() bool
+}
+
+const signatureListScheme = "list"
+
+// aggregationSchemes maps the names configured in general.signatures.aggregation,
+// and recorded in every aggregate signature, to their constructors
+var aggregationSchemes = map[string]func(keys *signatureKeys) (signatureAggregator, error){
+	signatureListScheme: newSignatureList,
+	blsScheme:           newBLSAggregator,
+}
+
+func newAggregator(scheme string, keys *signatureKeys) (signatureAggregator, error) {
+	newScheme, ok := aggregationSchemes[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown signature aggregation scheme %q", scheme)
+	}
+	return newScheme(keys)
+}
+
+// verifyAggregate verifies an aggregate signature with the scheme it was created with
+func verifyAggregate(agg *AggregateSignature, messages [][]byte, keys *signatureKeys) error {
+	aggregator, err := newAggregator(agg.Scheme, keys)
+	if err != nil {
+		return err
+	}
+	seen := make(map[uint64]bool)
+	for _, id := range agg.Signers {
+		if seen[id] {
+			return fmt.Errorf("replica %d signed more than once", id)
+		}
+		seen[id] = true
+	}
+	return aggregator.verifyAggregate(agg, messages)
+}
+
+// signatureList signs with the enrollment keys and collects the individual
+// signatures, in the order of the signers; it relies on nothing but the
+// signatures of the stack, and its aggregates are as large as the
+// signatures they carry
+type signatureList struct {
+	keys *signatureKeys
+}
+
+func newSignatureList(keys *signatureKeys) (signatureAggregator, error) {
+	return &signatureList{keys: keys}, nil
+}
+
+func (sl *signatureList) sign(message []byte) ([]byte, error) {
+	if sl.keys.sign == nil {
+		return nil, fmt.Errorf("no enrollment key to sign with")
+	}
+	return sl.keys.sign(message)
+}
+
+func (sl *signatureList) verify(replicaID uint64, signature []byte, message []byte) error {
+	if sl.keys.verify == nil {
+		return fmt.Errorf("no enrollment keys to verify with")
+	}
+	return sl.keys.verify(replicaID, signature, message)
+}
+
+func (sl *signatureList) compact() bool {
+	return false
+}
+
+func (sl *signatureList) aggregate(signers []uint64, signatures [][]byte) (*AggregateSignature, error) {
+	if len(signers) != len(signatures) {
+		return nil, fmt.Errorf("%d signatures for %d signers", len(signatures), len(signers))
+	}
+	raw, err := proto.Marshal(&MultiSignature{Signatures: signatures})
+	if err != nil {
+		return nil, err
+	}
+	return &AggregateSignature{
+		Scheme:    signatureListScheme,
+		Signers:   signers,
+		Signature: raw,
+	}, nil
+}
+
+func (sl *signatureList) verifyAggregate(agg *AggregateSignature, messages [][]byte) error {
+	multi := &MultiSignature{}
+	if err := proto.Unmarshal(agg.Signature, multi); err != nil {
+		return err
+	}
+	if len(multi.Signatures) != len(agg.Signers) || len(messages) != len(agg.Signers) {
+		return fmt.Errorf("%d signatures and %d messages for %d signers", len(multi.Signatures), len(messages), len(agg.Signers))
+	}
+	for i, id := range agg.Signers {
+		if err := sl.verify(id, multi.Signatures[i], messages[i]); err != nil {
+			return fmt.Errorf("signature of replica %d: %s", id, err)
+		}
+	}
+	return nil
+}
+
+// newCheckpointCertificate aggregates the signatures of matching checkpoints;
+// unsigned checkpoints, sent by replicas which do not sign their checkpoints
+// yet, are left out, so a quorum of the checkpoints must be signed
+func (instance *pbftCore) newCheckpointCertificate(chkpts []*Checkpoint) (*CheckpointCertificate, error) {
+	var items []signable
+	for _, chkpt := range chkpts {
+		if chkpt.Signature == nil {
+			continue
+		}
+		unsigned := *chkpt
+		items = append(items, &unsigned)
+	}
+	if len(items) < instance.intersectionQuorum() {
+		return nil, fmt.Errorf("only %d of %d matching checkpoints are signed, but %d are required", len(items), len(chkpts), instance.intersectionQuorum())
+	}
+	agg, err := instance.aggregate(items)
+	if err != nil {
+		return nil, err
+	}
+	return &CheckpointCertificate{
+		SequenceNumber: chkpts[0].SequenceNumber,
+		Id:             chkpts[0].Id,
+		Signature:      agg,
+	}, nil
+}
+
+// VerifyCheckpointCertificate checks that at least quorum replicas signed
+// the checkpoint a certificate attests to. Certificates of the list scheme
+// are checked with verify, which checks the signature of a replica;
+// certificates of the bls scheme with the keys returned by blsPublicKey.
+// The function of the scheme not in use may be nil
+func VerifyCheckpointCertificate(cert *CheckpointCertificate, quorum int, verify func(replicaID uint64, signature []byte, message []byte) error, blsPublicKey func(replicaID uint64) ([]byte, error)) error {
+	agg := cert.GetSignature()
+	if agg == nil {
+		return fmt.Errorf("checkpoint certificate is not signed")
+	}
+	if len(agg.Signers) < quorum {
+		return fmt.Errorf("checkpoint certificate is signed by %d replicas, but %d are required", len(agg.Signers), quorum)
+	}
+	messages := make([][]byte, len(agg.Signers))
+	for i, id := range agg.Signers {
+		raw, err := proto.Marshal(&Checkpoint{
+			SequenceNumber: cert.SequenceNumber,
+			ReplicaId:      id,
+			Id:             cert.Id,
+		})
+		if err != nil {
+			return err
+		}
+		messages[i] = raw
+	}
+	return verifyAggregate(agg, messages, &signatureKeys{verify: verify, blsPublicKey: blsPublicKey})
+}
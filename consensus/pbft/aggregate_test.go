@@ -0,0 +1,354 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// the messages signed by replicas carry their ID, so a copy of the
+// message is enough of a signature for these tests
+func echoSign(msg []byte) ([]byte, error) {
+	return msg, nil
+}
+
+func echoVerify(senderID uint64, signature []byte, message []byte) error {
+	if !bytes.Equal(signature, message) {
+		return fmt.Errorf("signature does not match message")
+	}
+	return nil
+}
+
+// blsTestKey returns the BLS key pair of a replica, derived from its ID
+func blsTestKey(id uint64) (privateKey []byte, publicKey []byte) {
+	privateKey, publicKey, err := GenerateBLSKey(rand.New(rand.NewSource(int64(id))))
+	if err != nil {
+		panic(err)
+	}
+	return privateKey, publicKey
+}
+
+func blsTestPublicKey(replicaID uint64) ([]byte, error) {
+	if replicaID >= 4 {
+		return nil, fmt.Errorf("replica %d is not a member", replicaID)
+	}
+	_, publicKey := blsTestKey(replicaID)
+	return publicKey, nil
+}
+
+// aggregationTestConfig configures replica id of a network of four
+// replicas to sign with scheme
+func aggregationTestConfig(id uint64, scheme string) *viper.Viper {
+	config := loadConfig()
+	config.Set("general.signatures.aggregation", scheme)
+	if scheme == blsScheme {
+		var publicKeys []string
+		for i := uint64(0); i < 4; i++ {
+			_, publicKey := blsTestKey(i)
+			publicKeys = append(publicKeys, hex.EncodeToString(publicKey))
+		}
+		privateKey, _ := blsTestKey(id)
+		config.Set("general.signatures.bls.privatekey", hex.EncodeToString(privateKey))
+		config.Set("general.signatures.bls.publickeys", publicKeys)
+	}
+	return config
+}
+
+// signAs signs s with the key replica id holds under scheme
+func signAs(t *testing.T, scheme string, id uint64, s signable) {
+	keys := &signatureKeys{sign: echoSign}
+	if scheme == blsScheme {
+		keys.blsPrivateKey, _ = blsTestKey(id)
+	}
+	signer, err := newAggregator(scheme, keys)
+	if err != nil {
+		t.Fatalf("Could not create aggregator: %s", err)
+	}
+	s.setSignature(nil)
+	raw, err := s.serialize()
+	if err != nil {
+		t.Fatalf("Could not serialize message: %s", err)
+	}
+	sig, err := signer.sign(raw)
+	if err != nil {
+		t.Fatalf("Replica %d could not sign: %s", id, err)
+	}
+	s.setSignature(sig)
+}
+
+func TestSignatureList(t *testing.T) {
+	keys := &signatureKeys{sign: echoSign, verify: echoVerify}
+	sl, err := newAggregator(signatureListScheme, keys)
+	if err != nil {
+		t.Fatalf("Could not create aggregator: %s", err)
+	}
+	if _, err = newAggregator("threshold", keys); err == nil {
+		t.Errorf("Expected unknown aggregation scheme to be rejected")
+	}
+	if sl.compact() {
+		t.Errorf("Expected the list scheme not to be compact")
+	}
+
+	messages := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	agg, err := sl.aggregate([]uint64{0, 1, 2}, messages)
+	if err != nil {
+		t.Fatalf("Could not aggregate signatures: %s", err)
+	}
+	if err = verifyAggregate(agg, messages, keys); err != nil {
+		t.Errorf("Expected aggregate signature to verify: %s", err)
+	}
+
+	tampered := [][]byte{[]byte("one"), []byte("two"), []byte("four")}
+	if err = verifyAggregate(agg, tampered, keys); err == nil {
+		t.Errorf("Expected aggregate signature over a tampered message to be rejected")
+	}
+
+	agg.Signers[2] = 0
+	if err = verifyAggregate(agg, messages, keys); err == nil {
+		t.Errorf("Expected aggregate signature with a duplicate signer to be rejected")
+	}
+}
+
+func TestBLSAggregation(t *testing.T) {
+	signers := []uint64{0, 1, 3}
+	messages := [][]byte{[]byte("one"), []byte("two"), []byte("three")}
+	var signatures [][]byte
+	for i, id := range signers {
+		privateKey, _ := blsTestKey(id)
+		ba, err := newAggregator(blsScheme, &signatureKeys{blsPrivateKey: privateKey, blsPublicKey: blsTestPublicKey})
+		if err != nil {
+			t.Fatalf("Could not create aggregator: %s", err)
+		}
+		sig, err := ba.sign(messages[i])
+		if err != nil {
+			t.Fatalf("Replica %d could not sign: %s", id, err)
+		}
+		if err = ba.verify(id, sig, messages[i]); err != nil {
+			t.Errorf("Expected signature of replica %d to verify: %s", id, err)
+		}
+		if err = ba.verify(2, sig, messages[i]); err == nil {
+			t.Errorf("Expected signature of replica %d to be rejected as one of replica 2", id)
+		}
+		signatures = append(signatures, sig)
+	}
+
+	keys := &signatureKeys{blsPublicKey: blsTestPublicKey}
+	ba, err := newAggregator(blsScheme, keys)
+	if err != nil {
+		t.Fatalf("Could not create aggregator: %s", err)
+	}
+	if !ba.compact() {
+		t.Errorf("Expected the bls scheme to be compact")
+	}
+	if _, err = ba.sign(messages[0]); err == nil {
+		t.Errorf("Expected signing without a private key to fail")
+	}
+	agg, err := ba.aggregate(signers, signatures)
+	if err != nil {
+		t.Fatalf("Could not aggregate signatures: %s", err)
+	}
+	if len(agg.Signature) != blsSignatureSize {
+		t.Errorf("Expected an aggregate signature of %d bytes, got %d", blsSignatureSize, len(agg.Signature))
+	}
+	if err = verifyAggregate(agg, messages, keys); err != nil {
+		t.Errorf("Expected aggregate signature to verify: %s", err)
+	}
+
+	tampered := [][]byte{[]byte("one"), []byte("two"), []byte("four")}
+	if err = verifyAggregate(agg, tampered, keys); err == nil {
+		t.Errorf("Expected aggregate signature over a tampered message to be rejected")
+	}
+
+	swapped := [][]byte{messages[1], messages[0], messages[2]}
+	if err = verifyAggregate(agg, swapped, keys); err == nil {
+		t.Errorf("Expected aggregate signature over messages of the wrong signers to be rejected")
+	}
+
+	agg.Signers = []uint64{0, 1, 2}
+	if err = verifyAggregate(agg, messages, keys); err == nil {
+		t.Errorf("Expected aggregate signature with a wrong signer to be rejected")
+	}
+
+	agg.Signers = []uint64{0, 1, 3}
+	agg.Signature = make([]byte, blsSignatureSize)
+	if err = verifyAggregate(agg, messages, keys); err == nil {
+		t.Errorf("Expected aggregate signature at infinity to be rejected")
+	}
+}
+
+func TestCheckpointCertificate(t *testing.T) {
+	for _, scheme := range []string{signatureListScheme, blsScheme} {
+		t.Logf("Testing scheme [%s]", scheme)
+		testCheckpointCertificate(t, scheme)
+	}
+}
+
+func testCheckpointCertificate(t *testing.T, scheme string) {
+	instance := newPbftCore(0, aggregationTestConfig(0, scheme), &omniProto{
+		signImpl:   echoSign,
+		verifyImpl: echoVerify,
+	}, &inertTimerFactory{})
+	defer instance.close()
+
+	instance.chkpts[10] = "state"
+	for id := uint64(1); id <= 3; id++ {
+		chkpt := &Checkpoint{SequenceNumber: 10, ReplicaId: id, Id: "state"}
+		signAs(t, scheme, id, chkpt)
+		if err := instance.verify(chkpt); err != nil {
+			t.Fatalf("Checkpoint of replica %d should verify: %s", id, err)
+		}
+		instance.recvCheckpoint(chkpt)
+	}
+
+	cert := instance.stableCert
+	if cert == nil {
+		t.Fatalf("Expected a checkpoint certificate after a checkpoint quorum")
+	}
+	if cert.SequenceNumber != 10 || cert.Id != "state" || cert.Signature.Scheme != scheme {
+		t.Errorf("Expected %s certificate for checkpoint 10, got %v", scheme, cert)
+	}
+	if err := VerifyCheckpointCertificate(cert, instance.intersectionQuorum(), echoVerify, blsTestPublicKey); err != nil {
+		t.Errorf("Expected checkpoint certificate to verify: %s", err)
+	}
+	if err := VerifyCheckpointCertificate(cert, instance.N, echoVerify, blsTestPublicKey); err == nil {
+		t.Errorf("Expected checkpoint certificate to fall short of %d signers", instance.N)
+	}
+
+	cert.Id = "other"
+	if err := VerifyCheckpointCertificate(cert, instance.intersectionQuorum(), echoVerify, blsTestPublicKey); err == nil {
+		t.Errorf("Expected certificate for a different checkpoint to be rejected")
+	}
+}
+
+func TestCheckpointCertificateUnsigned(t *testing.T) {
+	for _, scheme := range []string{signatureListScheme, blsScheme} {
+		t.Logf("Testing scheme [%s]", scheme)
+		testCheckpointCertificateUnsigned(t, scheme)
+	}
+}
+
+func testCheckpointCertificateUnsigned(t *testing.T, scheme string) {
+	instance := newPbftCore(0, aggregationTestConfig(0, scheme), &omniProto{
+		signImpl:   echoSign,
+		verifyImpl: echoVerify,
+	}, &inertTimerFactory{})
+	defer instance.close()
+
+	unsigned := &Checkpoint{SequenceNumber: 10, ReplicaId: 1, Id: "state"}
+	if _, err := instance.recvMsg(&Message{Payload: &Message_Checkpoint{Checkpoint: unsigned}}, 1); err != nil {
+		t.Errorf("Expected unsigned checkpoint to be accepted: %s", err)
+	}
+	tampered := &Checkpoint{SequenceNumber: 10, ReplicaId: 1, Id: "state"}
+	signAs(t, scheme, 1, tampered)
+	tampered.Id = "other"
+	if _, err := instance.recvMsg(&Message{Payload: &Message_Checkpoint{Checkpoint: tampered}}, 1); err == nil {
+		t.Errorf("Expected checkpoint with an incorrect signature to be rejected")
+	}
+
+	var chkpts []*Checkpoint
+	for id := uint64(0); id <= 3; id++ {
+		chkpt := &Checkpoint{SequenceNumber: 10, ReplicaId: id, Id: "state"}
+		if id != 1 {
+			signAs(t, scheme, id, chkpt)
+		}
+		chkpts = append(chkpts, chkpt)
+	}
+	cert, err := instance.newCheckpointCertificate(chkpts)
+	if err != nil {
+		t.Fatalf("Expected a certificate of the signed checkpoints: %s", err)
+	}
+	if signers := cert.Signature.Signers; len(signers) != 3 || signers[0] != 0 || signers[1] != 2 || signers[2] != 3 {
+		t.Errorf("Expected the certificate to be signed by replicas 0, 2 and 3, got %v", signers)
+	}
+	if err = VerifyCheckpointCertificate(cert, instance.intersectionQuorum(), echoVerify, blsTestPublicKey); err != nil {
+		t.Errorf("Expected checkpoint certificate to verify: %s", err)
+	}
+	if chkpts[0].Signature == nil {
+		t.Errorf("Expected the checkpoints to keep their signatures")
+	}
+
+	if _, err = instance.newCheckpointCertificate(chkpts[:3]); err == nil {
+		t.Errorf("Expected no certificate with only 2 signed checkpoints")
+	}
+
+	instance.chkpts[10] = "state"
+	for _, chkpt := range chkpts[1:] {
+		instance.recvCheckpoint(chkpt)
+	}
+	if instance.h != 10 {
+		t.Errorf("Expected unsigned checkpoint to count towards the checkpoint quorum, low watermark is %d", instance.h)
+	}
+	if instance.stableCert != nil {
+		t.Errorf("Expected no certificate of a quorum with an unsigned checkpoint, got %v", instance.stableCert)
+	}
+}
+
+func TestNewViewAggregateSignature(t *testing.T) {
+	for _, scheme := range []string{signatureListScheme, blsScheme} {
+		t.Logf("Testing scheme [%s]", scheme)
+		testNewViewAggregateSignature(t, scheme)
+	}
+}
+
+func testNewViewAggregateSignature(t *testing.T, scheme string) {
+	instance := newPbftCore(0, aggregationTestConfig(0, scheme), &omniProto{
+		signImpl:   echoSign,
+		verifyImpl: echoVerify,
+	}, &inertTimerFactory{})
+	defer instance.close()
+
+	newView := func() *NewView {
+		var vset []*ViewChange
+		var items []signable
+		for id := uint64(1); id <= 3; id++ {
+			vc := &ViewChange{View: 1, ReplicaId: id}
+			signAs(t, scheme, id, vc)
+			vset = append(vset, vc)
+			items = append(items, vc)
+		}
+		agg, err := instance.aggregate(items)
+		if err != nil {
+			t.Fatalf("Could not aggregate view-change signatures: %s", err)
+		}
+		return &NewView{View: 1, ReplicaId: 1, Vset: vset, VsetSignature: agg}
+	}
+
+	nv := newView()
+	nv.Vset[2].H = 10
+	instance.recvNewView(nv)
+	if instance.newViewStore[1] != nil {
+		t.Fatalf("Expected new-view with a tampered view-change to be rejected")
+	}
+
+	nv = newView()
+	nv.Vset[0].Signature = []byte("stale")
+	instance.recvNewView(nv)
+	if instance.newViewStore[1] != nil {
+		t.Fatalf("Expected new-view with view-changes carrying their own signatures to be rejected")
+	}
+
+	instance.recvNewView(newView())
+	if instance.newViewStore[1] == nil {
+		t.Errorf("Expected new-view with a valid aggregate signature to be accepted")
+	}
+}
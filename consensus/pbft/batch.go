@@ -84,7 +84,7 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 	op.pbft = newPbftCore(id, config, op, etf)
 	op.manager.Start()
 	op.externalEventReceiver.manager = op.manager
-	op.broadcaster = newBroadcaster(id, op.pbft.N, op.pbft.f, op.pbft.broadcastTimeout, stack)
+	op.broadcaster = newBroadcasterForReplicas(id, op.pbft.replicaIDs(), op.pbft.f, op.pbft.broadcastTimeout, stack)
 
 	op.batchSize = config.GetInt("general.batchsize")
 	op.batchStore = nil
@@ -125,6 +125,52 @@ func (op *obcBatch) Close() {
 	op.pbft.close()
 }
 
+// CheckpointCertificate returns the signatures of a quorum on the last
+// stable checkpoint, see VerifyCheckpointCertificate
+func (op *obcBatch) CheckpointCertificate() *CheckpointCertificate {
+	result := make(chan *CheckpointCertificate, 1)
+	op.manager.Queue() <- workEvent(func() {
+		result <- op.pbft.stableCert
+	})
+	return <-result
+}
+
+// Reconfigure endorses a change of the replica set and submits it for
+// ordering; it takes effect at the checkpoint after f+1 members endorsed it
+func (op *obcBatch) Reconfigure(reconf *Reconfiguration) error {
+	result := make(chan error, 1)
+	op.manager.Queue() <- workEvent(func() {
+		result <- op.submitReconfiguration(reconf)
+	})
+	return <-result
+}
+
+func (op *obcBatch) submitReconfiguration(reconf *Reconfiguration) error {
+	if _, err := op.pbft.nextMembership(reconf); err != nil {
+		return err
+	}
+	raw, err := serializeReconfiguration(reconf)
+	if err != nil {
+		return err
+	}
+	sig, err := op.stack.Sign(raw)
+	if err != nil {
+		return fmt.Errorf("could not endorse reconfiguration: %s", err)
+	}
+
+	endorsed := *reconf
+	endorsed.Endorsements = append(append([]*Endorsement(nil), reconf.Endorsements...), &Endorsement{
+		ReplicaId: op.pbft.id,
+		Signature: sig,
+	})
+	req := op.txToReq(nil)
+	req.Reconfiguration = &endorsed
+	if ev := op.submitToLeader(req); ev != nil {
+		op.manager.Inject(ev)
+	}
+	return nil
+}
+
 func (op *obcBatch) submitToLeader(req *Request) events.Event {
 	// Broadcast the request to the network, in case we're in the wrong view
 	op.broadcastMsg(&BatchMessage{Payload: &BatchMessage_Request{Request: req}})
@@ -137,6 +183,12 @@ func (op *obcBatch) submitToLeader(req *Request) events.Event {
 	return nil
 }
 
+// reconfigured reconnects the broadcaster to the new replica set
+func (op *obcBatch) reconfigured(membership *Membership) {
+	op.broadcaster.Close()
+	op.broadcaster = newBroadcasterForReplicas(op.pbft.id, op.pbft.replicaIDs(), op.pbft.f, op.pbft.broadcastTimeout, op.stack)
+}
+
 func (op *obcBatch) broadcastMsg(msg *BatchMessage) {
 	msgPayload, _ := proto.Marshal(msg)
 	ocMsg := &pb.Message{
@@ -176,6 +228,9 @@ func (op *obcBatch) sign(msg []byte) ([]byte, error) {
 
 // verify message signature
 func (op *obcBatch) verify(senderID uint64, signature []byte, message []byte) error {
+	if cert := op.pbft.enrollmentCert(senderID); cert != nil {
+		return verifyWithEnrollmentCert(cert, signature, message)
+	}
 	senderHandle, err := getValidatorHandle(senderID)
 	if err != nil {
 		return err
@@ -187,6 +242,13 @@ func (op *obcBatch) verify(senderID uint64, signature []byte, message []byte) er
 func (op *obcBatch) execute(seqNo uint64, reqBatch *RequestBatch) {
 	var txs []*pb.Transaction
 	for _, req := range reqBatch.GetBatch() {
+		if reconf := req.GetReconfiguration(); reconf != nil {
+			logger.Debugf("Batch replica %d executing reconfiguration of epoch %d, seqNo=%d", op.pbft.id, reconf.Epoch, seqNo)
+			op.reqStore.remove(req)
+			op.deduplicator.Execute(req)
+			op.pbft.executeReconfiguration(seqNo, reconf)
+			continue
+		}
 		tx := &pb.Transaction{}
 		if err := proto.Unmarshal(req.Payload, tx); err != nil {
 			logger.Warningf("Batch replica %d could not unmarshal transaction %s", op.pbft.id, err)
@@ -199,7 +261,7 @@ func (op *obcBatch) execute(seqNo uint64, reqBatch *RequestBatch) {
 		txs = append(txs, tx)
 		op.deduplicator.Execute(req)
 	}
-	meta, _ := proto.Marshal(&Metadata{seqNo})
+	meta, _ := proto.Marshal(&Metadata{SeqNo: seqNo, Membership: op.pbft.membershipState(seqNo)})
 	logger.Debugf("Batch replica %d received exec for seqNo %d containing %d transactions", op.pbft.id, seqNo, len(txs))
 	op.stack.Execute(meta, txs) // This executes in the background, we will receive an executedEvent once it completes
 }
@@ -289,6 +351,10 @@ func (op *obcBatch) processMessage(ocMsg *pb.Message, senderHandle *pb.PeerID) e
 		if err != nil {
 			panic("Cannot map sender's PeerID to a valid replica ID")
 		}
+		if !op.pbft.isMember(senderID) && op.pbft.isMember(op.pbft.id) {
+			logger.Warningf("Replica %d ignoring message from replica %d, which is not a member", op.pbft.id, senderID)
+			return nil
+		}
 		msg := &Message{}
 		err = proto.Unmarshal(pbftMsg, msg)
 		if err != nil {
@@ -0,0 +1,258 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/bn256"
+)
+
+// --------------------------------------------------------------
+//
+// bls implements the bls aggregation scheme, BLS signatures over
+// the bn256 pairing.  A signature is a point of G1, the public key
+// a point of G2; the signatures of several replicas, each over its
+// own message, add up to one point of G1, so an aggregate takes
+// 64 bytes however many replicas signed.  Every signature covers
+// the public key of its signer along with the message, which rules
+// out rogue key attacks without a proof of possession of the
+// private keys.
+//
+// --------------------------------------------------------------
+
+const blsScheme = "bls"
+
+const (
+	blsPrivateKeySize = 32
+	blsPublicKeySize  = 128
+	blsSignatureSize  = 64
+)
+
+// blsFieldPrime is the prime over which G1, the curve y² = x³ + 3, is defined
+var blsFieldPrime, _ = new(big.Int).SetString("65000549695646603732796438742359905742825358107623003571877145026864184071783", 10)
+
+var blsG2Generator = new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+
+type blsAggregator struct {
+	privateKey   *big.Int
+	publicKey    []byte
+	blsPublicKey publicKeyFunc
+}
+
+func newBLSAggregator(keys *signatureKeys) (signatureAggregator, error) {
+	agg := &blsAggregator{blsPublicKey: keys.blsPublicKey}
+	if keys.blsPrivateKey != nil {
+		privateKey, err := parseBLSPrivateKey(keys.blsPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		agg.privateKey = privateKey
+		agg.publicKey = new(bn256.G2).ScalarBaseMult(privateKey).Marshal()
+	}
+	return agg, nil
+}
+
+// GenerateBLSKey returns a new BLS private key of a replica, read from rand,
+// and the public key the replica is registered with
+func GenerateBLSKey(rand io.Reader) (privateKey []byte, publicKey []byte, err error) {
+	k, pk, err := bn256.RandomG2(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey = make([]byte, blsPrivateKeySize)
+	kBytes := k.Bytes()
+	copy(privateKey[blsPrivateKeySize-len(kBytes):], kBytes)
+	return privateKey, pk.Marshal(), nil
+}
+
+func parseBLSPrivateKey(raw []byte) (*big.Int, error) {
+	if len(raw) != blsPrivateKeySize {
+		return nil, fmt.Errorf("BLS private key has %d bytes, expected %d", len(raw), blsPrivateKeySize)
+	}
+	k := new(big.Int).SetBytes(raw)
+	if k.Sign() == 0 || k.Cmp(bn256.Order) >= 0 {
+		return nil, fmt.Errorf("BLS private key is out of range")
+	}
+	return k, nil
+}
+
+// parseBLSPublicKey decodes a public key; the point at infinity, against
+// which any signature verifies, is rejected
+func parseBLSPublicKey(raw []byte) (*bn256.G2, error) {
+	if len(raw) != blsPublicKeySize || isZero(raw) {
+		return nil, fmt.Errorf("invalid BLS public key")
+	}
+	pk, ok := new(bn256.G2).Unmarshal(raw)
+	if !ok || !bytes.Equal(pk.Marshal(), raw) {
+		return nil, fmt.Errorf("invalid BLS public key")
+	}
+	return pk, nil
+}
+
+// parseBLSSignature decodes a signature, which must be a point of G1 in
+// its canonical encoding other than the point at infinity
+func parseBLSSignature(raw []byte) (*bn256.G1, error) {
+	if len(raw) != blsSignatureSize || isZero(raw) {
+		return nil, fmt.Errorf("invalid BLS signature")
+	}
+	sig, ok := new(bn256.G1).Unmarshal(raw)
+	if !ok || !bytes.Equal(sig.Marshal(), raw) {
+		return nil, fmt.Errorf("invalid BLS signature")
+	}
+	return sig, nil
+}
+
+func isZero(raw []byte) bool {
+	for _, b := range raw {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// blsHash maps the public key of a signer and a message to a point of G1 by
+// trying successive counters until the hash is the x coordinate of a point
+// on the curve; as the order of the curve is prime, every such point is in G1
+func blsHash(publicKey []byte, message []byte) *bn256.G1 {
+	three := big.NewInt(3)
+	raw := make([]byte, blsSignatureSize)
+	for counter := uint32(0); ; counter++ {
+		h := sha256.New()
+		binary.Write(h, binary.BigEndian, counter)
+		h.Write(publicKey)
+		h.Write(message)
+		x := new(big.Int).SetBytes(h.Sum(nil))
+		x.Mod(x, blsFieldPrime)
+
+		y2 := new(big.Int).Exp(x, three, blsFieldPrime)
+		y2.Add(y2, three)
+		y2.Mod(y2, blsFieldPrime)
+		y := new(big.Int).ModSqrt(y2, blsFieldPrime)
+		if y == nil || y.Sign() == 0 {
+			continue
+		}
+
+		for i := range raw {
+			raw[i] = 0
+		}
+		xBytes, yBytes := x.Bytes(), y.Bytes()
+		copy(raw[blsSignatureSize/2-len(xBytes):], xBytes)
+		copy(raw[blsSignatureSize-len(yBytes):], yBytes)
+		if point, ok := new(bn256.G1).Unmarshal(raw); ok {
+			return point
+		}
+	}
+}
+
+func (ba *blsAggregator) publicKeyOf(replicaID uint64) ([]byte, *bn256.G2, error) {
+	if ba.blsPublicKey == nil {
+		return nil, nil, fmt.Errorf("no BLS public keys to verify with")
+	}
+	raw, err := ba.blsPublicKey(replicaID)
+	if err != nil {
+		return nil, nil, err
+	}
+	pk, err := parseBLSPublicKey(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("replica %d: %s", replicaID, err)
+	}
+	return raw, pk, nil
+}
+
+func (ba *blsAggregator) sign(message []byte) ([]byte, error) {
+	if ba.privateKey == nil {
+		return nil, fmt.Errorf("no BLS private key to sign with")
+	}
+	return new(bn256.G1).ScalarMult(blsHash(ba.publicKey, message), ba.privateKey).Marshal(), nil
+}
+
+func (ba *blsAggregator) verify(replicaID uint64, signature []byte, message []byte) error {
+	return ba.verifyPairing(signature, []uint64{replicaID}, [][]byte{message})
+}
+
+func (ba *blsAggregator) compact() bool {
+	return true
+}
+
+func (ba *blsAggregator) aggregate(signers []uint64, signatures [][]byte) (*AggregateSignature, error) {
+	if len(signers) != len(signatures) {
+		return nil, fmt.Errorf("%d signatures for %d signers", len(signatures), len(signers))
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no signatures to aggregate")
+	}
+	var sum *bn256.G1
+	for i, raw := range signatures {
+		sig, err := parseBLSSignature(raw)
+		if err != nil {
+			return nil, fmt.Errorf("signature of replica %d: %s", signers[i], err)
+		}
+		if sum == nil {
+			sum = sig
+		} else {
+			sum = new(bn256.G1).Add(sum, sig)
+		}
+	}
+	return &AggregateSignature{
+		Scheme:    blsScheme,
+		Signers:   signers,
+		Signature: sum.Marshal(),
+	}, nil
+}
+
+func (ba *blsAggregator) verifyAggregate(agg *AggregateSignature, messages [][]byte) error {
+	if len(messages) != len(agg.Signers) {
+		return fmt.Errorf("%d messages for %d signers", len(messages), len(agg.Signers))
+	}
+	if len(agg.Signers) == 0 {
+		return fmt.Errorf("aggregate signature has no signers")
+	}
+	return ba.verifyPairing(agg.Signature, agg.Signers, messages)
+}
+
+// verifyPairing checks e(signature, g2) = e(H(pk_1, m_1), pk_1) ... e(H(pk_n, m_n), pk_n),
+// which holds for the sum of the signatures of the signers over their messages
+func (ba *blsAggregator) verifyPairing(signature []byte, signers []uint64, messages [][]byte) error {
+	sig, err := parseBLSSignature(signature)
+	if err != nil {
+		return err
+	}
+	var expected *bn256.GT
+	for i, id := range signers {
+		raw, pk, err := ba.publicKeyOf(id)
+		if err != nil {
+			return err
+		}
+		pairing := bn256.Pair(blsHash(raw, messages[i]), pk)
+		if expected == nil {
+			expected = pairing
+		} else {
+			expected = new(bn256.GT).Add(expected, pairing)
+		}
+	}
+	if !bytes.Equal(bn256.Pair(sig, blsG2Generator).Marshal(), expected.Marshal()) {
+		return fmt.Errorf("invalid BLS signature")
+	}
+	return nil
+}
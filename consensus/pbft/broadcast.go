@@ -46,6 +46,14 @@ type sendRequest struct {
 }
 
 func newBroadcaster(self uint64, N int, f int, broadcastTimeout time.Duration, c communicator) *broadcaster {
+	replicas := make([]uint64, N)
+	for i := range replicas {
+		replicas[i] = uint64(i)
+	}
+	return newBroadcasterForReplicas(self, replicas, f, broadcastTimeout, c)
+}
+
+func newBroadcasterForReplicas(self uint64, replicas []uint64, f int, broadcastTimeout time.Duration, c communicator) *broadcaster {
 	queueSize := 10 // XXX increase after testing
 
 	chans := make(map[uint64]chan *sendRequest)
@@ -56,19 +64,19 @@ func newBroadcaster(self uint64, N int, f int, broadcastTimeout time.Duration, c
 		msgChans:         chans,
 		closedCh:         make(chan struct{}),
 	}
-	for i := 0; i < N; i++ {
-		if uint64(i) == self {
+	for _, id := range replicas {
+		if id == self {
 			continue
 		}
-		chans[uint64(i)] = make(chan *sendRequest, queueSize)
+		chans[id] = make(chan *sendRequest, queueSize)
 	}
 
 	// We do not start the go routines in the above loop to avoid concurrent map read/writes
-	for i := 0; i < N; i++ {
-		if uint64(i) == self {
+	for _, id := range replicas {
+		if id == self {
 			continue
 		}
-		go b.drainer(uint64(i))
+		go b.drainer(id)
 	}
 
 	return b
@@ -137,47 +137,47 @@ func TestMinimalFuzz(t *testing.T) {
 
 	validatorCount := 4
 	net := makePBFTNetwork(validatorCount, nil)
-	defer net.stop()
+	defer net.Stop()
 	fuzzer := &protoFuzzer{r: rand.New(rand.NewSource(0))}
-	net.filterFn = fuzzer.fuzzPacket
+	net.FilterFn = fuzzer.fuzzPacket
 
 	noExec := 0
 	for reqID := 1; reqID < 30; reqID++ {
 		if reqID%3 == 0 {
-			fuzzer.fuzzNode = fuzzer.r.Intn(len(net.endpoints))
+			fuzzer.fuzzNode = fuzzer.r.Intn(len(net.Endpoints))
 			fmt.Printf("Fuzzing node %d\n", fuzzer.fuzzNode)
 		}
 
 		sender := uint64(generateBroadcaster(validatorCount))
 		reqBatchMsg := createPbftReqBatchMsg(int64(reqID), sender)
-		for _, ep := range net.endpoints {
+		for _, ep := range net.Endpoints {
 			ep.(*pbftEndpoint).manager.Queue() <- &pbftMessageEvent{msg: reqBatchMsg, sender: sender}
 		}
 		if err != nil {
 			t.Fatalf("Request failed: %s", err)
 		}
 
-		err = net.process()
+		err = net.Process()
 		if err != nil {
 			t.Fatalf("Processing failed: %s", err)
 		}
 
 		quorum := 0
-		for _, ep := range net.endpoints {
+		for _, ep := range net.Endpoints {
 			if ep.(*pbftEndpoint).sc.executions > 0 {
 				quorum++
 				ep.(*pbftEndpoint).sc.executions = 0
 			}
 		}
-		if quorum < len(net.endpoints)/3 {
+		if quorum < len(net.Endpoints)/3 {
 			noExec++
 		}
 		if noExec > 1 {
 			noExec = 0
-			for _, ep := range net.endpoints {
+			for _, ep := range net.Endpoints {
 				ep.(*pbftEndpoint).pbft.sendViewChange()
 			}
-			err = net.process()
+			err = net.Process()
 			if err != nil {
 				t.Fatalf("Processing failed: %s", err)
 			}
@@ -22,6 +22,14 @@ It has these top-level messages:
 	FetchRequestBatch
 	RequestBatch
 	BatchMessage
+	Replica
+	Membership
+	Endorsement
+	Reconfiguration
+	MembershipState
+	AggregateSignature
+	MultiSignature
+	CheckpointCertificate
 	Metadata
 */
 package pbft
@@ -318,10 +326,11 @@ func _Message_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer
 }
 
 type Request struct {
-	Timestamp *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=timestamp" json:"timestamp,omitempty"`
-	Payload   []byte                     `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
-	ReplicaId uint64                     `protobuf:"varint,3,opt,name=replica_id" json:"replica_id,omitempty"`
-	Signature []byte                     `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Timestamp       *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Payload         []byte                     `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	ReplicaId       uint64                     `protobuf:"varint,3,opt,name=replica_id" json:"replica_id,omitempty"`
+	Signature       []byte                     `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Reconfiguration *Reconfiguration           `protobuf:"bytes,5,opt,name=reconfiguration" json:"reconfiguration,omitempty"`
 }
 
 func (m *Request) Reset()         { *m = Request{} }
@@ -335,6 +344,13 @@ func (m *Request) GetTimestamp() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *Request) GetReconfiguration() *Reconfiguration {
+	if m != nil {
+		return m.Reconfiguration
+	}
+	return nil
+}
+
 type PrePrepare struct {
 	View           uint64        `protobuf:"varint,1,opt,name=view" json:"view,omitempty"`
 	SequenceNumber uint64        `protobuf:"varint,2,opt,name=sequence_number" json:"sequence_number,omitempty"`
@@ -389,6 +405,7 @@ type Checkpoint struct {
 	SequenceNumber uint64 `protobuf:"varint,1,opt,name=sequence_number" json:"sequence_number,omitempty"`
 	ReplicaId      uint64 `protobuf:"varint,2,opt,name=replica_id" json:"replica_id,omitempty"`
 	Id             string `protobuf:"bytes,3,opt,name=id" json:"id,omitempty"`
+	Signature      []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
 }
 
 func (m *Checkpoint) Reset()         { *m = Checkpoint{} }
@@ -466,10 +483,11 @@ func (m *PQset) GetSet() []*ViewChange_PQ {
 }
 
 type NewView struct {
-	View      uint64            `protobuf:"varint,1,opt,name=view" json:"view,omitempty"`
-	Vset      []*ViewChange     `protobuf:"bytes,2,rep,name=vset" json:"vset,omitempty"`
-	Xset      map[uint64]string `protobuf:"bytes,3,rep,name=xset" json:"xset,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	ReplicaId uint64            `protobuf:"varint,4,opt,name=replica_id" json:"replica_id,omitempty"`
+	View          uint64              `protobuf:"varint,1,opt,name=view" json:"view,omitempty"`
+	Vset          []*ViewChange       `protobuf:"bytes,2,rep,name=vset" json:"vset,omitempty"`
+	Xset          map[uint64]string   `protobuf:"bytes,3,rep,name=xset" json:"xset,omitempty" protobuf_key:"varint,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ReplicaId     uint64              `protobuf:"varint,4,opt,name=replica_id" json:"replica_id,omitempty"`
+	VsetSignature *AggregateSignature `protobuf:"bytes,5,opt,name=vset_signature" json:"vset_signature,omitempty"`
 }
 
 func (m *NewView) Reset()         { *m = NewView{} }
@@ -490,6 +508,13 @@ func (m *NewView) GetXset() map[uint64]string {
 	return nil
 }
 
+func (m *NewView) GetVsetSignature() *AggregateSignature {
+	if m != nil {
+		return m.VsetSignature
+	}
+	return nil
+}
+
 type FetchRequestBatch struct {
 	BatchDigest string `protobuf:"bytes,1,opt,name=batch_digest" json:"batch_digest,omitempty"`
 	ReplicaId   uint64 `protobuf:"varint,2,opt,name=replica_id" json:"replica_id,omitempty"`
@@ -662,10 +687,147 @@ func _BatchMessage_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.B
 	}
 }
 
+type Replica struct {
+	Id             uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	EnrollmentCert []byte `protobuf:"bytes,2,opt,name=enrollment_cert,proto3" json:"enrollment_cert,omitempty"`
+	BlsPublicKey   []byte `protobuf:"bytes,3,opt,name=bls_public_key,proto3" json:"bls_public_key,omitempty"`
+}
+
+func (m *Replica) Reset()         { *m = Replica{} }
+func (m *Replica) String() string { return proto.CompactTextString(m) }
+func (*Replica) ProtoMessage()    {}
+
+type Membership struct {
+	Epoch    uint64     `protobuf:"varint,1,opt,name=epoch" json:"epoch,omitempty"`
+	SeqNo    uint64     `protobuf:"varint,2,opt,name=seq_no" json:"seq_no,omitempty"`
+	F        uint64     `protobuf:"varint,3,opt,name=f" json:"f,omitempty"`
+	Replicas []*Replica `protobuf:"bytes,4,rep,name=replicas" json:"replicas,omitempty"`
+}
+
+func (m *Membership) Reset()         { *m = Membership{} }
+func (m *Membership) String() string { return proto.CompactTextString(m) }
+func (*Membership) ProtoMessage()    {}
+
+func (m *Membership) GetReplicas() []*Replica {
+	if m != nil {
+		return m.Replicas
+	}
+	return nil
+}
+
+type Endorsement struct {
+	ReplicaId uint64 `protobuf:"varint,1,opt,name=replica_id" json:"replica_id,omitempty"`
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *Endorsement) Reset()         { *m = Endorsement{} }
+func (m *Endorsement) String() string { return proto.CompactTextString(m) }
+func (*Endorsement) ProtoMessage()    {}
+
+type Reconfiguration struct {
+	Epoch        uint64         `protobuf:"varint,1,opt,name=epoch" json:"epoch,omitempty"`
+	F            uint64         `protobuf:"varint,2,opt,name=f" json:"f,omitempty"`
+	Add          []*Replica     `protobuf:"bytes,3,rep,name=add" json:"add,omitempty"`
+	Remove       []uint64       `protobuf:"varint,4,rep,packed,name=remove" json:"remove,omitempty"`
+	Endorsements []*Endorsement `protobuf:"bytes,5,rep,name=endorsements" json:"endorsements,omitempty"`
+}
+
+func (m *Reconfiguration) Reset()         { *m = Reconfiguration{} }
+func (m *Reconfiguration) String() string { return proto.CompactTextString(m) }
+func (*Reconfiguration) ProtoMessage()    {}
+
+func (m *Reconfiguration) GetAdd() []*Replica {
+	if m != nil {
+		return m.Add
+	}
+	return nil
+}
+
+func (m *Reconfiguration) GetEndorsements() []*Endorsement {
+	if m != nil {
+		return m.Endorsements
+	}
+	return nil
+}
+
+type MembershipState struct {
+	Current   *Membership        `protobuf:"bytes,1,opt,name=current" json:"current,omitempty"`
+	Pending   *Membership        `protobuf:"bytes,2,opt,name=pending" json:"pending,omitempty"`
+	Proposals []*Reconfiguration `protobuf:"bytes,3,rep,name=proposals" json:"proposals,omitempty"`
+}
+
+func (m *MembershipState) Reset()         { *m = MembershipState{} }
+func (m *MembershipState) String() string { return proto.CompactTextString(m) }
+func (*MembershipState) ProtoMessage()    {}
+
+func (m *MembershipState) GetCurrent() *Membership {
+	if m != nil {
+		return m.Current
+	}
+	return nil
+}
+
+func (m *MembershipState) GetPending() *Membership {
+	if m != nil {
+		return m.Pending
+	}
+	return nil
+}
+
+func (m *MembershipState) GetProposals() []*Reconfiguration {
+	if m != nil {
+		return m.Proposals
+	}
+	return nil
+}
+
+type AggregateSignature struct {
+	Scheme    string   `protobuf:"bytes,1,opt,name=scheme" json:"scheme,omitempty"`
+	Signers   []uint64 `protobuf:"varint,2,rep,packed,name=signers" json:"signers,omitempty"`
+	Signature []byte   `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *AggregateSignature) Reset()         { *m = AggregateSignature{} }
+func (m *AggregateSignature) String() string { return proto.CompactTextString(m) }
+func (*AggregateSignature) ProtoMessage()    {}
+
+type MultiSignature struct {
+	Signatures [][]byte `protobuf:"bytes,1,rep,name=signatures,proto3" json:"signatures,omitempty"`
+}
+
+func (m *MultiSignature) Reset()         { *m = MultiSignature{} }
+func (m *MultiSignature) String() string { return proto.CompactTextString(m) }
+func (*MultiSignature) ProtoMessage()    {}
+
+type CheckpointCertificate struct {
+	SequenceNumber uint64              `protobuf:"varint,1,opt,name=sequence_number" json:"sequence_number,omitempty"`
+	Id             string              `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+	Signature      *AggregateSignature `protobuf:"bytes,3,opt,name=signature" json:"signature,omitempty"`
+}
+
+func (m *CheckpointCertificate) Reset()         { *m = CheckpointCertificate{} }
+func (m *CheckpointCertificate) String() string { return proto.CompactTextString(m) }
+func (*CheckpointCertificate) ProtoMessage()    {}
+
+func (m *CheckpointCertificate) GetSignature() *AggregateSignature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
 type Metadata struct {
-	SeqNo uint64 `protobuf:"varint,1,opt,name=seqNo" json:"seqNo,omitempty"`
+	SeqNo      uint64           `protobuf:"varint,1,opt,name=seqNo" json:"seqNo,omitempty"`
+	Membership *MembershipState `protobuf:"bytes,2,opt,name=membership" json:"membership,omitempty"`
 }
 
 func (m *Metadata) Reset()         { *m = Metadata{} }
 func (m *Metadata) String() string { return proto.CompactTextString(m) }
 func (*Metadata) ProtoMessage()    {}
+
+func (m *Metadata) GetMembership() *MembershipState {
+	if m != nil {
+		return m.Membership
+	}
+	return nil
+}
@@ -0,0 +1,582 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/consensus/testutil"
+)
+
+// The adversary sits between the replicas of a test network, as its
+// filterFn.  Rules decide, per sender and receiver, whether a message
+// is dropped, held back, or rewritten before it is delivered.  Held
+// messages are delivered once enough other messages went by, or when
+// the network runs out of work.
+
+type interceptedMsg struct {
+	src       int
+	dst       int
+	kind      string
+	msg       *Message // nil for anything but PBFT messages, e.g. requests
+	rewritten bool
+}
+
+type msgMatcher func(m *interceptedMsg) bool
+
+// msgAction returns whether to deliver a message and, if so, for how
+// many further messages to hold it back
+type msgAction func(adv *adversary, m *interceptedMsg) (deliver bool, hold uint64)
+
+type adversaryRule struct {
+	adv      *adversary
+	matchers []msgMatcher
+	action   msgAction
+	active   bool
+}
+
+type heldMsg struct {
+	src       int
+	dst       int
+	payload   []byte
+	releaseAt uint64
+}
+
+type adversary struct {
+	net     *testutil.Network
+	wrapped bool // payloads are batch messages rather than bare PBFT messages
+
+	lock  sync.Mutex
+	rand  *rand.Rand
+	rules []*adversaryRule
+	held  []*heldMsg
+	tick  uint64
+	forge int64
+}
+
+func newAdversary(net *testutil.Network, wrapped bool, seed int64) *adversary {
+	adv := &adversary{
+		net:     net,
+		wrapped: wrapped,
+		rand:    rand.New(rand.NewSource(seed)),
+	}
+	net.FilterFn = adv.filter
+	return adv
+}
+
+func msgKind(msg *Message) string {
+	switch msg.Payload.(type) {
+	case *Message_RequestBatch:
+		return "request-batch"
+	case *Message_PrePrepare:
+		return "pre-prepare"
+	case *Message_Prepare:
+		return "prepare"
+	case *Message_Commit:
+		return "commit"
+	case *Message_Checkpoint:
+		return "checkpoint"
+	case *Message_ViewChange:
+		return "view-change"
+	case *Message_NewView:
+		return "new-view"
+	case *Message_FetchRequestBatch:
+		return "fetch-request-batch"
+	case *Message_ReturnRequestBatch:
+		return "return-request-batch"
+	}
+	return "unknown"
+}
+
+func (adv *adversary) decode(src int, dst int, payload []byte) *interceptedMsg {
+	m := &interceptedMsg{src: src, dst: dst, kind: "unknown"}
+	raw := payload
+	if adv.wrapped {
+		batchMsg := &BatchMessage{}
+		if proto.Unmarshal(payload, batchMsg) != nil {
+			return m
+		}
+		if batchMsg.GetRequest() != nil {
+			m.kind = "request"
+			return m
+		}
+		if raw = batchMsg.GetPbftMessage(); raw == nil {
+			return m
+		}
+	}
+	msg := &Message{}
+	if proto.Unmarshal(raw, msg) != nil || msg.Payload == nil {
+		return m
+	}
+	m.msg = msg
+	m.kind = msgKind(msg)
+	return m
+}
+
+func (adv *adversary) encode(msg *Message) []byte {
+	raw, _ := proto.Marshal(msg)
+	if !adv.wrapped {
+		return raw
+	}
+	raw, _ = proto.Marshal(&BatchMessage{Payload: &BatchMessage_PbftMessage{PbftMessage: raw}})
+	return raw
+}
+
+func (adv *adversary) filter(src int, dst int, payload []byte) []byte {
+	if dst == -1 {
+		// Decided for each receiver once the broadcast is delivered
+		return payload
+	}
+	m := adv.decode(src, dst, payload)
+
+	adv.lock.Lock()
+	adv.tick++
+	deliver, hold := true, uint64(0)
+	for _, rule := range adv.rules {
+		if !rule.active || !rule.matches(m) {
+			continue
+		}
+		var h uint64
+		if deliver, h = rule.action(adv, m); !deliver {
+			break
+		}
+		if h > hold {
+			hold = h
+		}
+	}
+	if deliver && m.rewritten {
+		payload = adv.encode(m.msg)
+	}
+	if deliver && hold > 0 {
+		adv.held = append(adv.held, &heldMsg{src: src, dst: dst, payload: payload, releaseAt: adv.tick + hold})
+		deliver = false
+	}
+	due := adv.takeHeld(adv.tick)
+	adv.lock.Unlock()
+
+	adv.deliver(due)
+	if !deliver {
+		return nil
+	}
+	return payload
+}
+
+// takeHeld removes the held messages due at tick, in release order
+func (adv *adversary) takeHeld(tick uint64) []*heldMsg {
+	var due, remaining []*heldMsg
+	for _, h := range adv.held {
+		if h.releaseAt <= tick {
+			due = append(due, h)
+		} else {
+			remaining = append(remaining, h)
+		}
+	}
+	adv.held = remaining
+	sort.Sort(heldByRelease(due))
+	return due
+}
+
+// Delivered outside the lock, the receiver may broadcast in turn
+func (adv *adversary) deliver(msgs []*heldMsg) {
+	for _, h := range msgs {
+		adv.net.Endpoints[h.dst].Deliver(h.payload, adv.net.Endpoints[h.src].GetHandle())
+	}
+}
+
+// flush delivers all held messages and returns how many there were
+func (adv *adversary) flush() int {
+	adv.lock.Lock()
+	due := adv.takeHeld(^uint64(0))
+	adv.lock.Unlock()
+	adv.deliver(due)
+	return len(due)
+}
+
+// inject delivers a message as if src had broadcast it
+func (adv *adversary) inject(src int, msg *Message) {
+	payload := adv.encode(msg)
+	for dst := range adv.net.Endpoints {
+		if dst != src {
+			adv.net.Endpoints[dst].Deliver(payload, adv.net.Endpoints[src].GetHandle())
+		}
+	}
+}
+
+func (adv *adversary) addRule(action msgAction, matchers []msgMatcher) *adversaryRule {
+	adv.lock.Lock()
+	defer adv.lock.Unlock()
+	rule := &adversaryRule{adv: adv, matchers: matchers, action: action, active: true}
+	adv.rules = append(adv.rules, rule)
+	return rule
+}
+
+// heal deactivates all rules
+func (adv *adversary) heal() {
+	adv.lock.Lock()
+	defer adv.lock.Unlock()
+	for _, rule := range adv.rules {
+		rule.active = false
+	}
+}
+
+func (rule *adversaryRule) matches(m *interceptedMsg) bool {
+	for _, match := range rule.matchers {
+		if !match(m) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule *adversaryRule) remove() {
+	rule.adv.lock.Lock()
+	defer rule.adv.lock.Unlock()
+	rule.active = false
+}
+
+type heldByRelease []*heldMsg
+
+func (a heldByRelease) Len() int           { return len(a) }
+func (a heldByRelease) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a heldByRelease) Less(i, j int) bool { return a[i].releaseAt < a[j].releaseAt }
+
+// =============================================================================
+// Matchers and actions
+// =============================================================================
+
+func idSet(ids []int) map[int]bool {
+	set := make(map[int]bool)
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func from(ids ...int) msgMatcher {
+	set := idSet(ids)
+	return func(m *interceptedMsg) bool { return set[m.src] }
+}
+
+func to(ids ...int) msgMatcher {
+	set := idSet(ids)
+	return func(m *interceptedMsg) bool { return set[m.dst] }
+}
+
+func kind(kinds ...string) msgMatcher {
+	set := make(map[string]bool)
+	for _, k := range kinds {
+		set[k] = true
+	}
+	return func(m *interceptedMsg) bool { return set[m.kind] }
+}
+
+func dropAction(adv *adversary, m *interceptedMsg) (bool, uint64) {
+	return false, 0
+}
+
+func delayAction(n uint64) msgAction {
+	return func(adv *adversary, m *interceptedMsg) (bool, uint64) {
+		return true, n
+	}
+}
+
+// reorderAction holds each message back for a random number of messages
+// below window, shuffling the order of delivery
+func reorderAction(window int) msgAction {
+	return func(adv *adversary, m *interceptedMsg) (bool, uint64) {
+		return true, uint64(adv.rand.Intn(window))
+	}
+}
+
+// equivocateAction replaces the request batch of a pre-prepare with a
+// fabricated one, so that the receivers see a different proposal for
+// the same sequence number
+func equivocateAction(adv *adversary, m *interceptedMsg) (bool, uint64) {
+	preprep := m.msg.GetPrePrepare()
+	if preprep == nil || preprep.BatchDigest == "" {
+		return true, 0
+	}
+	adv.forge++
+	forged := *preprep
+	forged.RequestBatch = createPbftReqBatch(-adv.forge, preprep.ReplicaId)
+	forged.BatchDigest = hash(forged.RequestBatch)
+	m.msg = &Message{Payload: &Message_PrePrepare{PrePrepare: &forged}}
+	m.rewritten = true
+	return true, 0
+}
+
+// =============================================================================
+// Scenario DSL
+// =============================================================================
+
+// scenario runs a consumer network under an adversary; its steps chain, e.g.
+//
+//	s := newScenario(t, 4).byzantine(0)
+//	s.on(from(0), kind("pre-prepare"), to(2, 3)).equivocate()
+//	s.submit(3).run().heal().check()
+type scenario struct {
+	t         *testing.T
+	net       *consumerNetwork
+	adv       *adversary
+	byz       map[int]bool
+	entries   []int // replicas new transactions are handed to, all correct ones if empty
+	submitted []string
+	tag       int64
+}
+
+type ruleBuilder struct {
+	s        *scenario
+	matchers []msgMatcher
+}
+
+func newScenario(t *testing.T, N int, initFNs ...func(*consumerEndpoint)) *scenario {
+	timeouts := func(ce *consumerEndpoint) {
+		instance := ce.consumer.getPBFTCore()
+		instance.K = 2
+		instance.L = 4
+		instance.requestTimeout = 400 * time.Millisecond
+		instance.newViewTimeout = 800 * time.Millisecond
+		instance.lastNewViewTimeout = instance.newViewTimeout
+		instance.vcResendTimeout = 800 * time.Millisecond
+	}
+	s := &scenario{
+		t:   t,
+		net: makeConsumerNetwork(N, obcBatchSizeOneHelper, append([]func(*consumerEndpoint){timeouts}, initFNs...)...),
+		byz: make(map[int]bool),
+	}
+	s.adv = newAdversary(s.net.Network, true, 0)
+	return s
+}
+
+// byzantine excludes replicas from the safety and liveness assertions
+func (s *scenario) byzantine(ids ...int) *scenario {
+	for _, id := range ids {
+		s.byz[id] = true
+	}
+	return s
+}
+
+func (s *scenario) on(matchers ...msgMatcher) *ruleBuilder {
+	return &ruleBuilder{s: s, matchers: matchers}
+}
+
+func (rb *ruleBuilder) drop() *adversaryRule {
+	return rb.s.adv.addRule(dropAction, rb.matchers)
+}
+
+func (rb *ruleBuilder) delay(n uint64) *adversaryRule {
+	return rb.s.adv.addRule(delayAction(n), rb.matchers)
+}
+
+func (rb *ruleBuilder) reorder(window int) *adversaryRule {
+	return rb.s.adv.addRule(reorderAction(window), rb.matchers)
+}
+
+func (rb *ruleBuilder) equivocate() *adversaryRule {
+	return rb.s.adv.addRule(equivocateAction, rb.matchers)
+}
+
+// partition drops all messages between replicas of different groups
+func (s *scenario) partition(groups ...[]int) *adversaryRule {
+	group := make(map[int]int)
+	for i, ids := range groups {
+		for _, id := range ids {
+			group[id] = i
+		}
+	}
+	return s.adv.addRule(dropAction, []msgMatcher{func(m *interceptedMsg) bool {
+		return group[m.src] != group[m.dst]
+	}})
+}
+
+// forgeViewChange has replica src send a view change to view, claiming to
+// have prepared a request batch nobody ever proposed
+func (s *scenario) forgeViewChange(src int, view uint64) *scenario {
+	s.adv.forge++
+	digest := hash(createPbftReqBatch(-s.adv.forge, uint64(src)))
+	instance := s.net.Endpoints[src].(*consumerEndpoint).consumer.getPBFTCore()
+	vc := &ViewChange{
+		View:      view,
+		H:         instance.h,
+		ReplicaId: uint64(src),
+		Cset:      []*ViewChange_C{{SequenceNumber: instance.h, Id: instance.chkpts[instance.h]}},
+		Pset:      []*ViewChange_PQ{{SequenceNumber: instance.h + 1, BatchDigest: digest, View: view - 1}},
+		Qset:      []*ViewChange_PQ{{SequenceNumber: instance.h + 1, BatchDigest: digest, View: view - 1}},
+	}
+	s.adv.inject(src, &Message{Payload: &Message_ViewChange{ViewChange: vc}})
+	return s
+}
+
+// through restricts the replicas new transactions are handed to
+func (s *scenario) through(ids ...int) *scenario {
+	s.entries = ids
+	return s
+}
+
+// submit hands count new transactions to the entry replicas in turn
+func (s *scenario) submit(count int) *scenario {
+	entries := s.entries
+	if len(entries) == 0 {
+		entries = s.correct()
+	}
+	for i := 0; i < count; i++ {
+		s.tag++
+		s.submitted = append(s.submitted, fmt.Sprint(s.tag))
+		ce := s.net.Endpoints[entries[int(s.tag)%len(entries)]].(*consumerEndpoint)
+		ce.consumer.RecvMsg(createTxMsg(s.tag), ce.GetHandle())
+	}
+	return s
+}
+
+// run processes the network until it is idle and nothing is held back
+func (s *scenario) run() *scenario {
+	for {
+		s.net.Process()
+		if s.adv.flush() == 0 {
+			return s
+		}
+	}
+}
+
+// heal lifts all rules and runs the network to completion
+func (s *scenario) heal() *scenario {
+	s.adv.heal()
+	return s.run()
+}
+
+func (s *scenario) stop() {
+	s.net.Stop()
+}
+
+func (s *scenario) correct() []int {
+	var ids []int
+	for id := range s.net.Endpoints {
+		if !s.byz[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// check asserts safety and liveness of the correct replicas
+func (s *scenario) check() *scenario {
+	s.checkSafety()
+	s.checkLiveness()
+	return s
+}
+
+// checkSafety asserts that correct replicas agree on every block and
+// checkpoint they share, and that no transaction was committed twice
+func (s *scenario) checkSafety() {
+	correct := s.correct()
+
+	blocks := make(map[uint64]string)
+	chkpts := make(map[uint64]string)
+	for _, id := range correct {
+		ledger := s.net.mockLedgers[id]
+		committed := make(map[string]uint64)
+		for n := uint64(1); n < ledger.GetBlockchainSize(); n++ {
+			block, err := ledger.GetBlock(n)
+			if err != nil {
+				s.t.Errorf("Replica %d is missing block %d", id, n)
+				continue
+			}
+			hash, _ := ledger.HashBlock(block)
+			b64 := base64.StdEncoding.EncodeToString(hash)
+			if other, ok := blocks[n]; ok && other != b64 {
+				s.t.Errorf("Safety violated: replica %d committed a different block %d", id, n)
+			}
+			blocks[n] = b64
+			for _, tx := range block.Transactions {
+				if prev, ok := committed[string(tx.Payload)]; ok {
+					s.t.Errorf("Safety violated: replica %d committed transaction %s in blocks %d and %d", id, tx.Payload, prev, n)
+				}
+				committed[string(tx.Payload)] = n
+			}
+		}
+
+		instance := s.net.Endpoints[id].(*consumerEndpoint).consumer.getPBFTCore()
+		s.net.Endpoints[id].(*consumerEndpoint).consumer.getManager().Queue() <- workEvent(func() {
+			for n, chkpt := range instance.chkpts {
+				if n == 0 {
+					continue
+				}
+				if other, ok := chkpts[n]; ok && other != chkpt {
+					s.t.Errorf("Safety violated: replica %d took a different checkpoint at seqNo %d", id, n)
+				}
+				chkpts[n] = chkpt
+			}
+		})
+		s.net.Endpoints[id].(*consumerEndpoint).consumer.getManager().Queue() <- nil
+	}
+}
+
+// checkLiveness asserts that a quorum of correct replicas committed every
+// submitted transaction and settled in the same, active view
+func (s *scenario) checkLiveness() {
+	N := len(s.net.Endpoints)
+	quorum := N - (N-1)/3
+
+	var live []int
+	views := make(map[uint64]int)
+	for _, id := range s.correct() {
+		committed := make(map[string]bool)
+		ledger := s.net.mockLedgers[id]
+		for n := uint64(1); n < ledger.GetBlockchainSize(); n++ {
+			if block, err := ledger.GetBlock(n); err == nil {
+				for _, tx := range block.Transactions {
+					committed[string(tx.Payload)] = true
+				}
+			}
+		}
+		missing := 0
+		for _, tx := range s.submitted {
+			if !committed[tx] {
+				missing++
+			}
+		}
+		if missing == 0 {
+			live = append(live, id)
+		}
+
+		instance := s.net.Endpoints[id].(*consumerEndpoint).consumer.getPBFTCore()
+		if instance.activeView {
+			views[instance.view]++
+		}
+	}
+
+	if len(live) < quorum {
+		s.t.Errorf("Liveness violated: only replicas %v committed all %d submitted transactions, need %d", live, len(s.submitted), quorum)
+	}
+	settled := false
+	for _, count := range views {
+		if count >= quorum {
+			settled = true
+		}
+	}
+	if !settled {
+		s.t.Errorf("Liveness violated: no %d correct replicas share an active view: %v", quorum, views)
+	}
+}
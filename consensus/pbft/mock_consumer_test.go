@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/testutil"
 	"github.com/hyperledger/fabric/consensus/util/events"
 	pb "github.com/hyperledger/fabric/protos"
 
@@ -28,32 +29,31 @@ import (
 )
 
 type consumerEndpoint struct {
-	*testEndpoint
-	consumer     pbftConsumer
-	execTxResult func([]*pb.Transaction) ([]byte, error)
+	*testutil.TestEndpoint
+	consumer pbftConsumer
 }
 
-func (ce *consumerEndpoint) stop() {
+func (ce *consumerEndpoint) Stop() {
 	ce.consumer.Close()
 }
 
-func (ce *consumerEndpoint) isBusy() bool {
+func (ce *consumerEndpoint) IsBusy() bool {
 	pbft := ce.consumer.getPBFTCore()
 	if pbft.timerActive || pbft.skipInProgress || pbft.currentExec != nil {
-		ce.net.debugMsg("Reporting busy because of timer (%v) or skipInProgress (%v) or currentExec (%v)\n", pbft.timerActive, pbft.skipInProgress, pbft.currentExec)
+		ce.Net.DebugMsg("Reporting busy because of timer (%v) or skipInProgress (%v) or currentExec (%v)\n", pbft.timerActive, pbft.skipInProgress, pbft.currentExec)
 		return true
 	}
 
 	select {
 	case <-ce.consumer.idleChannel():
 	default:
-		ce.net.debugMsg("Reporting busy because consumer not idle\n")
+		ce.Net.DebugMsg("Reporting busy because consumer not idle\n")
 		return true
 	}
 
 	select {
 	case ce.consumer.getManager().Queue() <- nil:
-		ce.net.debugMsg("Reporting busy because pbft not idle\n")
+		ce.Net.DebugMsg("Reporting busy because pbft not idle\n")
 	default:
 		return true
 	}
@@ -62,14 +62,14 @@ func (ce *consumerEndpoint) isBusy() bool {
 	return false
 }
 
-func (ce *consumerEndpoint) deliver(msg []byte, senderHandle *pb.PeerID) {
+func (ce *consumerEndpoint) Deliver(msg []byte, senderHandle *pb.PeerID) {
 	ce.consumer.RecvMsg(&pb.Message{Type: pb.Message_CONSENSUS, Payload: msg}, senderHandle)
 }
 
 type completeStack struct {
 	*consumerEndpoint
 	*noopSecurity
-	*MockLedger
+	*testutil.MockLedger
 	mockPersist
 	skipTarget chan struct{}
 }
@@ -88,12 +88,12 @@ func (cs *completeStack) UpdateState(tag interface{}, target *pb.BlockchainInfo,
 		go func() {
 			// State transfer takes time, not simulating this hides bugs
 			time.Sleep(time.Duration((MaxStateTransferTime/2)+rand.Intn(MaxStateTransferTime/2)) * time.Millisecond)
-			cs.simulateStateTransfer(target, peers)
+			cs.SimulateStateTransfer(target, peers)
 			cs.consumer.StateUpdated(tag, cs.GetBlockchainInfo())
 			<-cs.skipTarget // Basically like releasing a mutex
 		}()
 	default:
-		cs.net.debugMsg("Ignoring skipTo because one is already in progress\n")
+		cs.Net.DebugMsg("Ignoring skipTo because one is already in progress\n")
 	}
 }
 
@@ -107,8 +107,8 @@ type pbftConsumer interface {
 }
 
 type consumerNetwork struct {
-	*testnet
-	mockLedgers []*MockLedger
+	*testutil.Network
+	mockLedgers []*testutil.MockLedger
 }
 
 func (cnet *consumerNetwork) GetLedgerByPeerID(peerID *pb.PeerID) (consensus.ReadOnlyLedger, bool) {
@@ -120,16 +120,15 @@ func (cnet *consumerNetwork) GetLedgerByPeerID(peerID *pb.PeerID) (consensus.Rea
 }
 
 func makeConsumerNetwork(N int, makeConsumer func(id uint64, config *viper.Viper, stack consensus.Stack) pbftConsumer, initFNs ...func(*consumerEndpoint)) *consumerNetwork {
-	twl := consumerNetwork{mockLedgers: make([]*MockLedger, N)}
+	twl := consumerNetwork{mockLedgers: make([]*testutil.MockLedger, N)}
 
-	endpointFunc := func(id uint64, net *testnet) endpoint {
-		tep := makeTestEndpoint(id, net)
+	endpointFunc := func(id uint64, net *testutil.Network) testutil.Endpoint {
+		tep := testutil.NewTestEndpoint(id, net)
 		ce := &consumerEndpoint{
-			testEndpoint: tep,
+			TestEndpoint: tep,
 		}
 
-		ml := NewMockLedger(&twl)
-		ml.ce = ce
+		ml := testutil.NewMockLedger(&twl)
 		twl.mockLedgers[id] = ml
 
 		cs := &completeStack{
@@ -140,6 +139,7 @@ func makeConsumerNetwork(N int, makeConsumer func(id uint64, config *viper.Viper
 		}
 
 		ce.consumer = makeConsumer(id, loadConfig(), cs)
+		ml.Consumer = ce.consumer
 		ce.consumer.getPBFTCore().N = N
 		ce.consumer.getPBFTCore().f = (N - 1) / 3
 
@@ -150,6 +150,6 @@ func makeConsumerNetwork(N int, makeConsumer func(id uint64, config *viper.Viper
 		return ce
 	}
 
-	twl.testnet = makeTestnet(N, endpointFunc)
+	twl.Network = testutil.MakeNetwork(N, endpointFunc)
 	return &twl
 }
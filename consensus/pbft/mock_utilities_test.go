@@ -199,17 +199,19 @@ type omniProto struct {
 	InvalidateStateImpl        func()
 
 	// Inner Stack methods
-	broadcastImpl       func(msgPayload []byte)
-	unicastImpl         func(msgPayload []byte, receiverID uint64) (err error)
-	executeImpl         func(seqNo uint64, reqBatch *RequestBatch)
-	getStateImpl        func() []byte
-	skipToImpl          func(seqNo uint64, snapshotID []byte, peers []uint64)
-	viewChangeImpl      func(curView uint64)
-	signImpl            func(msg []byte) ([]byte, error)
-	verifyImpl          func(senderID uint64, signature []byte, message []byte) error
-	getLastSeqNoImpl    func() (uint64, error)
-	validateStateImpl   func()
-	invalidateStateImpl func()
+	broadcastImpl         func(msgPayload []byte)
+	unicastImpl           func(msgPayload []byte, receiverID uint64) (err error)
+	executeImpl           func(seqNo uint64, reqBatch *RequestBatch)
+	getStateImpl          func() []byte
+	skipToImpl            func(seqNo uint64, snapshotID []byte, peers []uint64)
+	viewChangeImpl        func(curView uint64)
+	signImpl              func(msg []byte) ([]byte, error)
+	verifyImpl            func(senderID uint64, signature []byte, message []byte) error
+	getLastSeqNoImpl      func() (uint64, error)
+	getLastMembershipImpl func() (*MembershipState, error)
+	reconfiguredImpl      func(membership *Membership)
+	validateStateImpl     func()
+	invalidateStateImpl   func()
 
 	// Closable Consenter methods
 	RecvMsgImpl func(ocMsg *pb.Message, senderHandle *pb.PeerID) error
@@ -481,6 +483,23 @@ func (op *omniProto) getLastSeqNo() (uint64, error) {
 	return 0, fmt.Errorf("getLastSeqNo is not implemented")
 }
 
+func (op *omniProto) getLastMembership() (*MembershipState, error) {
+	if op.getLastMembershipImpl != nil {
+		return op.getLastMembershipImpl()
+	}
+
+	return nil, fmt.Errorf("getLastMembership is not implemented")
+}
+
+func (op *omniProto) reconfigured(membership *Membership) {
+	if nil != op.reconfiguredImpl {
+		op.reconfiguredImpl(membership)
+		return
+	}
+
+	panic("Unimplemented")
+}
+
 func (op *omniProto) Close() {
 	if nil != op.CloseImpl {
 		op.CloseImpl()
@@ -18,6 +18,7 @@ package pbft
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"sort"
@@ -86,6 +87,7 @@ type innerStack interface {
 	execute(seqNo uint64, reqBatch *RequestBatch) // This is invoked on a separate thread
 	getState() []byte
 	getLastSeqNo() (uint64, error)
+	getLastMembership() (*MembershipState, error)
 	skipTo(seqNo uint64, snapshotID []byte, peers []uint64)
 
 	sign(msg []byte) ([]byte, error)
@@ -94,6 +96,8 @@ type innerStack interface {
 	invalidateState()
 	validateState()
 
+	reconfigured(membership *Membership) // the replica set changed
+
 	consensus.StatePersistor
 }
 
@@ -121,7 +125,9 @@ type pbftCore struct {
 	idleChan   chan struct{} // Used to detect idleness for testing
 	injectChan chan func()   // Used as a hack to inject work onto the PBFT thread, to be removed eventually
 
-	consumer innerStack
+	consumer      innerStack
+	aggregator    signatureAggregator // signs checkpoints and view-changes, combines signatures for new-views and checkpoint certificates
+	blsPublicKeys [][]byte            // BLS public keys of the configured replicas, by ID
 
 	// PBFT data
 	activeView    bool              // view change happening
@@ -141,6 +147,10 @@ type pbftCore struct {
 	pset          map[uint64]*ViewChange_PQ
 	qset          map[qidx]*ViewChange_PQ
 
+	membership        *Membership        // replica set in effect, nil for the configured replicas 0..N-1
+	pendingMembership *Membership        // replica set taking effect after its checkpoint
+	proposals         []*Reconfiguration // reconfigurations still short of f+1 endorsements
+
 	skipInProgress    bool               // Set when we have detected a fall behind scenario until we pick a new starting point
 	stateTransferring bool               // Set when state transfer is executing
 	highStateTarget   *stateUpdateTarget // Set to the highest weak checkpoint cert we have observed
@@ -165,10 +175,12 @@ type pbftCore struct {
 
 	missingReqBatches map[string]bool // for all the assigned, non-checkpointed request batches we might be missing during view-change
 
+	stableCert *CheckpointCertificate // signatures of a quorum on the last stable checkpoint
+
 	// implementation of PBFT `in`
 	reqBatchStore   map[string]*RequestBatch // track request batches
 	certStore       map[msgID]*msgCert       // track quorum certificates for requests
-	checkpointStore map[Checkpoint]bool      // track checkpoints as set
+	checkpointStore map[chkptIdx]*Checkpoint // track checkpoints as set
 	viewChangeStore map[vcidx]*ViewChange    // track view-change messages
 	newViewStore    map[uint64]*NewView      // track last new-view we received or sent
 }
@@ -192,6 +204,12 @@ type msgCert struct {
 	commit      []*Commit
 }
 
+type chkptIdx struct {
+	n  uint64
+	id string
+	r  uint64
+}
+
 type vcidx struct {
 	v  uint64
 	id uint64
@@ -240,6 +258,33 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 
 	instance.byzantine = config.GetBool("general.byzantine")
 
+	keys := &signatureKeys{
+		sign:         consumer.sign,
+		verify:       consumer.verify,
+		blsPublicKey: instance.blsPublicKey,
+	}
+	if privateKey := config.GetString("general.signatures.bls.privatekey"); privateKey != "" {
+		if keys.blsPrivateKey, err = hex.DecodeString(privateKey); err != nil {
+			panic(fmt.Errorf("Cannot decode BLS private key: %s", err))
+		}
+	} else if config.GetString("general.signatures.aggregation") == blsScheme {
+		panic(fmt.Errorf("Cannot set up signature aggregation: no BLS private key configured"))
+	}
+	for i, publicKey := range config.GetStringSlice("general.signatures.bls.publickeys") {
+		raw, err := hex.DecodeString(publicKey)
+		if err == nil {
+			_, err = parseBLSPublicKey(raw)
+		}
+		if err != nil {
+			panic(fmt.Errorf("Cannot decode BLS public key of replica %d: %s", i, err))
+		}
+		instance.blsPublicKeys = append(instance.blsPublicKeys, raw)
+	}
+	instance.aggregator, err = newAggregator(config.GetString("general.signatures.aggregation"), keys)
+	if err != nil {
+		panic(fmt.Errorf("Cannot set up signature aggregation: %s", err))
+	}
+
 	instance.requestTimeout, err = time.ParseDuration(config.GetString("general.timeout.request"))
 	if err != nil {
 		panic(fmt.Errorf("Cannot parse request timeout: %s", err))
@@ -268,6 +313,7 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 	logger.Infof("PBFT Max number of validating peers (N) = %v", instance.N)
 	logger.Infof("PBFT Max number of failing peers (f) = %v", instance.f)
 	logger.Infof("PBFT byzantine flag = %v", instance.byzantine)
+	logger.Infof("PBFT signature aggregation = %v", config.GetString("general.signatures.aggregation"))
 	logger.Infof("PBFT request timeout = %v", instance.requestTimeout)
 	logger.Infof("PBFT view change timeout = %v", instance.newViewTimeout)
 	logger.Infof("PBFT Checkpoint period (K) = %v", instance.K)
@@ -288,7 +334,7 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack, etf events
 	// init the logs
 	instance.certStore = make(map[msgID]*msgCert)
 	instance.reqBatchStore = make(map[string]*RequestBatch)
-	instance.checkpointStore = make(map[Checkpoint]bool)
+	instance.checkpointStore = make(map[chkptIdx]*Checkpoint)
 	instance.chkpts = make(map[uint64]string)
 	instance.viewChangeStore = make(map[vcidx]*ViewChange)
 	instance.pset = make(map[uint64]*ViewChange_PQ)
@@ -378,6 +424,12 @@ func (instance *pbftCore) ProcessEvent(e events.Event) events.Event {
 		logger.Infof("Replica %d application caught up via state transfer, lastExec now %d", instance.id, update.seqNo)
 		// XXX create checkpoint
 		instance.lastExec = update.seqNo
+		if instance.restoreMembership() {
+			instance.consumer.reconfigured(instance.currentMembership())
+		}
+		if instance.membershipDue() {
+			instance.applyPendingMembership()
+		}
 		instance.moveWatermarks(instance.lastExec) // The watermark movement handles moving this to a checkpoint boundary
 		instance.skipInProgress = false
 		instance.consumer.validateState()
@@ -426,6 +478,10 @@ func (instance *pbftCore) ProcessEvent(e events.Event) events.Event {
 
 // Given a certain view n, what is the expected primary?
 func (instance *pbftCore) primary(n uint64) uint64 {
+	if instance.membership != nil {
+		replicas := instance.membership.Replicas
+		return replicas[n%uint64(len(replicas))].Id
+	}
 	return n % uint64(instance.replicaCount)
 }
 
@@ -585,6 +641,12 @@ func (instance *pbftCore) recvMsg(msg *Message, senderID uint64) (interface{}, e
 		if senderID != chkpt.ReplicaId {
 			return nil, fmt.Errorf("Sender ID included in checkpoint message (%v) doesn't match ID corresponding to the receiving stream (%v)", chkpt.ReplicaId, senderID)
 		}
+		// replicas which do not sign their checkpoints yet are still heard, but left out of checkpoint certificates
+		if chkpt.Signature != nil {
+			if err := instance.verify(chkpt); err != nil {
+				return nil, fmt.Errorf("Incorrect signature in checkpoint message from replica %d: %s", chkpt.ReplicaId, err)
+			}
+		}
 		return chkpt, nil
 	} else if vc := msg.GetViewChange(); vc != nil {
 		if senderID != vc.ReplicaId {
@@ -651,6 +713,11 @@ func (instance *pbftCore) sendPrePrepare(reqBatch *RequestBatch, digest string)
 		return
 	}
 
+	if instance.pendingMembership != nil && n > instance.pendingMembership.SeqNo {
+		logger.Infof("Primary %d waiting for reconfiguration after seqNo %d, not sending pre-prepare with seqno=%d", instance.id, instance.pendingMembership.SeqNo, n)
+		return
+	}
+
 	logger.Debugf("Primary %d broadcasting pre-prepare for view=%d/seqNo=%d and digest %s", instance.id, instance.view, n, digest)
 	instance.seqNo = n
 	preprep := &PrePrepare{
@@ -729,6 +796,11 @@ func (instance *pbftCore) recvPrePrepare(preprep *PrePrepare) error {
 		return nil
 	}
 
+	if instance.pendingMembership != nil && preprep.SequenceNumber > instance.pendingMembership.SeqNo {
+		logger.Warningf("Replica %d received pre-prepare for %d, beyond the reconfiguration after seqNo %d", instance.id, preprep.SequenceNumber, instance.pendingMembership.SeqNo)
+		return nil
+	}
+
 	cert := instance.getCert(preprep.View, preprep.SequenceNumber)
 	if cert.digest != "" && cert.digest != preprep.BatchDigest {
 		logger.Warningf("Pre-prepare found for same view/seqNo but different digest: received %s, stored %s", preprep.BatchDigest, cert.digest)
@@ -983,6 +1055,9 @@ func (instance *pbftCore) Checkpoint(seqNo uint64, id []byte) {
 		Id:             idAsString,
 	}
 	instance.chkpts[seqNo] = idAsString
+	if err := instance.sign(chkpt); err != nil {
+		logger.Warningf("Replica %d could not sign checkpoint for seqNo %d: %s", instance.id, seqNo, err)
+	}
 
 	instance.persistCheckpoint(seqNo, id)
 	instance.recvCheckpoint(chkpt)
@@ -993,6 +1068,9 @@ func (instance *pbftCore) execDoneSync() {
 	if instance.currentExec != nil {
 		logger.Infof("Replica %d finished execution %d, trying next", instance.id, *instance.currentExec)
 		instance.lastExec = *instance.currentExec
+		if instance.membershipDue() {
+			instance.applyPendingMembership()
+		}
 		if instance.lastExec%instance.K == 0 {
 			instance.Checkpoint(instance.lastExec, instance.consumer.getState())
 		}
@@ -1005,6 +1083,7 @@ func (instance *pbftCore) execDoneSync() {
 	instance.currentExec = nil
 
 	instance.executeOutstanding()
+	instance.padToReconfiguration()
 }
 
 func (instance *pbftCore) moveWatermarks(n uint64) {
@@ -1021,11 +1100,11 @@ func (instance *pbftCore) moveWatermarks(n uint64) {
 		}
 	}
 
-	for testChkpt := range instance.checkpointStore {
+	for idx, testChkpt := range instance.checkpointStore {
 		if testChkpt.SequenceNumber <= h {
 			logger.Debugf("Replica %d cleaning checkpoint message from replica %d, seqNo %d, b64 snapshot id %s",
 				instance.id, testChkpt.ReplicaId, testChkpt.SequenceNumber, testChkpt.Id)
-			delete(instance.checkpointStore, testChkpt)
+			delete(instance.checkpointStore, idx)
 		}
 	}
 
@@ -1108,7 +1187,7 @@ func (instance *pbftCore) weakCheckpointSetOutOfRange(chkpt *Checkpoint) bool {
 func (instance *pbftCore) witnessCheckpointWeakCert(chkpt *Checkpoint) {
 	checkpointMembers := make([]uint64, instance.f+1) // Only ever invoked for the first weak cert, so guaranteed to be f+1
 	i := 0
-	for testChkpt := range instance.checkpointStore {
+	for _, testChkpt := range instance.checkpointStore {
 		if testChkpt.SequenceNumber == chkpt.SequenceNumber && testChkpt.Id == chkpt.Id {
 			checkpointMembers[i] = testChkpt.ReplicaId
 			logger.Debugf("Replica %d adding replica %d (handle %v) to weak cert", instance.id, testChkpt.ReplicaId, checkpointMembers[i])
@@ -1158,14 +1237,15 @@ func (instance *pbftCore) recvCheckpoint(chkpt *Checkpoint) events.Event {
 		return nil
 	}
 
-	instance.checkpointStore[*chkpt] = true
+	instance.checkpointStore[chkptIdx{chkpt.SequenceNumber, chkpt.Id, chkpt.ReplicaId}] = chkpt
 
-	matching := 0
-	for testChkpt := range instance.checkpointStore {
+	var matchingChkpts []*Checkpoint
+	for _, testChkpt := range instance.checkpointStore {
 		if testChkpt.SequenceNumber == chkpt.SequenceNumber && testChkpt.Id == chkpt.Id {
-			matching++
+			matchingChkpts = append(matchingChkpts, testChkpt)
 		}
 	}
+	matching := len(matchingChkpts)
 	logger.Debugf("Replica %d found %d matching checkpoints for seqNo %d, digest %s",
 		instance.id, matching, chkpt.SequenceNumber, chkpt.Id)
 
@@ -1206,6 +1286,13 @@ func (instance *pbftCore) recvCheckpoint(chkpt *Checkpoint) events.Event {
 	logger.Debugf("Replica %d found checkpoint quorum for seqNo %d, digest %s",
 		instance.id, chkpt.SequenceNumber, chkpt.Id)
 
+	if cert, err := instance.newCheckpointCertificate(matchingChkpts); err != nil {
+		logger.Debugf("Replica %d could not certify checkpoint for seqNo %d: %s", instance.id, chkpt.SequenceNumber, err)
+	} else {
+		instance.stableCert = cert
+		instance.persistCheckpointCertificate()
+	}
+
 	if chkptID != chkpt.Id {
 		logger.Criticalf("Replica %d generated a checkpoint of %s, but a quorum of the network agrees on %s. This is almost definitely non-deterministic chaincode.",
 			instance.id, chkptID, chkpt.Id)
@@ -1285,12 +1372,13 @@ func (instance *pbftCore) innerBroadcast(msg *Message) error {
 	// testing byzantine fault.
 	if doByzantine {
 		rand2 := rand.New(rand.NewSource(time.Now().UnixNano()))
-		ignoreidx := rand2.Intn(instance.N)
-		for i := 0; i < instance.N; i++ {
-			if i != ignoreidx && uint64(i) != instance.id { //Pick a random replica and do not send message
-				instance.consumer.unicast(msgRaw, uint64(i))
+		replicas := instance.replicaIDs()
+		ignoreidx := rand2.Intn(len(replicas))
+		for i, id := range replicas {
+			if i != ignoreidx && id != instance.id { //Pick a random replica and do not send message
+				instance.consumer.unicast(msgRaw, id)
 			} else {
-				logger.Debugf("PBFT byzantine: not broadcasting to replica %v", i)
+				logger.Debugf("PBFT byzantine: not broadcasting to replica %v", id)
 			}
 		}
 	} else {
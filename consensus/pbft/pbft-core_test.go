@@ -48,6 +48,7 @@ func TestConfigSet(t *testing.T) {
 		"general.batchsize",
 		"general.byzantine",
 		"general.viewchangeperiod",
+		"general.signatures.aggregation",
 		"general.timeout.batch",
 		"general.timeout.request",
 		"general.timeout.viewchange",
@@ -259,23 +260,23 @@ func TestNetwork(t *testing.T) {
 	reqBatch := createPbftReqBatch(1, uint64(generateBroadcaster(validatorCount)))
 	net.pbftEndpoints[0].manager.Queue() <- reqBatch
 
-	err := net.process()
+	err := net.Process()
 	if err != nil {
 		t.Fatalf("Processing failed: %s", err)
 	}
 
 	for _, pep := range net.pbftEndpoints {
 		if pep.sc.executions <= 0 {
-			t.Errorf("Instance %d did not execute transaction", pep.id)
+			t.Errorf("Instance %d did not execute transaction", pep.ID)
 			continue
 		}
 		if pep.sc.executions != 1 {
-			t.Errorf("Instance %d executed more than one transaction", pep.id)
+			t.Errorf("Instance %d executed more than one transaction", pep.ID)
 			continue
 		}
 		if !reflect.DeepEqual(pep.sc.lastExecution, hash(reqBatch.GetBatch()[0])) {
 			t.Errorf("Instance %d executed wrong transaction, %x should be %x",
-				pep.id, pep.sc.lastExecution, hash(reqBatch.GetBatch()[0]))
+				pep.ID, pep.sc.lastExecution, hash(reqBatch.GetBatch()[0]))
 		}
 	}
 }
@@ -297,18 +298,18 @@ func TestCheckpoint(t *testing.T) {
 	config.Set("general.K", 2)
 	config.Set("general.logmultiplier", 2)
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	execReqBatch := func(tag int64) {
 		net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(tag, uint64(generateBroadcaster(validatorCount)))
-		net.process()
+		net.Process()
 	}
 
 	// execWait is 0, and execute will proceed
 	execReqBatch(1)
 	execReqBatch(2)
 	finishWait.Wait()
-	net.process()
+	net.Process()
 
 	for _, pep := range net.pbftEndpoints {
 		if len(pep.pbft.chkpts) != 1 {
@@ -345,16 +346,16 @@ func TestCheckpoint(t *testing.T) {
 	// unblock executes.
 	execWait.Add(-1)
 
-	net.process()
+	net.Process()
 	finishWait.Wait() // Decoupling the execution thread makes this nastiness necessary
-	net.process()
+	net.Process()
 
 	// by now request 7 should have been confirmed and executed
 
 	for _, pep := range net.pbftEndpoints {
 		expectedExecutions := uint64(7)
 		if pep.sc.executions != expectedExecutions {
-			t.Errorf("Should have executed %d, got %d instead for replica %d", expectedExecutions, pep.sc.executions, pep.id)
+			t.Errorf("Should have executed %d, got %d instead for replica %d", expectedExecutions, pep.sc.executions, pep.ID)
 		}
 	}
 }
@@ -362,35 +363,35 @@ func TestCheckpoint(t *testing.T) {
 func TestLostPrePrepare(t *testing.T) {
 	validatorCount := 4
 	net := makePBFTNetwork(validatorCount, nil)
-	defer net.stop()
+	defer net.Stop()
 
 	net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(1, uint64(generateBroadcaster(validatorCount)))
 
 	// clear all messages sent by primary
-	msg := <-net.msgs
+	msg := <-net.Msgs
 	prePrep := &Message{}
-	err := proto.Unmarshal(msg.msg, prePrep)
+	err := proto.Unmarshal(msg.Msg, prePrep)
 	if err != nil {
 		t.Fatalf("Error unmarshaling message")
 	}
-	net.clearMessages()
+	net.ClearMessages()
 
 	// deliver pre-prepare to subset of replicas
 	for _, pep := range net.pbftEndpoints[1 : len(net.pbftEndpoints)-1] {
 		pep.manager.Queue() <- prePrep.GetPrePrepare()
 	}
 
-	err = net.process()
+	err = net.Process()
 	if err != nil {
 		t.Fatalf("Processing failed: %s", err)
 	}
 
 	for _, pep := range net.pbftEndpoints {
-		if pep.id != 3 && pep.sc.executions != 1 {
-			t.Errorf("Expected execution on replica %d", pep.id)
+		if pep.ID != 3 && pep.sc.executions != 1 {
+			t.Errorf("Expected execution on replica %d", pep.ID)
 			continue
 		}
-		if pep.id == 3 && pep.sc.executions > 0 {
+		if pep.ID == 3 && pep.sc.executions > 0 {
 			t.Errorf("Expected no execution")
 			continue
 		}
@@ -400,7 +401,7 @@ func TestLostPrePrepare(t *testing.T) {
 func TestInconsistentPrePrepare(t *testing.T) {
 	validatorCount := 4
 	net := makePBFTNetwork(validatorCount, nil)
-	defer net.stop()
+	defer net.Stop()
 
 	makePP := func(tag int64) *PrePrepare {
 		reqBatch := createPbftReqBatch(tag, uint64(generateBroadcaster(validatorCount)))
@@ -417,14 +418,14 @@ func TestInconsistentPrePrepare(t *testing.T) {
 	net.pbftEndpoints[0].manager.Queue() <- makePP(1).GetRequestBatch()
 
 	// clear all messages sent by primary
-	net.clearMessages()
+	net.ClearMessages()
 
 	// replace with fake messages
 	net.pbftEndpoints[1].manager.Queue() <- makePP(1)
 	net.pbftEndpoints[2].manager.Queue() <- makePP(2)
 	net.pbftEndpoints[3].manager.Queue() <- makePP(3)
 
-	net.process()
+	net.Process()
 
 	for n, pep := range net.pbftEndpoints {
 		if pep.sc.executions < 1 || pep.sc.executions > 3 {
@@ -563,11 +564,11 @@ func TestViewChange(t *testing.T) {
 	config.Set("general.K", 2)
 	config.Set("general.logmultiplier", 2)
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	execReqBatch := func(tag int64) {
 		net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(tag, uint64(generateBroadcaster(validatorCount)))
-		net.process()
+		net.Process()
 	}
 
 	execReqBatch(1)
@@ -578,7 +579,7 @@ func TestViewChange(t *testing.T) {
 		net.pbftEndpoints[i].pbft.sendViewChange()
 	}
 
-	err := net.process()
+	err := net.Process()
 	if err != nil {
 		t.Fatalf("Processing failed: %s", err)
 	}
@@ -602,7 +603,7 @@ func TestViewChange(t *testing.T) {
 func TestInconsistentDataViewChange(t *testing.T) {
 	validatorCount := 4
 	net := makePBFTNetwork(validatorCount, nil)
-	defer net.stop()
+	defer net.Stop()
 
 	makePP := func(tag int64) *PrePrepare {
 		reqBatch := createPbftReqBatch(tag, uint64(generateBroadcaster(validatorCount)))
@@ -619,14 +620,14 @@ func TestInconsistentDataViewChange(t *testing.T) {
 	net.pbftEndpoints[0].manager.Queue() <- makePP(0).GetRequestBatch()
 
 	// clear all messages sent by primary
-	net.clearMessages()
+	net.ClearMessages()
 
 	// replace with fake messages
 	net.pbftEndpoints[1].manager.Queue() <- makePP(1)
 	net.pbftEndpoints[2].manager.Queue() <- makePP(1)
 	net.pbftEndpoints[3].manager.Queue() <- makePP(0)
 
-	err := net.process()
+	err := net.Process()
 	if err != nil {
 		t.Fatalf("Processing failed: %s", err)
 	}
@@ -642,7 +643,7 @@ func TestInconsistentDataViewChange(t *testing.T) {
 func TestViewChangeWithStateTransfer(t *testing.T) {
 	validatorCount := 4
 	net := makePBFTNetwork(validatorCount, nil)
-	defer net.stop()
+	defer net.Stop()
 
 	var err error
 
@@ -671,13 +672,13 @@ func TestViewChangeWithStateTransfer(t *testing.T) {
 		net.pbftEndpoints[0].manager.Queue() <- makePP(i).GetRequestBatch()
 
 		// clear all messages sent by primary
-		net.clearMessages()
+		net.ClearMessages()
 
 		net.pbftEndpoints[0].manager.Queue() <- makePP(i)
 		net.pbftEndpoints[1].manager.Queue() <- makePP(i)
 		net.pbftEndpoints[2].manager.Queue() <- makePP(i)
 
-		err = net.process()
+		err = net.Process()
 		if err != nil {
 			t.Fatalf("Processing failed: %s", err)
 		}
@@ -689,7 +690,7 @@ func TestViewChangeWithStateTransfer(t *testing.T) {
 	// Add to replica 3's complaint, cause a view change
 	net.pbftEndpoints[1].pbft.sendViewChange()
 	net.pbftEndpoints[2].pbft.sendViewChange()
-	err = net.process()
+	err = net.Process()
 	if err != nil {
 		t.Fatalf("Processing failed: %s", err)
 	}
@@ -697,7 +698,7 @@ func TestViewChangeWithStateTransfer(t *testing.T) {
 	fmt.Println("Done with stage 3")
 
 	net.pbftEndpoints[1].manager.Queue() <- makePP(5).GetRequestBatch()
-	err = net.process()
+	err = net.Process()
 	if err != nil {
 		t.Fatalf("Processing failed: %s", err)
 	}
@@ -718,17 +719,17 @@ func TestNewViewTimeout(t *testing.T) {
 	config.Set("general.timeout.request", "400ms")
 	config.Set("general.timeout.viewchange", "800ms")
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	replica1Disabled := false
-	net.filterFn = func(src int, dst int, msg []byte) []byte {
+	net.FilterFn = func(src int, dst int, msg []byte) []byte {
 		if dst == -1 && src == 1 && replica1Disabled {
 			return nil
 		}
 		return msg
 	}
 
-	go net.processContinually()
+	go net.ProcessContinually()
 
 	reqBatch := createPbftReqBatch(1, uint64(generateBroadcaster(validatorCount)))
 
@@ -781,7 +782,7 @@ func TestViewChangeUpdateSeqNo(t *testing.T) {
 	}
 	net.pbftEndpoints[0].pbft.seqNo = 99
 
-	go net.processContinually()
+	go net.ProcessContinually()
 
 	broadcaster := uint64(generateBroadcaster(validatorCount))
 
@@ -800,7 +801,7 @@ func TestViewChangeUpdateSeqNo(t *testing.T) {
 	net.pbftEndpoints[1].manager.Queue() <- reqBatch
 	time.Sleep(5 * millisUntilTimeout)
 
-	net.stop()
+	net.Stop()
 	for i, pep := range net.pbftEndpoints {
 		if pep.pbft.view < 1 {
 			t.Errorf("Should have reached view 3, got %d instead for replica %d", pep.pbft.view, i)
@@ -888,14 +889,14 @@ func TestFallBehind(t *testing.T) {
 	config.Set("general.K", 2)
 	config.Set("general.logmultiplier", 2)
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	execReqBatch := func(tag int64, skipThree bool) {
 		net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(tag, uint64(generateBroadcaster(validatorCount)))
 
 		if skipThree {
 			// Send the request for consensus to everone but replica 3
-			net.filterFn = func(src, replica int, msg []byte) []byte {
+			net.FilterFn = func(src, replica int, msg []byte) []byte {
 				if src != -1 && replica == 3 {
 					return nil
 				}
@@ -903,9 +904,9 @@ func TestFallBehind(t *testing.T) {
 			}
 		} else {
 			// Send the request for consensus to everone
-			net.filterFn = nil
+			net.FilterFn = nil
 		}
-		err := net.process()
+		err := net.Process()
 		if err != nil {
 			t.Fatalf("Processing failed: %s", err)
 		}
@@ -955,28 +956,28 @@ func TestFallBehind(t *testing.T) {
 
 func TestPbftF0(t *testing.T) {
 	net := makePBFTNetwork(1, nil)
-	defer net.stop()
+	defer net.Stop()
 
 	reqBatch := createPbftReqBatch(1, 0)
 	net.pbftEndpoints[0].manager.Queue() <- reqBatch
 
-	err := net.process()
+	err := net.Process()
 	if err != nil {
 		t.Fatalf("Processing failed: %s", err)
 	}
 
 	for _, pep := range net.pbftEndpoints {
 		if pep.sc.executions < 1 {
-			t.Errorf("Instance %d did not execute transaction", pep.id)
+			t.Errorf("Instance %d did not execute transaction", pep.ID)
 			continue
 		}
 		if pep.sc.executions >= 2 {
-			t.Errorf("Instance %d executed more than one transaction", pep.id)
+			t.Errorf("Instance %d executed more than one transaction", pep.ID)
 			continue
 		}
 		if !reflect.DeepEqual(pep.sc.lastExecution, hash(reqBatch.GetBatch()[0])) {
 			t.Errorf("Instance %d executed wrong transaction, %x should be %x",
-				pep.id, pep.sc.lastExecution, hash(reqBatch.GetBatch()[0]))
+				pep.ID, pep.sc.lastExecution, hash(reqBatch.GetBatch()[0]))
 		}
 	}
 }
@@ -1014,10 +1015,10 @@ func TestReplicaCrash1(t *testing.T) {
 	config.Set("general.K", 2)
 	config.Set("general.logmultiplier", 2)
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(1, uint64(generateBroadcaster(validatorCount)))
-	net.process()
+	net.Process()
 
 	for id := 0; id < 2; id++ {
 		pe := net.pbftEndpoints[id]
@@ -1031,16 +1032,16 @@ func TestReplicaCrash1(t *testing.T) {
 
 	net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(2, uint64(generateBroadcaster(validatorCount)))
 	net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(3, uint64(generateBroadcaster(validatorCount)))
-	net.process()
+	net.Process()
 
 	for _, pep := range net.pbftEndpoints {
 		if pep.sc.executions != 3 {
-			t.Errorf("Expected 3 executions on replica %d, got %d", pep.id, pep.sc.executions)
+			t.Errorf("Expected 3 executions on replica %d, got %d", pep.ID, pep.sc.executions)
 			continue
 		}
 
 		if pep.pbft.view != 0 {
-			t.Errorf("Replica %d should still be in view 0, is %v %d", pep.id, pep.pbft.activeView, pep.pbft.view)
+			t.Errorf("Replica %d should still be in view 0, is %v %d", pep.ID, pep.pbft.activeView, pep.pbft.view)
 		}
 	}
 }
@@ -1057,10 +1058,10 @@ func TestReplicaCrash2(t *testing.T) {
 	config.Set("general.K", 2)
 	config.Set("general.logmultiplier", 2)
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	filterMsg := true
-	net.filterFn = func(src int, dst int, msg []byte) []byte {
+	net.FilterFn = func(src int, dst int, msg []byte) []byte {
 		if dst == 3 { // 3 is byz
 			return nil
 		}
@@ -1079,7 +1080,7 @@ func TestReplicaCrash2(t *testing.T) {
 	}
 
 	net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(1, uint64(generateBroadcaster(validatorCount)))
-	net.process()
+	net.Process()
 
 	logger.Info("stopping filtering")
 	filterMsg = false
@@ -1087,15 +1088,15 @@ func TestReplicaCrash2(t *testing.T) {
 	net.pbftEndpoints[primary].manager.Queue() <- createPbftReqBatch(2, uint64(generateBroadcaster(validatorCount)))
 	net.pbftEndpoints[primary].manager.Queue() <- createPbftReqBatch(3, uint64(generateBroadcaster(validatorCount)))
 	net.pbftEndpoints[primary].manager.Queue() <- createPbftReqBatch(4, uint64(generateBroadcaster(validatorCount)))
-	go net.processContinually()
+	go net.ProcessContinually()
 	time.Sleep(5 * time.Second)
 
 	for _, pep := range net.pbftEndpoints {
-		if pep.id != 3 && pep.sc.executions != 4 {
-			t.Errorf("Expected 4 executions on replica %d, got %d", pep.id, pep.sc.executions)
+		if pep.ID != 3 && pep.sc.executions != 4 {
+			t.Errorf("Expected 4 executions on replica %d, got %d", pep.ID, pep.sc.executions)
 			continue
 		}
-		if pep.id == 3 && pep.sc.executions > 0 {
+		if pep.ID == 3 && pep.sc.executions > 0 {
 			t.Errorf("Expected no execution")
 			continue
 		}
@@ -1113,11 +1114,11 @@ func TestReplicaCrash3(t *testing.T) {
 	config.Set("general.K", 2)
 	config.Set("general.logmultiplier", 2)
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	twoOffline := false
 	threeOffline := true
-	net.filterFn = func(src int, dst int, msg []byte) []byte {
+	net.FilterFn = func(src int, dst int, msg []byte) []byte {
 		if twoOffline && dst == 2 { // 2 is 'offline'
 			return nil
 		}
@@ -1130,7 +1131,7 @@ func TestReplicaCrash3(t *testing.T) {
 	for i := int64(1); i <= 8; i++ {
 		net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(i, uint64(generateBroadcaster(validatorCount)))
 	}
-	net.process() // vp0,1,2 should have a stable checkpoint for seqNo 8
+	net.Process() // vp0,1,2 should have a stable checkpoint for seqNo 8
 
 	// Create new pbft instances to restore from persistence
 	for id := 0; id < 2; id++ {
@@ -1151,7 +1152,7 @@ func TestReplicaCrash3(t *testing.T) {
 	// Because vp2 is 'offline', and vp3 is still at the genesis block, the network needs to make a view change
 
 	net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(9, uint64(generateBroadcaster(validatorCount)))
-	net.process()
+	net.Process()
 
 	// Now vp0,1,3 should be in sync with 9 executions in view 1, and vp2 should be at 8 executions in view 0
 	for i, pep := range net.pbftEndpoints {
@@ -1159,22 +1160,22 @@ func TestReplicaCrash3(t *testing.T) {
 		if i == 2 {
 			// 2 is 'offline'
 			if pep.pbft.view != 0 {
-				t.Errorf("Expected replica %d to be in view 0, got %d", pep.id, pep.pbft.view)
+				t.Errorf("Expected replica %d to be in view 0, got %d", pep.ID, pep.pbft.view)
 			}
 			expectedExecutions := uint64(8)
 			if pep.sc.executions != expectedExecutions {
-				t.Errorf("Expected %d executions on replica %d, got %d", expectedExecutions, pep.id, pep.sc.executions)
+				t.Errorf("Expected %d executions on replica %d, got %d", expectedExecutions, pep.ID, pep.sc.executions)
 			}
 			continue
 		}
 
 		if pep.pbft.view != 1 {
-			t.Errorf("Expected replica %d to be in view 1, got %d", pep.id, pep.pbft.view)
+			t.Errorf("Expected replica %d to be in view 1, got %d", pep.ID, pep.pbft.view)
 		}
 
 		expectedExecutions := uint64(9)
 		if pep.sc.executions != expectedExecutions {
-			t.Errorf("Expected %d executions on replica %d, got %d", expectedExecutions, pep.id, pep.sc.executions)
+			t.Errorf("Expected %d executions on replica %d, got %d", expectedExecutions, pep.ID, pep.sc.executions)
 		}
 	}
 }
@@ -1189,11 +1190,11 @@ func TestReplicaCrash4(t *testing.T) {
 	config.Set("general.K", 2)
 	config.Set("general.logmultiplier", 2)
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	twoOffline := false
 	threeOffline := true
-	net.filterFn = func(src int, dst int, msg []byte) []byte {
+	net.FilterFn = func(src int, dst int, msg []byte) []byte {
 		if twoOffline && dst == 2 { // 2 is 'offline'
 			return nil
 		}
@@ -1206,8 +1207,8 @@ func TestReplicaCrash4(t *testing.T) {
 	for i := int64(1); i <= 8; i++ {
 		net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(i, uint64(generateBroadcaster(validatorCount)))
 	}
-	net.process() // vp0,1,2 should have a stable checkpoint for seqNo 8
-	net.process() // this second time is necessary for garbage collection it seams
+	net.Process() // vp0,1,2 should have a stable checkpoint for seqNo 8
+	net.Process() // this second time is necessary for garbage collection it seams
 
 	// Now vp0,1,2 should be in sync with 8 executions in view 0, and vp4 should be offline
 	for i, pep := range net.pbftEndpoints {
@@ -1218,12 +1219,12 @@ func TestReplicaCrash4(t *testing.T) {
 		}
 
 		if pep.pbft.view != 0 {
-			t.Errorf("Expected replica %d to be in view 1, got %d", pep.id, pep.pbft.view)
+			t.Errorf("Expected replica %d to be in view 1, got %d", pep.ID, pep.pbft.view)
 		}
 
 		expectedExecutions := uint64(8)
 		if pep.sc.executions != expectedExecutions {
-			t.Errorf("Expected %d executions on replica %d, got %d", expectedExecutions, pep.id, pep.sc.executions)
+			t.Errorf("Expected %d executions on replica %d, got %d", expectedExecutions, pep.ID, pep.sc.executions)
 		}
 	}
 
@@ -1327,22 +1328,22 @@ func TestNetworkNullRequests(t *testing.T) {
 	config.Set("general.timeout.nullrequest", "200ms")
 	config.Set("general.timeout.request", "500ms")
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(1, 0)
 
-	go net.processContinually()
+	go net.ProcessContinually()
 	time.Sleep(3 * time.Second)
 
 	for _, pep := range net.pbftEndpoints {
 		if pep.sc.executions != 1 {
-			t.Errorf("Instance %d executed incorrect number of transactions: %d", pep.id, pep.sc.executions)
+			t.Errorf("Instance %d executed incorrect number of transactions: %d", pep.ID, pep.sc.executions)
 		}
 		if pep.pbft.lastExec <= 1 {
-			t.Errorf("Instance %d: no null requests processed", pep.id)
+			t.Errorf("Instance %d: no null requests processed", pep.ID)
 		}
 		if pep.pbft.view != 0 {
-			t.Errorf("Instance %d: expected view=0", pep.id)
+			t.Errorf("Instance %d: expected view=0", pep.ID)
 		}
 	}
 }
@@ -1353,24 +1354,24 @@ func TestNetworkNullRequestMissing(t *testing.T) {
 	config.Set("general.timeout.nullrequest", "200ms")
 	config.Set("general.timeout.request", "500ms")
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	net.pbftEndpoints[0].pbft.nullRequestTimeout = 0
 
 	net.pbftEndpoints[0].manager.Queue() <- createPbftReqBatch(1, 0)
 
-	go net.processContinually()
+	go net.ProcessContinually()
 	time.Sleep(3 * time.Second) // Bumped from 2 to 3 seconds because of sporadic CI failures
 
 	for _, pep := range net.pbftEndpoints {
 		if pep.sc.executions != 1 {
-			t.Errorf("Instance %d executed incorrect number of transactions: %d", pep.id, pep.sc.executions)
+			t.Errorf("Instance %d executed incorrect number of transactions: %d", pep.ID, pep.sc.executions)
 		}
 		if pep.pbft.lastExec <= 1 {
-			t.Errorf("Instance %d: no null requests processed", pep.id)
+			t.Errorf("Instance %d: no null requests processed", pep.ID)
 		}
 		if pep.pbft.view != 1 {
-			t.Errorf("Instance %d: expected view=1", pep.id)
+			t.Errorf("Instance %d: expected view=1", pep.ID)
 		}
 	}
 }
@@ -1383,22 +1384,22 @@ func TestNetworkPeriodicViewChange(t *testing.T) {
 	config.Set("general.timeout.request", "500ms")
 	config.Set("general.viewchangeperiod", "1")
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	for n := 1; n < 6; n++ {
 		for _, pe := range net.pbftEndpoints {
 			pe.manager.Queue() <- createPbftReqBatch(int64(n), 0)
 		}
-		net.process()
+		net.Process()
 	}
 
 	for _, pep := range net.pbftEndpoints {
 		if pep.sc.executions != 5 {
-			t.Errorf("Instance %d executed incorrect number of transactions: %d", pep.id, pep.sc.executions)
+			t.Errorf("Instance %d executed incorrect number of transactions: %d", pep.ID, pep.sc.executions)
 		}
 		// We should be in view 2, 2 exec, VC, 2 exec, VC, exec
 		if pep.pbft.view != 2 {
-			t.Errorf("Instance %d: expected view=2", pep.id)
+			t.Errorf("Instance %d: expected view=2", pep.ID)
 		}
 	}
 }
@@ -1411,7 +1412,7 @@ func TestNetworkPeriodicViewChangeMissing(t *testing.T) {
 	config.Set("general.timeout.request", "500ms")
 	config.Set("general.viewchangeperiod", "1")
 	net := makePBFTNetwork(validatorCount, config)
-	defer net.stop()
+	defer net.Stop()
 
 	net.pbftEndpoints[0].pbft.viewChangePeriod = 0
 	net.pbftEndpoints[0].pbft.viewChangeSeqNo = ^uint64(0)
@@ -1420,15 +1421,15 @@ func TestNetworkPeriodicViewChangeMissing(t *testing.T) {
 		for _, pe := range net.pbftEndpoints {
 			pe.manager.Queue() <- createPbftReqBatch(int64(n), 0)
 		}
-		net.process()
+		net.Process()
 	}
 
 	for _, pep := range net.pbftEndpoints {
 		if pep.sc.executions != 2 {
-			t.Errorf("Instance %d executed incorrect number of transactions: %d", pep.id, pep.sc.executions)
+			t.Errorf("Instance %d executed incorrect number of transactions: %d", pep.ID, pep.sc.executions)
 		}
 		if pep.pbft.view != 1 {
-			t.Errorf("Instance %d: expected view=1", pep.id)
+			t.Errorf("Instance %d: expected view=1", pep.ID)
 		}
 	}
 }
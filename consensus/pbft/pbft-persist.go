@@ -104,6 +104,32 @@ func (instance *pbftCore) persistCheckpoint(seqNo uint64, id []byte) {
 	}
 }
 
+func (instance *pbftCore) persistCheckpointCertificate() {
+	raw, err := proto.Marshal(instance.stableCert)
+	if err != nil {
+		logger.Warningf("Replica %d could not persist checkpoint certificate: %s", instance.id, err)
+		return
+	}
+	err = instance.consumer.StoreState("stableCert", raw)
+	if err != nil {
+		logger.Warningf("Replica %d could not persist checkpoint certificate: %s", instance.id, err)
+	}
+}
+
+func (instance *pbftCore) restoreCheckpointCertificate() {
+	raw, err := instance.consumer.ReadState("stableCert")
+	if err != nil {
+		logger.Debugf("Replica %d could not restore checkpoint certificate: %s", instance.id, err)
+		return
+	}
+	cert := &CheckpointCertificate{}
+	if err = proto.Unmarshal(raw, cert); err != nil {
+		logger.Errorf("Replica %d could not unmarshal checkpoint certificate - local state is damaged: %s", instance.id, err)
+		return
+	}
+	instance.stableCert = cert
+}
+
 func (instance *pbftCore) persistDelCheckpoint(seqNo uint64) {
 	key := fmt.Sprintf("chkpt.%d", seqNo)
 	instance.consumer.DelState(key)
@@ -149,6 +175,12 @@ func (instance *pbftCore) restoreState() {
 	}
 
 	instance.restoreLastSeqNo()
+	instance.restoreMembership()
+	if instance.membershipDue() {
+		instance.setMembership(instance.pendingMembership)
+		instance.pendingMembership = nil
+		instance.proposals = nil
+	}
 
 	chkpts, err := instance.consumer.ReadStateSet("chkpt.")
 	if err == nil {
@@ -171,6 +203,8 @@ func (instance *pbftCore) restoreState() {
 		logger.Warningf("Replica %d could not restore checkpoints: %s", instance.id, err)
 	}
 
+	instance.restoreCheckpointCertificate()
+
 	logger.Infof("Replica %d restored state: view: %d, seqNo: %d, pset: %d, qset: %d, reqBatches: %d, chkpts: %d h: %d",
 		instance.id, instance.view, instance.seqNo, len(instance.pset), len(instance.qset), len(instance.reqBatchStore), len(instance.chkpts), instance.h)
 }
@@ -32,11 +32,15 @@ import (
 
 const configPrefix = "CORE_PBFT"
 
+// Name is the name under which this plugin is registered with 'consensus.Registry'
+const Name = "pbft"
+
 var pluginInstance consensus.Consenter // singleton service
 var config *viper.Viper
 
 func init() {
 	config = loadConfig()
+	consensus.Registry.Add(Name, GetPlugin)
 }
 
 // GetPlugin returns the handle to the Consenter singleton
@@ -47,6 +51,18 @@ func GetPlugin(c consensus.Stack) consensus.Consenter {
 	return pluginInstance
 }
 
+// Reconfigurer is implemented by PBFT consenters which support changing
+// the replica set at runtime
+type Reconfigurer interface {
+	Reconfigure(reconf *Reconfiguration) error
+}
+
+// CheckpointCertifier is implemented by PBFT consenters which can prove
+// their last stable checkpoint to third parties
+type CheckpointCertifier interface {
+	CheckpointCertificate() *CheckpointCertificate
+}
+
 // New creates a new Obc* instance that provides the Consenter interface.
 // Internally, it uses an opaque pbft-core instance.
 func New(stack consensus.Stack) consensus.Consenter {
@@ -148,6 +164,18 @@ func (op *obcGeneric) getState() []byte {
 	return op.stack.GetBlockchainInfoBlob()
 }
 
+func (op *obcGeneric) getLastMembership() (*MembershipState, error) {
+	raw, err := op.stack.GetBlockHeadMetadata()
+	if err != nil {
+		return nil, err
+	}
+	meta := &Metadata{}
+	if err = proto.Unmarshal(raw, meta); err != nil {
+		return nil, err
+	}
+	return meta.Membership, nil
+}
+
 func (op *obcGeneric) getLastSeqNo() (uint64, error) {
 	raw, err := op.stack.GetBlockHeadMetadata()
 	if err != nil {
@@ -0,0 +1,353 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+// --------------------------------------------------------------
+//
+// reconfig contains the handling of membership reconfigurations.
+// A reconfiguration is ordered like any other request; once f+1
+// members of the current replica set have endorsed it, the new
+// membership becomes pending and takes effect after the next
+// checkpoint.  No sequence number beyond that checkpoint is
+// assigned until then.
+//
+// --------------------------------------------------------------
+
+// currentMembership returns the replica set in effect; without any
+// reconfiguration, this is the configured replica set 0..N-1, with
+// the configured BLS public keys
+func (instance *pbftCore) currentMembership() *Membership {
+	if instance.membership != nil {
+		return instance.membership
+	}
+	replicas := make([]*Replica, instance.N)
+	for i := range replicas {
+		replicas[i] = &Replica{Id: uint64(i)}
+		if i < len(instance.blsPublicKeys) {
+			replicas[i].BlsPublicKey = instance.blsPublicKeys[i]
+		}
+	}
+	return &Membership{F: uint64(instance.f), Replicas: replicas}
+}
+
+func (instance *pbftCore) replicaIDs() []uint64 {
+	replicas := instance.currentMembership().Replicas
+	ids := make([]uint64, len(replicas))
+	for i, replica := range replicas {
+		ids[i] = replica.Id
+	}
+	return ids
+}
+
+func (instance *pbftCore) isMember(id uint64) bool {
+	if instance.membership == nil {
+		return id < uint64(instance.N)
+	}
+	for _, replica := range instance.membership.Replicas {
+		if replica.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// enrollmentCert returns the certificate a replica was added with, if any
+func (instance *pbftCore) enrollmentCert(id uint64) []byte {
+	if instance.membership == nil {
+		return nil
+	}
+	for _, replica := range instance.membership.Replicas {
+		if replica.Id == id {
+			return replica.EnrollmentCert
+		}
+	}
+	return nil
+}
+
+// blsPublicKey returns the BLS public key a member was configured or added with
+func (instance *pbftCore) blsPublicKey(id uint64) ([]byte, error) {
+	for _, replica := range instance.currentMembership().Replicas {
+		if replica.Id != id {
+			continue
+		}
+		if replica.BlsPublicKey == nil {
+			return nil, fmt.Errorf("replica %d has no BLS public key", id)
+		}
+		return replica.BlsPublicKey, nil
+	}
+	return nil, fmt.Errorf("replica %d is not a member", id)
+}
+
+func (instance *pbftCore) setMembership(membership *Membership) {
+	instance.membership = membership
+	instance.N = len(membership.Replicas)
+	instance.f = int(membership.F)
+	instance.replicaCount = instance.N
+}
+
+// serializeReconfiguration returns the bytes endorsements are signed
+// over, the reconfiguration without its endorsements
+func serializeReconfiguration(reconf *Reconfiguration) ([]byte, error) {
+	unendorsed := *reconf
+	unendorsed.Endorsements = nil
+	return proto.Marshal(&unendorsed)
+}
+
+// nextMembership validates a reconfiguration against the current
+// membership and returns the membership it results in
+func (instance *pbftCore) nextMembership(reconf *Reconfiguration) (*Membership, error) {
+	current := instance.currentMembership()
+	if instance.pendingMembership != nil {
+		return nil, fmt.Errorf("membership of epoch %d is already pending", instance.pendingMembership.Epoch)
+	}
+	if reconf.Epoch != current.Epoch {
+		return nil, fmt.Errorf("reconfiguration is for epoch %d, but current epoch is %d", reconf.Epoch, current.Epoch)
+	}
+
+	replicas := make(map[uint64]*Replica)
+	for _, replica := range current.Replicas {
+		replicas[replica.Id] = replica
+	}
+	for _, id := range reconf.Remove {
+		if _, ok := replicas[id]; !ok {
+			return nil, fmt.Errorf("cannot remove replica %d, it is not a member", id)
+		}
+		delete(replicas, id)
+	}
+	for _, replica := range reconf.Add {
+		if _, ok := replicas[replica.Id]; ok {
+			return nil, fmt.Errorf("cannot add replica %d, it is already a member", replica.Id)
+		}
+		if replica.BlsPublicKey != nil {
+			if _, err := parseBLSPublicKey(replica.BlsPublicKey); err != nil {
+				return nil, fmt.Errorf("cannot add replica %d: %s", replica.Id, err)
+			}
+		} else if instance.aggregator.compact() {
+			return nil, fmt.Errorf("cannot add replica %d without a BLS public key", replica.Id)
+		}
+		replicas[replica.Id] = replica
+	}
+
+	if int(reconf.F)*3+1 > len(replicas) {
+		return nil, fmt.Errorf("need at least %d replicas to tolerate %d byzantine faults, but only %d replicas would remain", reconf.F*3+1, reconf.F, len(replicas))
+	}
+
+	ids := make(sortableUint64Slice, 0, len(replicas))
+	for id := range replicas {
+		ids = append(ids, id)
+	}
+	sort.Sort(ids)
+
+	next := &Membership{Epoch: current.Epoch + 1, F: reconf.F}
+	for _, id := range ids {
+		next.Replicas = append(next.Replicas, replicas[id])
+	}
+	return next, nil
+}
+
+// executeReconfiguration is invoked when a reconfiguration request is
+// executed at seqNo; it collects the endorsements of the request and,
+// once f+1 current members endorsed it, makes the resulting membership
+// pending until the next checkpoint
+func (instance *pbftCore) executeReconfiguration(seqNo uint64, reconf *Reconfiguration) {
+	next, err := instance.nextMembership(reconf)
+	if err != nil {
+		logger.Warningf("Replica %d ignoring reconfiguration executed at seqNo %d: %s", instance.id, seqNo, err)
+		return
+	}
+	raw, err := serializeReconfiguration(reconf)
+	if err != nil {
+		logger.Warningf("Replica %d could not serialize reconfiguration: %s", instance.id, err)
+		return
+	}
+
+	var proposal *Reconfiguration
+	for _, p := range instance.proposals {
+		if praw, _ := serializeReconfiguration(p); string(praw) == string(raw) {
+			proposal = p
+			break
+		}
+	}
+	if proposal == nil {
+		proposal = &Reconfiguration{}
+		proto.Unmarshal(raw, proposal)
+		instance.proposals = append(instance.proposals, proposal)
+	}
+
+outer:
+	for _, endorsement := range reconf.Endorsements {
+		if !instance.isMember(endorsement.ReplicaId) {
+			logger.Warningf("Replica %d ignoring reconfiguration endorsement from replica %d, which is not a member", instance.id, endorsement.ReplicaId)
+			continue
+		}
+		for _, e := range proposal.Endorsements {
+			if e.ReplicaId == endorsement.ReplicaId {
+				continue outer
+			}
+		}
+		if err := instance.consumer.verify(endorsement.ReplicaId, endorsement.Signature, raw); err != nil {
+			logger.Warningf("Replica %d ignoring reconfiguration endorsement from replica %d: %s", instance.id, endorsement.ReplicaId, err)
+			continue
+		}
+		proposal.Endorsements = append(proposal.Endorsements, endorsement)
+	}
+
+	if len(proposal.Endorsements) < instance.f+1 {
+		logger.Infof("Replica %d has %d of %d endorsements for reconfiguration to epoch %d", instance.id, len(proposal.Endorsements), instance.f+1, next.Epoch)
+		return
+	}
+
+	// The new membership takes effect after the checkpoint following seqNo
+	next.SeqNo = (seqNo + instance.K - 1) / instance.K * instance.K
+	instance.pendingMembership = next
+	instance.proposals = nil
+	logger.Infof("Replica %d will reconfigure to epoch %d with %d replicas (f=%d) after seqNo %d", instance.id, next.Epoch, len(next.Replicas), next.F, next.SeqNo)
+
+	instance.padToReconfiguration()
+}
+
+// membershipState returns the membership state to be recorded with the
+// execution of seqNo, or nil if the static configuration is in effect
+func (instance *pbftCore) membershipState(seqNo uint64) *MembershipState {
+	if instance.pendingMembership != nil && seqNo >= instance.pendingMembership.SeqNo {
+		return &MembershipState{Current: instance.pendingMembership}
+	}
+	if instance.membership == nil && instance.pendingMembership == nil && len(instance.proposals) == 0 {
+		return nil
+	}
+	return &MembershipState{
+		Current:   instance.membership,
+		Pending:   instance.pendingMembership,
+		Proposals: instance.proposals,
+	}
+}
+
+// padToReconfiguration has the primary fill the sequence numbers up to
+// a pending reconfiguration with null requests, so that the new
+// membership does not wait for further requests to take effect
+func (instance *pbftCore) padToReconfiguration() {
+	if instance.pendingMembership == nil || !instance.activeView || instance.primary(instance.view) != instance.id {
+		return
+	}
+	for instance.seqNo < instance.pendingMembership.SeqNo {
+		n := instance.seqNo
+		instance.sendPrePrepare(nil, "")
+		if instance.seqNo == n {
+			return
+		}
+	}
+}
+
+// applyPendingMembership switches to the pending membership once the
+// checkpoint it was scheduled for has been executed
+func (instance *pbftCore) applyPendingMembership() {
+	membership := instance.pendingMembership
+	instance.pendingMembership = nil
+	instance.proposals = nil
+	instance.setMembership(membership)
+	logger.Infof("Replica %d reconfigured to epoch %d with replicas %v (f=%d)", instance.id, membership.Epoch, instance.replicaIDs(), instance.f)
+
+	// Nothing beyond the reconfiguration boundary can have been ordered, but
+	// discard whatever we may have been sent under the old membership
+	for idx := range instance.certStore {
+		if idx.n > instance.lastExec {
+			delete(instance.certStore, idx)
+		}
+	}
+	for n := range instance.pset {
+		if n > instance.lastExec {
+			delete(instance.pset, n)
+		}
+	}
+	for idx := range instance.qset {
+		if idx.n > instance.lastExec {
+			delete(instance.qset, idx)
+		}
+	}
+	instance.persistPSet()
+	instance.persistQSet()
+	if instance.seqNo > instance.lastExec {
+		instance.seqNo = instance.lastExec
+	}
+
+	instance.viewChangeStore = make(map[vcidx]*ViewChange)
+	for id := range instance.hChkpts {
+		if !instance.isMember(id) {
+			delete(instance.hChkpts, id)
+		}
+	}
+
+	if !instance.isMember(instance.id) {
+		logger.Warningf("Replica %d is no longer a member of the replica set", instance.id)
+	}
+
+	instance.consumer.reconfigured(membership)
+	instance.resubmitRequestBatches()
+}
+
+// restoreMembership reads the membership state recorded with the last
+// executed request, and reports whether the membership changed
+func (instance *pbftCore) restoreMembership() bool {
+	state, err := instance.consumer.getLastMembership()
+	if err != nil {
+		logger.Warningf("Replica %d could not restore membership: %s", instance.id, err)
+		return false
+	}
+	if state == nil {
+		return false
+	}
+
+	changed := false
+	if state.Current != nil && !proto.Equal(state.Current, instance.currentMembership()) {
+		instance.setMembership(state.Current)
+		changed = true
+	}
+	instance.pendingMembership = state.Pending
+	instance.proposals = state.Proposals
+	logger.Infof("Replica %d restored membership of epoch %d with replicas %v (f=%d)", instance.id, instance.currentMembership().Epoch, instance.replicaIDs(), instance.f)
+	return changed
+}
+
+// membershipDue reports whether a pending membership should be in effect
+func (instance *pbftCore) membershipDue() bool {
+	return instance.pendingMembership != nil && instance.lastExec >= instance.pendingMembership.SeqNo
+}
+
+// verifyWithEnrollmentCert checks a signature against the public key of
+// an enrollment certificate
+func verifyWithEnrollmentCert(cert []byte, signature []byte, message []byte) error {
+	x509Cert, err := primitives.DERToX509Certificate(cert)
+	if err != nil {
+		return fmt.Errorf("could not parse enrollment certificate: %s", err)
+	}
+	ok, err := primitives.ECDSAVerify(x509Cert.PublicKey, message, signature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
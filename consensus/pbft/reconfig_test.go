@@ -0,0 +1,191 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pbft
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestReconfigurationValidation(t *testing.T) {
+	instance := newPbftCore(0, loadConfig(), &omniProto{}, &inertTimerFactory{})
+	defer instance.close()
+
+	for i, reconf := range []*Reconfiguration{
+		{Epoch: 1, F: 1, Add: []*Replica{{Id: 4}}},
+		{Epoch: 0, F: 1, Add: []*Replica{{Id: 3}}},
+		{Epoch: 0, F: 1, Remove: []uint64{7}},
+		{Epoch: 0, F: 1, Remove: []uint64{3}},
+	} {
+		if _, err := instance.nextMembership(reconf); err == nil {
+			t.Errorf("Expected reconfiguration %d to be rejected", i)
+		}
+	}
+
+	next, err := instance.nextMembership(&Reconfiguration{Epoch: 0, F: 1, Add: []*Replica{{Id: 7}}, Remove: []uint64{1}})
+	if err != nil {
+		t.Fatalf("Expected reconfiguration to be accepted: %s", err)
+	}
+	if next.Epoch != 1 || next.F != 1 || len(next.Replicas) != 4 {
+		t.Fatalf("Unexpected membership %v", next)
+	}
+	ids := []uint64{}
+	for _, replica := range next.Replicas {
+		ids = append(ids, replica.Id)
+	}
+	if !reflect.DeepEqual(ids, []uint64{0, 2, 3, 7}) {
+		t.Errorf("Expected replicas [0 2 3 7], got %v", ids)
+	}
+}
+
+func TestReconfigurationEndorsementThreshold(t *testing.T) {
+	instance := newPbftCore(3, loadConfig(), &omniProto{
+		verifyImpl: func(senderID uint64, signature []byte, message []byte) error { return nil },
+	}, &inertTimerFactory{})
+	defer instance.close()
+
+	reconf := func(endorsers ...uint64) *Reconfiguration {
+		r := &Reconfiguration{Epoch: 0, F: 1, Add: []*Replica{{Id: 4}}}
+		for _, id := range endorsers {
+			r.Endorsements = append(r.Endorsements, &Endorsement{ReplicaId: id})
+		}
+		return r
+	}
+
+	instance.executeReconfiguration(1, reconf(1, 1, 9))
+	if instance.pendingMembership != nil {
+		t.Fatalf("Reconfiguration should not be pending with a single valid endorsement")
+	}
+	if len(instance.proposals) != 1 || len(instance.proposals[0].Endorsements) != 1 {
+		t.Fatalf("Expected one proposal with one endorsement, got %v", instance.proposals)
+	}
+
+	instance.executeReconfiguration(12, reconf(2))
+	if instance.pendingMembership == nil {
+		t.Fatalf("Reconfiguration should be pending after f+1 endorsements")
+	}
+	if instance.pendingMembership.SeqNo != 20 {
+		t.Errorf("Reconfiguration should take effect after checkpoint 20, but is scheduled after %d", instance.pendingMembership.SeqNo)
+	}
+	if len(instance.proposals) != 0 {
+		t.Errorf("Proposals should be cleared once a reconfiguration is pending")
+	}
+	if instance.N != 4 {
+		t.Errorf("Reconfiguration should not take effect before its checkpoint")
+	}
+}
+
+func TestReconfigurationRestore(t *testing.T) {
+	membership := &Membership{Epoch: 1, SeqNo: 10, F: 0, Replicas: []*Replica{{Id: 0}, {Id: 2}, {Id: 5}}}
+	instance := newPbftCore(2, loadConfig(), &omniProto{
+		getLastSeqNoImpl: func() (uint64, error) { return 10, nil },
+		getLastMembershipImpl: func() (*MembershipState, error) {
+			return &MembershipState{Pending: membership}, nil
+		},
+	}, &inertTimerFactory{})
+	defer instance.close()
+
+	if instance.pendingMembership != nil || instance.N != 3 || instance.f != 0 {
+		t.Fatalf("Expected restored membership to be in effect, got N=%d, f=%d", instance.N, instance.f)
+	}
+	if !instance.isMember(5) || instance.isMember(1) {
+		t.Errorf("Expected replica set %v, got %v", membership.Replicas, instance.replicaIDs())
+	}
+	if primary := instance.primary(2); primary != 5 {
+		t.Errorf("Expected replica 5 to be primary of view 2, got %d", primary)
+	}
+}
+
+func TestReconfigurationRemovesReplica(t *testing.T) {
+	validatorCount := 4
+	net := makeConsumerNetwork(validatorCount, obcBatchSizeOneHelper)
+	defer net.Stop()
+
+	reconf := &Reconfiguration{Epoch: 0, F: 0, Remove: []uint64{3}}
+	for _, id := range []int{1, 2} {
+		if err := net.Endpoints[id].(*consumerEndpoint).consumer.(Reconfigurer).Reconfigure(reconf); err != nil {
+			t.Fatalf("Replica %d could not submit reconfiguration: %s", id, err)
+		}
+		net.Process()
+	}
+
+	for _, ep := range net.Endpoints {
+		ce := ep.(*consumerEndpoint)
+		instance := ce.consumer.getPBFTCore()
+		if instance.N != 3 || instance.f != 0 || instance.isMember(3) {
+			t.Errorf("Replica %d expected replicas [0 1 2] with f=0, got %v with f=%d", ce.ID, instance.replicaIDs(), instance.f)
+		}
+		if instance.lastExec != instance.K {
+			t.Errorf("Replica %d should have padded the log to checkpoint %d, but executed up to %d", ce.ID, instance.K, instance.lastExec)
+		}
+	}
+
+	broadcaster := net.Endpoints[generateBroadcaster(validatorCount)].GetHandle()
+	net.Endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createTxMsg(1), broadcaster)
+	net.Process()
+
+	for _, ce := range net.Endpoints[:3] {
+		obc := ce.(*consumerEndpoint).consumer.(*obcBatch)
+		block, err := obc.stack.GetBlock(3)
+		if err != nil {
+			t.Fatalf("Replica %d expected a block after the reconfiguration: %s", obc.pbft.id, err)
+		}
+		meta := &Metadata{}
+		proto.Unmarshal(block.ConsensusMetadata, meta)
+		if current := meta.GetMembership().GetCurrent(); current == nil || current.Epoch != 1 {
+			t.Errorf("Replica %d should record the membership of epoch 1 with block 3, got %v", obc.pbft.id, meta.Membership)
+		}
+	}
+	if _, err := net.Endpoints[3].(*consumerEndpoint).consumer.(*obcBatch).stack.GetBlock(3); err == nil {
+		t.Errorf("Removed replica 3 should not take part in ordering anymore")
+	}
+}
+
+func TestReconfigurationBLSPublicKeys(t *testing.T) {
+	instance := newPbftCore(0, aggregationTestConfig(0, blsScheme), &omniProto{}, &inertTimerFactory{})
+	defer instance.close()
+
+	_, configured := blsTestKey(2)
+	if publicKey, err := instance.blsPublicKey(2); err != nil || !bytes.Equal(publicKey, configured) {
+		t.Errorf("Expected the configured BLS public key of replica 2, got %x (%v)", publicKey, err)
+	}
+
+	for i, replica := range []*Replica{{Id: 7}, {Id: 7, BlsPublicKey: []byte("invalid")}} {
+		if _, err := instance.nextMembership(&Reconfiguration{Epoch: 0, F: 1, Add: []*Replica{replica}}); err == nil {
+			t.Errorf("Expected replica %d to be rejected", i)
+		}
+	}
+
+	_, added := blsTestKey(7)
+	next, err := instance.nextMembership(&Reconfiguration{Epoch: 0, F: 1, Add: []*Replica{{Id: 7, BlsPublicKey: added}}, Remove: []uint64{1}})
+	if err != nil {
+		t.Fatalf("Expected reconfiguration to be accepted: %s", err)
+	}
+	instance.setMembership(next)
+	if publicKey, err := instance.blsPublicKey(2); err != nil || !bytes.Equal(publicKey, configured) {
+		t.Errorf("Expected replica 2 to keep its configured BLS public key, got %x (%v)", publicKey, err)
+	}
+	if publicKey, err := instance.blsPublicKey(7); err != nil || !bytes.Equal(publicKey, added) {
+		t.Errorf("Expected replica 7 to be registered with its BLS public key, got %x (%v)", publicKey, err)
+	}
+	if _, err := instance.blsPublicKey(1); err == nil {
+		t.Errorf("Expected no BLS public key for removed replica 1")
+	}
+}
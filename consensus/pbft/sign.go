@@ -16,7 +16,11 @@ limitations under the License.
 
 package pbft
 
-import pb "github.com/golang/protobuf/proto"
+import (
+	"fmt"
+
+	pb "github.com/golang/protobuf/proto"
+)
 
 type signable interface {
 	getSignature() []byte
@@ -32,7 +36,7 @@ func (instance *pbftCore) sign(s signable) error {
 	if err != nil {
 		return err
 	}
-	signedRaw, err := instance.consumer.sign(raw)
+	signedRaw, err := instance.aggregator.sign(raw)
 	if err != nil {
 		return err
 	}
@@ -49,7 +53,45 @@ func (instance *pbftCore) verify(s signable) error {
 	if err != nil {
 		return err
 	}
-	return instance.consumer.verify(s.getID(), origSig, raw)
+	return instance.aggregator.verify(s.getID(), origSig, raw)
+}
+
+// aggregate moves the signatures of the signables into one aggregate signature
+func (instance *pbftCore) aggregate(items []signable) (*AggregateSignature, error) {
+	signers := make([]uint64, len(items))
+	signatures := make([][]byte, len(items))
+	for i, s := range items {
+		signers[i] = s.getID()
+		signatures[i] = s.getSignature()
+		s.setSignature(nil)
+	}
+	return instance.aggregator.aggregate(signers, signatures)
+}
+
+// verifyAggregate verifies an aggregate signature over signables which
+// carry no signatures of their own
+func (instance *pbftCore) verifyAggregate(items []signable, agg *AggregateSignature) error {
+	if len(items) != len(agg.Signers) {
+		return fmt.Errorf("aggregate signature has %d signers for %d messages", len(agg.Signers), len(items))
+	}
+	messages := make([][]byte, len(items))
+	for i, s := range items {
+		if s.getID() != agg.Signers[i] {
+			return fmt.Errorf("message %d is from replica %d, but signed by replica %d", i, s.getID(), agg.Signers[i])
+		}
+		if s.getSignature() != nil {
+			return fmt.Errorf("message %d from replica %d carries its own signature", i, s.getID())
+		}
+		raw, err := s.serialize()
+		if err != nil {
+			return err
+		}
+		messages[i] = raw
+	}
+	return verifyAggregate(agg, messages, &signatureKeys{
+		verify:       instance.consumer.verify,
+		blsPublicKey: instance.blsPublicKey,
+	})
 }
 
 func (vc *ViewChange) getSignature() []byte {
@@ -71,3 +113,23 @@ func (vc *ViewChange) setID(id uint64) {
 func (vc *ViewChange) serialize() ([]byte, error) {
 	return pb.Marshal(vc)
 }
+
+func (chkpt *Checkpoint) getSignature() []byte {
+	return chkpt.Signature
+}
+
+func (chkpt *Checkpoint) setSignature(sig []byte) {
+	chkpt.Signature = sig
+}
+
+func (chkpt *Checkpoint) getID() uint64 {
+	return chkpt.ReplicaId
+}
+
+func (chkpt *Checkpoint) setID(id uint64) {
+	chkpt.ReplicaId = id
+}
+
+func (chkpt *Checkpoint) serialize() ([]byte, error) {
+	return pb.Marshal(chkpt)
+}
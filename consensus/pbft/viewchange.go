@@ -275,11 +275,30 @@ func (instance *pbftCore) sendNewView() events.Event {
 		return nil
 	}
 
+	// carry the view-change signatures as one aggregate signature, if that
+	// is smaller than the individual signatures
+	var vsetSignature *AggregateSignature
+	if instance.aggregator.compact() {
+		items := make([]signable, len(vset))
+		for i, vc := range vset {
+			unsigned := *vc
+			vset[i] = &unsigned
+			items[i] = &unsigned
+		}
+		var err error
+		vsetSignature, err = instance.aggregate(items)
+		if err != nil {
+			logger.Warningf("Replica %d could not aggregate view-change signatures: %s", instance.id, err)
+			return nil
+		}
+	}
+
 	nv := &NewView{
-		View:      instance.view,
-		Vset:      vset,
-		Xset:      msgList,
-		ReplicaId: instance.id,
+		View:          instance.view,
+		Vset:          vset,
+		Xset:          msgList,
+		ReplicaId:     instance.id,
+		VsetSignature: vsetSignature,
 	}
 
 	logger.Infof("Replica %d is new primary, sending new-view, v:%d, X:%+v",
@@ -300,11 +319,22 @@ func (instance *pbftCore) recvNewView(nv *NewView) events.Event {
 		return nil
 	}
 
-	for _, vc := range nv.Vset {
-		if err := instance.verify(vc); err != nil {
+	if nv.VsetSignature != nil {
+		items := make([]signable, len(nv.Vset))
+		for i, vc := range nv.Vset {
+			items[i] = vc
+		}
+		if err := instance.verifyAggregate(items, nv.VsetSignature); err != nil {
 			logger.Warningf("Replica %d found incorrect view-change signature in new-view message: %s", instance.id, err)
 			return nil
 		}
+	} else {
+		for _, vc := range nv.Vset {
+			if err := instance.verify(vc); err != nil {
+				logger.Warningf("Replica %d found incorrect view-change signature in new-view message: %s", instance.id, err)
+				return nil
+			}
+		}
 	}
 
 	instance.newViewStore[nv.View] = nv
@@ -0,0 +1,126 @@
+// Code generated by protoc-gen-go.
+// source: messages.proto
+// DO NOT EDIT!
+
+/*
+Package raft is a generated protocol buffer package.
+
+It is generated from these files:
+	messages.proto
+
+It has these top-level messages:
+	Message
+	Entry
+	Snapshot
+	Metadata
+*/
+package raft
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+type Message_Type int32
+
+const (
+	Message_UNDEFINED        Message_Type = 0
+	Message_REQUEST_VOTE     Message_Type = 1
+	Message_VOTE             Message_Type = 2
+	Message_APPEND_ENTRIES   Message_Type = 3
+	Message_APPEND_RESPONSE  Message_Type = 4
+	Message_INSTALL_SNAPSHOT Message_Type = 5
+	Message_REQUEST          Message_Type = 6
+)
+
+var Message_Type_name = map[int32]string{
+	0: "UNDEFINED",
+	1: "REQUEST_VOTE",
+	2: "VOTE",
+	3: "APPEND_ENTRIES",
+	4: "APPEND_RESPONSE",
+	5: "INSTALL_SNAPSHOT",
+	6: "REQUEST",
+}
+var Message_Type_value = map[string]int32{
+	"UNDEFINED":        0,
+	"REQUEST_VOTE":     1,
+	"VOTE":             2,
+	"APPEND_ENTRIES":   3,
+	"APPEND_RESPONSE":  4,
+	"INSTALL_SNAPSHOT": 5,
+	"REQUEST":          6,
+}
+
+func (x Message_Type) String() string {
+	return proto.EnumName(Message_Type_name, int32(x))
+}
+
+type Message struct {
+	Type         Message_Type `protobuf:"varint,1,opt,name=type,enum=raft.Message_Type" json:"type,omitempty"`
+	Term         uint64       `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+	LastLogIndex uint64       `protobuf:"varint,3,opt,name=last_log_index" json:"last_log_index,omitempty"`
+	LastLogTerm  uint64       `protobuf:"varint,4,opt,name=last_log_term" json:"last_log_term,omitempty"`
+	Success      bool         `protobuf:"varint,5,opt,name=success" json:"success,omitempty"`
+	PrevLogIndex uint64       `protobuf:"varint,6,opt,name=prev_log_index" json:"prev_log_index,omitempty"`
+	PrevLogTerm  uint64       `protobuf:"varint,7,opt,name=prev_log_term" json:"prev_log_term,omitempty"`
+	Entries      []*Entry     `protobuf:"bytes,8,rep,name=entries" json:"entries,omitempty"`
+	CommitIndex  uint64       `protobuf:"varint,9,opt,name=commit_index" json:"commit_index,omitempty"`
+	MatchIndex   uint64       `protobuf:"varint,10,opt,name=match_index" json:"match_index,omitempty"`
+	Snapshot     *Snapshot    `protobuf:"bytes,11,opt,name=snapshot" json:"snapshot,omitempty"`
+	Payload      []byte       `protobuf:"bytes,12,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *Message) Reset()         { *m = Message{} }
+func (m *Message) String() string { return proto.CompactTextString(m) }
+func (*Message) ProtoMessage()    {}
+
+func (m *Message) GetEntries() []*Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *Message) GetSnapshot() *Snapshot {
+	if m != nil {
+		return m.Snapshot
+	}
+	return nil
+}
+
+type Entry struct {
+	Term     uint64   `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	Index    uint64   `protobuf:"varint,2,opt,name=index" json:"index,omitempty"`
+	Requests [][]byte `protobuf:"bytes,3,rep,name=requests,proto3" json:"requests,omitempty"`
+}
+
+func (m *Entry) Reset()         { *m = Entry{} }
+func (m *Entry) String() string { return proto.CompactTextString(m) }
+func (*Entry) ProtoMessage()    {}
+
+type Snapshot struct {
+	Index          uint64 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Term           uint64 `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+	BlockchainInfo []byte `protobuf:"bytes,3,opt,name=blockchain_info,proto3" json:"blockchain_info,omitempty"`
+}
+
+func (m *Snapshot) Reset()         { *m = Snapshot{} }
+func (m *Snapshot) String() string { return proto.CompactTextString(m) }
+func (*Snapshot) ProtoMessage()    {}
+
+type Metadata struct {
+	Index uint64 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+}
+
+func (m *Metadata) Reset()         { *m = Metadata{} }
+func (m *Metadata) String() string { return proto.CompactTextString(m) }
+func (*Metadata) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterEnum("raft.Message_Type", Message_Type_name, Message_Type_value)
+}
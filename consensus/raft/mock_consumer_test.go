@@ -0,0 +1,147 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+
+	"github.com/spf13/viper"
+)
+
+type consumerEndpoint struct {
+	*testutil.TestEndpoint
+	consumer *raftCore
+}
+
+func (ce *consumerEndpoint) Stop() {
+	ce.consumer.Close()
+}
+
+func (ce *consumerEndpoint) IsBusy() bool {
+	if ce.consumer.currentExec != nil || ce.consumer.skipInProgress {
+		ce.Net.DebugMsg("Reporting busy because of currentExec (%v) or skipInProgress (%v)\n", ce.consumer.currentExec, ce.consumer.skipInProgress)
+		return true
+	}
+	return false
+}
+
+func (ce *consumerEndpoint) Deliver(msg []byte, senderHandle *pb.PeerID) {
+	ce.consumer.RecvMsg(&pb.Message{Type: pb.Message_CONSENSUS, Payload: msg}, senderHandle)
+}
+
+type completeStack struct {
+	*consumerEndpoint
+	*noopSecurity
+	*testutil.MockLedger
+	mockPersist
+	skipTarget chan struct{}
+}
+
+const MaxStateTransferTime int = 200
+
+func (cs *completeStack) ValidateState()   {}
+func (cs *completeStack) InvalidateState() {}
+func (cs *completeStack) Start()           {}
+func (cs *completeStack) Halt()            {}
+
+func (cs *completeStack) UpdateState(tag interface{}, target *pb.BlockchainInfo, peers []*pb.PeerID) {
+	select {
+	// This guarantees the first SkipTo call is the one that's queued, whereas a mutex can be raced for
+	case cs.skipTarget <- struct{}{}:
+		go func() {
+			// State transfer takes time, not simulating this hides bugs
+			time.Sleep(time.Duration((MaxStateTransferTime/2)+rand.Intn(MaxStateTransferTime/2)) * time.Millisecond)
+			cs.SimulateStateTransfer(target, peers)
+			cs.consumer.StateUpdated(tag, cs.GetBlockchainInfo())
+			<-cs.skipTarget // Basically like releasing a mutex
+		}()
+	default:
+		cs.Net.DebugMsg("Ignoring skipTo because one is already in progress\n")
+	}
+}
+
+type consumerNetwork struct {
+	*testutil.Network
+	mockLedgers []*testutil.MockLedger
+	stacks      []*completeStack
+}
+
+func (cnet *consumerNetwork) GetLedgerByPeerID(peerID *pb.PeerID) (consensus.ReadOnlyLedger, bool) {
+	id, err := getValidatorID(peerID)
+	if nil != err {
+		return nil, false
+	}
+	return cnet.mockLedgers[id], true
+}
+
+// makeConsumerNetwork creates N raft replicas, configFn adjusts the configuration
+// they are created with
+func makeConsumerNetwork(N int, configFn func(*viper.Viper)) *consumerNetwork {
+	twl := consumerNetwork{mockLedgers: make([]*testutil.MockLedger, N), stacks: make([]*completeStack, N)}
+
+	endpointFunc := func(id uint64, net *testutil.Network) testutil.Endpoint {
+		tep := testutil.NewTestEndpoint(id, net)
+		ce := &consumerEndpoint{
+			TestEndpoint: tep,
+		}
+
+		ml := testutil.NewMockLedger(&twl)
+		twl.mockLedgers[id] = ml
+
+		cs := &completeStack{
+			consumerEndpoint: ce,
+			noopSecurity:     &noopSecurity{},
+			MockLedger:       ml,
+			skipTarget:       make(chan struct{}, 1),
+		}
+		twl.stacks[id] = cs
+
+		ce.consumer = newRaftCore(id, makeTestConfig(N, configFn), cs)
+		ml.Consumer = ce.consumer
+
+		return ce
+	}
+
+	twl.Network = testutil.MakeNetwork(N, endpointFunc)
+	return &twl
+}
+
+func makeTestConfig(N int, configFn func(*viper.Viper)) *viper.Viper {
+	config := loadConfig()
+	config.Set("general.N", N)
+	config.Set("general.timeout.batch", "20ms")
+	config.Set("general.timeout.election", "150ms")
+	config.Set("general.timeout.heartbeat", "30ms")
+	if configFn != nil {
+		configFn(config)
+	}
+	return config
+}
+
+// restart replaces the replica with a new one that restores its state from the
+// stack of the old one
+func (cnet *consumerNetwork) restart(id uint64, configFn func(*viper.Viper)) {
+	ce := cnet.Endpoints[id].(*consumerEndpoint)
+	ce.consumer.Close()
+	ce.consumer = newRaftCore(id, makeTestConfig(len(cnet.Endpoints), configFn), cnet.stacks[id])
+	cnet.mockLedgers[id].Consumer = ce.consumer
+}
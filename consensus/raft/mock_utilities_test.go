@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	gp "google/protobuf"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type noopSecurity struct{}
+
+func (ns *noopSecurity) Sign(msg []byte) ([]byte, error) {
+	return nil, nil
+}
+
+func (ns *noopSecurity) Verify(peerID *pb.PeerID, signature []byte, message []byte) error {
+	return nil
+}
+
+type mockPersist struct {
+	store map[string][]byte
+}
+
+func (p *mockPersist) initialize() {
+	if p.store == nil {
+		p.store = make(map[string][]byte)
+	}
+}
+
+func (p *mockPersist) ReadState(key string) ([]byte, error) {
+	p.initialize()
+	if val, ok := p.store[key]; ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("cannot find key %s", key)
+}
+
+func (p *mockPersist) ReadStateSet(prefix string) (map[string][]byte, error) {
+	if p.store == nil {
+		return nil, fmt.Errorf("no state yet")
+	}
+	ret := make(map[string][]byte)
+	for k, v := range p.store {
+		if len(k) >= len(prefix) && k[0:len(prefix)] == prefix {
+			ret[k] = v
+		}
+	}
+	return ret, nil
+}
+
+func (p *mockPersist) StoreState(key string, value []byte) error {
+	p.initialize()
+	p.store[key] = value
+	return nil
+}
+
+func (p *mockPersist) DelState(key string) {
+	p.initialize()
+	delete(p.store, key)
+}
+
+// crashFilter drops every message from or to the replicas marked as crashed
+type crashFilter struct {
+	lock    sync.Mutex
+	crashed map[int]bool
+}
+
+func newCrashFilter() *crashFilter {
+	return &crashFilter{crashed: make(map[int]bool)}
+}
+
+func (cf *crashFilter) set(id uint64, crashed bool) {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+	cf.crashed[int(id)] = crashed
+}
+
+func (cf *crashFilter) filter(src int, dst int, payload []byte) []byte {
+	cf.lock.Lock()
+	defer cf.lock.Unlock()
+	if cf.crashed[src] || (dst >= 0 && cf.crashed[dst]) {
+		return nil
+	}
+	return payload
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(20 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func createTx(tag int64) (tx *pb.Transaction) {
+	txTime := &gp.Timestamp{Seconds: tag, Nanos: 0}
+	tx = &pb.Transaction{Type: pb.Transaction_CHAINCODE_DEPLOY,
+		Timestamp: txTime,
+		Payload:   []byte(fmt.Sprint(tag)),
+	}
+	return
+}
+
+func marshalTx(tx *pb.Transaction) (txPacked []byte) {
+	txPacked, _ = proto.Marshal(tx)
+	return
+}
+
+func createTxMsg(tag int64) *pb.Message {
+	return &pb.Message{Type: pb.Message_CHAIN_TRANSACTION, Payload: marshalTx(createTx(tag))}
+}
@@ -0,0 +1,866 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/consensus/util/events"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+)
+
+// maxAppendEntries is the number of log entries the leader sends at most in one
+// APPEND_ENTRIES message to a follower which is catching up
+const maxAppendEntries = 10
+
+type raftRole int
+
+const (
+	follower raftRole = iota
+	candidate
+	leader
+)
+
+func (r raftRole) String() string {
+	switch r {
+	case follower:
+		return "follower"
+	case candidate:
+		return "candidate"
+	case leader:
+		return "leader"
+	}
+	return fmt.Sprintf("raftRole(%d)", int(r))
+}
+
+// Event types
+
+// electionTimerEvent is sent when a follower or a candidate hears from no leader in time
+type electionTimerEvent struct{}
+
+// heartbeatTimerEvent is sent when the leader should send entries or a heartbeat
+type heartbeatTimerEvent struct{}
+
+// batchTimerEvent is sent when the leader should append the pending requests
+type batchTimerEvent struct{}
+
+type raftCore struct {
+	externalEventReceiver
+
+	// internal data
+	stack consensus.Stack
+	id    uint64 // replica ID; Raft `i`
+	N     int    // number of replicas; Raft `N`
+
+	batchSize        int
+	snapshotInterval uint64
+
+	electionTimeout  time.Duration
+	heartbeatTimeout time.Duration
+	batchTimeout     time.Duration
+	electionTimer    events.Timer
+	heartbeatTimer   events.Timer
+	batchTimer       events.Timer
+	batchTimerActive bool
+
+	// state persisted through the stack
+	term     uint64    // latest term this replica has seen
+	votedFor uint64    // candidate that received the vote of this replica in term
+	voted    bool      // whether votedFor is set
+	snapshot *Snapshot // last compacted entry and the ledger it produced
+	log      []*Entry  // entries that follow the snapshot
+
+	// volatile state
+	role        raftRole
+	leader      uint64 // leader of leaderTerm, if leaderKnown
+	leaderTerm  uint64
+	leaderKnown bool
+	votes       map[uint64]bool // votes a candidate received in term
+	nextIndex   []uint64        // leader only: next entry to send to each replica
+	matchIndex  []uint64        // leader only: highest entry known to be replicated on each replica
+
+	commitIndex    uint64 // highest entry known to be committed
+	lastApplied    uint64 // highest entry applied to the ledger
+	currentExec    *Entry // entry being executed and committed, if any
+	skipInProgress bool   // set while a snapshot is being installed through state transfer
+
+	batchStore  [][]byte          // leader only: requests waiting for the next entry
+	inFlight    map[string]bool   // leader only: requests in the log, or in batchStore
+	outstanding map[string][]byte // requests submitted through this replica, not applied yet
+}
+
+func newRaftCore(id uint64, config *viper.Viper, stack consensus.Stack) *raftCore {
+	var err error
+	instance := &raftCore{}
+	instance.id = id
+	instance.stack = stack
+
+	instance.manager = events.NewManagerImpl()
+	instance.manager.SetReceiver(instance)
+	etf := events.NewTimerFactoryImpl(instance.manager)
+	instance.electionTimer = etf.CreateTimer()
+	instance.heartbeatTimer = etf.CreateTimer()
+	instance.batchTimer = etf.CreateTimer()
+
+	instance.N = config.GetInt("general.N")
+	instance.batchSize = config.GetInt("general.batchsize")
+	instance.snapshotInterval = uint64(config.GetInt("general.snapshotinterval"))
+	instance.batchTimeout, err = time.ParseDuration(config.GetString("general.timeout.batch"))
+	if err != nil {
+		panic(fmt.Errorf("Cannot parse batch timeout: %s", err))
+	}
+	instance.electionTimeout, err = time.ParseDuration(config.GetString("general.timeout.election"))
+	if err != nil {
+		panic(fmt.Errorf("Cannot parse election timeout: %s", err))
+	}
+	instance.heartbeatTimeout, err = time.ParseDuration(config.GetString("general.timeout.heartbeat"))
+	if err != nil {
+		panic(fmt.Errorf("Cannot parse heartbeat timeout: %s", err))
+	}
+	if instance.heartbeatTimeout >= instance.electionTimeout {
+		instance.heartbeatTimeout = instance.electionTimeout / 4
+		logger.Warningf("Configured heartbeat timeout must be less than election timeout, setting to %v", instance.heartbeatTimeout)
+	}
+
+	logger.Infof("Raft replica %d of %d", instance.id, instance.N)
+	logger.Infof("Raft batch size = %d", instance.batchSize)
+	logger.Infof("Raft snapshot interval = %d", instance.snapshotInterval)
+	logger.Infof("Raft batch timeout = %v", instance.batchTimeout)
+	logger.Infof("Raft election timeout = %v", instance.electionTimeout)
+	logger.Infof("Raft heartbeat timeout = %v", instance.heartbeatTimeout)
+
+	instance.votes = make(map[uint64]bool)
+	instance.nextIndex = make([]uint64, instance.N)
+	instance.matchIndex = make([]uint64, instance.N)
+	instance.inFlight = make(map[string]bool)
+	instance.outstanding = make(map[string][]byte)
+
+	instance.restoreState()
+
+	instance.manager.Start()
+	instance.resetElectionTimer()
+
+	return instance
+}
+
+// Close tells us to release resources we are holding
+func (instance *raftCore) Close() {
+	instance.electionTimer.Halt()
+	instance.heartbeatTimer.Halt()
+	instance.batchTimer.Halt()
+	instance.manager.Halt()
+}
+
+// ProcessEvent is the main event handling switch, all events are processed serially
+func (instance *raftCore) ProcessEvent(e events.Event) events.Event {
+	switch et := e.(type) {
+	case messageEvent:
+		return instance.recvMsg(et.msg, et.sender)
+	case electionTimerEvent:
+		if instance.role != leader {
+			instance.startElection()
+		}
+	case heartbeatTimerEvent:
+		if instance.role == leader {
+			instance.sendAppendEntriesAll()
+			instance.heartbeatTimer.Reset(instance.heartbeatTimeout, heartbeatTimerEvent{})
+		}
+	case batchTimerEvent:
+		instance.batchTimerActive = false
+		if instance.role == leader && len(instance.batchStore) > 0 {
+			logger.Debugf("Leader %d batch timer expired", instance.id)
+			instance.appendBatch()
+		}
+	case executedEvent:
+		instance.stack.Commit(nil, et.tag.([]byte))
+	case committedEvent:
+		if instance.currentExec == nil {
+			logger.Warningf("Replica %d received a commit with no execution in progress", instance.id)
+			return nil
+		}
+		logger.Debugf("Replica %d applied entry %d", instance.id, instance.currentExec.Index)
+		instance.lastApplied = instance.currentExec.Index
+		instance.currentExec = nil
+		instance.applyCommitted()
+	case stateUpdatedEvent:
+		instance.stateUpdated(et.snapshot, et.target)
+	default:
+		logger.Warningf("Replica %d received an unknown message type %T", instance.id, et)
+	}
+	return nil
+}
+
+// =============================================================================
+// log helpers
+// =============================================================================
+
+func (instance *raftCore) lastLogIndex() uint64 {
+	if len(instance.log) > 0 {
+		return instance.log[len(instance.log)-1].Index
+	}
+	return instance.snapshot.Index
+}
+
+func (instance *raftCore) lastLogTerm() uint64 {
+	if len(instance.log) > 0 {
+		return instance.log[len(instance.log)-1].Term
+	}
+	return instance.snapshot.Term
+}
+
+// entry returns the entry at index, or nil if it is compacted or not in the log
+func (instance *raftCore) entry(index uint64) *Entry {
+	if index <= instance.snapshot.Index || index > instance.lastLogIndex() {
+		return nil
+	}
+	return instance.log[index-instance.snapshot.Index-1]
+}
+
+// termAt returns the term of the entry at index, if it is known
+func (instance *raftCore) termAt(index uint64) (uint64, bool) {
+	if index == instance.snapshot.Index {
+		return instance.snapshot.Term, true
+	}
+	if entry := instance.entry(index); entry != nil {
+		return entry.Term, true
+	}
+	return 0, false
+}
+
+func (instance *raftCore) appendEntry(entry *Entry) {
+	instance.persistEntry(entry)
+	instance.log = append(instance.log, entry)
+}
+
+// truncateLog removes the entries from index on, which must not be committed
+func (instance *raftCore) truncateLog(index uint64) {
+	for i := index; i <= instance.lastLogIndex(); i++ {
+		instance.persistDelEntry(i)
+	}
+	instance.log = instance.log[:index-instance.snapshot.Index-1]
+}
+
+// upToDate reports whether a log ending with lastTerm and lastIndex is at least as up
+// to date as the log of this replica
+func (instance *raftCore) upToDate(lastTerm, lastIndex uint64) bool {
+	if lastTerm != instance.lastLogTerm() {
+		return lastTerm > instance.lastLogTerm()
+	}
+	return lastIndex >= instance.lastLogIndex()
+}
+
+func digest(req []byte) string {
+	return base64.StdEncoding.EncodeToString(util.ComputeCryptoHash(req))
+}
+
+// =============================================================================
+// messaging
+// =============================================================================
+
+func (instance *raftCore) wrapMessage(msg *Message) *pb.Message {
+	msgPayload, _ := proto.Marshal(msg)
+	return &pb.Message{
+		Type:    pb.Message_CONSENSUS,
+		Payload: msgPayload,
+	}
+}
+
+func (instance *raftCore) broadcast(msg *Message) {
+	msg.Term = instance.term
+	if err := instance.stack.Broadcast(instance.wrapMessage(msg), pb.PeerEndpoint_VALIDATOR); err != nil {
+		logger.Warningf("Replica %d could not broadcast %s: %s", instance.id, msg.Type, err)
+	}
+}
+
+func (instance *raftCore) unicast(msg *Message, receiverID uint64) {
+	msg.Term = instance.term
+	if err := instance.stack.Unicast(instance.wrapMessage(msg), getValidatorHandle(receiverID)); err != nil {
+		logger.Warningf("Replica %d could not send %s to replica %d: %s", instance.id, msg.Type, receiverID, err)
+	}
+}
+
+func (instance *raftCore) recvMsg(ocMsg *pb.Message, senderHandle *pb.PeerID) events.Event {
+	if ocMsg.Type == pb.Message_CHAIN_TRANSACTION {
+		instance.submit(ocMsg.Payload)
+		return nil
+	}
+
+	if ocMsg.Type != pb.Message_CONSENSUS {
+		logger.Errorf("Unexpected message type: %s", ocMsg.Type)
+		return nil
+	}
+
+	senderID, err := getValidatorID(senderHandle)
+	if err != nil {
+		logger.Errorf("Replica %d received a message from an unknown sender: %s", instance.id, err)
+		return nil
+	}
+	if senderID >= uint64(instance.N) {
+		logger.Errorf("Replica %d received a message from replica %d, which is not one of the %d replicas", instance.id, senderID, instance.N)
+		return nil
+	}
+
+	msg := &Message{}
+	if err = proto.Unmarshal(ocMsg.Payload, msg); err != nil {
+		logger.Errorf("Error unpacking payload from message: %s", err)
+		return nil
+	}
+
+	if msg.Type == Message_REQUEST {
+		instance.recvRequest(msg.Payload, senderID)
+		return nil
+	}
+
+	if msg.Term > instance.term {
+		logger.Infof("Replica %d received %s of term %d from replica %d, leaving term %d",
+			instance.id, msg.Type, msg.Term, senderID, instance.term)
+		instance.stepDown(msg.Term)
+	}
+
+	switch msg.Type {
+	case Message_REQUEST_VOTE:
+		instance.recvRequestVote(msg, senderID)
+	case Message_VOTE:
+		instance.recvVote(msg, senderID)
+	case Message_APPEND_ENTRIES:
+		instance.recvAppendEntries(msg, senderID)
+	case Message_APPEND_RESPONSE:
+		instance.recvAppendResponse(msg, senderID)
+	case Message_INSTALL_SNAPSHOT:
+		instance.recvInstallSnapshot(msg, senderID)
+	default:
+		logger.Errorf("Replica %d received a message of unknown type %s", instance.id, msg.Type)
+	}
+	return nil
+}
+
+// =============================================================================
+// requests
+// =============================================================================
+
+// submit keeps a transaction outstanding until it is applied, and hands it to the
+// leader. It is handed again when a leader of a new term is known
+func (instance *raftCore) submit(req []byte) {
+	d := digest(req)
+	if _, ok := instance.outstanding[d]; ok {
+		logger.Debugf("Replica %d already has request %s outstanding", instance.id, d)
+		return
+	}
+	instance.outstanding[d] = req
+	instance.forward(req)
+}
+
+func (instance *raftCore) forward(req []byte) {
+	if instance.role == leader {
+		instance.leaderProcReq(req)
+		return
+	}
+	if instance.leaderKnown {
+		instance.unicast(&Message{Type: Message_REQUEST, Payload: req}, instance.leader)
+		return
+	}
+	logger.Debugf("Replica %d keeps request %s until a leader is known", instance.id, digest(req))
+}
+
+func (instance *raftCore) resubmitOutstanding() {
+	for _, req := range instance.outstanding {
+		instance.forward(req)
+	}
+}
+
+func (instance *raftCore) recvRequest(req []byte, senderID uint64) {
+	if instance.role != leader {
+		// the sender hands the request to the new leader once it knows it
+		logger.Debugf("Replica %d is not the leader, ignoring request from replica %d", instance.id, senderID)
+		return
+	}
+	instance.leaderProcReq(req)
+}
+
+func (instance *raftCore) leaderProcReq(req []byte) {
+	d := digest(req)
+	if instance.inFlight[d] {
+		logger.Debugf("Leader %d already has request %s", instance.id, d)
+		return
+	}
+	logger.Debugf("Leader %d queueing new request %s", instance.id, d)
+	instance.inFlight[d] = true
+	instance.batchStore = append(instance.batchStore, req)
+
+	if len(instance.batchStore) >= instance.batchSize {
+		instance.appendBatch()
+		return
+	}
+	if !instance.batchTimerActive {
+		instance.batchTimerActive = true
+		instance.batchTimer.Reset(instance.batchTimeout, batchTimerEvent{})
+	}
+}
+
+func (instance *raftCore) stopBatchTimer() {
+	instance.batchTimer.Stop()
+	instance.batchTimerActive = false
+}
+
+// appendBatch appends the pending requests to the log as a new entry
+func (instance *raftCore) appendBatch() {
+	instance.stopBatchTimer()
+	if len(instance.batchStore) == 0 {
+		return
+	}
+	entry := &Entry{
+		Term:     instance.term,
+		Index:    instance.lastLogIndex() + 1,
+		Requests: instance.batchStore,
+	}
+	instance.batchStore = nil
+	logger.Infof("Leader %d appending entry %d with %d requests", instance.id, entry.Index, len(entry.Requests))
+	instance.leaderAppend(entry)
+}
+
+func (instance *raftCore) leaderAppend(entry *Entry) {
+	instance.appendEntry(entry)
+	instance.matchIndex[instance.id] = entry.Index
+	instance.sendAppendEntriesAll()
+	instance.advanceCommitIndex()
+}
+
+// =============================================================================
+// leader election
+// =============================================================================
+
+func (instance *raftCore) electionTimeoutWithJitter() time.Duration {
+	return instance.electionTimeout + time.Duration(rand.Int63n(int64(instance.electionTimeout)))
+}
+
+func (instance *raftCore) resetElectionTimer() {
+	instance.electionTimer.Reset(instance.electionTimeoutWithJitter(), electionTimerEvent{})
+}
+
+// stepDown makes this replica a follower, in a newer term if term is greater
+func (instance *raftCore) stepDown(term uint64) {
+	if term > instance.term {
+		instance.term = term
+		instance.voted = false
+		instance.persistVote()
+	}
+	if instance.role == leader {
+		instance.heartbeatTimer.Stop()
+		instance.stopBatchTimer()
+		instance.batchStore = nil
+	}
+	instance.role = follower
+	instance.resetElectionTimer()
+}
+
+func (instance *raftCore) startElection() {
+	instance.term++
+	instance.role = candidate
+	instance.votedFor = instance.id
+	instance.voted = true
+	instance.persistVote()
+	instance.votes = map[uint64]bool{instance.id: true}
+	instance.leaderKnown = false
+	instance.resetElectionTimer()
+
+	logger.Infof("Replica %d starting election for term %d", instance.id, instance.term)
+	if instance.hasMajority(len(instance.votes)) {
+		instance.becomeLeader()
+		return
+	}
+	instance.broadcast(&Message{
+		Type:         Message_REQUEST_VOTE,
+		LastLogIndex: instance.lastLogIndex(),
+		LastLogTerm:  instance.lastLogTerm(),
+	})
+}
+
+func (instance *raftCore) hasMajority(count int) bool {
+	return count > instance.N/2
+}
+
+func (instance *raftCore) recvRequestVote(msg *Message, senderID uint64) {
+	grant := msg.Term == instance.term &&
+		(!instance.voted || instance.votedFor == senderID) &&
+		instance.upToDate(msg.LastLogTerm, msg.LastLogIndex)
+	if grant {
+		logger.Debugf("Replica %d voting for replica %d in term %d", instance.id, senderID, instance.term)
+		instance.votedFor = senderID
+		instance.voted = true
+		instance.persistVote()
+		instance.resetElectionTimer()
+	}
+	instance.unicast(&Message{Type: Message_VOTE, Success: grant}, senderID)
+}
+
+func (instance *raftCore) recvVote(msg *Message, senderID uint64) {
+	if instance.role != candidate || msg.Term != instance.term || !msg.Success {
+		return
+	}
+	instance.votes[senderID] = true
+	if instance.hasMajority(len(instance.votes)) {
+		instance.becomeLeader()
+	}
+}
+
+func (instance *raftCore) becomeLeader() {
+	logger.Infof("Replica %d is the leader of term %d", instance.id, instance.term)
+	instance.role = leader
+	instance.electionTimer.Stop()
+
+	for i := range instance.nextIndex {
+		instance.nextIndex[i] = instance.lastLogIndex() + 1
+		instance.matchIndex[i] = 0
+	}
+
+	instance.batchStore = nil
+	instance.inFlight = make(map[string]bool)
+	for _, entry := range instance.log {
+		for _, req := range entry.Requests {
+			instance.inFlight[digest(req)] = true
+		}
+	}
+
+	// Entries of earlier terms are committed once an entry of this term is, so
+	// start the term with an empty entry
+	instance.leaderAppend(&Entry{Term: instance.term, Index: instance.lastLogIndex() + 1})
+	instance.heartbeatTimer.Reset(instance.heartbeatTimeout, heartbeatTimerEvent{})
+
+	// queues the outstanding requests of this replica
+	instance.noteLeader(instance.id)
+}
+
+// noteLeader records the leader of the current term, the outstanding requests are
+// handed to it when it is new
+func (instance *raftCore) noteLeader(id uint64) {
+	if instance.leaderKnown && instance.leader == id && instance.leaderTerm == instance.term {
+		return
+	}
+	instance.leader = id
+	instance.leaderTerm = instance.term
+	instance.leaderKnown = true
+	logger.Debugf("Replica %d follows leader %d in term %d", instance.id, id, instance.term)
+	instance.resubmitOutstanding()
+}
+
+// =============================================================================
+// log replication
+// =============================================================================
+
+func (instance *raftCore) sendAppendEntriesAll() {
+	for i := 0; i < instance.N; i++ {
+		if uint64(i) != instance.id {
+			instance.sendAppendEntries(uint64(i))
+		}
+	}
+}
+
+func (instance *raftCore) sendAppendEntries(receiverID uint64) {
+	next := instance.nextIndex[receiverID]
+	if next <= instance.snapshot.Index {
+		logger.Debugf("Leader %d sending snapshot %d to replica %d", instance.id, instance.snapshot.Index, receiverID)
+		instance.unicast(&Message{Type: Message_INSTALL_SNAPSHOT, Snapshot: instance.snapshot}, receiverID)
+		return
+	}
+
+	prevTerm, _ := instance.termAt(next - 1)
+	var entries []*Entry
+	for i := next; i <= instance.lastLogIndex() && len(entries) < maxAppendEntries; i++ {
+		entries = append(entries, instance.entry(i))
+	}
+	instance.unicast(&Message{
+		Type:         Message_APPEND_ENTRIES,
+		PrevLogIndex: next - 1,
+		PrevLogTerm:  prevTerm,
+		Entries:      entries,
+		CommitIndex:  instance.commitIndex,
+	}, receiverID)
+}
+
+func (instance *raftCore) recvAppendEntries(msg *Message, senderID uint64) {
+	if msg.Term < instance.term {
+		instance.unicast(&Message{Type: Message_APPEND_RESPONSE, MatchIndex: instance.lastLogIndex()}, senderID)
+		return
+	}
+	if instance.role == leader {
+		logger.Errorf("Replica %d is the leader of term %d, but replica %d sent it entries", instance.id, instance.term, senderID)
+		return
+	}
+	instance.stepDown(msg.Term)
+	instance.noteLeader(senderID)
+
+	if instance.skipInProgress {
+		logger.Debugf("Replica %d is installing a snapshot, ignoring entries", instance.id)
+		return
+	}
+
+	if msg.PrevLogIndex > instance.lastLogIndex() {
+		instance.unicast(&Message{Type: Message_APPEND_RESPONSE, MatchIndex: instance.lastLogIndex()}, senderID)
+		return
+	}
+	if term, ok := instance.termAt(msg.PrevLogIndex); ok && term != msg.PrevLogTerm {
+		// committed entries are in the log of the leader
+		instance.unicast(&Message{Type: Message_APPEND_RESPONSE, MatchIndex: instance.commitIndex}, senderID)
+		return
+	}
+
+	for _, entry := range msg.Entries {
+		if entry.Index <= instance.snapshot.Index {
+			continue
+		}
+		if term, ok := instance.termAt(entry.Index); ok {
+			if term == entry.Term {
+				continue
+			}
+			if entry.Index <= instance.commitIndex {
+				logger.Errorf("Replica %d was sent entry %d of term %d, which conflicts with a committed entry", instance.id, entry.Index, entry.Term)
+				return
+			}
+			logger.Infof("Replica %d removing conflicting entries from %d on", instance.id, entry.Index)
+			instance.truncateLog(entry.Index)
+		}
+		instance.appendEntry(entry)
+	}
+
+	lastNew := msg.PrevLogIndex + uint64(len(msg.Entries))
+	if msg.CommitIndex > instance.commitIndex {
+		instance.commitIndex = msg.CommitIndex
+		if lastNew < instance.commitIndex {
+			instance.commitIndex = lastNew
+		}
+		instance.applyCommitted()
+	}
+	instance.unicast(&Message{Type: Message_APPEND_RESPONSE, Success: true, MatchIndex: lastNew}, senderID)
+}
+
+func (instance *raftCore) recvAppendResponse(msg *Message, senderID uint64) {
+	if instance.role != leader || msg.Term != instance.term {
+		return
+	}
+	if msg.Success {
+		if msg.MatchIndex > instance.matchIndex[senderID] {
+			instance.matchIndex[senderID] = msg.MatchIndex
+		}
+		if msg.MatchIndex+1 > instance.nextIndex[senderID] {
+			instance.nextIndex[senderID] = msg.MatchIndex + 1
+		}
+		instance.advanceCommitIndex()
+		if instance.nextIndex[senderID] <= instance.lastLogIndex() {
+			instance.sendAppendEntries(senderID)
+		}
+		return
+	}
+
+	// the follower hints at the last entry it may have in common with this replica
+	next := msg.MatchIndex + 1
+	if next > instance.lastLogIndex()+1 {
+		next = instance.lastLogIndex() + 1
+	}
+	instance.nextIndex[senderID] = next
+	instance.sendAppendEntries(senderID)
+}
+
+// advanceCommitIndex commits the entries of this term which a majority has
+func (instance *raftCore) advanceCommitIndex() {
+	for index := instance.lastLogIndex(); index > instance.commitIndex; index-- {
+		if term, _ := instance.termAt(index); term != instance.term {
+			return
+		}
+		count := 0
+		for _, match := range instance.matchIndex {
+			if match >= index {
+				count++
+			}
+		}
+		if instance.hasMajority(count) {
+			logger.Debugf("Leader %d committing entries up to %d", instance.id, index)
+			instance.commitIndex = index
+			instance.applyCommitted()
+			return
+		}
+	}
+}
+
+// =============================================================================
+// applying entries
+// =============================================================================
+
+// applyCommitted executes the next committed entry, unless one is in progress
+func (instance *raftCore) applyCommitted() {
+	if instance.currentExec != nil || instance.skipInProgress {
+		return
+	}
+	for instance.lastApplied < instance.commitIndex {
+		entry := instance.entry(instance.lastApplied + 1)
+		if entry == nil {
+			logger.Errorf("Replica %d is missing committed entry %d", instance.id, instance.lastApplied+1)
+			return
+		}
+		if len(entry.Requests) == 0 {
+			instance.lastApplied = entry.Index
+			continue
+		}
+		instance.execute(entry)
+		return
+	}
+	instance.maybeSnapshot()
+}
+
+func (instance *raftCore) execute(entry *Entry) {
+	var txs []*pb.Transaction
+	for _, req := range entry.Requests {
+		delete(instance.outstanding, digest(req))
+		tx := &pb.Transaction{}
+		if err := proto.Unmarshal(req, tx); err != nil {
+			logger.Warningf("Replica %d could not unmarshal transaction %s", instance.id, err)
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	meta, _ := proto.Marshal(&Metadata{Index: entry.Index})
+	logger.Debugf("Replica %d executing entry %d containing %d transactions", instance.id, entry.Index, len(txs))
+	instance.currentExec = entry
+	instance.stack.Execute(meta, txs) // This executes in the background, we will receive an executedEvent once it completes
+}
+
+// =============================================================================
+// snapshots
+// =============================================================================
+
+// maybeSnapshot compacts the applied entries once there are snapshotInterval of them.
+// The ledger holds their result, so the snapshot only records the blockchain info
+func (instance *raftCore) maybeSnapshot() {
+	if instance.snapshotInterval == 0 || instance.lastApplied-instance.snapshot.Index < instance.snapshotInterval {
+		return
+	}
+	term, _ := instance.termAt(instance.lastApplied)
+	snapshot := &Snapshot{
+		Index:          instance.lastApplied,
+		Term:           term,
+		BlockchainInfo: instance.stack.GetBlockchainInfoBlob(),
+	}
+	logger.Infof("Replica %d taking snapshot at entry %d", instance.id, snapshot.Index)
+	instance.compact(snapshot)
+}
+
+// compact replaces the entries up to the snapshot with it, and drops the following
+// ones if they do not extend it
+func (instance *raftCore) compact(snapshot *Snapshot) {
+	var kept []*Entry
+	if term, ok := instance.termAt(snapshot.Index); ok && term == snapshot.Term {
+		for i := snapshot.Index + 1; i <= instance.lastLogIndex(); i++ {
+			kept = append(kept, instance.entry(i))
+		}
+	} else {
+		for i := snapshot.Index + 1; i <= instance.lastLogIndex(); i++ {
+			instance.persistDelEntry(i)
+		}
+	}
+
+	for _, entry := range instance.log {
+		if entry.Index > snapshot.Index {
+			break
+		}
+		instance.persistDelEntry(entry.Index)
+		for _, req := range entry.Requests {
+			delete(instance.inFlight, digest(req))
+		}
+	}
+
+	instance.snapshot = snapshot
+	instance.persistSnapshot()
+	instance.log = kept
+}
+
+func (instance *raftCore) recvInstallSnapshot(msg *Message, senderID uint64) {
+	if msg.Term < instance.term {
+		instance.unicast(&Message{Type: Message_APPEND_RESPONSE, MatchIndex: instance.lastLogIndex()}, senderID)
+		return
+	}
+	if instance.role == leader {
+		logger.Errorf("Replica %d is the leader of term %d, but replica %d sent it a snapshot", instance.id, instance.term, senderID)
+		return
+	}
+	instance.stepDown(msg.Term)
+	instance.noteLeader(senderID)
+
+	snapshot := msg.Snapshot
+	if snapshot == nil {
+		logger.Warningf("Replica %d was sent an empty snapshot by replica %d", instance.id, senderID)
+		return
+	}
+	if snapshot.Index <= instance.lastApplied {
+		instance.unicast(&Message{Type: Message_APPEND_RESPONSE, Success: true, MatchIndex: snapshot.Index}, senderID)
+		return
+	}
+	if instance.skipInProgress || instance.currentExec != nil {
+		// the leader sends the snapshot again with its next heartbeat
+		return
+	}
+
+	target := &pb.BlockchainInfo{}
+	if err := proto.Unmarshal(snapshot.BlockchainInfo, target); err != nil {
+		logger.Errorf("Replica %d could not unmarshal the blockchain info of snapshot %d: %s", instance.id, snapshot.Index, err)
+		return
+	}
+	if instance.stack.GetBlockchainSize() >= target.Height {
+		// the ledger already holds the result of the compacted entries
+		instance.installSnapshot(snapshot)
+		return
+	}
+
+	logger.Infof("Replica %d installing snapshot %d through state transfer to block height %d", instance.id, snapshot.Index, target.Height)
+	instance.skipInProgress = true
+	instance.stack.InvalidateState()
+	instance.stack.UpdateState(snapshot, target, []*pb.PeerID{getValidatorHandle(senderID)})
+}
+
+func (instance *raftCore) stateUpdated(snapshot *Snapshot, target *pb.BlockchainInfo) {
+	instance.skipInProgress = false
+	if target == nil {
+		logger.Warningf("Replica %d could not install snapshot %d, waiting for the leader to send it again", instance.id, snapshot.Index)
+		return
+	}
+	instance.stack.ValidateState()
+	// The replica may have forwarded requests that are in the snapshot, it cannot
+	// tell which, so it does not hand them to the leader again
+	instance.outstanding = make(map[string][]byte)
+	instance.installSnapshot(snapshot)
+}
+
+// installSnapshot takes a snapshot whose result is in the ledger as the state of
+// this replica
+func (instance *raftCore) installSnapshot(snapshot *Snapshot) {
+	logger.Infof("Replica %d installed snapshot %d", instance.id, snapshot.Index)
+	instance.compact(snapshot)
+	instance.lastApplied = snapshot.Index
+	if instance.commitIndex < snapshot.Index {
+		instance.commitIndex = snapshot.Index
+	}
+	if instance.leaderKnown {
+		instance.unicast(&Message{Type: Message_APPEND_RESPONSE, Success: true, MatchIndex: snapshot.Index}, instance.leader)
+	}
+	instance.applyCommitted()
+}
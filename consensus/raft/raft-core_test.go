@@ -0,0 +1,307 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+)
+
+func (net *consumerNetwork) replica(id uint64) *raftCore {
+	return net.Endpoints[id].(*consumerEndpoint).consumer
+}
+
+// leaderOf returns the only leader among the replicas which are not crashed
+func (net *consumerNetwork) leaderOf(crashed ...uint64) (uint64, bool) {
+	var leaders []uint64
+outer:
+	for i := range net.Endpoints {
+		for _, c := range crashed {
+			if c == uint64(i) {
+				continue outer
+			}
+		}
+		if net.replica(uint64(i)).role == leader {
+			leaders = append(leaders, uint64(i))
+		}
+	}
+	if len(leaders) != 1 {
+		return 0, false
+	}
+	return leaders[0], true
+}
+
+func (net *consumerNetwork) waitForLeader(t *testing.T, crashed ...uint64) uint64 {
+	var id uint64
+	waitFor(t, "a leader", func() bool {
+		var ok bool
+		id, ok = net.leaderOf(crashed...)
+		return ok
+	})
+	return id
+}
+
+func (net *consumerNetwork) waitForHeight(t *testing.T, height uint64, ids ...uint64) {
+	waitFor(t, "the ledgers to catch up", func() bool {
+		for _, id := range ids {
+			if net.mockLedgers[id].GetBlockchainSize() < height {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (net *consumerNetwork) assertSameBlocks(t *testing.T, height uint64, ids ...uint64) {
+	for n := uint64(1); n < height; n++ {
+		expected, err := net.mockLedgers[ids[0]].GetBlock(n)
+		if err != nil {
+			t.Fatalf("Replica %d is missing block %d", ids[0], n)
+		}
+		expectedHash, _ := expected.GetHash()
+		for _, id := range ids[1:] {
+			block, err := net.mockLedgers[id].GetBlock(n)
+			if err != nil {
+				t.Fatalf("Replica %d is missing block %d", id, n)
+			}
+			hash, _ := block.GetHash()
+			if !bytes.Equal(expectedHash, hash) {
+				t.Errorf("Block %d of replica %d differs from the one of replica %d", n, id, ids[0])
+			}
+		}
+	}
+}
+
+func TestRaftElectsOneLeader(t *testing.T) {
+	net := makeConsumerNetwork(3, nil)
+	defer net.Stop()
+	go net.ProcessContinually()
+
+	leaderID := net.waitForLeader(t)
+	term := net.replica(leaderID).term
+	waitFor(t, "the followers to know the leader", func() bool {
+		for i := range net.Endpoints {
+			r := net.replica(uint64(i))
+			if !r.leaderKnown || r.leader != leaderID || r.term != term {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestRaftReplicatesRequests(t *testing.T) {
+	net := makeConsumerNetwork(3, func(config *viper.Viper) {
+		config.Set("general.batchsize", 2)
+	})
+	defer net.Stop()
+	go net.ProcessContinually()
+
+	leaderID := net.waitForLeader(t)
+	followerID := (leaderID + 1) % 3
+	for i := int64(1); i <= 4; i++ {
+		net.replica(followerID).RecvMsg(createTxMsg(i), net.Endpoints[followerID].GetHandle())
+	}
+
+	net.waitForHeight(t, 3, 0, 1, 2)
+	net.assertSameBlocks(t, 3, 0, 1, 2)
+	for i := range net.Endpoints {
+		if size := net.mockLedgers[i].GetBlockchainSize(); size != 3 {
+			t.Errorf("Replica %d has %d blocks, expected 3", i, size)
+		}
+	}
+}
+
+func TestRaftLeaderCrash(t *testing.T) {
+	net := makeConsumerNetwork(3, nil)
+	cf := newCrashFilter()
+	net.FilterFn = cf.filter
+	defer net.Stop()
+	go net.ProcessContinually()
+
+	oldLeader := net.waitForLeader(t)
+	oldTerm := net.replica(oldLeader).term
+	cf.set(oldLeader, true)
+
+	newLeader := net.waitForLeader(t, oldLeader)
+	if newLeader == oldLeader {
+		t.Fatalf("Expected a new leader after replica %d crashed", oldLeader)
+	}
+	if net.replica(newLeader).term <= oldTerm {
+		t.Fatalf("Expected the new leader to have a term greater than %d", oldTerm)
+	}
+
+	var live []uint64
+	for i := range net.Endpoints {
+		if uint64(i) != oldLeader {
+			live = append(live, uint64(i))
+		}
+	}
+	follower := live[0]
+	if follower == newLeader {
+		follower = live[1]
+	}
+	net.replica(follower).RecvMsg(createTxMsg(1), net.Endpoints[follower].GetHandle())
+	net.waitForHeight(t, 2, live...)
+	net.assertSameBlocks(t, 2, live...)
+
+	cf.set(oldLeader, false)
+	net.waitForHeight(t, 2, oldLeader)
+	net.assertSameBlocks(t, 2, 0, 1, 2)
+}
+
+func TestRaftSnapshotCatchUp(t *testing.T) {
+	net := makeConsumerNetwork(3, func(config *viper.Viper) {
+		config.Set("general.batchsize", 1)
+		config.Set("general.snapshotinterval", 3)
+	})
+	cf := newCrashFilter()
+	net.FilterFn = cf.filter
+	defer net.Stop()
+	go net.ProcessContinually()
+
+	leaderID := net.waitForLeader(t)
+	lagging := (leaderID + 1) % 3
+	cf.set(lagging, true)
+
+	for i := int64(1); i <= 6; i++ {
+		net.replica(leaderID).RecvMsg(createTxMsg(i), net.Endpoints[leaderID].GetHandle())
+	}
+	net.waitForHeight(t, 7, leaderID)
+	waitFor(t, "the leader to compact its log", func() bool {
+		return net.replica(leaderID).snapshot.Index >= 3
+	})
+
+	cf.set(lagging, false)
+	net.waitForHeight(t, 7, 0, 1, 2)
+	net.assertSameBlocks(t, 7, 0, 1, 2)
+	if net.replica(lagging).snapshot.Index == 0 {
+		t.Errorf("Expected replica %d to catch up through a snapshot", lagging)
+	}
+}
+
+func TestRaftRestart(t *testing.T) {
+	net := makeConsumerNetwork(1, nil)
+	defer net.Stop()
+	go net.ProcessContinually()
+
+	net.waitForLeader(t)
+	net.replica(0).RecvMsg(createTxMsg(1), net.Endpoints[0].GetHandle())
+	net.replica(0).RecvMsg(createTxMsg(2), net.Endpoints[0].GetHandle())
+	net.waitForHeight(t, 2, 0)
+	waitFor(t, "the replica to be idle", func() bool {
+		return !net.Endpoints[0].IsBusy()
+	})
+
+	old := net.replica(0)
+	term, lastIndex, lastApplied := old.term, old.lastLogIndex(), old.lastApplied
+	net.restart(0, nil)
+	r := net.replica(0)
+	if r.term < term || r.lastLogIndex() != lastIndex || r.lastApplied != lastApplied {
+		t.Fatalf("Expected term %d, last index %d, last applied %d, got %d, %d, %d",
+			term, lastIndex, lastApplied, r.term, r.lastLogIndex(), r.lastApplied)
+	}
+
+	net.waitForLeader(t)
+	height := net.mockLedgers[0].GetBlockchainSize()
+	r.RecvMsg(createTxMsg(3), net.Endpoints[0].GetHandle())
+	net.waitForHeight(t, height+1, 0)
+
+	raw, _ := net.mockLedgers[0].GetBlockHeadMetadata()
+	meta := &Metadata{}
+	if err := proto.Unmarshal(raw, meta); err != nil {
+		t.Fatalf("Could not unmarshal the metadata of the last block: %s", err)
+	}
+	if meta.Index <= lastApplied {
+		t.Errorf("Expected the entry of the new block to follow entry %d, got %d", lastApplied, meta.Index)
+	}
+}
+
+func TestRaftTruncatesConflictingEntries(t *testing.T) {
+	net := makeConsumerNetwork(3, func(config *viper.Viper) {
+		config.Set("general.timeout.election", "1h")
+	})
+	defer net.Stop()
+	r := net.replica(0)
+
+	r.term = 1
+	for i := uint64(1); i <= 3; i++ {
+		r.appendEntry(&Entry{Term: 1, Index: i})
+	}
+	r.commitIndex = 1
+
+	r.recvAppendEntries(&Message{
+		Type:         Message_APPEND_ENTRIES,
+		Term:         2,
+		PrevLogIndex: 1,
+		PrevLogTerm:  1,
+		Entries:      []*Entry{{Term: 2, Index: 2}},
+		CommitIndex:  1,
+	}, 1)
+
+	if r.lastLogIndex() != 2 || r.entry(2).Term != 2 {
+		t.Fatalf("Expected the log to end with entry 2 of term 2, got %+v", r.log)
+	}
+	if r.term != 2 || !r.leaderKnown || r.leader != 1 {
+		t.Errorf("Expected replica 1 to be the known leader of term 2")
+	}
+
+	store := net.stacks[0].store
+	if _, ok := store[logKey(3)]; ok {
+		t.Errorf("Expected the persisted entry 3 to be removed")
+	}
+	entry := &Entry{}
+	if err := proto.Unmarshal(store[logKey(2)], entry); err != nil || entry.Term != 2 {
+		t.Errorf("Expected entry 2 of term 2 to be persisted, got %+v (%v)", entry, err)
+	}
+}
+
+func TestRaftVotesForUpToDateLogOnly(t *testing.T) {
+	net := makeConsumerNetwork(3, func(config *viper.Viper) {
+		config.Set("general.timeout.election", "1h")
+	})
+	defer net.Stop()
+	r := net.replica(0)
+
+	r.term = 2
+	r.appendEntry(&Entry{Term: 2, Index: 1})
+
+	r.recvRequestVote(&Message{Type: Message_REQUEST_VOTE, Term: 2, LastLogIndex: 5, LastLogTerm: 1}, 1)
+	if r.voted {
+		t.Fatalf("Expected no vote for a candidate whose log ends with an older term")
+	}
+
+	r.recvRequestVote(&Message{Type: Message_REQUEST_VOTE, Term: 2, LastLogIndex: 1, LastLogTerm: 2}, 1)
+	if !r.voted || r.votedFor != 1 {
+		t.Fatalf("Expected a vote for replica 1")
+	}
+
+	r.recvRequestVote(&Message{Type: Message_REQUEST_VOTE, Term: 2, LastLogIndex: 1, LastLogTerm: 2}, 2)
+	if r.votedFor != 1 {
+		t.Fatalf("Expected a single vote in a term")
+	}
+
+	restored := &raftCore{id: 0, stack: net.stacks[0]}
+	restored.restoreState()
+	if restored.term != 2 || !restored.voted || restored.votedFor != 1 || restored.lastLogIndex() != 1 {
+		t.Errorf("Expected the term, the vote and the log to be restored, got term %d, vote %v for %d, last index %d",
+			restored.term, restored.voted, restored.votedFor, restored.lastLogIndex())
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// The term, the vote and the log of a replica survive a crash in the consensus state
+// of the stack, i.e. through persist.Helper, under these keys. The entries that are
+// applied are in the ledger, the consensus metadata of a block is the index of its
+// entry, so a restarted replica carries on from the last block
+const (
+	termKey      = "raft.term"
+	votedForKey  = "raft.votedFor"
+	snapshotKey  = "raft.snapshot"
+	logKeyPrefix = "raft.log."
+)
+
+func logKey(index uint64) string {
+	return fmt.Sprintf("%s%d", logKeyPrefix, index)
+}
+
+// persistVote stores the term and the vote, which must be stored before the replica
+// sends a message of the term or its vote
+func (instance *raftCore) persistVote() {
+	if err := instance.stack.StoreState(termKey, []byte(strconv.FormatUint(instance.term, 10))); err != nil {
+		logger.Warningf("Replica %d could not persist term %d: %s", instance.id, instance.term, err)
+	}
+	if !instance.voted {
+		instance.stack.DelState(votedForKey)
+		return
+	}
+	if err := instance.stack.StoreState(votedForKey, []byte(strconv.FormatUint(instance.votedFor, 10))); err != nil {
+		logger.Warningf("Replica %d could not persist its vote: %s", instance.id, err)
+	}
+}
+
+func (instance *raftCore) persistEntry(entry *Entry) {
+	raw, err := proto.Marshal(entry)
+	if err != nil {
+		logger.Warningf("Replica %d could not persist entry %d: %s", instance.id, entry.Index, err)
+		return
+	}
+	if err = instance.stack.StoreState(logKey(entry.Index), raw); err != nil {
+		logger.Warningf("Replica %d could not persist entry %d: %s", instance.id, entry.Index, err)
+	}
+}
+
+func (instance *raftCore) persistDelEntry(index uint64) {
+	instance.stack.DelState(logKey(index))
+}
+
+func (instance *raftCore) persistSnapshot() {
+	raw, err := proto.Marshal(instance.snapshot)
+	if err != nil {
+		logger.Warningf("Replica %d could not persist snapshot %d: %s", instance.id, instance.snapshot.Index, err)
+		return
+	}
+	if err = instance.stack.StoreState(snapshotKey, raw); err != nil {
+		logger.Warningf("Replica %d could not persist snapshot %d: %s", instance.id, instance.snapshot.Index, err)
+	}
+}
+
+func (instance *raftCore) readUint64(key string) (uint64, bool) {
+	raw, err := instance.stack.ReadState(key)
+	if err != nil || raw == nil {
+		logger.Debugf("Replica %d could not restore state %s: %v", instance.id, key, err)
+		return 0, false
+	}
+	value, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		logger.Errorf("Replica %d could not parse %s - local state is damaged: %s", instance.id, key, err)
+		return 0, false
+	}
+	return value, true
+}
+
+func (instance *raftCore) restoreState() {
+	instance.term, _ = instance.readUint64(termKey)
+	instance.votedFor, instance.voted = instance.readUint64(votedForKey)
+
+	instance.snapshot = &Snapshot{}
+	if raw, err := instance.stack.ReadState(snapshotKey); err == nil && raw != nil {
+		if err = proto.Unmarshal(raw, instance.snapshot); err != nil {
+			logger.Errorf("Replica %d could not unmarshal its snapshot - local state is damaged: %s", instance.id, err)
+			instance.snapshot = &Snapshot{}
+		}
+	}
+
+	instance.log = nil
+	if entriesPacked, err := instance.stack.ReadStateSet(logKeyPrefix); err == nil {
+		var entries []*Entry
+		for key, raw := range entriesPacked {
+			entry := &Entry{}
+			if err = proto.Unmarshal(raw, entry); err != nil {
+				logger.Warningf("Replica %d could not restore entry %s", instance.id, key)
+				continue
+			}
+			entries = append(entries, entry)
+		}
+		sort.Sort(entriesByIndex(entries))
+		// keep the entries that follow the snapshot without a gap
+		for _, entry := range entries {
+			if entry.Index == instance.lastLogIndex()+1 {
+				instance.log = append(instance.log, entry)
+			}
+		}
+	} else {
+		logger.Warningf("Replica %d could not restore its log: %s", instance.id, err)
+	}
+
+	instance.restoreLastApplied()
+	instance.commitIndex = instance.lastApplied
+
+	logger.Infof("Replica %d restored state: term: %d, snapshot: %d, log: %d entries, lastApplied: %d",
+		instance.id, instance.term, instance.snapshot.Index, len(instance.log), instance.lastApplied)
+}
+
+// restoreLastApplied reads the index of the last applied entry from the metadata of
+// the last block. The entries of the snapshot are applied, some may not have a block
+func (instance *raftCore) restoreLastApplied() {
+	instance.lastApplied = instance.snapshot.Index
+	raw, err := instance.stack.GetBlockHeadMetadata()
+	if err != nil {
+		logger.Warningf("Replica %d could not restore lastApplied: %s", instance.id, err)
+		return
+	}
+	meta := &Metadata{}
+	if err = proto.Unmarshal(raw, meta); err != nil {
+		logger.Warningf("Replica %d could not unmarshal the block metadata: %s", instance.id, err)
+		return
+	}
+	if meta.Index > instance.lastApplied {
+		instance.lastApplied = meta.Index
+	}
+}
+
+type entriesByIndex []*Entry
+
+func (a entriesByIndex) Len() int           { return len(a) }
+func (a entriesByIndex) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a entriesByIndex) Less(i, j int) bool { return a[i].Index < a[j].Index }
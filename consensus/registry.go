@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consensus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Constructor returns the Consenter of a consensus plugin, running on top of stack
+type Constructor func(stack Stack) Consenter
+
+// pluginRegistry keeps the consensus plugins by name. Names are case-insensitive
+type pluginRegistry struct {
+	lock    sync.RWMutex
+	plugins map[string]Constructor
+}
+
+// Registry is the registry the consensus plugins add themselves to, usually from the
+// init function of the plugin package
+var Registry = &pluginRegistry{plugins: make(map[string]Constructor)}
+
+// Add registers the constructor of a consensus plugin under the given name
+func (r *pluginRegistry) Add(name string, constructor Constructor) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	name = strings.ToLower(name)
+	if _, ok := r.plugins[name]; ok {
+		return fmt.Errorf("Consensus plugin [%s] is already registered", name)
+	}
+	r.plugins[name] = constructor
+	return nil
+}
+
+// New returns the Consenter of the plugin registered under name. It returns an error
+// if no plugin is registered under name
+func (r *pluginRegistry) New(name string, stack Stack) (Consenter, error) {
+	r.lock.RLock()
+	constructor, ok := r.plugins[strings.ToLower(name)]
+	r.lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unregistered consensus plugin: %s. Registered plugins are %v", name, r.Registered())
+	}
+	return constructor(stack), nil
+}
+
+// Registered returns the sorted names of the registered consensus plugins
+func (r *pluginRegistry) Registered() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	names := make([]string, 0, len(r.plugins))
+	for name := range r.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consensus
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testConsenter only tells the plugins apart, its methods are not called
+type testConsenter struct {
+	Consenter
+	stack Stack
+}
+
+func newTestRegistry() *pluginRegistry {
+	registry := &pluginRegistry{plugins: make(map[string]Constructor)}
+	registry.Add("test", func(stack Stack) Consenter { return &testConsenter{stack: stack} })
+	registry.Add("other", func(stack Stack) Consenter { return &testConsenter{stack: stack} })
+	return registry
+}
+
+func TestRegistry_AddTwice(t *testing.T) {
+	registry := newTestRegistry()
+	if err := registry.Add("TEST", nil); err == nil {
+		t.Fatalf("Registering a consensus plugin twice should fail")
+	}
+}
+
+func TestRegistry_New(t *testing.T) {
+	registry := newTestRegistry()
+	consenter, err := registry.New("Test", nil)
+	if err != nil {
+		t.Fatalf("Error while creating consenter: %s", err)
+	}
+	if _, ok := consenter.(*testConsenter); !ok {
+		t.Fatalf("Expected the consenter of the test plugin, found %#v", consenter)
+	}
+}
+
+func TestRegistry_Unknown(t *testing.T) {
+	registry := newTestRegistry()
+	_, err := registry.New("tset", nil)
+	if err == nil || !strings.Contains(err.Error(), "Unregistered consensus plugin: tset. Registered plugins are [other test]") {
+		t.Fatalf("Expected an error listing the registered plugins, found [%v]", err)
+	}
+	if registered := registry.Registered(); !reflect.DeepEqual(registered, []string{"other", "test"}) {
+		t.Fatalf("Expected the sorted plugin names, found %v", registered)
+	}
+}
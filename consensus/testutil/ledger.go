@@ -14,7 +14,7 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package pbft
+package testutil
 
 import (
 	"fmt"
@@ -28,65 +28,13 @@ import (
 	"github.com/hyperledger/fabric/protos"
 )
 
+// LedgerDirectory finds the ledgers of the other replicas for state transfer
 type LedgerDirectory interface {
 	GetLedgerByPeerID(peerID *protos.PeerID) (consensus.ReadOnlyLedger, bool)
 }
 
-type HashLedgerDirectory struct {
-	remoteLedgers map[protos.PeerID]consensus.ReadOnlyLedger
-}
-
-func (hd *HashLedgerDirectory) GetLedgerByPeerID(peerID *protos.PeerID) (consensus.ReadOnlyLedger, bool) {
-	ledger, ok := hd.remoteLedgers[*peerID]
-	return ledger, ok
-}
-
-func (hd *HashLedgerDirectory) GetPeers() (*protos.PeersMessage, error) {
-	_, network, err := hd.GetNetworkInfo()
-	return &protos.PeersMessage{Peers: network}, err
-}
-
-func (hd *HashLedgerDirectory) GetPeerEndpoint() (*protos.PeerEndpoint, error) {
-	self, _, err := hd.GetNetworkInfo()
-	return self, err
-}
-
-func (hd *HashLedgerDirectory) GetNetworkInfo() (self *protos.PeerEndpoint, network []*protos.PeerEndpoint, err error) {
-	network = make([]*protos.PeerEndpoint, len(hd.remoteLedgers)+1)
-	i := 0
-	for peerID := range hd.remoteLedgers {
-		peerID := peerID // Get a memory address which will not be overwritten
-		network[i] = &protos.PeerEndpoint{
-			ID:   &peerID,
-			Type: protos.PeerEndpoint_VALIDATOR,
-		}
-		i++
-	}
-	network[i] = &protos.PeerEndpoint{
-		ID: &protos.PeerID{
-			Name: "SelfID",
-		},
-		Type: protos.PeerEndpoint_VALIDATOR,
-	}
-
-	self = network[i]
-	return
-}
-
-func (hd *HashLedgerDirectory) GetNetworkHandles() (self *protos.PeerID, network []*protos.PeerID, err error) {
-	oSelf, oNetwork, err := hd.GetNetworkInfo()
-	if nil != err {
-		return
-	}
-
-	self = oSelf.ID
-	network = make([]*protos.PeerID, len(oNetwork))
-	for i, endpoint := range oNetwork {
-		network[i] = endpoint.ID
-	}
-	return
-}
-
+// MockLedger is an in-memory ledger which executes a transaction by appending its
+// payload to the results of the block
 type MockLedger struct {
 	cleanML       *MockLedger
 	blocks        map[uint64]*protos.Block
@@ -100,9 +48,13 @@ type MockLedger struct {
 	curResults    []byte
 	preBatchState uint64
 
-	ce *consumerEndpoint // To support the ExecTx stuff
+	// Consumer is notified when Execute, Commit and Rollback complete
+	Consumer consensus.ExecutionConsumer
+	// ExecTxResult, if set, replaces the default transaction execution
+	ExecTxResult func([]*protos.Transaction) ([]byte, error)
 }
 
+// NewMockLedger returns a ledger holding an empty genesis block
 func NewMockLedger(remoteLedgers LedgerDirectory) *MockLedger {
 	mock := &MockLedger{}
 	mock.mutex = &sync.Mutex{}
@@ -134,7 +86,7 @@ func (mock *MockLedger) Execute(tag interface{}, txs []*protos.Transaction) {
 		if err != nil {
 			panic(err)
 		}
-		mock.ce.consumer.Executed(tag)
+		mock.Consumer.Executed(tag)
 	}()
 }
 
@@ -144,14 +96,14 @@ func (mock *MockLedger) Commit(tag interface{}, meta []byte) {
 		if err != nil {
 			panic(err)
 		}
-		mock.ce.consumer.Committed(tag, mock.GetBlockchainInfo())
+		mock.Consumer.Committed(tag, mock.GetBlockchainInfo())
 	}()
 }
 
 func (mock *MockLedger) Rollback(tag interface{}) {
 	go func() {
 		mock.RollbackTxBatch(mock)
-		mock.ce.consumer.RolledBack(tag)
+		mock.Consumer.RolledBack(tag)
 	}()
 }
 
@@ -163,8 +115,8 @@ func (mock *MockLedger) ExecTxs(id interface{}, txs []*protos.Transaction) ([]by
 	mock.curBatch = append(mock.curBatch, txs...)
 	var err error
 	var txResult []byte
-	if nil != mock.ce && nil != mock.ce.execTxResult {
-		txResult, err = mock.ce.execTxResult(txs)
+	if nil != mock.ExecTxResult {
+		txResult, err = mock.ExecTxResult(txs)
 	} else {
 		// This is basically a default fake default transaction execution
 		if nil == txs {
@@ -218,8 +170,10 @@ func (mock *MockLedger) commonCommitTx(id interface{}, metadata []byte, preview
 	if !preview {
 		hash, _ := mock.HashBlock(block)
 		fmt.Printf("TEST LEDGER: Mock ledger is inserting block %d with hash %x\n", mock.blockHeight, hash)
+		mock.mutex.Lock()
 		mock.blocks[mock.blockHeight] = block
 		mock.blockHeight++
+		mock.mutex.Unlock()
 	}
 
 	return block, nil
@@ -289,6 +243,8 @@ func (mock *MockLedger) getBlockInfo(height uint64, block *protos.Block) *protos
 }
 
 func (mock *MockLedger) GetBlockHeadMetadata() ([]byte, error) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
 	b, ok := mock.blocks[mock.blockHeight-1]
 	if !ok {
 		return nil, fmt.Errorf("could not retrieve block from mock ledger")
@@ -296,7 +252,9 @@ func (mock *MockLedger) GetBlockHeadMetadata() ([]byte, error) {
 	return b.ConsensusMetadata, nil
 }
 
-func (mock *MockLedger) simulateStateTransfer(info *protos.BlockchainInfo, peers []*protos.PeerID) {
+// SimulateStateTransfer copies the blocks up to the target from the ledger of the
+// first of the peers
+func (mock *MockLedger) SimulateStateTransfer(info *protos.BlockchainInfo, peers []*protos.PeerID) {
 	var remoteLedger consensus.ReadOnlyLedger
 	if len(peers) > 0 {
 		var ok bool
@@ -326,7 +284,11 @@ func (mock *MockLedger) simulateStateTransfer(info *protos.BlockchainInfo, peers
 			continue
 		}
 
+		mock.mutex.Lock()
 		mock.blocks[n] = block
+		mock.mutex.Unlock()
 	}
+	mock.mutex.Lock()
 	mock.blockHeight = info.Height
+	mock.mutex.Unlock()
 }
@@ -0,0 +1,337 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testutil holds the simulated network and ledger that the consensus plugins
+// run their replicas on in their tests. Every message passes through the FilterFn of
+// the Network, which may drop or rewrite it.
+package testutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Endpoint is a replica attached to a Network
+type Endpoint interface {
+	Stop()
+	Deliver([]byte, *pb.PeerID)
+	GetHandle() *pb.PeerID
+	GetID() uint64
+	IsBusy() bool
+}
+
+// TaggedMsg is a message queued on a Network, Dst is -1 for a broadcast
+type TaggedMsg struct {
+	Src int
+	Dst int
+	Msg []byte
+}
+
+// Network delivers the messages between its endpoints. FilterFn, if set, is called
+// with the sender, the receiver (-1 when a broadcast is queued) and the payload, and
+// returns the payload to deliver, or nil to drop it
+type Network struct {
+	Debug     bool
+	closed    chan struct{}
+	Endpoints []Endpoint
+	Msgs      chan TaggedMsg
+	FilterFn  func(int, int, []byte) []byte
+}
+
+// TestEndpoint is the network stack of an endpoint, replicas embed it
+type TestEndpoint struct {
+	ID  uint64
+	Net *Network
+}
+
+// NewTestEndpoint returns the network stack of endpoint id
+func NewTestEndpoint(id uint64, net *Network) *TestEndpoint {
+	ep := &TestEndpoint{}
+	ep.ID = id
+	ep.Net = net
+	return ep
+}
+
+// GetID returns the replica ID of the endpoint
+func (ep *TestEndpoint) GetID() uint64 {
+	return ep.ID
+}
+
+// GetHandle returns the peer handle of the endpoint
+func (ep *TestEndpoint) GetHandle() *pb.PeerID {
+	return &pb.PeerID{Name: fmt.Sprintf("vp%d", ep.ID)}
+}
+
+// GetNetworkInfo returns the endpoints of the network as validators
+func (ep *TestEndpoint) GetNetworkInfo() (self *pb.PeerEndpoint, network []*pb.PeerEndpoint, err error) {
+	oSelf, oNetwork, _ := ep.GetNetworkHandles()
+	self = &pb.PeerEndpoint{
+		ID:   oSelf,
+		Type: pb.PeerEndpoint_VALIDATOR,
+	}
+
+	network = make([]*pb.PeerEndpoint, len(oNetwork))
+	for i, id := range oNetwork {
+		network[i] = &pb.PeerEndpoint{
+			ID:   id,
+			Type: pb.PeerEndpoint_VALIDATOR,
+		}
+	}
+	return
+}
+
+// GetNetworkHandles returns the handles of the endpoints of the network
+func (ep *TestEndpoint) GetNetworkHandles() (self *pb.PeerID, network []*pb.PeerID, err error) {
+	if nil == ep.Net {
+		err = fmt.Errorf("Network not initialized")
+		return
+	}
+	self = ep.GetHandle()
+	network = make([]*pb.PeerID, len(ep.Net.Endpoints))
+	for i, oep := range ep.Net.Endpoints {
+		if nil != oep {
+			// In case this is invoked before all endpoints are initialized, this emulates a real network as well
+			network[i] = oep.GetHandle()
+		}
+	}
+	return
+}
+
+// Broadcast delivers to all endpoints.  In contrast to the stack
+// Broadcast, this will also deliver back to the replica.  We keep
+// this behavior, because it exposes subtle bugs in the
+// implementation.
+func (ep *TestEndpoint) Broadcast(msg *pb.Message, peerType pb.PeerEndpoint_Type) error {
+	ep.Net.broadcastFilter(ep, msg.Payload)
+	return nil
+}
+
+// Unicast queues a message for the endpoint with the given handle
+func (ep *TestEndpoint) Unicast(msg *pb.Message, receiverHandle *pb.PeerID) error {
+	receiverID, err := validatorID(receiverHandle)
+	if err != nil {
+		return fmt.Errorf("Couldn't unicast message to %s: %v", receiverHandle.Name, err)
+	}
+	internalQueueMessage(ep.Net.Msgs, TaggedMsg{int(ep.ID), int(receiverID), msg.Payload})
+	return nil
+}
+
+func internalQueueMessage(queue chan<- TaggedMsg, tm TaggedMsg) {
+	select {
+	case queue <- tm:
+	default:
+		fmt.Println("TEST NET: Message cannot be queued without blocking, consider increasing the queue size")
+		queue <- tm
+	}
+}
+
+// DebugMsg prints the message if Debug is set
+func (net *Network) DebugMsg(msg string, args ...interface{}) {
+	if net.Debug {
+		fmt.Printf(msg, args...)
+	}
+}
+
+func (net *Network) broadcastFilter(ep *TestEndpoint, payload []byte) {
+	select {
+	case <-net.closed:
+		fmt.Println("WARNING! Attempted to send a request to a closed network, ignoring")
+		return
+	default:
+	}
+	if net.FilterFn != nil {
+		payload = net.FilterFn(int(ep.ID), -1, payload)
+		net.DebugMsg("TEST: filtered message\n")
+	}
+	if payload != nil {
+		net.DebugMsg("TEST: attempting to queue message %p\n", payload)
+		internalQueueMessage(net.Msgs, TaggedMsg{int(ep.ID), -1, payload})
+		net.DebugMsg("TEST: message queued successfully %p\n", payload)
+	} else {
+		net.DebugMsg("TEST: suppressing message with payload %p\n", payload)
+	}
+}
+
+func (net *Network) deliverFilter(msg TaggedMsg) {
+	net.DebugMsg("TEST: deliver\n")
+	senderHandle := net.Endpoints[msg.Src].GetHandle()
+	if msg.Dst == -1 {
+		net.DebugMsg("TEST: Sending broadcast %v\n", net.Endpoints)
+		wg := &sync.WaitGroup{}
+		wg.Add(len(net.Endpoints))
+		for id, ep := range net.Endpoints {
+			net.DebugMsg("TEST: Looping broadcast %d\n", ep.GetID())
+			lid := id
+			lep := ep
+			go func() {
+				defer wg.Done()
+				if msg.Src == lid {
+					if net.Debug {
+						net.DebugMsg("TEST: Skipping local delivery %d %d\n", lid, msg.Src)
+					}
+					// do not deliver to local replica
+					return
+				}
+				payload := msg.Msg
+				net.DebugMsg("TEST: Filtering %d\n", lid)
+				if net.FilterFn != nil {
+					payload = net.FilterFn(msg.Src, lid, payload)
+				}
+				net.DebugMsg("TEST: Delivering %d\n", lid)
+				if payload != nil {
+					net.DebugMsg("TEST: Sending message %d\n", lid)
+					lep.Deliver(payload, senderHandle)
+					net.DebugMsg("TEST: Sent message %d\n", lid)
+				} else {
+					net.DebugMsg("TEST: Message to %d was skipped\n", lid)
+				}
+			}()
+		}
+		wg.Wait()
+	} else {
+		payload := msg.Msg
+		net.DebugMsg("TEST: Filtering %d\n", msg.Dst)
+		if net.FilterFn != nil {
+			payload = net.FilterFn(msg.Src, msg.Dst, payload)
+		}
+		if payload != nil {
+			net.DebugMsg("TEST: Sending unicast\n")
+			net.Endpoints[msg.Dst].Deliver(payload, senderHandle)
+		}
+	}
+}
+
+func (net *Network) processMessageFromChannel(msg TaggedMsg, ok bool) bool {
+	if !ok {
+		net.DebugMsg("TEST: message channel closed, exiting\n")
+		return false
+	}
+	net.DebugMsg("TEST: new message, delivering\n")
+	net.deliverFilter(msg)
+	return true
+}
+
+// Process delivers messages until none are queued and no endpoint is busy
+func (net *Network) Process() error {
+	retry := true
+	countdown := time.After(60 * time.Second)
+	for {
+		net.DebugMsg("TEST: process looping\n")
+		select {
+		case msg, ok := <-net.Msgs:
+			retry = true
+			net.DebugMsg("TEST: processing message without testing for idle\n")
+			if !net.processMessageFromChannel(msg, ok) {
+				return nil
+			}
+		case <-net.closed:
+			return nil
+		case <-countdown:
+			panic("Test network took more than 60 seconds to resolve requests, this usually indicates a hang")
+		default:
+			if !retry {
+				return nil
+			}
+
+			var busy []int
+			for i, ep := range net.Endpoints {
+				if ep.IsBusy() {
+					busy = append(busy, i)
+				}
+			}
+			if len(busy) == 0 {
+				retry = false
+				continue
+			}
+
+			net.DebugMsg("TEST: some replicas are busy, waiting: %v\n", busy)
+			select {
+			case msg, ok := <-net.Msgs:
+				retry = true
+				if !net.processMessageFromChannel(msg, ok) {
+					return nil
+				}
+				continue
+			case <-time.After(100 * time.Millisecond):
+				continue
+			}
+		}
+	}
+}
+
+// ProcessContinually delivers messages until the network is stopped
+func (net *Network) ProcessContinually() {
+	for {
+		select {
+		case msg, ok := <-net.Msgs:
+			if !net.processMessageFromChannel(msg, ok) {
+				return
+			}
+		case <-net.closed:
+			return
+		}
+	}
+}
+
+// MakeNetwork returns a network of N endpoints, built by initFn
+func MakeNetwork(N int, initFn func(id uint64, network *Network) Endpoint) *Network {
+	net := &Network{}
+	net.Msgs = make(chan TaggedMsg, 100)
+	net.closed = make(chan struct{})
+	net.Endpoints = make([]Endpoint, N)
+
+	for i := range net.Endpoints {
+		net.Endpoints[i] = initFn(uint64(i), net)
+	}
+
+	return net
+}
+
+// ClearMessages drops the queued messages
+func (net *Network) ClearMessages() {
+	for {
+		select {
+		case <-net.Msgs:
+		default:
+			return
+		}
+	}
+}
+
+// Stop closes the network and stops its endpoints
+func (net *Network) Stop() {
+	close(net.closed)
+	for _, ep := range net.Endpoints {
+		ep.Stop()
+	}
+}
+
+// validatorID returns the replica ID of a "vpX" peer handle
+func validatorID(handle *pb.PeerID) (uint64, error) {
+	if !strings.HasPrefix(handle.Name, "vp") {
+		return 0, fmt.Errorf("Handle %s is not of the form vpX", handle.Name)
+	}
+	id, err := strconv.ParseUint(handle.Name[2:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Error extracting ID from \"%s\" handle: %v", handle.Name, err)
+	}
+	return id, nil
+}
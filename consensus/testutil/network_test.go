@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testutil
+
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type recordingEndpoint struct {
+	*TestEndpoint
+	received [][]byte
+}
+
+func (ep *recordingEndpoint) Stop() {}
+
+func (ep *recordingEndpoint) IsBusy() bool {
+	return false
+}
+
+func (ep *recordingEndpoint) Deliver(msg []byte, sender *pb.PeerID) {
+	ep.received = append(ep.received, msg)
+}
+
+func makeRecordingNetwork(N int) *Network {
+	return MakeNetwork(N, func(id uint64, net *Network) Endpoint {
+		return &recordingEndpoint{TestEndpoint: NewTestEndpoint(id, net)}
+	})
+}
+
+func TestNetworkUnicastFilter(t *testing.T) {
+	net := makeRecordingNetwork(2)
+	defer net.Stop()
+	net.FilterFn = func(src, dst int, payload []byte) []byte {
+		if string(payload) == "drop" {
+			return nil
+		}
+		return []byte("filtered")
+	}
+
+	ep := net.Endpoints[0].(*recordingEndpoint)
+	for _, payload := range []string{"original", "drop"} {
+		if err := ep.Unicast(&pb.Message{Payload: []byte(payload)}, net.Endpoints[1].GetHandle()); err != nil {
+			t.Fatalf("Could not unicast: %s", err)
+		}
+	}
+	net.Process()
+
+	received := net.Endpoints[1].(*recordingEndpoint).received
+	if len(received) != 1 || string(received[0]) != "filtered" {
+		t.Errorf("Expected only the payload returned by the filter to be delivered, got %q", received)
+	}
+}
+
+func TestNetworkBroadcastFilter(t *testing.T) {
+	net := makeRecordingNetwork(3)
+	defer net.Stop()
+	net.FilterFn = func(src, dst int, payload []byte) []byte {
+		if dst == 2 {
+			return nil
+		}
+		return payload
+	}
+
+	ep := net.Endpoints[0].(*recordingEndpoint)
+	ep.Broadcast(&pb.Message{Payload: []byte("hello")}, pb.PeerEndpoint_VALIDATOR)
+	net.Process()
+
+	if received := ep.received; len(received) != 0 {
+		t.Errorf("Expected the sender not to receive its own broadcast, got %q", received)
+	}
+	if received := net.Endpoints[1].(*recordingEndpoint).received; len(received) != 1 || string(received[0]) != "hello" {
+		t.Errorf("Expected endpoint 1 to receive the broadcast, got %q", received)
+	}
+	if received := net.Endpoints[2].(*recordingEndpoint).received; len(received) != 0 {
+		t.Errorf("Expected the broadcast to endpoint 2 to be dropped, got %q", received)
+	}
+}
@@ -61,6 +61,9 @@ type transactionContext struct {
 
 	// tracks open iterators used for range queries
 	rangeQueryIteratorMap map[string]statemgmt.RangeScanIterator
+
+	// tracks open iterators used for key history queries
+	historyQueryIteratorMap map[string]*ledger.HistoryIterator
 }
 
 type nextStateInfo struct {
@@ -123,7 +126,8 @@ func (handler *Handler) createTxContext(txid string, tx *pb.Transaction) (*trans
 		return nil, fmt.Errorf("txid:%s exists", txid)
 	}
 	txctx := &transactionContext{transactionSecContext: tx, responseNotifier: make(chan *pb.ChaincodeMessage, 1),
-		rangeQueryIteratorMap: make(map[string]statemgmt.RangeScanIterator)}
+		rangeQueryIteratorMap:   make(map[string]statemgmt.RangeScanIterator),
+		historyQueryIteratorMap: make(map[string]*ledger.HistoryIterator)}
 	handler.txCtxs[txid] = txctx
 	return txctx, nil
 }
@@ -161,6 +165,25 @@ func (handler *Handler) deleteRangeQueryIterator(txContext *transactionContext,
 	delete(txContext.rangeQueryIteratorMap, txid)
 }
 
+func (handler *Handler) putHistoryQueryIterator(txContext *transactionContext, txid string,
+	historyIterator *ledger.HistoryIterator) {
+	handler.Lock()
+	defer handler.Unlock()
+	txContext.historyQueryIteratorMap[txid] = historyIterator
+}
+
+func (handler *Handler) getHistoryQueryIterator(txContext *transactionContext, txid string) *ledger.HistoryIterator {
+	handler.Lock()
+	defer handler.Unlock()
+	return txContext.historyQueryIteratorMap[txid]
+}
+
+func (handler *Handler) deleteHistoryQueryIterator(txContext *transactionContext, txid string) {
+	handler.Lock()
+	defer handler.Unlock()
+	delete(txContext.historyQueryIteratorMap, txid)
+}
+
 //THIS CAN BE REMOVED ONCE WE SUPPORT CONFIDENTIALITY WITH CC-CALLING-CC
 //we dissallow chaincode-chaincode interactions till confidentiality implications are understood
 func (handler *Handler) canCallChaincode(txid string) *pb.ChaincodeMessage {
@@ -416,6 +439,26 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{transactionstate}, Dst: transactionstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_NEXT.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_NEXT.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_NEXT.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_NEXT.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_NEXT.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_CLOSE.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_CLOSE.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_CLOSE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_CLOSE.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_CLOSE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{initstate}, Dst: endstate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{transactionstate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{busyinitstate}, Dst: initstate},
@@ -424,22 +467,26 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			{Name: pb.ChaincodeMessage_RESPONSE.String(), Src: []string{busyxactstate}, Dst: transactionstate},
 		},
 		fsm.Callbacks{
-			"before_" + pb.ChaincodeMessage_REGISTER.String():               func(e *fsm.Event) { v.beforeRegisterEvent(e, v.FSM.Current()) },
-			"before_" + pb.ChaincodeMessage_COMPLETED.String():              func(e *fsm.Event) { v.beforeCompletedEvent(e, v.FSM.Current()) },
-			"before_" + pb.ChaincodeMessage_INIT.String():                   func(e *fsm.Event) { v.beforeInitState(e, v.FSM.Current()) },
-			"after_" + pb.ChaincodeMessage_GET_STATE.String():               func(e *fsm.Event) { v.afterGetState(e, v.FSM.Current()) },
-			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE.String():       func(e *fsm.Event) { v.afterRangeQueryState(e, v.FSM.Current()) },
-			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String():  func(e *fsm.Event) { v.afterRangeQueryStateNext(e, v.FSM.Current()) },
-			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(): func(e *fsm.Event) { v.afterRangeQueryStateClose(e, v.FSM.Current()) },
-			"after_" + pb.ChaincodeMessage_PUT_STATE.String():               func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
-			"after_" + pb.ChaincodeMessage_DEL_STATE.String():               func(e *fsm.Event) { v.afterDelState(e, v.FSM.Current()) },
-			"after_" + pb.ChaincodeMessage_INVOKE_CHAINCODE.String():        func(e *fsm.Event) { v.afterInvokeChaincode(e, v.FSM.Current()) },
-			"enter_" + establishedstate:                                     func(e *fsm.Event) { v.enterEstablishedState(e, v.FSM.Current()) },
-			"enter_" + initstate:                                            func(e *fsm.Event) { v.enterInitState(e, v.FSM.Current()) },
-			"enter_" + readystate:                                           func(e *fsm.Event) { v.enterReadyState(e, v.FSM.Current()) },
-			"enter_" + busyinitstate:                                        func(e *fsm.Event) { v.enterBusyState(e, v.FSM.Current()) },
-			"enter_" + busyxactstate:                                        func(e *fsm.Event) { v.enterBusyState(e, v.FSM.Current()) },
-			"enter_" + endstate:                                             func(e *fsm.Event) { v.enterEndState(e, v.FSM.Current()) },
+			"before_" + pb.ChaincodeMessage_REGISTER.String():                 func(e *fsm.Event) { v.beforeRegisterEvent(e, v.FSM.Current()) },
+			"before_" + pb.ChaincodeMessage_COMPLETED.String():                func(e *fsm.Event) { v.beforeCompletedEvent(e, v.FSM.Current()) },
+			"before_" + pb.ChaincodeMessage_INIT.String():                     func(e *fsm.Event) { v.beforeInitState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_STATE.String():                 func(e *fsm.Event) { v.afterGetState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE.String():         func(e *fsm.Event) { v.afterRangeQueryState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String():    func(e *fsm.Event) { v.afterRangeQueryStateNext(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String():   func(e *fsm.Event) { v.afterRangeQueryStateClose(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String():       func(e *fsm.Event) { v.afterGetHistoryForKey(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_NEXT.String():  func(e *fsm.Event) { v.afterGetHistoryForKeyNext(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_CLOSE.String(): func(e *fsm.Event) { v.afterGetHistoryForKeyClose(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_QUERY_RESULT.String():          func(e *fsm.Event) { v.afterGetQueryResult(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_PUT_STATE.String():                 func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_DEL_STATE.String():                 func(e *fsm.Event) { v.afterDelState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_INVOKE_CHAINCODE.String():          func(e *fsm.Event) { v.afterInvokeChaincode(e, v.FSM.Current()) },
+			"enter_" + establishedstate:                                       func(e *fsm.Event) { v.enterEstablishedState(e, v.FSM.Current()) },
+			"enter_" + initstate:                                              func(e *fsm.Event) { v.enterInitState(e, v.FSM.Current()) },
+			"enter_" + readystate:                                             func(e *fsm.Event) { v.enterReadyState(e, v.FSM.Current()) },
+			"enter_" + busyinitstate:                                          func(e *fsm.Event) { v.enterBusyState(e, v.FSM.Current()) },
+			"enter_" + busyxactstate:                                          func(e *fsm.Event) { v.enterBusyState(e, v.FSM.Current()) },
+			"enter_" + endstate:                                               func(e *fsm.Event) { v.enterEndState(e, v.FSM.Current()) },
 		},
 	)
 
@@ -553,6 +600,10 @@ func (handler *Handler) notify(msg *pb.ChaincodeMessage) {
 		for _, v := range tctx.rangeQueryIteratorMap {
 			v.Close()
 		}
+		// clean up historyQueryIteratorMap
+		for _, v := range tctx.historyQueryIteratorMap {
+			v.Close()
+		}
 	}
 }
 
@@ -713,7 +764,21 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 		chaincodeID := handler.ChaincodeID.Name
 
 		readCommittedState := !handler.getIsTransaction(msg.Txid)
-		rangeIter, err := ledger.GetStateRangeScanIterator(chaincodeID, rangeQueryState.StartKey, rangeQueryState.EndKey, readCommittedState)
+		var rangeIter statemgmt.RangeScanIterator
+		var bookmark string
+		var err error
+		if rangeQueryState.Limit > 0 || rangeQueryState.Reverse || rangeQueryState.Bookmark != "" {
+			// a page of the range, in the order of the keys
+			var pageIter *statemgmt.PagedRangeScanIterator
+			pageIter, err = ledger.GetStateRangeScanPage(chaincodeID, rangeQueryState.StartKey, rangeQueryState.EndKey, readCommittedState,
+				statemgmt.RangeScanOptions{Limit: int(rangeQueryState.Limit), Reverse: rangeQueryState.Reverse, Bookmark: rangeQueryState.Bookmark})
+			if err == nil {
+				rangeIter = pageIter
+				bookmark = pageIter.GetBookmark()
+			}
+		} else {
+			rangeIter, err = ledger.GetStateRangeScanIterator(chaincodeID, rangeQueryState.StartKey, rangeQueryState.EndKey, readCommittedState)
+		}
 		if err != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(err.Error())
@@ -755,7 +820,7 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 			handler.deleteRangeQueryIterator(txContext, iterID)
 		}
 
-		payload := &pb.RangeQueryStateResponse{KeysAndValues: keysAndValues, HasMore: hasNext, ID: iterID}
+		payload := &pb.RangeQueryStateResponse{KeysAndValues: keysAndValues, HasMore: hasNext, ID: iterID, Bookmark: bookmark}
 		payloadBytes, err := proto.Marshal(payload)
 		if err != nil {
 			rangeIter.Close()
@@ -945,6 +1010,371 @@ func (handler *Handler) handleRangeQueryStateClose(msg *pb.ChaincodeMessage) {
 	}()
 }
 
+// afterGetHistoryForKey handles a GET_HISTORY_FOR_KEY request from the chaincode.
+func (handler *Handler) afterGetHistoryForKey(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debugf("Received %s, invoking get history from ledger", pb.ChaincodeMessage_GET_HISTORY_FOR_KEY)
+
+	// Query ledger for history
+	handler.handleGetHistoryForKey(msg)
+	chaincodeLogger.Debug("Exiting GET_HISTORY_FOR_KEY")
+}
+
+// Handles query to ledger for the history of a key
+func (handler *Handler) handleGetHistoryForKey(msg *pb.ChaincodeMessage) {
+	// The defer followed by triggering a go routine dance is needed to ensure that the previous state transition
+	// is completed before the next one is triggered. The previous state transition is deemed complete only when
+	// the afterGetHistoryForKey function is exited.
+	go func() {
+		// Check if this is the unique state request from this chaincode txid
+		uniqueReq := handler.createTXIDEntry(msg.Txid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Error("Another state request pending for this Txid. Cannot process.")
+			return
+		}
+
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteTXIDEntry(msg.Txid)
+			chaincodeLogger.Debugf("[%s]handleGetHistoryForKey serial send %s", shorttxid(serialSendMsg.Txid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		getHistoryForKey := &pb.GetHistoryForKey{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, getHistoryForKey)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Errorf("Failed to unmarshall history query request. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		ledger, ledgerErr := ledger.GetLedger()
+		if ledgerErr != nil {
+			payload := []byte(ledgerErr.Error())
+			chaincodeLogger.Errorf("Failed to get ledger. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		chaincodeID := handler.ChaincodeID.Name
+		historyIter, err := ledger.GetHistoryForKey(chaincodeID, getHistoryForKey.Key)
+		if err != nil {
+			payload := []byte(err.Error())
+			chaincodeLogger.Errorf("Failed to get ledger history iterator. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		iterID := util.GenerateUUID()
+		txContext := handler.getTxContext(msg.Txid)
+		handler.putHistoryQueryIterator(txContext, iterID, historyIter)
+
+		serialSendMsg = handler.sendHistoryPage(msg.Txid, txContext, iterID, historyIter, historyIter.Next())
+	}()
+}
+
+// sendHistoryPage builds the response holding the next maxRangeQueryStateLimit
+// modifications of historyIter. hasNext tells whether historyIter is positioned on a
+// modification. The iterator is closed once it is exhausted or on error
+func (handler *Handler) sendHistoryPage(txid string, txContext *transactionContext, iterID string,
+	historyIter *ledger.HistoryIterator, hasNext bool) *pb.ChaincodeMessage {
+	closeIter := func() {
+		historyIter.Close()
+		handler.deleteHistoryQueryIterator(txContext, iterID)
+	}
+
+	var modifications []*pb.KeyModification
+	for i := uint32(0); hasNext && i < maxRangeQueryStateLimit; i++ {
+		modification, err := historyIter.GetKeyModification()
+		if err != nil {
+			closeIter()
+			chaincodeLogger.Errorf("Failed to read key history. Sending %s", pb.ChaincodeMessage_ERROR)
+			return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Txid: txid}
+		}
+		var value []byte
+		if !modification.IsDelete {
+			// Decrypt the data if the confidential is enabled
+			value, err = handler.decrypt(txid, modification.Value)
+			if err != nil {
+				closeIter()
+				chaincodeLogger.Errorf("Failed decrypt value. Sending %s", pb.ChaincodeMessage_ERROR)
+				return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Txid: txid}
+			}
+		}
+		modifications = append(modifications, &pb.KeyModification{TxID: modification.TxID,
+			BlockNumber: modification.BlockNumber, TxIndex: modification.TxIndex, Value: value, IsDelete: modification.IsDelete})
+
+		hasNext = historyIter.Next()
+	}
+
+	if !hasNext {
+		closeIter()
+	}
+
+	payload := &pb.GetHistoryForKeyResponse{Modifications: modifications, HasMore: hasNext, ID: iterID}
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		if hasNext {
+			closeIter()
+		}
+		chaincodeLogger.Errorf("Failed marshall response. Sending %s", pb.ChaincodeMessage_ERROR)
+		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Txid: txid}
+	}
+
+	chaincodeLogger.Debugf("Got key history. Sending %s", pb.ChaincodeMessage_RESPONSE)
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payloadBytes, Txid: txid}
+}
+
+// afterGetHistoryForKeyNext handles a GET_HISTORY_FOR_KEY_NEXT request from the chaincode.
+func (handler *Handler) afterGetHistoryForKeyNext(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debugf("Received %s, invoking get history from ledger", pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_NEXT)
+
+	// Query ledger for history
+	handler.handleGetHistoryForKeyNext(msg)
+	chaincodeLogger.Debug("Exiting GET_HISTORY_FOR_KEY_NEXT")
+}
+
+// Handles query to ledger for the next page of the history of a key
+func (handler *Handler) handleGetHistoryForKeyNext(msg *pb.ChaincodeMessage) {
+	// See handleGetHistoryForKey for the go routine dance
+	go func() {
+		// Check if this is the unique state request from this chaincode txid
+		uniqueReq := handler.createTXIDEntry(msg.Txid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Error("Another state request pending for this Txid. Cannot process.")
+			return
+		}
+
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteTXIDEntry(msg.Txid)
+			chaincodeLogger.Debugf("[%s]handleGetHistoryForKeyNext serial send %s", shorttxid(serialSendMsg.Txid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		getHistoryForKeyNext := &pb.GetHistoryForKeyNext{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, getHistoryForKeyNext)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Errorf("Failed to unmarshall history next query request. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		txContext := handler.getTxContext(msg.Txid)
+		historyIter := handler.getHistoryQueryIterator(txContext, getHistoryForKeyNext.ID)
+		if historyIter == nil {
+			payload := []byte("History query iterator not found")
+			chaincodeLogger.Errorf("History query iterator not found. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		// the iterator is still positioned on the first modification of this page
+		serialSendMsg = handler.sendHistoryPage(msg.Txid, txContext, getHistoryForKeyNext.ID, historyIter, true)
+	}()
+}
+
+// afterGetHistoryForKeyClose handles a GET_HISTORY_FOR_KEY_CLOSE request from the chaincode.
+func (handler *Handler) afterGetHistoryForKeyClose(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debugf("Received %s, closing history iterator", pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_CLOSE)
+
+	handler.handleGetHistoryForKeyClose(msg)
+	chaincodeLogger.Debug("Exiting GET_HISTORY_FOR_KEY_CLOSE")
+}
+
+// Handles the closing of a history iterator
+func (handler *Handler) handleGetHistoryForKeyClose(msg *pb.ChaincodeMessage) {
+	// See handleGetHistoryForKey for the go routine dance
+	go func() {
+		// Check if this is the unique state request from this chaincode txid
+		uniqueReq := handler.createTXIDEntry(msg.Txid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Error("Another state request pending for this Txid. Cannot process.")
+			return
+		}
+
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteTXIDEntry(msg.Txid)
+			chaincodeLogger.Debugf("[%s]handleGetHistoryForKeyClose serial send %s", shorttxid(serialSendMsg.Txid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		getHistoryForKeyClose := &pb.GetHistoryForKeyClose{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, getHistoryForKeyClose)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Errorf("Failed to unmarshall history query close request. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		txContext := handler.getTxContext(msg.Txid)
+		iter := handler.getHistoryQueryIterator(txContext, getHistoryForKeyClose.ID)
+		if iter != nil {
+			iter.Close()
+			handler.deleteHistoryQueryIterator(txContext, getHistoryForKeyClose.ID)
+		}
+
+		payload := &pb.GetHistoryForKeyResponse{HasMore: false, ID: getHistoryForKeyClose.ID}
+		payloadBytes, err := proto.Marshal(payload)
+		if err != nil {
+			payload := []byte(err.Error())
+			chaincodeLogger.Errorf("Failed marshall response. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		chaincodeLogger.Debugf("Closed. Sending %s", pb.ChaincodeMessage_RESPONSE)
+		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payloadBytes, Txid: msg.Txid}
+	}()
+}
+
+// afterGetQueryResult handles a GET_QUERY_RESULT request from the chaincode.
+func (handler *Handler) afterGetQueryResult(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debugf("Received %s, invoking rich query on ledger", pb.ChaincodeMessage_GET_QUERY_RESULT)
+
+	// Query ledger for the documents selected by the query
+	handler.handleGetQueryResult(msg)
+	chaincodeLogger.Debug("Exiting GET_QUERY_RESULT")
+}
+
+// Handles a rich query to ledger. The result is read on with RANGE_QUERY_STATE_NEXT
+// and RANGE_QUERY_STATE_CLOSE like the result of a range query
+func (handler *Handler) handleGetQueryResult(msg *pb.ChaincodeMessage) {
+	// The defer followed by triggering a go routine dance is needed to ensure that the previous state transition
+	// is completed before the next one is triggered. The previous state transition is deemed complete only when
+	// the afterGetQueryResult function is exited.
+	go func() {
+		// Check if this is the unique state request from this chaincode txid
+		uniqueReq := handler.createTXIDEntry(msg.Txid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Error("Another state request pending for this Txid. Cannot process.")
+			return
+		}
+
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteTXIDEntry(msg.Txid)
+			chaincodeLogger.Debugf("[%s]handleGetQueryResult serial send %s", shorttxid(serialSendMsg.Txid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		// The documents are read from the committed state, which does not hold the
+		// changes of the transaction, so rich queries are for queries only
+		if handler.getIsTransaction(msg.Txid) {
+			payload := []byte("Rich queries are not supported in transactions, only in queries")
+			chaincodeLogger.Errorf("Rich query in a transaction. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		getQueryResult := &pb.GetQueryResult{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, getQueryResult)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Errorf("Failed to unmarshall rich query request. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		ledger, ledgerErr := ledger.GetLedger()
+		if ledgerErr != nil {
+			payload := []byte(ledgerErr.Error())
+			chaincodeLogger.Errorf("Failed to get ledger. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		chaincodeID := handler.ChaincodeID.Name
+		queryIter, err := ledger.GetQueryResult(chaincodeID, getQueryResult.Query)
+		if err != nil {
+			payload := []byte(err.Error())
+			chaincodeLogger.Errorf("Failed to get ledger query iterator. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Txid: msg.Txid}
+			return
+		}
+
+		iterID := util.GenerateUUID()
+		txContext := handler.getTxContext(msg.Txid)
+		handler.putRangeQueryIterator(txContext, iterID, queryIter)
+
+		serialSendMsg = handler.sendRangeQueryPage(msg.Txid, txContext, iterID, queryIter, queryIter.Next())
+	}()
+}
+
+// sendRangeQueryPage builds the response holding the next maxRangeQueryStateLimit
+// key-values of rangeIter. hasNext tells whether rangeIter is positioned on a
+// key-value. The iterator is closed once it is exhausted or on error
+func (handler *Handler) sendRangeQueryPage(txid string, txContext *transactionContext, iterID string,
+	rangeIter statemgmt.RangeScanIterator, hasNext bool) *pb.ChaincodeMessage {
+	closeIter := func() {
+		rangeIter.Close()
+		handler.deleteRangeQueryIterator(txContext, iterID)
+	}
+
+	var keysAndValues []*pb.RangeQueryStateKeyValue
+	for i := uint32(0); hasNext && i < maxRangeQueryStateLimit; i++ {
+		key, value := rangeIter.GetKeyValue()
+		// Decrypt the data if the confidential is enabled
+		decryptedValue, err := handler.decrypt(txid, value)
+		if err != nil {
+			closeIter()
+			chaincodeLogger.Errorf("Failed decrypt value. Sending %s", pb.ChaincodeMessage_ERROR)
+			return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Txid: txid}
+		}
+		keysAndValues = append(keysAndValues, &pb.RangeQueryStateKeyValue{Key: key, Value: decryptedValue})
+
+		hasNext = rangeIter.Next()
+	}
+
+	if !hasNext {
+		closeIter()
+	}
+
+	payload := &pb.RangeQueryStateResponse{KeysAndValues: keysAndValues, HasMore: hasNext, ID: iterID}
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		if hasNext {
+			closeIter()
+		}
+		chaincodeLogger.Errorf("Failed marshall response. Sending %s", pb.ChaincodeMessage_ERROR)
+		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Txid: txid}
+	}
+
+	chaincodeLogger.Debugf("Got keys and values. Sending %s", pb.ChaincodeMessage_RESPONSE)
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payloadBytes, Txid: txid}
+}
+
 // afterPutState handles a PUT_STATE request from the chaincode.
 func (handler *Handler) afterPutState(e *fsm.Event, state string) {
 	_, ok := e.Args[0].(*pb.ChaincodeMessage)
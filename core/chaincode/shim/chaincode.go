@@ -27,6 +27,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	gp "google/protobuf"
 
@@ -331,13 +332,44 @@ type StateRangeQueryIterator struct {
 // between the startKey and endKey, inclusive. The order in which keys are
 // returned by the iterator is random.
 func (stub *ChaincodeStub) RangeQueryState(startKey, endKey string) (StateRangeQueryIteratorInterface, error) {
-	response, err := handler.handleRangeQueryState(startKey, endKey, stub.UUID)
+	response, err := handler.handleRangeQueryState(&pb.RangeQueryState{StartKey: startKey, EndKey: endKey}, stub.UUID)
 	if err != nil {
 		return nil, err
 	}
 	return &StateRangeQueryIterator{handler, stub.UUID, response, 0}, nil
 }
 
+// RangeQueryOptions select a page of the keys of a range query, in lexical
+// order of the keys.
+type RangeQueryOptions struct {
+	// Limit is the maximum number of keys of the page, 0 for no limit
+	Limit int
+	// Reverse returns the keys in descending order
+	Reverse bool
+	// Bookmark is the bookmark returned with the previous page, the page
+	// starts after it. An empty bookmark starts from the beginning of the range
+	Bookmark string
+}
+
+// RangeQueryStateWithOptions function can be invoked by a chaincode to query
+// a page of a range of keys in the state. Unlike RangeQueryState, the
+// iterator returns the keys between startKey and endKey, inclusive, in lexical
+// order, or in reverse with options.Reverse, and stops after options.Limit
+// keys. The returned bookmark is passed in the options of the query of the
+// next page, and is empty on the last page. Within a transaction, the writes
+// and deletions of the transaction are taken into account.
+func (stub *ChaincodeStub) RangeQueryStateWithOptions(startKey, endKey string, options RangeQueryOptions) (StateRangeQueryIteratorInterface, string, error) {
+	if options.Limit < 0 {
+		return nil, "", fmt.Errorf("Invalid range query limit %d", options.Limit)
+	}
+	response, err := handler.handleRangeQueryState(&pb.RangeQueryState{StartKey: startKey, EndKey: endKey,
+		Limit: uint32(options.Limit), Reverse: options.Reverse, Bookmark: options.Bookmark}, stub.UUID)
+	if err != nil {
+		return nil, "", err
+	}
+	return &StateRangeQueryIterator{handler, stub.UUID, response, 0}, response.Bookmark, nil
+}
+
 // HasNext returns true if the range query iterator contains additional keys
 // and values.
 func (iter *StateRangeQueryIterator) HasNext() bool {
@@ -378,6 +410,157 @@ func (iter *StateRangeQueryIterator) Close() error {
 	return err
 }
 
+// Composite keys are made of an object type and a list of attributes. They
+// start with compositeKeyNamespace, so that they do not mix with simple keys
+// in range queries, and each part is followed by minUnicodeRuneValue. The keys
+// with a given object type and first attributes are then the keys between the
+// partial composite key and the same key followed by maxUnicodeRuneValue.
+const (
+	compositeKeyNamespace = "\x00"
+	minUnicodeRuneValue   = '\u0000'     // U+0000
+	maxUnicodeRuneValue   = utf8.MaxRune // U+10FFFF - maximum (and unassigned) code point
+)
+
+// CreateCompositeKey combines the given objectType and attributes to form a
+// composite key. The objectType and attributes must be valid UTF-8 strings
+// and must not contain U+0000 nor U+10FFFF.
+func (stub *ChaincodeStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return createCompositeKey(objectType, attributes)
+}
+
+// SplitCompositeKey splits the given composite key into the objectType and
+// the attributes it was created from.
+func (stub *ChaincodeStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	return splitCompositeKey(compositeKey)
+}
+
+// GetStateByPartialCompositeKey returns an iterator over the keys of the
+// state that start with the composite key of objectType and the given first
+// attributes, which may be none. The order of the keys is the one of
+// RangeQueryState.
+func (stub *ChaincodeStub) GetStateByPartialCompositeKey(objectType string, attributes []string) (StateRangeQueryIteratorInterface, error) {
+	startKey, endKey, err := partialCompositeKeyRange(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	return stub.RangeQueryState(startKey, endKey)
+}
+
+func validateCompositeKeyAttribute(str string) error {
+	if !utf8.ValidString(str) {
+		return fmt.Errorf("Not a valid utf8 string: [%x]", str)
+	}
+	for index, runeValue := range str {
+		if runeValue == minUnicodeRuneValue || runeValue == maxUnicodeRuneValue {
+			return fmt.Errorf("Input contains unicode %#U starting at position [%d]. %#U and %#U are not allowed in the input attribute of a composite key",
+				runeValue, index, minUnicodeRuneValue, maxUnicodeRuneValue)
+		}
+	}
+	return nil
+}
+
+func createCompositeKey(objectType string, attributes []string) (string, error) {
+	if objectType == "" {
+		return "", errors.New("The object type of a composite key must not be empty")
+	}
+	if err := validateCompositeKeyAttribute(objectType); err != nil {
+		return "", err
+	}
+	compositeKey := compositeKeyNamespace + objectType + string(minUnicodeRuneValue)
+	for _, attribute := range attributes {
+		if err := validateCompositeKeyAttribute(attribute); err != nil {
+			return "", err
+		}
+		compositeKey += attribute + string(minUnicodeRuneValue)
+	}
+	return compositeKey, nil
+}
+
+func splitCompositeKey(compositeKey string) (string, []string, error) {
+	if !strings.HasPrefix(compositeKey, compositeKeyNamespace) || !strings.HasSuffix(compositeKey, string(minUnicodeRuneValue)) {
+		return "", nil, fmt.Errorf("Not a composite key: [%q]", compositeKey)
+	}
+	parts := strings.Split(compositeKey[len(compositeKeyNamespace):len(compositeKey)-1], string(minUnicodeRuneValue))
+	if parts[0] == "" {
+		return "", nil, fmt.Errorf("Not a composite key: [%q]", compositeKey)
+	}
+	return parts[0], parts[1:], nil
+}
+
+func partialCompositeKeyRange(objectType string, attributes []string) (string, string, error) {
+	partialCompositeKey, err := createCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", "", err
+	}
+	return partialCompositeKey, partialCompositeKey + string(maxUnicodeRuneValue), nil
+}
+
+// GetQueryResult returns an iterator over the values of the state that are
+// JSON objects selected by a rich query, in lexical order of the keys. The
+// query is a JSON object holding a selector and an optional limit, e.g.
+//   {"selector": {"owner": "alice", "size": {"$gt": 10}}, "limit": 20}
+// The peer must run with 'ledger.state.richQuery.enabled'. Rich queries read
+// the committed state and can only be run by queries, not by transactions.
+func (stub *ChaincodeStub) GetQueryResult(query string) (StateRangeQueryIteratorInterface, error) {
+	response, err := handler.handleGetQueryResult(query, stub.UUID)
+	if err != nil {
+		return nil, err
+	}
+	return &StateRangeQueryIterator{handler, stub.UUID, response, 0}, nil
+}
+
+// HistoryQueryIterator allows a chaincode to iterate over the modifications
+// of a key.
+type HistoryQueryIterator struct {
+	handler    *Handler
+	uuid       string
+	response   *pb.GetHistoryForKeyResponse
+	currentLoc int
+}
+
+// GetHistoryForKey returns an iterator over the values written to `key` by
+// committed transactions, oldest first. The peer must run with
+// 'ledger.history.enabled', and only the blocks committed while it was
+// enabled are covered. Writes of the running transaction are not included.
+func (stub *ChaincodeStub) GetHistoryForKey(key string) (HistoryQueryIteratorInterface, error) {
+	response, err := handler.handleGetHistoryForKey(key, stub.UUID)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryQueryIterator{handler, stub.UUID, response, 0}, nil
+}
+
+// HasNext returns true if the history query iterator contains additional
+// modifications.
+func (iter *HistoryQueryIterator) HasNext() bool {
+	return iter.currentLoc < len(iter.response.Modifications) || iter.response.HasMore
+}
+
+// Next returns the next modification in the history query iterator.
+func (iter *HistoryQueryIterator) Next() (*pb.KeyModification, error) {
+	if iter.currentLoc >= len(iter.response.Modifications) {
+		if !iter.response.HasMore {
+			return nil, errors.New("No more modifications")
+		}
+		response, err := iter.handler.handleGetHistoryForKeyNext(iter.response.ID, iter.uuid)
+		if err != nil {
+			return nil, err
+		}
+		iter.currentLoc = 0
+		iter.response = response
+	}
+	modification := iter.response.Modifications[iter.currentLoc]
+	iter.currentLoc++
+	return modification, nil
+}
+
+// Close closes the history query iterator. This should be called when done
+// reading from the iterator to free up resources.
+func (iter *HistoryQueryIterator) Close() error {
+	_, err := iter.handler.handleGetHistoryForKeyClose(iter.response.ID, iter.uuid)
+	return err
+}
+
 func (stub *ChaincodeStub) GetArgs() [][]byte {
 	return stub.args
 }
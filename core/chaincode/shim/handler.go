@@ -580,7 +580,17 @@ func (handler *Handler) handleDelState(key string, txid string) error {
 	return errors.New("Incorrect chaincode message received")
 }
 
-func (handler *Handler) handleRangeQueryState(startKey, endKey string, txid string) (*pb.RangeQueryStateResponse, error) {
+func (handler *Handler) handleRangeQueryState(payload *pb.RangeQueryState, txid string) (*pb.RangeQueryStateResponse, error) {
+	return handler.sendRangeQuery(pb.ChaincodeMessage_RANGE_QUERY_STATE, payload, txid)
+}
+
+func (handler *Handler) handleGetQueryResult(query string, txid string) (*pb.RangeQueryStateResponse, error) {
+	return handler.sendRangeQuery(pb.ChaincodeMessage_GET_QUERY_RESULT, &pb.GetQueryResult{Query: query}, txid)
+}
+
+// sendRangeQuery sends a RANGE_QUERY_STATE or GET_QUERY_RESULT message to the validator
+// chaincode support and waits for the first page of key-values it answers with
+func (handler *Handler) sendRangeQuery(msgType pb.ChaincodeMessage_Type, payload proto.Message, txid string) (*pb.RangeQueryStateResponse, error) {
 	// Create the channel on which to communicate the response from validating peer
 	respChan, uniqueReqErr := handler.createChannel(txid)
 	if uniqueReqErr != nil {
@@ -590,16 +600,15 @@ func (handler *Handler) handleRangeQueryState(startKey, endKey string, txid stri
 
 	defer handler.deleteChannel(txid)
 
-	// Send RANGE_QUERY_STATE message to validator chaincode support
-	payload := &pb.RangeQueryState{StartKey: startKey, EndKey: endKey}
+	// Send the query message to validator chaincode support
 	payloadBytes, err := proto.Marshal(payload)
 	if err != nil {
-		return nil, errors.New("Failed to process range query state request")
+		return nil, fmt.Errorf("Failed to process %s request", msgType)
 	}
-	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RANGE_QUERY_STATE, Payload: payloadBytes, Txid: txid}
-	chaincodeLogger.Debugf("[%s]Sending %s", shorttxid(msg.Txid), pb.ChaincodeMessage_RANGE_QUERY_STATE)
+	msg := &pb.ChaincodeMessage{Type: msgType, Payload: payloadBytes, Txid: txid}
+	chaincodeLogger.Debugf("[%s]Sending %s", shorttxid(msg.Txid), msgType)
 	if err = handler.serialSend(msg); err != nil {
-		chaincodeLogger.Errorf("[%s]error sending %s", shorttxid(msg.Txid), pb.ChaincodeMessage_RANGE_QUERY_STATE)
+		chaincodeLogger.Errorf("[%s]error sending %s", shorttxid(msg.Txid), msgType)
 		return nil, errors.New("could not send msg")
 	}
 
@@ -918,3 +927,69 @@ func getFunctionAndParams(stub ChaincodeStubInterface) (function string, params
 	}
 	return
 }
+
+func (handler *Handler) handleGetHistoryForKey(key string, txid string) (*pb.GetHistoryForKeyResponse, error) {
+	return handler.sendHistoryQuery(pb.ChaincodeMessage_GET_HISTORY_FOR_KEY, &pb.GetHistoryForKey{Key: key}, txid)
+}
+
+func (handler *Handler) handleGetHistoryForKeyNext(id, txid string) (*pb.GetHistoryForKeyResponse, error) {
+	return handler.sendHistoryQuery(pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_NEXT, &pb.GetHistoryForKeyNext{ID: id}, txid)
+}
+
+func (handler *Handler) handleGetHistoryForKeyClose(id, txid string) (*pb.GetHistoryForKeyResponse, error) {
+	return handler.sendHistoryQuery(pb.ChaincodeMessage_GET_HISTORY_FOR_KEY_CLOSE, &pb.GetHistoryForKeyClose{ID: id}, txid)
+}
+
+// sendHistoryQuery sends one of the GET_HISTORY_FOR_KEY messages to the validator
+// chaincode support and waits for the page of modifications it answers with
+func (handler *Handler) sendHistoryQuery(msgType pb.ChaincodeMessage_Type, payload proto.Message, txid string) (*pb.GetHistoryForKeyResponse, error) {
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(txid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Debugf("[%s]Another state request pending for this Txid. Cannot process.", shorttxid(txid))
+		return nil, uniqueReqErr
+	}
+
+	defer handler.deleteChannel(txid)
+
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to process %s request", msgType)
+	}
+	msg := &pb.ChaincodeMessage{Type: msgType, Payload: payloadBytes, Txid: txid}
+	chaincodeLogger.Debugf("[%s]Sending %s", shorttxid(msg.Txid), msgType)
+	if err = handler.serialSend(msg); err != nil {
+		chaincodeLogger.Errorf("[%s]error sending %s", shorttxid(msg.Txid), msgType)
+		return nil, errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Errorf("[%s]Received unexpected message type", txid)
+		return nil, errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debugf("[%s]Received %s. Successfully got history", shorttxid(responseMsg.Txid), pb.ChaincodeMessage_RESPONSE)
+
+		historyResponse := &pb.GetHistoryForKeyResponse{}
+		unmarshalErr := proto.Unmarshal(responseMsg.Payload, historyResponse)
+		if unmarshalErr != nil {
+			chaincodeLogger.Errorf("[%s]unmarshall error", shorttxid(responseMsg.Txid))
+			return nil, errors.New("Error unmarshalling GetHistoryForKeyResponse.")
+		}
+
+		return historyResponse, nil
+	}
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Errorf("[%s]Received %s", shorttxid(responseMsg.Txid), pb.ChaincodeMessage_ERROR)
+		return nil, errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Errorf("Incorrect chaincode message %s recieved. Expecting %s or %s", responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR)
+	return nil, errors.New("Incorrect chaincode message received")
+}
@@ -21,6 +21,7 @@ import (
 	gp "google/protobuf"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim/crypto/attr"
+	pb "github.com/hyperledger/fabric/protos"
 )
 
 // Chaincode interface must be implemented by all chaincodes. The fabric runs
@@ -73,6 +74,40 @@ type ChaincodeStubInterface interface {
 	// returned by the iterator is random.
 	RangeQueryState(startKey, endKey string) (StateRangeQueryIteratorInterface, error)
 
+	// RangeQueryStateWithOptions returns a page of the keys between startKey
+	// and endKey, inclusive, in lexical order or in reverse, with up to
+	// options.Limit keys after options.Bookmark. It also returns the bookmark
+	// of the next page, empty on the last page.
+	RangeQueryStateWithOptions(startKey, endKey string, options RangeQueryOptions) (StateRangeQueryIteratorInterface, string, error)
+
+	// CreateCompositeKey combines the given objectType and attributes to form a
+	// composite key, which can be used as the key of PutState. The objectType
+	// and attributes must be valid UTF-8 strings and must not contain U+0000
+	// nor U+10FFFF.
+	CreateCompositeKey(objectType string, attributes []string) (string, error)
+
+	// SplitCompositeKey splits the given composite key into the objectType and
+	// the attributes it was created from.
+	SplitCompositeKey(compositeKey string) (string, []string, error)
+
+	// GetStateByPartialCompositeKey returns an iterator over the keys of the
+	// state that start with the composite key of objectType and the given
+	// first attributes, which may be none. The order of the keys is the one
+	// of RangeQueryState.
+	GetStateByPartialCompositeKey(objectType string, attributes []string) (StateRangeQueryIteratorInterface, error)
+
+	// GetQueryResult returns an iterator over the values of the state that are
+	// JSON objects selected by a rich query, in lexical order of the keys. The
+	// peer must run with 'ledger.state.richQuery.enabled', and rich queries
+	// can only be run by queries, not by transactions.
+	GetQueryResult(query string) (StateRangeQueryIteratorInterface, error)
+
+	// GetHistoryForKey returns an iterator over the values written to `key` by
+	// committed transactions, oldest first. The peer must run with
+	// 'ledger.history.enabled', and only the blocks committed while it was
+	// enabled are covered. Writes of the running transaction are not included.
+	GetHistoryForKey(key string) (HistoryQueryIteratorInterface, error)
+
 	// CreateTable creates a new table given the table name and column definitions
 	CreateTable(name string, columnDefinitions []*ColumnDefinition) error
 
@@ -173,3 +208,19 @@ type StateRangeQueryIteratorInterface interface {
 	// reading from the iterator to free up resources.
 	Close() error
 }
+
+// HistoryQueryIteratorInterface allows a chaincode to iterate over the
+// modifications of a key.
+type HistoryQueryIteratorInterface interface {
+
+	// HasNext returns true if the history query iterator contains additional
+	// modifications.
+	HasNext() bool
+
+	// Next returns the next modification in the history query iterator.
+	Next() (*pb.KeyModification, error)
+
+	// Close closes the history query iterator. This should be called when done
+	// reading from the iterator to free up resources.
+	Close() error
+}
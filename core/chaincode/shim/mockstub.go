@@ -21,11 +21,13 @@ package shim
 import (
 	"container/list"
 	"errors"
+	"fmt"
 	"strings"
 
 	gp "google/protobuf"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim/crypto/attr"
+	pb "github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
 )
 
@@ -51,6 +53,10 @@ type MockStub struct {
 	// Keys stores the list of mapped values in lexical order
 	Keys *list.List
 
+	// History keeps the modifications of each key, oldest first. There are no
+	// blocks, so BlockNumber and TxIndex are left at 0
+	History map[string][]*pb.KeyModification
+
 	// registered list of other MockStub chaincodes that can be called from this MockStub
 	Invokables map[string]*MockStub
 
@@ -133,6 +139,7 @@ func (stub *MockStub) PutState(key string, value []byte) error {
 
 	mockLogger.Debug("MockStub", stub.Name, "Putting", key, value)
 	stub.State[key] = value
+	stub.History[key] = append(stub.History[key], &pb.KeyModification{TxID: stub.Uuid, Value: value})
 
 	// insert key into ordered list of keys
 	for elem := stub.Keys.Front(); elem != nil; elem = elem.Next() {
@@ -171,6 +178,7 @@ func (stub *MockStub) PutState(key string, value []byte) error {
 func (stub *MockStub) DelState(key string) error {
 	mockLogger.Debug("MockStub", stub.Name, "Deleting", key, stub.State[key])
 	delete(stub.State, key)
+	stub.History[key] = append(stub.History[key], &pb.KeyModification{TxID: stub.Uuid, IsDelete: true})
 
 	for elem := stub.Keys.Front(); elem != nil; elem = elem.Next() {
 		if strings.Compare(key, elem.Value.(string)) == 0 {
@@ -185,6 +193,71 @@ func (stub *MockStub) RangeQueryState(startKey, endKey string) (StateRangeQueryI
 	return NewMockStateRangeQueryIterator(stub, startKey, endKey), nil
 }
 
+// RangeQueryStateWithOptions returns a page of the keys between startKey and
+// endKey, see ChaincodeStub.RangeQueryStateWithOptions
+func (stub *MockStub) RangeQueryStateWithOptions(startKey, endKey string, options RangeQueryOptions) (StateRangeQueryIteratorInterface, string, error) {
+	if options.Limit < 0 {
+		return nil, "", fmt.Errorf("Invalid range query limit %d", options.Limit)
+	}
+	var keys []string
+	for elem := stub.Keys.Front(); elem != nil; elem = elem.Next() {
+		key := elem.Value.(string)
+		if key < startKey || (endKey != "" && key > endKey) {
+			continue
+		}
+		if options.Bookmark != "" && (!options.Reverse && key <= options.Bookmark || options.Reverse && key >= options.Bookmark) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if options.Reverse {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+	bookmark := ""
+	if options.Limit > 0 && len(keys) > options.Limit {
+		keys = keys[:options.Limit]
+		bookmark = keys[options.Limit-1]
+	}
+	return &MockStateRangeQueryPageIterator{Stub: stub, Keys: keys}, bookmark, nil
+}
+
+// CreateCompositeKey combines the given objectType and attributes to form a
+// composite key, see ChaincodeStub.CreateCompositeKey
+func (stub *MockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return createCompositeKey(objectType, attributes)
+}
+
+// SplitCompositeKey splits the given composite key into the objectType and
+// the attributes it was created from
+func (stub *MockStub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	return splitCompositeKey(compositeKey)
+}
+
+// GetStateByPartialCompositeKey returns an iterator over the keys that start
+// with the composite key of objectType and attributes, in lexical order
+func (stub *MockStub) GetStateByPartialCompositeKey(objectType string, attributes []string) (StateRangeQueryIteratorInterface, error) {
+	startKey, endKey, err := partialCompositeKeyRange(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	iter, _, err := stub.RangeQueryStateWithOptions(startKey, endKey, RangeQueryOptions{})
+	return iter, err
+}
+
+// GetHistoryForKey returns an iterator over the modifications of `key`. Unlike
+// the peer, the writes of the running transaction are included
+func (stub *MockStub) GetHistoryForKey(key string) (HistoryQueryIteratorInterface, error) {
+	return &MockHistoryQueryIterator{Modifications: stub.History[key]}, nil
+}
+
+// GetQueryResult is not supported by the MockStub, rich queries need the
+// document index of a peer
+func (stub *MockStub) GetQueryResult(query string) (StateRangeQueryIteratorInterface, error) {
+	return nil, errors.New("Rich queries are not supported by the MockStub")
+}
+
 // Not implemented
 func (stub *MockStub) CreateTable(name string, columnDefinitions []*ColumnDefinition) error {
 	return nil
@@ -315,6 +388,7 @@ func NewMockStub(name string, cc Chaincode) *MockStub {
 	s.State = make(map[string][]byte)
 	s.Invokables = make(map[string]*MockStub)
 	s.Keys = list.New()
+	s.History = make(map[string][]*pb.KeyModification)
 
 	return s
 }
@@ -421,6 +495,47 @@ func NewMockStateRangeQueryIterator(stub *MockStub, startKey string, endKey stri
 	return iter
 }
 
+// MockStateRangeQueryPageIterator iterates over the keys of a page of a range
+// query, in the order of the page
+type MockStateRangeQueryPageIterator struct {
+	Closed     bool
+	Stub       *MockStub
+	Keys       []string
+	currentLoc int
+}
+
+// HasNext returns true if the range query iterator contains additional keys
+// and values.
+func (iter *MockStateRangeQueryPageIterator) HasNext() bool {
+	return !iter.Closed && iter.currentLoc < len(iter.Keys)
+}
+
+// Next returns the next key and value in the range query iterator.
+func (iter *MockStateRangeQueryPageIterator) Next() (string, []byte, error) {
+	if iter.Closed {
+		mockLogger.Error("MockStateRangeQueryPageIterator.Next() called after Close()")
+		return "", nil, errors.New("MockStateRangeQueryPageIterator.Next() called after Close()")
+	}
+	if !iter.HasNext() {
+		mockLogger.Error("MockStateRangeQueryPageIterator.Next() called when it does not HaveNext()")
+		return "", nil, errors.New("MockStateRangeQueryPageIterator.Next() called when it does not HaveNext()")
+	}
+	key := iter.Keys[iter.currentLoc]
+	iter.currentLoc++
+	value, err := iter.Stub.GetState(key)
+	return key, value, err
+}
+
+// Close closes the range query iterator.
+func (iter *MockStateRangeQueryPageIterator) Close() error {
+	if iter.Closed {
+		mockLogger.Error("MockStateRangeQueryPageIterator.Close() called after Close()")
+		return errors.New("MockStateRangeQueryPageIterator.Close() called after Close()")
+	}
+	iter.Closed = true
+	return nil
+}
+
 func getBytes(function string, args []string) [][]byte {
 	bytes := make([][]byte, 0, len(args)+1)
 	bytes = append(bytes, []byte(function))
@@ -440,3 +555,44 @@ func getFuncArgs(bytes [][]byte) (string, []string) {
 	}
 	return function, args
 }
+
+/*****************************
+ History Query Iterator
+*****************************/
+
+type MockHistoryQueryIterator struct {
+	Closed        bool
+	Modifications []*pb.KeyModification
+	currentLoc    int
+}
+
+// HasNext returns true if the history query iterator contains additional
+// modifications.
+func (iter *MockHistoryQueryIterator) HasNext() bool {
+	return !iter.Closed && iter.currentLoc < len(iter.Modifications)
+}
+
+// Next returns the next modification in the history query iterator.
+func (iter *MockHistoryQueryIterator) Next() (*pb.KeyModification, error) {
+	if iter.Closed {
+		mockLogger.Error("MockHistoryQueryIterator.Next() called after Close()")
+		return nil, errors.New("MockHistoryQueryIterator.Next() called after Close()")
+	}
+	if !iter.HasNext() {
+		mockLogger.Error("MockHistoryQueryIterator.Next() called when it does not HaveNext()")
+		return nil, errors.New("MockHistoryQueryIterator.Next() called when it does not HaveNext()")
+	}
+	modification := iter.Modifications[iter.currentLoc]
+	iter.currentLoc++
+	return modification, nil
+}
+
+// Close closes the history query iterator.
+func (iter *MockHistoryQueryIterator) Close() error {
+	if iter.Closed {
+		mockLogger.Error("MockHistoryQueryIterator.Close() called after Close()")
+		return errors.New("MockHistoryQueryIterator.Close() called after Close()")
+	}
+	iter.Closed = true
+	return nil
+}
@@ -50,3 +50,117 @@ func TestMockStateRangeQueryIterator(t *testing.T) {
 		}
 	}
 }
+
+func TestMockRangeQueryStateWithOptions(t *testing.T) {
+	stub := NewMockStub("rangeOptionsTest", nil)
+	stub.MockTransactionStart("init")
+	for _, key := range []string{"3", "1", "5", "2", "4", "6"} {
+		stub.PutState(key, []byte(key))
+	}
+	stub.MockTransactionEnd("init")
+
+	collect := func(options RangeQueryOptions) (string, string) {
+		iter, bookmark, err := stub.RangeQueryStateWithOptions("2", "5", options)
+		if err != nil {
+			t.Fatalf("Error in range query: %s", err)
+		}
+		defer iter.Close()
+		keys := ""
+		for iter.HasNext() {
+			key, value, err := iter.Next()
+			if err != nil || string(value) != key {
+				t.Fatalf("Unexpected key %s, value %s, error %v", key, value, err)
+			}
+			keys += key
+		}
+		return keys, bookmark
+	}
+
+	if keys, bookmark := collect(RangeQueryOptions{Limit: 3}); keys != "234" || bookmark != "4" {
+		t.Fatalf("Expected keys 234 and bookmark 4, got %s and %s", keys, bookmark)
+	}
+	if keys, bookmark := collect(RangeQueryOptions{Limit: 3, Bookmark: "4"}); keys != "5" || bookmark != "" {
+		t.Fatalf("Expected keys 5 and no bookmark, got %s and %s", keys, bookmark)
+	}
+	if keys, bookmark := collect(RangeQueryOptions{Limit: 2, Reverse: true, Bookmark: "4"}); keys != "32" || bookmark != "" {
+		t.Fatalf("Expected keys 32 and no bookmark, got %s and %s", keys, bookmark)
+	}
+}
+
+func TestMockHistoryQueryIterator(t *testing.T) {
+	stub := NewMockStub("historyTest", nil)
+	stub.MockTransactionStart("tx1")
+	stub.PutState("a", []byte{61})
+	stub.PutState("b", []byte{62})
+	stub.MockTransactionEnd("tx1")
+	stub.MockTransactionStart("tx2")
+	stub.PutState("a", []byte{63})
+	stub.MockTransactionEnd("tx2")
+	stub.MockTransactionStart("tx3")
+	stub.DelState("a")
+	stub.MockTransactionEnd("tx3")
+
+	itr, err := stub.GetHistoryForKey("a")
+	if err != nil {
+		t.Fatalf("Error getting history: %s", err)
+	}
+	expectTxIDs := []string{"tx1", "tx2", "tx3"}
+	for i := 0; itr.HasNext(); i++ {
+		modification, err := itr.Next()
+		if err != nil {
+			t.Fatalf("Error reading history: %s", err)
+		}
+		if modification.TxID != expectTxIDs[i] {
+			t.Fatalf("Expected txid %s, got %s", expectTxIDs[i], modification.TxID)
+		}
+		if modification.IsDelete != (i == 2) {
+			t.Fatalf("Unexpected isDelete %t for %s", modification.IsDelete, modification.TxID)
+		}
+	}
+	itr.Close()
+	if _, err = itr.Next(); err == nil {
+		t.Fatalf("Next should fail after Close")
+	}
+}
+
+func TestMockGetStateByPartialCompositeKey(t *testing.T) {
+	stub := NewMockStub("compositeKeyTest", nil)
+	stub.MockTransactionStart("init")
+	for _, attributes := range [][]string{{"blue", "1"}, {"red", "2"}, {"blue", "3"}, {"bluegreen", "4"}} {
+		key, err := stub.CreateCompositeKey("color~id", attributes)
+		if err != nil {
+			t.Fatalf("Error creating composite key: %s", err)
+		}
+		stub.PutState(key, []byte(attributes[1]))
+	}
+	stub.PutState("blue", []byte("simple key"))
+	stub.MockTransactionEnd("init")
+
+	iter, err := stub.GetStateByPartialCompositeKey("color~id", []string{"blue"})
+	if err != nil {
+		t.Fatalf("Error in partial composite key query: %s", err)
+	}
+	defer iter.Close()
+	ids := ""
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			t.Fatalf("Error iterating partial composite key query: %s", err)
+		}
+		objectType, attributes, err := stub.SplitCompositeKey(key)
+		if err != nil || objectType != "color~id" || len(attributes) != 2 || attributes[0] != "blue" || attributes[1] != string(value) {
+			t.Fatalf("Unexpected composite key %q: %s %v %v", key, objectType, attributes, err)
+		}
+		ids += attributes[1]
+	}
+	if ids != "13" {
+		t.Fatalf("Expected ids 13, got %s", ids)
+	}
+
+	if _, err = stub.CreateCompositeKey("color~id", []string{"a\x00b"}); err == nil {
+		t.Fatalf("Expected an attribute with U+0000 to be rejected")
+	}
+	if _, _, err = stub.SplitCompositeKey("blue"); err == nil {
+		t.Fatalf("Expected a simple key not to split")
+	}
+}
@@ -48,7 +48,11 @@ func cacheConfiguration() {
 	}
 }
 
+// DbPluginName return cached value for "datastore.name" configuration value
 func DbPluginName() string {
+	if !configurationCached {
+		cacheConfiguration()
+	}
 	return dbPluginName
 }
 
@@ -0,0 +1,330 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package boltdb is a pure-Go, single-file datastore plugin built on bolt. Each of
+// the tables listed in 'db.ColumnFamilies' is a top level bucket. Snapshots are
+// read-only transactions and a write batch is committed as one read-write transaction,
+// so a block commit is atomic and synced to disk when Commit returns. Bolt does not
+// accept empty keys, which the ledger uses (the root of the state trie), so every key
+// is stored behind a one byte marker (see 'storedKey').
+package boltdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/op/go-logging"
+)
+
+// Name is the name under which this datastore is registered with 'db.Registry'
+const Name = `boltdb`
+
+// openTimeout bounds how long Start waits for the file lock held by another process
+const openTimeout = 5 * time.Second
+
+// initialMmapSize is the size the db file is mapped with. A read transaction that is
+// open while a commit grows the file beyond the mapped size blocks that commit, so the
+// mapping is made large enough that this does not happen in practice. The mapping is
+// virtual memory, the file itself grows only with the data
+const initialMmapSize = 1 << 30
+
+var dbLogger = logging.MustGetLogger("db")
+
+// OpenchainBoltDB implements the interface 'db.OpenchainDB' on top of bolt
+type OpenchainBoltDB struct {
+	DB *bolt.DB
+
+	// readTxs are the read-only transactions of the open iterators and snapshots.
+	// Bolt cannot close the file while a read transaction is open, Stop ends the
+	// ones that were never released
+	readTxsLock sync.Mutex
+	readTxs     map[*bolt.Tx]struct{}
+}
+
+// DbSnapshot implements the interface 'db.Snapshot' with a read-only transaction
+type DbSnapshot struct {
+	openchainDB *OpenchainBoltDB
+	tx          *bolt.Tx
+}
+
+func init() {
+	db.Registry.Add(Name, func() db.OpenchainDB { return &OpenchainBoltDB{} })
+}
+
+// Type returns the name of this datastore
+func (openchainDB *OpenchainBoltDB) Type() string {
+	return Name
+}
+
+// Start opens (or creates) the db file under 'peer.fileSystemPath' and creates a
+// bucket for each table that does not exist yet
+func (openchainDB *OpenchainBoltDB) Start() {
+	dbPath := db.GetDBPath("boltdb")
+	dbFile := filepath.Join(dbPath, "fabric.db")
+	dbLogger.Debugf("Opening DB at path [%s]", dbFile)
+	err := os.MkdirAll(dbPath, 0755)
+	if err != nil {
+		panic(fmt.Sprintf("Error making directory path [%s]: %s", dbPath, err))
+	}
+
+	boltDB, err := bolt.Open(dbFile, 0600, &bolt.Options{Timeout: openTimeout, InitialMmapSize: initialMmapSize})
+	if err != nil {
+		panic(fmt.Sprintf("Error opening DB: %s", err))
+	}
+	err = boltDB.Update(func(tx *bolt.Tx) error {
+		for _, cf := range db.ColumnFamilies {
+			if _, err := tx.CreateBucketIfNotExists([]byte(cf)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		boltDB.Close()
+		panic(fmt.Sprintf("Error creating buckets: %s", err))
+	}
+	openchainDB.DB = boltDB
+	openchainDB.readTxs = make(map[*bolt.Tx]struct{})
+}
+
+// Stop closes the db. Iterators and snapshots that are still open become unusable.
+// Note this method has no guarantee correct behavior concurrent invocation.
+func (openchainDB *OpenchainBoltDB) Stop() {
+	if openchainDB.DB == nil {
+		return
+	}
+	openchainDB.readTxsLock.Lock()
+	if len(openchainDB.readTxs) > 0 {
+		dbLogger.Warningf("Closing DB with [%d] iterators or snapshots that were not released", len(openchainDB.readTxs))
+	}
+	for tx := range openchainDB.readTxs {
+		tx.Rollback()
+	}
+	openchainDB.readTxs = nil
+	openchainDB.readTxsLock.Unlock()
+	if err := openchainDB.DB.Close(); err != nil {
+		dbLogger.Errorf("Error while closing DB: %s", err)
+	}
+	openchainDB.DB = nil
+}
+
+// GetFromBlockchain get value for given key from bucket - blockchainCF
+func (openchainDB *OpenchainBoltDB) GetFromBlockchain(key []byte) ([]byte, error) {
+	return openchainDB.get(db.BlockchainCF, key)
+}
+
+// GetFromState get value for given key from bucket - stateCF
+func (openchainDB *OpenchainBoltDB) GetFromState(key []byte) ([]byte, error) {
+	return openchainDB.get(db.StateCF, key)
+}
+
+// GetFromStateDelta get value for given key from bucket - stateDeltaCF
+func (openchainDB *OpenchainBoltDB) GetFromStateDelta(key []byte) ([]byte, error) {
+	return openchainDB.get(db.StateDeltaCF, key)
+}
+
+// GetFromPersistence get value for given key from bucket - persistCF
+func (openchainDB *OpenchainBoltDB) GetFromPersistence(key []byte) ([]byte, error) {
+	return openchainDB.get(db.PersistCF, key)
+}
+
+// GetFromIndexes get value for given key from bucket - indexesCF
+func (openchainDB *OpenchainBoltDB) GetFromIndexes(key []byte) ([]byte, error) {
+	return openchainDB.get(db.IndexesCF, key)
+}
+
+// GetSnapshot returns a point-in-time view of the DB. You MUST call snapshot.Release()
+// when you are done with the snapshot.
+func (openchainDB *OpenchainBoltDB) GetSnapshot() db.Snapshot {
+	tx, err := openchainDB.beginReadTx()
+	if err != nil {
+		panic(fmt.Sprintf("Error while taking DB snapshot: %s", err))
+	}
+	return &DbSnapshot{openchainDB, tx}
+}
+
+// Release - see interface 'db.Snapshot' for details
+func (snapshot *DbSnapshot) Release() {
+	snapshot.openchainDB.endReadTx(snapshot.tx)
+}
+
+// GetFromBlockchainSnapshot get value for given key from bucket - blockchainCF, as of the snapshot
+func (openchainDB *OpenchainBoltDB) GetFromBlockchainSnapshot(snapshot db.Snapshot, key []byte) ([]byte, error) {
+	return getFromTx(snapshot.(*DbSnapshot).tx, db.BlockchainCF, key), nil
+}
+
+// GetBlockchainIterator get iterator for bucket - blockchainCF
+func (openchainDB *OpenchainBoltDB) GetBlockchainIterator() db.Iterator {
+	return openchainDB.getIterator(db.BlockchainCF)
+}
+
+// GetStateIterator get iterator for bucket - stateCF
+func (openchainDB *OpenchainBoltDB) GetStateIterator() db.Iterator {
+	return openchainDB.getIterator(db.StateCF)
+}
+
+// GetStateDeltaIterator get iterator for bucket - stateDeltaCF
+func (openchainDB *OpenchainBoltDB) GetStateDeltaIterator() db.Iterator {
+	return openchainDB.getIterator(db.StateDeltaCF)
+}
+
+// GetPersistenceIterator get iterator for bucket - persistCF
+func (openchainDB *OpenchainBoltDB) GetPersistenceIterator() db.Iterator {
+	return openchainDB.getIterator(db.PersistCF)
+}
+
+// GetIndexesIterator get iterator for bucket - indexCF
+func (openchainDB *OpenchainBoltDB) GetIndexesIterator() db.Iterator {
+	return openchainDB.getIterator(db.IndexesCF)
+}
+
+// GetStateSnapshotIterator get iterator for bucket - stateCF. This iterator
+// is based on a snapshot and should be used for long running scans, such as
+// reading the entire state. Remember to call iterator.Close() when you are done.
+func (openchainDB *OpenchainBoltDB) GetStateSnapshotIterator(snapshot db.Snapshot) db.Iterator {
+	return newIterator(openchainDB, snapshot.(*DbSnapshot).tx, db.StateCF, false)
+}
+
+// PutToPersistence saves the key/value in bucket - persistCF
+func (openchainDB *OpenchainBoltDB) PutToPersistence(key []byte, value []byte) error {
+	err := openchainDB.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(db.PersistCF)).Put(storedKey(key), value)
+	})
+	if err != nil {
+		dbLogger.Errorf("Error while trying to write key: %s", key)
+		return err
+	}
+	return nil
+}
+
+// DeleteState deletes ALL state keys/values from the DB. This is generally
+// only used during state synchronization when creating a new state from
+// a snapshot.
+func (openchainDB *OpenchainBoltDB) DeleteState() error {
+	err := openchainDB.DB.Update(func(tx *bolt.Tx) error {
+		for _, cf := range []string{db.StateCF, db.StateDeltaCF} {
+			if err := tx.DeleteBucket([]byte(cf)); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket([]byte(cf)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		dbLogger.Errorf("Error deleting state: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Stats counts the keys and the size of each bucket. DiskSize is the size of the db
+// file. Bolt does not compact, so Compaction is empty
+func (openchainDB *OpenchainBoltDB) Stats() (*db.Stats, error) {
+	stats := &db.Stats{Type: Name}
+	err := openchainDB.DB.View(func(tx *bolt.Tx) error {
+		stats.DiskSize = uint64(tx.Size())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, cf := range db.ColumnFamilies {
+		stats.Tables = append(stats.Tables, db.ScanTableStats(openchainDB, cf))
+	}
+	return stats, nil
+}
+
+func (openchainDB *OpenchainBoltDB) get(cf string, key []byte) ([]byte, error) {
+	var value []byte
+	err := openchainDB.DB.View(func(tx *bolt.Tx) error {
+		value = getFromTx(tx, cf, key)
+		return nil
+	})
+	if err != nil {
+		dbLogger.Errorf("Error while trying to retrieve key: %s", key)
+		return nil, err
+	}
+	return value, nil
+}
+
+// getIterator returns an iterator that owns its read-only transaction
+func (openchainDB *OpenchainBoltDB) getIterator(cf string) db.Iterator {
+	tx, err := openchainDB.beginReadTx()
+	if err != nil {
+		panic(fmt.Sprintf("Error while creating iterator: %s", err))
+	}
+	return newIterator(openchainDB, tx, cf, true)
+}
+
+func (openchainDB *OpenchainBoltDB) beginReadTx() (*bolt.Tx, error) {
+	openchainDB.readTxsLock.Lock()
+	defer openchainDB.readTxsLock.Unlock()
+	tx, err := openchainDB.DB.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	openchainDB.readTxs[tx] = struct{}{}
+	return tx, nil
+}
+
+// endReadTx rolls back the transaction unless Stop already did
+func (openchainDB *OpenchainBoltDB) endReadTx(tx *bolt.Tx) {
+	openchainDB.readTxsLock.Lock()
+	defer openchainDB.readTxsLock.Unlock()
+	if _, ok := openchainDB.readTxs[tx]; ok {
+		delete(openchainDB.readTxs, tx)
+		tx.Rollback()
+	}
+}
+
+// getFromTx returns a copy of the value, bolt values are only valid for the life of the transaction
+func getFromTx(tx *bolt.Tx, cf string, key []byte) []byte {
+	value := getBucket(tx, cf).Get(storedKey(key))
+	if value == nil {
+		return nil
+	}
+	return makeCopy(value)
+}
+
+func getBucket(tx *bolt.Tx, cf string) *bolt.Bucket {
+	bucket := tx.Bucket([]byte(cf))
+	if bucket == nil {
+		panic(fmt.Sprintf("Unknown column family [%s]", cf))
+	}
+	return bucket
+}
+
+// keyMarker is the first byte of every key in a bucket
+const keyMarker = 0x00
+
+// storedKey returns the key as it is stored in a bucket. The marker keeps the order
+// of the keys and makes the empty key a valid bolt key
+func storedKey(key []byte) []byte {
+	return append([]byte{keyMarker}, key...)
+}
+
+func makeCopy(src []byte) []byte {
+	dest := make([]byte, len(src))
+	copy(dest, src)
+	return dest
+}
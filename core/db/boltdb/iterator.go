@@ -0,0 +1,149 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package boltdb
+
+import (
+	"bytes"
+
+	"github.com/boltdb/bolt"
+)
+
+// positions of the iterator outside of the keys of the bucket
+const (
+	atKey = iota
+	beforeFirst
+	afterLast
+)
+
+// DbIterator implements the interface 'db.Iterator' with a bolt cursor. The iterator
+// reads the bucket as of the transaction it was created in
+type DbIterator struct {
+	openchainDB *OpenchainBoltDB
+	tx          *bolt.Tx
+	ownsTx      bool
+	cursor      *bolt.Cursor
+	position    int
+	key         []byte
+	value       []byte
+}
+
+// newIterator returns an iterator over the bucket cf. If ownsTx is set the
+// transaction is rolled back when the iterator is closed
+func newIterator(openchainDB *OpenchainBoltDB, tx *bolt.Tx, cf string, ownsTx bool) *DbIterator {
+	return &DbIterator{openchainDB: openchainDB, tx: tx, ownsTx: ownsTx, cursor: getBucket(tx, cf).Cursor(), position: afterLast}
+}
+
+// Valid returns true if the iterator is positioned at a key
+func (iterator *DbIterator) Valid() bool {
+	return iterator.position == atKey
+}
+
+// Next moves to the next key. Calling Next after moving before the first key
+// positions the iterator at the first key
+func (iterator *DbIterator) Next() {
+	switch iterator.position {
+	case beforeFirst:
+		iterator.SeekToFirst()
+	case atKey:
+		key, value := iterator.cursor.Next()
+		iterator.set(key, value, afterLast)
+	}
+}
+
+// Prev moves to the previous key. Calling Prev after moving past the last key
+// positions the iterator at the last key
+func (iterator *DbIterator) Prev() {
+	switch iterator.position {
+	case afterLast:
+		iterator.SeekToLast()
+	case atKey:
+		key, value := iterator.cursor.Prev()
+		iterator.set(key, value, beforeFirst)
+	}
+}
+
+// SeekToFirst moves to the first key
+func (iterator *DbIterator) SeekToFirst() {
+	key, value := iterator.cursor.First()
+	iterator.set(key, value, afterLast)
+}
+
+// SeekToLast moves to the last key
+func (iterator *DbIterator) SeekToLast() {
+	key, value := iterator.cursor.Last()
+	iterator.set(key, value, beforeFirst)
+}
+
+// Seek moves to the first key that is greater than or equal to the given key
+func (iterator *DbIterator) Seek(key []byte) {
+	key, value := iterator.cursor.Seek(storedKey(key))
+	iterator.set(key, value, afterLast)
+}
+
+// set records the cursor position. A nil key means the cursor ran off the bucket,
+// in which case the iterator is positioned at 'outside'
+func (iterator *DbIterator) set(key []byte, value []byte, outside int) {
+	if key != nil {
+		iterator.key, iterator.value = key[1:], value
+		iterator.position = atKey
+	} else {
+		iterator.key, iterator.value = nil, nil
+		iterator.position = outside
+	}
+}
+
+// KeyData returns the key at the current position. The bytes are only valid
+// until the iterator is closed
+func (iterator *DbIterator) KeyData() []byte {
+	return iterator.key
+}
+
+// KeySize returns the length of the key at the current position
+func (iterator *DbIterator) KeySize() int {
+	return len(iterator.key)
+}
+
+// FreeKey is a no-op, the key belongs to the transaction
+func (iterator *DbIterator) FreeKey() {
+}
+
+// ValueData returns the value at the current position. The bytes are only valid
+// until the iterator is closed
+func (iterator *DbIterator) ValueData() []byte {
+	return iterator.value
+}
+
+// ValueSize returns the length of the value at the current position
+func (iterator *DbIterator) ValueSize() int {
+	return len(iterator.value)
+}
+
+// FreeValue is a no-op, the value belongs to the transaction
+func (iterator *DbIterator) FreeValue() {
+}
+
+// ValidForPrefix returns true if the iterator is positioned at a key that starts with prefix
+func (iterator *DbIterator) ValidForPrefix(prefix []byte) bool {
+	return iterator.Valid() && bytes.HasPrefix(iterator.key, prefix)
+}
+
+// Close ends the read-only transaction the iterator owns
+func (iterator *DbIterator) Close() {
+	if iterator.ownsTx {
+		iterator.openchainDB.endReadTx(iterator.tx)
+	}
+}
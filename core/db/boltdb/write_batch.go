@@ -0,0 +1,82 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package boltdb
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/hyperledger/fabric/core/db"
+)
+
+// WriteBatch implements the interface 'db.WriteBatch'. The changes are recorded and
+// applied in a single read-write transaction on Commit
+type WriteBatch struct {
+	openchainDB *OpenchainBoltDB
+	ops         []batchOp
+}
+
+type batchOp struct {
+	cf     string
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// NewWriteBatch returns an empty batch for this db
+func (openchainDB *OpenchainBoltDB) NewWriteBatch() db.WriteBatch {
+	return &WriteBatch{openchainDB: openchainDB}
+}
+
+// Put - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Put(cf string, key []byte, value []byte) {
+	writeBatch.ops = append(writeBatch.ops, batchOp{cf, storedKey(key), makeCopy(value), false})
+}
+
+// Delete - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Delete(cf string, key []byte) {
+	writeBatch.ops = append(writeBatch.ops, batchOp{cf, storedKey(key), nil, true})
+}
+
+// Commit - see interface 'db.WriteBatch' for details. Bolt syncs every read-write
+// transaction, so the changes are on stable storage when Commit returns whatever the
+// write options say
+func (writeBatch *WriteBatch) Commit(opts *db.WriteOptions) error {
+	err := writeBatch.openchainDB.DB.Update(func(tx *bolt.Tx) error {
+		for _, op := range writeBatch.ops {
+			bucket := getBucket(tx, op.cf)
+			var err error
+			if op.delete {
+				err = bucket.Delete(op.key)
+			} else {
+				err = bucket.Put(op.key, op.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		dbLogger.Errorf("Error while committing write batch: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Destroy - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Destroy() {
+	writeBatch.ops = nil
+}
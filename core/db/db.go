@@ -1,5 +1,24 @@
 package db
 
+// Names of the tables (column families in RocksDB terms) that every
+// datastore plugin has to provide
+const (
+	BlockchainCF = "blockchainCF" // blocks of the block chain
+	StateCF      = "stateCF"      // world state
+	StateDeltaCF = "stateDeltaCF" // open transaction state
+	IndexesCF    = "indexesCF"    // tx uuid -> blockno
+	PersistCF    = "persistCF"    // persistent per-peer state (consensus)
+)
+
+// ColumnFamilies lists the names of all the tables a datastore provides
+var ColumnFamilies = []string{
+	BlockchainCF,
+	StateCF,
+	StateDeltaCF,
+	IndexesCF,
+	PersistCF,
+}
+
 type ConnnectionManager interface {
 	Stop()
 	Start()
@@ -9,6 +28,15 @@ type OpenchainDB interface {
 	ConnnectionManager
 	StateManager
 	Type() string
+	// Stats reports the size of the datastore and of each of its tables
+	Stats() (*Stats, error)
+}
+
+// Purger is implemented by datastores that do not keep their data under
+// 'peer.fileSystemPath', for instance the in-memory store. Purge drops all
+// the data, like removing the db directory does for the on-disk stores
+type Purger interface {
+	Purge()
 }
 
 type StateManager interface {
@@ -19,22 +47,53 @@ type StateManager interface {
 	GetFromPersistence([]byte) ([]byte, error)
 	GetFromIndexes(key []byte) ([]byte, error)
 
-        // GetSnapshot method gives a snapshot of the DB at a given point in time
+	// GetSnapshot method gives a snapshot of the DB at a given point in time
 	GetSnapshot() Snapshot
 	GetFromBlockchainSnapshot(snapshot Snapshot, key []byte) ([]byte, error)
 
-        // Iterators for dealing with different DB tables or column families
+	// Iterators for dealing with different DB tables or column families
 	GetBlockchainIterator() Iterator
 	GetStateSnapshotIterator(snapshot Snapshot) Iterator
 	GetStateIterator() Iterator
 	GetStateDeltaIterator() Iterator
+	GetPersistenceIterator() Iterator
+	GetIndexesIterator() Iterator
 
 	//A Put method to interact with the Put column family
 	PutToPersistence(key []byte, value []byte) error
 	DeleteState() error
-}
-
 
+	// NewWriteBatch returns an empty batch. Changes added to the batch are
+	// written atomically, across all the tables, when the batch is committed
+	NewWriteBatch() WriteBatch
+}
 
+// WriteOptions controls how a WriteBatch is committed
+type WriteOptions struct {
+	// Sync makes Commit return only after the changes have been flushed
+	// to stable storage. An async commit may lose the most recent writes
+	// on a machine crash, but never leaves a batch partially applied.
+	Sync bool
+}
 
+var (
+	// SyncWrite flushes the batch to stable storage before returning
+	SyncWrite = &WriteOptions{Sync: true}
+	// AsyncWrite leaves flushing to the datastore
+	AsyncWrite = &WriteOptions{Sync: false}
+)
 
+// WriteBatch collects puts and deletes for one or more tables. The
+// tables are identified by the names listed in ColumnFamilies
+type WriteBatch interface {
+	// Put adds the key/value to the given table
+	Put(cf string, key []byte, value []byte)
+	// Delete removes the key from the given table
+	Delete(cf string, key []byte)
+	// Commit writes all the changes in the batch atomically. A nil opts
+	// is the same as AsyncWrite
+	Commit(opts *WriteOptions) error
+	// Destroy releases the resources held by the batch. The batch cannot
+	// be used after this call
+	Destroy()
+}
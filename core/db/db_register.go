@@ -1,58 +1,136 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
 package db
 
 import (
 	"fmt"
-	"errors"
+	"sort"
+	"sync"
 )
 
+// Constructor returns a new, not yet started, instance of a datastore
 type Constructor func() OpenchainDB
 
+// dbRegistry keeps the datastore plugins by name. The registry owns one instance per
+// plugin: it is built on the first Open and kept for the life of the process, so every
+// caller of Get shares the same started instance
 type dbRegistry struct {
-	dbs map[string]Constructor
+	lock      sync.RWMutex
+	dbs       map[string]Constructor
+	instances map[string]*registeredDB
 }
 
-var all_registered []string
+type registeredDB struct {
+	db      OpenchainDB
+	started bool
+}
 
+// Registry is the registry the datastore plugins add themselves to, usually from the
+// init function of the plugin package
 var Registry = &dbRegistry{
-	dbs: make(map[string]Constructor),
+	dbs:       make(map[string]Constructor),
+	instances: make(map[string]*registeredDB),
 }
 
-func (r *dbRegistry) Add(name string, constructor Constructor) (OpenchainDB, error) {
-	all_registered = r.Registered()
-	if contains(all_registered,name) {
-		return nil, errors.New("openchain db: " + name + " is already registered")
+// Add registers the constructor of a datastore under the given name
+func (r *dbRegistry) Add(name string, constructor Constructor) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.dbs[name]; ok {
+		return fmt.Errorf("Datastore [%s] is already registered", name)
 	}
 	r.dbs[name] = constructor
+	return nil
+}
 
-	return constructor(),nil
+// Open starts the datastore registered under name, unless it is already started,
+// and returns it
+func (r *dbRegistry) Open(name string) (OpenchainDB, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	instance, err := r.instance(name)
+	if err != nil {
+		return nil, err
+	}
+	if !instance.started {
+		instance.db.Start()
+		instance.started = true
+	}
+	return instance.db, nil
 }
 
-func (r *dbRegistry) Get(name string) (OpenchainDB, error) {
-	constructor, ok := r.dbs[name]
-	if !ok {
-		return nil, fmt.Errorf("Unregistered db type: %s", name)
+// Close stops the datastore registered under name. The instance is kept, a later
+// Open starts it again. Closing a datastore that is not started does nothing
+func (r *dbRegistry) Close(name string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	instance, err := r.instance(name)
+	if err != nil {
+		return err
+	}
+	if instance.started {
+		instance.db.Stop()
+		instance.started = false
 	}
+	return nil
+}
 
-	return constructor(), nil
+// Get returns the started datastore registered under name. It returns an error if
+// no datastore is registered under name or if it has not been opened
+func (r *dbRegistry) Get(name string) (OpenchainDB, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if _, ok := r.dbs[name]; !ok {
+		return nil, fmt.Errorf("Unregistered db type: %s. Registered types are %v", name, r.registered())
+	}
+	instance, ok := r.instances[name]
+	if !ok || !instance.started {
+		return nil, fmt.Errorf("Datastore [%s] is not started", name)
+	}
+	return instance.db, nil
 }
 
+// Registered returns the sorted names of the registered datastores
 func (r *dbRegistry) Registered() []string {
-	names := make([]string, len(r.dbs))
-	i := 0
-	for k := range r.dbs {
-		names[i] = k
-		i++
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.registered()
+}
+
+func (r *dbRegistry) registered() []string {
+	names := make([]string, 0, len(r.dbs))
+	for name := range r.dbs {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
-func contains(s []string, e string) bool {
-	for _, a := range s {
-		if a == e {
-			return true
-		}
+// instance returns the instance for name, building it on first use. The caller must
+// hold the write lock
+func (r *dbRegistry) instance(name string) (*registeredDB, error) {
+	if instance, ok := r.instances[name]; ok {
+		return instance, nil
 	}
-	return false
+	constructor, ok := r.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("Unregistered db type: %s. Registered types are %v", name, r.registered())
+	}
+	instance := &registeredDB{db: constructor()}
+	r.instances[name] = instance
+	return instance, nil
 }
-
-
@@ -0,0 +1,128 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingDB counts the calls to Start and Stop. The StateManager methods are not
+// used by these tests
+type countingDB struct {
+	OpenchainDB
+	starts int
+	stops  int
+}
+
+func (countingDB *countingDB) Start() { countingDB.starts++ }
+func (countingDB *countingDB) Stop()  { countingDB.stops++ }
+
+func newTestRegistry() (*dbRegistry, *int) {
+	registry := &dbRegistry{
+		dbs:       make(map[string]Constructor),
+		instances: make(map[string]*registeredDB),
+	}
+	constructed := 0
+	registry.Add("counting", func() OpenchainDB {
+		constructed++
+		return &countingDB{}
+	})
+	return registry, &constructed
+}
+
+func TestRegistry_AddTwice(t *testing.T) {
+	registry, _ := newTestRegistry()
+	if err := registry.Add("counting", nil); err == nil {
+		t.Fatalf("Registering a datastore twice should fail")
+	}
+}
+
+func TestRegistry_Unknown(t *testing.T) {
+	registry, _ := newTestRegistry()
+	if _, err := registry.Get("unknown"); err == nil || !strings.Contains(err.Error(), "Unregistered db type: unknown") {
+		t.Fatalf("Expected an error for an unregistered datastore, found [%v]", err)
+	}
+	if _, err := registry.Open("unknown"); err == nil {
+		t.Fatalf("Opening an unregistered datastore should fail")
+	}
+	if err := registry.Close("unknown"); err == nil {
+		t.Fatalf("Closing an unregistered datastore should fail")
+	}
+}
+
+func TestRegistry_NotStarted(t *testing.T) {
+	registry, _ := newTestRegistry()
+	if _, err := registry.Get("counting"); err == nil || !strings.Contains(err.Error(), "not started") {
+		t.Fatalf("Expected an error for a datastore that is not started, found [%v]", err)
+	}
+}
+
+func TestRegistry_Lifecycle(t *testing.T) {
+	registry, constructed := newTestRegistry()
+	opened, err := registry.Open("counting")
+	if err != nil {
+		t.Fatalf("Error while opening: %s", err)
+	}
+	registry.Open("counting")
+	got, err := registry.Get("counting")
+	if err != nil {
+		t.Fatalf("Error while getting an open datastore: %s", err)
+	}
+	if got != opened {
+		t.Fatalf("Get should return the instance returned by Open")
+	}
+	instance := opened.(*countingDB)
+	if instance.starts != 1 {
+		t.Fatalf("Expected the datastore to be started once, found [%d]", instance.starts)
+	}
+
+	registry.Close("counting")
+	registry.Close("counting")
+	if instance.stops != 1 {
+		t.Fatalf("Expected the datastore to be stopped once, found [%d]", instance.stops)
+	}
+	if _, err := registry.Get("counting"); err == nil {
+		t.Fatalf("Get should fail after Close")
+	}
+
+	reopened, _ := registry.Open("counting")
+	if reopened != opened || instance.starts != 2 {
+		t.Fatalf("Open after Close should start the same instance again")
+	}
+	if *constructed != 1 {
+		t.Fatalf("Expected one instance to be built, found [%d]", *constructed)
+	}
+}
+
+func TestRegistry_ConcurrentGet(t *testing.T) {
+	registry, _ := newTestRegistry()
+	registry.Open("counting")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := registry.Get("counting"); err != nil {
+				t.Errorf("Error while getting an open datastore: %s", err)
+			}
+			registry.Registered()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,158 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/spf13/viper"
+)
+
+// TestDBWrapper wraps the datastore configured by 'datastore.name'. Can be used by
+// other modules for testing. The test package has to import the datastore plugin
+// (for instance, _ "github.com/hyperledger/fabric/core/db/rocksdb") so that it is
+// registered with the Registry
+type TestDBWrapper struct {
+	performCleanup bool
+}
+
+// NewTestDBWrapper constructs a new TestDBWrapper
+func NewTestDBWrapper() *TestDBWrapper {
+	return &TestDBWrapper{}
+}
+
+///////////////////////////
+// Test db creation and cleanup functions
+
+// CleanDB This method closes existing db, remove the db dir.
+// Can be called before starting a test so that data from other tests does not interfere
+func (testDB *TestDBWrapper) CleanDB(t testing.TB) {
+	// cleaning up test db here so that each test does not have to call it explicitly
+	// at the end of the test
+	testDB.cleanup()
+	testDB.removeDBPath()
+	t.Logf("Creating testDB")
+	testDB.OpenDB(t)
+}
+
+// CreateFreshDBGinkgo creates a fresh database for ginkgo testing
+func (testDB *TestDBWrapper) CreateFreshDBGinkgo() {
+	// cleaning up test db here so that each test does not have to call it explicitly
+	// at the end of the test
+	testDB.cleanup()
+	testDB.removeDBPath()
+	if _, err := Registry.Open(comm.DbPluginName()); err != nil {
+		panic(err)
+	}
+	testDB.performCleanup = true
+}
+
+func (testDB *TestDBWrapper) cleanup() {
+	if testDB.performCleanup {
+		Registry.Close(comm.DbPluginName())
+		testDB.performCleanup = false
+	}
+}
+
+func (testDB *TestDBWrapper) removeDBPath() {
+	dbPath := viper.GetString("peer.fileSystemPath")
+	os.RemoveAll(dbPath)
+	// the datastore is closed at this point, so it is not available through Get
+	Registry.lock.Lock()
+	instance, err := Registry.instance(comm.DbPluginName())
+	Registry.lock.Unlock()
+	if err != nil {
+		return
+	}
+	if purger, ok := instance.db.(Purger); ok {
+		purger.Purge()
+	}
+}
+
+func (testDB *TestDBWrapper) getDB(t testing.TB) OpenchainDB {
+	openchainDB, err := Registry.Get(comm.DbPluginName())
+	if err != nil {
+		t.Fatalf("Error while getting the datastore [%s]. Error:%s", comm.DbPluginName(), err)
+	}
+	return openchainDB
+}
+
+// WriteToDB tests can use this method for persisting a given batch to db
+func (testDB *TestDBWrapper) WriteToDB(t testing.TB, writeBatch WriteBatch) {
+	err := writeBatch.Commit(AsyncWrite)
+	if err != nil {
+		t.Fatalf("Error while writing to db. Error:%s", err)
+	}
+}
+
+// GetFromStateCF tests can use this method for getting value from StateCF column-family
+func (testDB *TestDBWrapper) GetFromStateCF(t testing.TB, key []byte) []byte {
+	value, err := testDB.getDB(t).GetFromState(key)
+	if err != nil {
+		t.Fatalf("Error while getting from db. Error:%s", err)
+	}
+	return value
+}
+
+// GetFromStateDeltaCF tests can use this method for getting value from StateDeltaCF column-family
+func (testDB *TestDBWrapper) GetFromStateDeltaCF(t testing.TB, key []byte) []byte {
+	value, err := testDB.getDB(t).GetFromStateDelta(key)
+	if err != nil {
+		t.Fatalf("Error while getting from db. Error:%s", err)
+	}
+	return value
+}
+
+// CloseDB closes the db
+func (testDB *TestDBWrapper) CloseDB(t testing.TB) {
+	if err := Registry.Close(comm.DbPluginName()); err != nil {
+		t.Fatalf("Error while closing the datastore [%s]. Error:%s", comm.DbPluginName(), err)
+	}
+	testDB.performCleanup = false
+}
+
+// OpenDB opens the db
+func (testDB *TestDBWrapper) OpenDB(t testing.TB) {
+	if _, err := Registry.Open(comm.DbPluginName()); err != nil {
+		t.Fatalf("Error while opening the datastore [%s]. Error:%s", comm.DbPluginName(), err)
+	}
+	testDB.performCleanup = true
+}
+
+// GetEstimatedNumKeys returns the number of key-values in the tables that can be iterated
+// through the StateManager interface. Unlike the rocksdb property this used to be based on,
+// the count is exact but requires a full scan of each table
+func (testDB *TestDBWrapper) GetEstimatedNumKeys(t testing.TB) map[string]string {
+	openchainDB := testDB.getDB(t)
+	result := make(map[string]string, 3)
+	result[StateCF] = strconv.Itoa(countKeys(openchainDB.GetStateIterator()))
+	result[StateDeltaCF] = strconv.Itoa(countKeys(openchainDB.GetStateDeltaIterator()))
+	result[BlockchainCF] = strconv.Itoa(countKeys(openchainDB.GetBlockchainIterator()))
+	return result
+}
+
+func countKeys(itr Iterator) int {
+	defer itr.Close()
+	count := 0
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		count++
+	}
+	return count
+}
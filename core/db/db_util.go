@@ -0,0 +1,84 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// GetDBPath returns the path under 'peer.fileSystemPath' where a datastore keeps its
+// files. Each datastore uses its own dirName so that stores of different types can
+// live side by side under the same peer
+func GetDBPath(dirName string) string {
+	dbPath := viper.GetString("peer.fileSystemPath")
+	if dbPath == "" {
+		panic("DB path not specified in configuration file. Please check that property 'peer.fileSystemPath' is set")
+	}
+	if !strings.HasSuffix(dbPath, "/") {
+		dbPath = dbPath + "/"
+	}
+	return dbPath + dirName
+}
+
+// DirMissingOrEmpty returns true if the given directory does not exist or is empty
+func DirMissingOrEmpty(path string) (bool, error) {
+	dirExists, err := dirExists(path)
+	if err != nil {
+		return false, err
+	}
+	if !dirExists {
+		return true, nil
+	}
+
+	dirEmpty, err := dirEmpty(path)
+	if err != nil {
+		return false, err
+	}
+	if dirEmpty {
+		return true, nil
+	}
+	return false, nil
+}
+
+func dirExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func dirEmpty(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	_, err = f.Readdir(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
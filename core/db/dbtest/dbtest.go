@@ -0,0 +1,470 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dbtest is the conformance suite for 'db.OpenchainDB' implementations. It
+// spells out, as tests, what the ledger expects from a datastore beyond the method
+// signatures. A plugin runs it from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		dbtest.RunConformanceTests(t, &OpenchainLevelDB{})
+//	}
+//
+// A plugin that stores its data under 'peer.fileSystemPath' has to point it to a
+// scratch directory; the directory is removed between tests. A plugin that keeps its
+// data elsewhere implements 'db.Purger'.
+package dbtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/spf13/viper"
+)
+
+// conformanceTests lists the tests run by RunConformanceTests. Each test starts
+// with an empty, started datastore and the datastore is stopped when it returns
+var conformanceTests = []struct {
+	name string
+	test func(t *testing.T, openchainDB db.OpenchainDB)
+}{
+	{"MissingKey", testMissingKey},
+	{"TablesAreIndependent", testTablesAreIndependent},
+	{"WriteBatchOverwriteAndDelete", testWriteBatchOverwriteAndDelete},
+	{"WriteBatchDestroyWithoutCommit", testWriteBatchDestroyWithoutCommit},
+	{"ValuesAreCopies", testValuesAreCopies},
+	{"PutToPersistence", testPutToPersistence},
+	{"IteratorOrdering", testIteratorOrdering},
+	{"IteratorReverse", testIteratorReverse},
+	{"IteratorSeek", testIteratorSeek},
+	{"IteratorValidForPrefix", testIteratorValidForPrefix},
+	{"IteratorStaysWithinTable", testIteratorStaysWithinTable},
+	{"IteratorIsolation", testIteratorIsolation},
+	{"SnapshotIsolation", testSnapshotIsolation},
+	{"DeleteState", testDeleteState},
+	{"Restart", testRestart},
+	{"Stats", testStats},
+}
+
+// RunConformanceTests runs the conformance suite against openchainDB, which must
+// not be started. The name of each test is logged before it runs, so that a failure
+// is reported under the test it belongs to
+func RunConformanceTests(t *testing.T, openchainDB db.OpenchainDB) {
+	for _, conformanceTest := range conformanceTests {
+		t.Logf("Running conformance test [%s]", conformanceTest.name)
+		runConformanceTest(t, openchainDB, conformanceTest.test)
+	}
+}
+
+func runConformanceTest(t *testing.T, openchainDB db.OpenchainDB, test func(t *testing.T, openchainDB db.OpenchainDB)) {
+	cleanDB(t, openchainDB)
+	openchainDB.Start()
+	defer openchainDB.Stop()
+	test(t, openchainDB)
+}
+
+func cleanDB(t *testing.T, openchainDB db.OpenchainDB) {
+	if dbPath := viper.GetString("peer.fileSystemPath"); dbPath != "" {
+		if err := os.RemoveAll(dbPath); err != nil {
+			t.Fatalf("Error while removing [%s]: %s", dbPath, err)
+		}
+	}
+	if purger, ok := openchainDB.(db.Purger); ok {
+		purger.Purge()
+	}
+}
+
+// A datastore returns nil, and no error, for a key that is not present
+func testMissingKey(t *testing.T, openchainDB db.OpenchainDB) {
+	for cf, get := range getters(openchainDB) {
+		assertNil(t, cf, get, "missing")
+	}
+}
+
+// The same key in different tables holds different values
+func testTablesAreIndependent(t *testing.T, openchainDB db.OpenchainDB) {
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	for _, cf := range db.ColumnFamilies {
+		writeBatch.Put(cf, []byte("key"), []byte("value_"+cf))
+	}
+	commit(t, writeBatch)
+	for cf, get := range getters(openchainDB) {
+		assertValue(t, cf, get, "key", "value_"+cf)
+	}
+
+	writeBatch = openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Delete(db.StateCF, []byte("key"))
+	commit(t, writeBatch)
+	for cf, get := range getters(openchainDB) {
+		if cf == db.StateCF {
+			assertNil(t, cf, get, "key")
+		} else {
+			assertValue(t, cf, get, "key", "value_"+cf)
+		}
+	}
+}
+
+// Changes in a batch are applied in the order they were added
+func testWriteBatchOverwriteAndDelete(t *testing.T, openchainDB db.OpenchainDB) {
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Put(db.StateCF, []byte("key1"), []byte("value1"))
+	writeBatch.Put(db.StateCF, []byte("key1"), []byte("value1_new"))
+	writeBatch.Put(db.StateCF, []byte("key2"), []byte("value2"))
+	writeBatch.Delete(db.StateCF, []byte("key2"))
+	writeBatch.Delete(db.StateCF, []byte("key3"))
+	writeBatch.Put(db.StateCF, []byte("key3"), []byte("value3"))
+	commit(t, writeBatch)
+
+	assertValue(t, db.StateCF, openchainDB.GetFromState, "key1", "value1_new")
+	assertNil(t, db.StateCF, openchainDB.GetFromState, "key2")
+	assertValue(t, db.StateCF, openchainDB.GetFromState, "key3", "value3")
+}
+
+// Nothing is written for a batch that is destroyed without a commit
+func testWriteBatchDestroyWithoutCommit(t *testing.T, openchainDB db.OpenchainDB) {
+	writeBatch := openchainDB.NewWriteBatch()
+	writeBatch.Put(db.StateCF, []byte("key"), []byte("value"))
+	writeBatch.Destroy()
+	assertNil(t, db.StateCF, openchainDB.GetFromState, "key")
+}
+
+// The batch keeps its own copy of keys and values, and callers may modify the
+// values returned by the get methods
+func testValuesAreCopies(t *testing.T, openchainDB db.OpenchainDB) {
+	key := []byte("key")
+	value := []byte("value")
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Put(db.StateCF, key, value)
+	key[0] = 'K'
+	value[0] = 'V'
+	commit(t, writeBatch)
+	assertValue(t, db.StateCF, openchainDB.GetFromState, "key", "value")
+
+	got, _ := openchainDB.GetFromState([]byte("key"))
+	got[0] = 'V'
+	assertValue(t, db.StateCF, openchainDB.GetFromState, "key", "value")
+}
+
+// PutToPersistence writes to the persistCF table only
+func testPutToPersistence(t *testing.T, openchainDB db.OpenchainDB) {
+	if err := openchainDB.PutToPersistence([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Error while writing: %s", err)
+	}
+	for cf, get := range getters(openchainDB) {
+		if cf == db.PersistCF {
+			assertValue(t, cf, get, "key", "value")
+		} else {
+			assertNil(t, cf, get, "key")
+		}
+	}
+	assertKeys(t, openchainDB.GetPersistenceIterator(), []string{"key"})
+}
+
+// Iterators return the keys in bytewise order, whatever the order they were written
+// in. The empty key and keys with 0x00 and 0xff bytes are valid keys
+func testIteratorOrdering(t *testing.T, openchainDB db.OpenchainDB) {
+	keys := []string{"b", "\xff", "a\x00", "", "a", "ab", "\x00", "a\xff"}
+	writeKeys(t, openchainDB, db.StateCF, keys...)
+	assertKeys(t, openchainDB.GetStateIterator(),
+		[]string{"", "\x00", "a", "a\x00", "ab", "a\xff", "b", "\xff"})
+
+	itr := openchainDB.GetStateIterator()
+	defer itr.Close()
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		key := string(itr.KeyData())
+		if itr.KeySize() != len(key) {
+			t.Fatalf("KeySize [%d] does not match the key [%x]", itr.KeySize(), key)
+		}
+		value := itr.ValueData()
+		if string(value) != "value_"+key {
+			t.Fatalf("Expected value [%s] for key [%x], found [%s]", "value_"+key, key, value)
+		}
+		if itr.ValueSize() != len(value) {
+			t.Fatalf("ValueSize [%d] does not match the value [%s]", itr.ValueSize(), value)
+		}
+	}
+}
+
+// SeekToLast and Prev walk the keys backwards
+func testIteratorReverse(t *testing.T, openchainDB db.OpenchainDB) {
+	writeKeys(t, openchainDB, db.StateCF, "a", "b", "c")
+	itr := openchainDB.GetStateIterator()
+	defer itr.Close()
+	var keys []string
+	for itr.SeekToLast(); itr.Valid(); itr.Prev() {
+		keys = append(keys, string(itr.KeyData()))
+	}
+	assertEqualKeys(t, []string{"c", "b", "a"}, keys)
+}
+
+// Seek positions the iterator at the first key greater than or equal to the given key
+func testIteratorSeek(t *testing.T, openchainDB db.OpenchainDB) {
+	writeKeys(t, openchainDB, db.StateCF, "b", "d", "f")
+	itr := openchainDB.GetStateIterator()
+	defer itr.Close()
+	for seekKey, expectedKey := range map[string]string{"": "b", "b": "b", "c": "d", "f": "f"} {
+		itr.Seek([]byte(seekKey))
+		if !itr.Valid() || string(itr.KeyData()) != expectedKey {
+			t.Fatalf("Expected the iterator at [%s] after seeking [%s]", expectedKey, seekKey)
+		}
+	}
+	itr.Seek([]byte("g"))
+	if itr.Valid() {
+		t.Fatalf("Iterator should not be valid after seeking past the last key, found [%s]", itr.KeyData())
+	}
+}
+
+// ValidForPrefix is true only while the iterator is at a key that starts with the prefix
+func testIteratorValidForPrefix(t *testing.T, openchainDB db.OpenchainDB) {
+	writeKeys(t, openchainDB, db.StateCF, "a", "b1", "b2", "c")
+	itr := openchainDB.GetStateIterator()
+	defer itr.Close()
+	prefix := []byte("b")
+	var keys []string
+	for itr.Seek(prefix); itr.ValidForPrefix(prefix); itr.Next() {
+		keys = append(keys, string(itr.KeyData()))
+	}
+	assertEqualKeys(t, []string{"b1", "b2"}, keys)
+	if !itr.Valid() || string(itr.KeyData()) != "c" {
+		t.Fatalf("Expected the iterator at [c] after the prefix")
+	}
+	if !itr.ValidForPrefix(nil) {
+		t.Fatalf("Every key has the empty prefix")
+	}
+	itr.Next()
+	if itr.ValidForPrefix(nil) {
+		t.Fatalf("ValidForPrefix should be false for an iterator that is not valid")
+	}
+}
+
+// An iterator returns the keys of its own table only
+func testIteratorStaysWithinTable(t *testing.T, openchainDB db.OpenchainDB) {
+	for _, cf := range db.ColumnFamilies {
+		writeKeys(t, openchainDB, cf, cf+"_1", cf+"_2")
+	}
+	iterators := map[string]db.Iterator{
+		db.BlockchainCF: openchainDB.GetBlockchainIterator(),
+		db.StateCF:      openchainDB.GetStateIterator(),
+		db.StateDeltaCF: openchainDB.GetStateDeltaIterator(),
+		db.IndexesCF:    openchainDB.GetIndexesIterator(),
+		db.PersistCF:    openchainDB.GetPersistenceIterator(),
+	}
+	for cf, itr := range iterators {
+		assertKeys(t, itr, []string{cf + "_1", cf + "_2"})
+	}
+
+	itr := openchainDB.GetStateIterator()
+	defer itr.Close()
+	itr.SeekToLast()
+	itr.Next()
+	if itr.Valid() {
+		t.Fatalf("Iterator moved past the last key of its table to [%s]", itr.KeyData())
+	}
+	itr.SeekToFirst()
+	itr.Prev()
+	if itr.Valid() {
+		t.Fatalf("Iterator moved before the first key of its table to [%s]", itr.KeyData())
+	}
+}
+
+// An iterator sees the table as of the time it was created
+func testIteratorIsolation(t *testing.T, openchainDB db.OpenchainDB) {
+	writeKeys(t, openchainDB, db.StateCF, "a", "b")
+	itr := openchainDB.GetStateIterator()
+	defer itr.Close()
+
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Delete(db.StateCF, []byte("a"))
+	writeBatch.Put(db.StateCF, []byte("c"), []byte("value_c"))
+	commit(t, writeBatch)
+
+	assertKeys(t, itr, []string{"a", "b"})
+	assertKeys(t, openchainDB.GetStateIterator(), []string{"b", "c"})
+}
+
+// A snapshot sees the data as of the time it was taken, until it is released
+func testSnapshotIsolation(t *testing.T, openchainDB db.OpenchainDB) {
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Put(db.BlockchainCF, []byte("block1"), []byte("value1"))
+	writeBatch.Put(db.StateCF, []byte("key1"), []byte("value1"))
+	commit(t, writeBatch)
+
+	snapshot := openchainDB.GetSnapshot()
+	defer snapshot.Release()
+
+	writeBatch = openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Put(db.BlockchainCF, []byte("block1"), []byte("value1_new"))
+	writeBatch.Put(db.BlockchainCF, []byte("block2"), []byte("value2"))
+	writeBatch.Delete(db.StateCF, []byte("key1"))
+	writeBatch.Put(db.StateCF, []byte("key2"), []byte("value2"))
+	commit(t, writeBatch)
+
+	getFromSnapshot := func(key []byte) ([]byte, error) {
+		return openchainDB.GetFromBlockchainSnapshot(snapshot, key)
+	}
+	assertValue(t, "snapshot", getFromSnapshot, "block1", "value1")
+	assertNil(t, "snapshot", getFromSnapshot, "block2")
+	assertKeys(t, openchainDB.GetStateSnapshotIterator(snapshot), []string{"key1"})
+
+	assertValue(t, db.BlockchainCF, openchainDB.GetFromBlockchain, "block1", "value1_new")
+	assertKeys(t, openchainDB.GetStateIterator(), []string{"key2"})
+}
+
+// DeleteState clears stateCF and stateDeltaCF, and leaves the other tables alone.
+// The state tables can be written again afterwards
+func testDeleteState(t *testing.T, openchainDB db.OpenchainDB) {
+	for _, cf := range db.ColumnFamilies {
+		writeKeys(t, openchainDB, cf, "key1", "key2")
+	}
+	if err := openchainDB.DeleteState(); err != nil {
+		t.Fatalf("Error while deleting state: %s", err)
+	}
+	for cf, get := range getters(openchainDB) {
+		if cf == db.StateCF || cf == db.StateDeltaCF {
+			assertNil(t, cf, get, "key1")
+		} else {
+			assertValue(t, cf, get, "key1", "value_key1")
+		}
+	}
+	assertKeys(t, openchainDB.GetStateIterator(), nil)
+	assertKeys(t, openchainDB.GetStateDeltaIterator(), nil)
+
+	writeKeys(t, openchainDB, db.StateCF, "key3")
+	writeKeys(t, openchainDB, db.StateDeltaCF, "key3")
+	assertKeys(t, openchainDB.GetStateIterator(), []string{"key3"})
+	assertKeys(t, openchainDB.GetStateDeltaIterator(), []string{"key3"})
+}
+
+// The data of every table, the state deltas included, survives a Stop and Start
+func testRestart(t *testing.T, openchainDB db.OpenchainDB) {
+	for _, cf := range db.ColumnFamilies {
+		writeKeys(t, openchainDB, cf, "key")
+	}
+	openchainDB.Stop()
+	openchainDB.Start()
+	for cf, get := range getters(openchainDB) {
+		assertValue(t, cf, get, "key", "value_key")
+	}
+	assertKeys(t, openchainDB.GetStateDeltaIterator(), []string{"key"})
+}
+
+// Stats reports every table, in the order of ColumnFamilies. Counts that are not
+// estimates are exact
+func testStats(t *testing.T, openchainDB db.OpenchainDB) {
+	for i, cf := range db.ColumnFamilies {
+		for j := 0; j <= i; j++ {
+			writeKeys(t, openchainDB, cf, fmt.Sprintf("key%d", j))
+		}
+	}
+	stats, err := openchainDB.Stats()
+	if err != nil {
+		t.Fatalf("Error while reading stats: %s", err)
+	}
+	if stats.Type != openchainDB.Type() {
+		t.Fatalf("Expected stats of type [%s], found [%s]", openchainDB.Type(), stats.Type)
+	}
+	if len(stats.Tables) != len(db.ColumnFamilies) {
+		t.Fatalf("Expected stats for [%d] tables, found [%d]", len(db.ColumnFamilies), len(stats.Tables))
+	}
+	for i, cf := range db.ColumnFamilies {
+		table := stats.Tables[i]
+		if table.Name != cf {
+			t.Fatalf("Expected stats of table [%s], found [%s]", cf, table.Name)
+		}
+		if table.Estimated {
+			continue
+		}
+		// each key is "key<j>" with the value "value_key<j>"
+		expectedKeys := uint64(i + 1)
+		if table.Keys != expectedKeys || table.Size != expectedKeys*(4+10) {
+			t.Fatalf("Expected [%d] keys of [%d] bytes in table [%s], found [%d] keys of [%d] bytes",
+				expectedKeys, expectedKeys*(4+10), cf, table.Keys, table.Size)
+		}
+	}
+}
+
+// helper functions
+
+func getters(openchainDB db.OpenchainDB) map[string]func([]byte) ([]byte, error) {
+	return map[string]func([]byte) ([]byte, error){
+		db.BlockchainCF: openchainDB.GetFromBlockchain,
+		db.StateCF:      openchainDB.GetFromState,
+		db.StateDeltaCF: openchainDB.GetFromStateDelta,
+		db.IndexesCF:    openchainDB.GetFromIndexes,
+		db.PersistCF:    openchainDB.GetFromPersistence,
+	}
+}
+
+// writeKeys writes the keys to cf, each with the value "value_<key>"
+func writeKeys(t *testing.T, openchainDB db.OpenchainDB, cf string, keys ...string) {
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	for _, key := range keys {
+		writeBatch.Put(cf, []byte(key), []byte("value_"+key))
+	}
+	commit(t, writeBatch)
+}
+
+func commit(t *testing.T, writeBatch db.WriteBatch) {
+	if err := writeBatch.Commit(db.SyncWrite); err != nil {
+		t.Fatalf("Error while committing batch: %s", err)
+	}
+}
+
+// assertKeys iterates over all the keys of itr and closes it
+func assertKeys(t *testing.T, itr db.Iterator, expectedKeys []string) {
+	defer itr.Close()
+	var keys []string
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.KeyData()))
+	}
+	assertEqualKeys(t, expectedKeys, keys)
+}
+
+func assertEqualKeys(t *testing.T, expectedKeys []string, keys []string) {
+	if fmt.Sprintf("%q", expectedKeys) != fmt.Sprintf("%q", keys) {
+		t.Fatalf("Expected keys %q, found %q", expectedKeys, keys)
+	}
+}
+
+func assertValue(t *testing.T, cf string, get func([]byte) ([]byte, error), key string, expectedValue string) {
+	value, err := get([]byte(key))
+	if err != nil {
+		t.Fatalf("Error while getting key [%s] from [%s]: %s", key, cf, err)
+	}
+	if !bytes.Equal(value, []byte(expectedValue)) {
+		t.Fatalf("Expected value [%s] for key [%s] in [%s], found [%s]", expectedValue, key, cf, value)
+	}
+}
+
+func assertNil(t *testing.T, cf string, get func([]byte) ([]byte, error), key string) {
+	value, err := get([]byte(key))
+	if err != nil {
+		t.Fatalf("Error while getting key [%s] from [%s]: %s", key, cf, err)
+	}
+	if value != nil {
+		t.Fatalf("A nil value expected for key [%s] in [%s]. Found [%s]", key, cf, value)
+	}
+}
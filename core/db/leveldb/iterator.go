@@ -0,0 +1,111 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leveldb
+
+import (
+	"bytes"
+
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// DbIterator implements the interface 'db.Iterator' over the keys of a single table.
+// The table prefix is stripped from the keys returned by KeyData and added to the
+// keys passed to Seek
+type DbIterator struct {
+	itr    iterator.Iterator
+	prefix []byte
+}
+
+func newIterator(r reader, cf string) *DbIterator {
+	prefix := getTablePrefix(cf)
+	return &DbIterator{r.NewIterator(util.BytesPrefix(prefix), nil), prefix}
+}
+
+// Valid returns true if the iterator is positioned at a key of the table
+func (iterator *DbIterator) Valid() bool {
+	return iterator.itr.Valid()
+}
+
+// Next moves to the next key
+func (iterator *DbIterator) Next() {
+	iterator.itr.Next()
+}
+
+// Prev moves to the previous key
+func (iterator *DbIterator) Prev() {
+	iterator.itr.Prev()
+}
+
+// SeekToFirst moves to the first key of the table
+func (iterator *DbIterator) SeekToFirst() {
+	iterator.itr.First()
+}
+
+// SeekToLast moves to the last key of the table
+func (iterator *DbIterator) SeekToLast() {
+	iterator.itr.Last()
+}
+
+// Seek moves to the first key that is greater than or equal to the given key
+func (iterator *DbIterator) Seek(key []byte) {
+	iterator.itr.Seek(append(append([]byte(nil), iterator.prefix...), key...))
+}
+
+// KeyData returns the key at the current position. The bytes are only valid
+// until the iterator is moved
+func (iterator *DbIterator) KeyData() []byte {
+	key := iterator.itr.Key()
+	if key == nil {
+		return nil
+	}
+	return key[len(iterator.prefix):]
+}
+
+// KeySize returns the length of the key at the current position
+func (iterator *DbIterator) KeySize() int {
+	return len(iterator.KeyData())
+}
+
+// FreeKey is a no-op, goleveldb keys are garbage collected
+func (iterator *DbIterator) FreeKey() {
+}
+
+// ValueData returns the value at the current position. The bytes are only valid
+// until the iterator is moved
+func (iterator *DbIterator) ValueData() []byte {
+	return iterator.itr.Value()
+}
+
+// ValueSize returns the length of the value at the current position
+func (iterator *DbIterator) ValueSize() int {
+	return len(iterator.itr.Value())
+}
+
+// FreeValue is a no-op, goleveldb values are garbage collected
+func (iterator *DbIterator) FreeValue() {
+}
+
+// ValidForPrefix returns true if the iterator is positioned at a key that starts with prefix
+func (iterator *DbIterator) ValidForPrefix(prefix []byte) bool {
+	return iterator.Valid() && bytes.HasPrefix(iterator.KeyData(), prefix)
+}
+
+// Close releases the iterator
+func (iterator *DbIterator) Close() {
+	iterator.itr.Release()
+}
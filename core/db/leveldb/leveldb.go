@@ -0,0 +1,306 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package leveldb is a pure-Go datastore plugin built on goleveldb. LevelDB has
+// a single keyspace, so each of the tables listed in 'db.ColumnFamilies' is
+// emulated by prefixing its keys with the table name.
+package leveldb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/op/go-logging"
+	goleveldb "github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Name is the name under which this datastore is registered with 'db.Registry'
+const Name = `leveldb`
+
+// tableSeparator separates the table name from the key. Table names never
+// contain this byte, so the key ranges of two tables never overlap
+const tableSeparator = byte(0x00)
+
+// deleteStateBatchSize is the number of deletes DeleteState collects in a
+// batch before writing it out
+const deleteStateBatchSize = 10000
+
+var dbLogger = logging.MustGetLogger("db")
+
+// tablePrefixes maps a table name from 'db.ColumnFamilies' to its key prefix
+var tablePrefixes = make(map[string][]byte)
+
+// OpenchainLevelDB implements the interface 'db.OpenchainDB' on top of goleveldb
+type OpenchainLevelDB struct {
+	DB *goleveldb.DB
+}
+
+// DbSnapshot implements the interface 'db.Snapshot'
+type DbSnapshot struct {
+	*goleveldb.Snapshot
+}
+
+// reader is the read API shared by goleveldb's DB and Snapshot
+type reader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+func init() {
+	for _, cf := range db.ColumnFamilies {
+		tablePrefixes[cf] = append([]byte(cf), tableSeparator)
+	}
+	db.Registry.Add(Name, func() db.OpenchainDB { return &OpenchainLevelDB{} })
+}
+
+// Type returns the name of this datastore
+func (openchainDB *OpenchainLevelDB) Type() string {
+	return Name
+}
+
+// Start opens the db under 'peer.fileSystemPath'. An existing non-empty directory
+// that does not hold a leveldb causes a panic. All the tables share one keyspace, so
+// only the options of 'datastore.leveldb.tables.default' are used
+func (openchainDB *OpenchainLevelDB) Start() {
+	dbPath := db.GetDBPath("leveldb")
+	dbLogger.Debugf("Opening DB at path [%s]", dbPath)
+	missing, err := db.DirMissingOrEmpty(dbPath)
+	if err != nil {
+		panic(fmt.Sprintf("Error while trying to open DB: %s", err))
+	}
+	dbLogger.Debugf("Is db path [%s] empty [%t]", dbPath, missing)
+
+	if missing {
+		err = os.MkdirAll(dbPath, 0755)
+		if err != nil {
+			panic(fmt.Sprintf("Error making directory path [%s]: %s", dbPath, err))
+		}
+	}
+
+	options, err := getOptions()
+	if err != nil {
+		panic(fmt.Sprintf("Error opening DB: %s", err))
+	}
+	options.ErrorIfMissing = !missing
+	levelDB, err := goleveldb.OpenFile(dbPath, options)
+	if err != nil {
+		panic(fmt.Sprintf("Error opening DB: %s", err))
+	}
+	openchainDB.DB = levelDB
+}
+
+// Stop closes the db. Note this method has no guarantee correct behavior concurrent invocation.
+func (openchainDB *OpenchainLevelDB) Stop() {
+	if openchainDB.DB == nil {
+		return
+	}
+	if err := openchainDB.DB.Close(); err != nil {
+		dbLogger.Errorf("Error while closing DB: %s", err)
+	}
+	openchainDB.DB = nil
+}
+
+// GetFromBlockchain get value for given key from table - blockchainCF
+func (openchainDB *OpenchainLevelDB) GetFromBlockchain(key []byte) ([]byte, error) {
+	return get(openchainDB.DB, db.BlockchainCF, key)
+}
+
+// GetFromState get value for given key from table - stateCF
+func (openchainDB *OpenchainLevelDB) GetFromState(key []byte) ([]byte, error) {
+	return get(openchainDB.DB, db.StateCF, key)
+}
+
+// GetFromStateDelta get value for given key from table - stateDeltaCF
+func (openchainDB *OpenchainLevelDB) GetFromStateDelta(key []byte) ([]byte, error) {
+	return get(openchainDB.DB, db.StateDeltaCF, key)
+}
+
+// GetFromPersistence get value for given key from table - persistCF
+func (openchainDB *OpenchainLevelDB) GetFromPersistence(key []byte) ([]byte, error) {
+	return get(openchainDB.DB, db.PersistCF, key)
+}
+
+// GetFromIndexes get value for given key from table - indexesCF
+func (openchainDB *OpenchainLevelDB) GetFromIndexes(key []byte) ([]byte, error) {
+	return get(openchainDB.DB, db.IndexesCF, key)
+}
+
+// GetSnapshot returns a point-in-time view of the DB. You MUST call snapshot.Release()
+// when you are done with the snapshot.
+func (openchainDB *OpenchainLevelDB) GetSnapshot() db.Snapshot {
+	snapshot, err := openchainDB.DB.GetSnapshot()
+	if err != nil {
+		panic(fmt.Sprintf("Error while taking DB snapshot: %s", err))
+	}
+	return &DbSnapshot{snapshot}
+}
+
+// GetFromBlockchainSnapshot get value for given key from table - blockchainCF, as of the snapshot
+func (openchainDB *OpenchainLevelDB) GetFromBlockchainSnapshot(snapshot db.Snapshot, key []byte) ([]byte, error) {
+	return get(snapshot.(*DbSnapshot).Snapshot, db.BlockchainCF, key)
+}
+
+// GetBlockchainIterator get iterator for table - blockchainCF
+func (openchainDB *OpenchainLevelDB) GetBlockchainIterator() db.Iterator {
+	return newIterator(openchainDB.DB, db.BlockchainCF)
+}
+
+// GetStateIterator get iterator for table - stateCF
+func (openchainDB *OpenchainLevelDB) GetStateIterator() db.Iterator {
+	return newIterator(openchainDB.DB, db.StateCF)
+}
+
+// GetStateDeltaIterator get iterator for table - stateDeltaCF
+func (openchainDB *OpenchainLevelDB) GetStateDeltaIterator() db.Iterator {
+	return newIterator(openchainDB.DB, db.StateDeltaCF)
+}
+
+// GetPersistenceIterator get iterator for table - persistCF
+func (openchainDB *OpenchainLevelDB) GetPersistenceIterator() db.Iterator {
+	return newIterator(openchainDB.DB, db.PersistCF)
+}
+
+// GetIndexesIterator get iterator for table - indexCF
+func (openchainDB *OpenchainLevelDB) GetIndexesIterator() db.Iterator {
+	return newIterator(openchainDB.DB, db.IndexesCF)
+}
+
+// GetStateSnapshotIterator get iterator for table - stateCF. This iterator
+// is based on a snapshot and should be used for long running scans, such as
+// reading the entire state. Remember to call iterator.Close() when you are done.
+func (openchainDB *OpenchainLevelDB) GetStateSnapshotIterator(snapshot db.Snapshot) db.Iterator {
+	return newIterator(snapshot.(*DbSnapshot).Snapshot, db.StateCF)
+}
+
+// PutToPersistence saves the key/value in table - persistCF
+func (openchainDB *OpenchainLevelDB) PutToPersistence(key []byte, value []byte) error {
+	err := openchainDB.DB.Put(tableKey(db.PersistCF, key), value, nil)
+	if err != nil {
+		dbLogger.Errorf("Error while trying to write key: %s", key)
+		return err
+	}
+	return nil
+}
+
+// DeleteState deletes ALL state keys/values from the DB. This is generally
+// only used during state synchronization when creating a new state from
+// a snapshot.
+func (openchainDB *OpenchainLevelDB) DeleteState() error {
+	for _, cf := range []string{db.StateCF, db.StateDeltaCF} {
+		if err := openchainDB.deleteTable(cf); err != nil {
+			dbLogger.Errorf("Error deleting table [%s]: %s", cf, err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (openchainDB *OpenchainLevelDB) deleteTable(cf string) error {
+	itr := openchainDB.DB.NewIterator(util.BytesPrefix(getTablePrefix(cf)), nil)
+	defer itr.Release()
+	batch := new(goleveldb.Batch)
+	for itr.Next() {
+		batch.Delete(itr.Key())
+		if batch.Len() == deleteStateBatchSize {
+			if err := openchainDB.DB.Write(batch, nil); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return err
+	}
+	return openchainDB.DB.Write(batch, &opt.WriteOptions{Sync: true})
+}
+
+// Stats counts the keys and the size of each table. DiskSize is the size of the db
+// directory and Compaction is the 'leveldb.stats' property
+func (openchainDB *OpenchainLevelDB) Stats() (*db.Stats, error) {
+	diskSize, err := db.DirSize(db.GetDBPath("leveldb"))
+	if err != nil {
+		return nil, err
+	}
+	compaction, err := openchainDB.DB.GetProperty("leveldb.stats")
+	if err != nil {
+		return nil, err
+	}
+	stats := &db.Stats{Type: Name, DiskSize: diskSize, Compaction: compaction}
+	for _, cf := range db.ColumnFamilies {
+		stats.Tables = append(stats.Tables, db.ScanTableStats(openchainDB, cf))
+	}
+	return stats, nil
+}
+
+// getOptions maps 'datastore.leveldb.tables.default' to the goleveldb options.
+// goleveldb only supports snappy compression
+func getOptions() (*opt.Options, error) {
+	tableOptions, err := db.GetTableOptions(Name, db.DefaultTableOptions)
+	if err != nil {
+		return nil, err
+	}
+	options := &opt.Options{
+		BlockCacheCapacity: tableOptions.BlockCacheSize * opt.MiB,
+		WriteBuffer:        tableOptions.WriteBufferSize * opt.MiB,
+	}
+	if tableOptions.BloomFilterBits > 0 {
+		options.Filter = filter.NewBloomFilter(tableOptions.BloomFilterBits)
+	}
+	switch tableOptions.Compression {
+	case "":
+	case db.NoCompression:
+		options.Compression = opt.NoCompression
+	case db.SnappyCompression:
+		options.Compression = opt.SnappyCompression
+	default:
+		return nil, fmt.Errorf("Compression [%s] is not supported by datastore [%s]", tableOptions.Compression, Name)
+	}
+	return options, nil
+}
+
+func get(r reader, cf string, key []byte) ([]byte, error) {
+	value, err := r.Get(tableKey(cf, key), nil)
+	if err == goleveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		dbLogger.Errorf("Error while trying to retrieve key: %s", key)
+		return nil, err
+	}
+	return value, nil
+}
+
+// getTablePrefix maps a table name from 'db.ColumnFamilies' to its key prefix
+func getTablePrefix(cf string) []byte {
+	prefix, ok := tablePrefixes[cf]
+	if !ok {
+		panic(fmt.Sprintf("Unknown column family [%s]", cf))
+	}
+	return prefix
+}
+
+func tableKey(cf string, key []byte) []byte {
+	prefix := getTablePrefix(cf)
+	tableKey := make([]byte, len(prefix)+len(key))
+	copy(tableKey, prefix)
+	copy(tableKey[len(prefix):], key)
+	return tableKey
+}
@@ -0,0 +1,282 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leveldb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/dbtest"
+	"github.com/spf13/viper"
+)
+
+func TestMain(m *testing.M) {
+	tempDir, err := ioutil.TempDir("", "fabric-leveldb-test")
+	if err != nil {
+		panic(err)
+	}
+	viper.Set("peer.fileSystemPath", tempDir)
+	code := m.Run()
+	os.RemoveAll(tempDir)
+	os.Exit(code)
+}
+
+// openchaindb is the instance the tests start and stop directly, the registry
+// keeps its own
+var openchaindb = &OpenchainLevelDB{}
+
+func TestRegistered(t *testing.T) {
+	openchainDB, err := db.Registry.Open(Name)
+	if err != nil {
+		t.Fatalf("Error while opening [%s] from the registry: %s", Name, err)
+	}
+	defer db.Registry.Close(Name)
+	if _, ok := openchainDB.(*OpenchainLevelDB); !ok {
+		t.Fatalf("Expected an instance of OpenchainLevelDB, found [%T]", openchainDB)
+	}
+	if openchainDB.Type() != Name {
+		t.Fatalf("Expected type [%s], found [%s]", Name, openchainDB.Type())
+	}
+}
+
+func TestStartDB_DirDoesNotExist(t *testing.T) {
+	deleteTestDBPath()
+	defer deleteTestDBPath()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Failed to open DB: %s", r)
+		}
+	}()
+	openchaindb.Start()
+	openchaindb.Stop()
+}
+
+func TestStartDB_NonEmptyDirExists(t *testing.T) {
+	deleteTestDBPath()
+	defer deleteTestDBPath()
+	os.MkdirAll(db.GetDBPath("leveldb")+"/tmpFile", 0775)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("dbPath is already exists. DB open should throw error")
+		}
+	}()
+	openchaindb.Start()
+}
+
+func TestWriteBatchAndTables(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+
+	writeBatch := openchaindb.NewWriteBatch()
+	defer writeBatch.Destroy()
+	for _, cf := range db.ColumnFamilies {
+		writeBatch.Put(cf, []byte("key"), []byte("value_"+cf))
+	}
+	if err := writeBatch.Commit(db.SyncWrite); err != nil {
+		t.Fatalf("Error while committing batch: %s", err)
+	}
+
+	assertValue(t, openchaindb.GetFromBlockchain, "key", "value_"+db.BlockchainCF)
+	assertValue(t, openchaindb.GetFromState, "key", "value_"+db.StateCF)
+	assertValue(t, openchaindb.GetFromStateDelta, "key", "value_"+db.StateDeltaCF)
+	assertValue(t, openchaindb.GetFromIndexes, "key", "value_"+db.IndexesCF)
+	assertValue(t, openchaindb.GetFromPersistence, "key", "value_"+db.PersistCF)
+
+	writeBatch = openchaindb.NewWriteBatch()
+	writeBatch.Delete(db.StateCF, []byte("key"))
+	if err := writeBatch.Commit(nil); err != nil {
+		t.Fatalf("Error while committing batch: %s", err)
+	}
+	assertNil(t, openchaindb.GetFromState, "key")
+	assertValue(t, openchaindb.GetFromStateDelta, "key", "value_"+db.StateDeltaCF)
+}
+
+func TestPutToPersistence(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+
+	if err := openchaindb.PutToPersistence([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("Error while writing: %s", err)
+	}
+	assertValue(t, openchaindb.GetFromPersistence, "key", "value")
+	assertNil(t, openchaindb.GetFromStateDelta, "key")
+}
+
+func TestDeleteState(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+
+	writeBatch := openchaindb.NewWriteBatch()
+	writeBatch.Put(db.StateCF, []byte("key1"), []byte("value1"))
+	writeBatch.Put(db.StateDeltaCF, []byte("key2"), []byte("value2"))
+	writeBatch.Put(db.BlockchainCF, []byte("key3"), []byte("value3"))
+	writeBatch.Commit(db.SyncWrite)
+
+	if err := openchaindb.DeleteState(); err != nil {
+		t.Fatalf("Error while deleting state: %s", err)
+	}
+	assertNil(t, openchaindb.GetFromState, "key1")
+	assertNil(t, openchaindb.GetFromStateDelta, "key2")
+	assertValue(t, openchaindb.GetFromBlockchain, "key3", "value3")
+}
+
+func TestDBSnapshot(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+
+	writeBatch := openchaindb.NewWriteBatch()
+	writeBatch.Put(db.BlockchainCF, []byte("key1"), []byte("value1"))
+	writeBatch.Put(db.StateCF, []byte("key1"), []byte("value1"))
+	writeBatch.Commit(db.SyncWrite)
+
+	snapshot := openchaindb.GetSnapshot()
+	defer snapshot.Release()
+
+	writeBatch = openchaindb.NewWriteBatch()
+	writeBatch.Delete(db.BlockchainCF, []byte("key1"))
+	writeBatch.Put(db.BlockchainCF, []byte("key2"), []byte("value2"))
+	writeBatch.Put(db.StateCF, []byte("key2"), []byte("value2"))
+	writeBatch.Commit(db.SyncWrite)
+
+	value, _ := openchaindb.GetFromBlockchainSnapshot(snapshot, []byte("key1"))
+	if !bytes.Equal(value, []byte("value1")) {
+		t.Fatalf("Expected value from db snapshot [%s], found [%s]", "value1", value)
+	}
+	value, _ = openchaindb.GetFromBlockchainSnapshot(snapshot, []byte("key2"))
+	if value != nil {
+		t.Fatalf("Expected value from db snapshot is 'nil', found [%s]", value)
+	}
+
+	itr := openchaindb.GetStateSnapshotIterator(snapshot)
+	defer itr.Close()
+	testIterator(t, itr, []string{"key1"})
+}
+
+func TestIteratorStaysWithinTable(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+
+	writeBatch := openchaindb.NewWriteBatch()
+	for _, key := range []string{"a", "b1", "b2", "c"} {
+		writeBatch.Put(db.StateCF, []byte(key), []byte("value_"+key))
+	}
+	writeBatch.Put(db.BlockchainCF, []byte("z"), []byte("value_z"))
+	writeBatch.Put(db.StateDeltaCF, []byte("0"), []byte("value_0"))
+	writeBatch.Commit(db.SyncWrite)
+
+	itr := openchaindb.GetStateIterator()
+	defer itr.Close()
+	testIterator(t, itr, []string{"a", "b1", "b2", "c"})
+
+	itr.Seek([]byte("b"))
+	if !itr.ValidForPrefix([]byte("b")) || string(itr.KeyData()) != "b1" {
+		t.Fatalf("Expected iterator at [b1], found [%s]", itr.KeyData())
+	}
+	itr.Next()
+	if !itr.ValidForPrefix([]byte("b")) || string(itr.ValueData()) != "value_b2" {
+		t.Fatalf("Expected value [value_b2], found [%s]", itr.ValueData())
+	}
+	itr.Next()
+	if itr.ValidForPrefix([]byte("b")) {
+		t.Fatalf("Iterator should not be valid for prefix [b] at key [%s]", itr.KeyData())
+	}
+	itr.Prev()
+	if string(itr.KeyData()) != "b2" {
+		t.Fatalf("Expected iterator at [b2], found [%s]", itr.KeyData())
+	}
+
+	itr.SeekToLast()
+	if string(itr.KeyData()) != "c" {
+		t.Fatalf("Expected iterator at [c], found [%s]", itr.KeyData())
+	}
+	itr.Next()
+	if itr.Valid() {
+		t.Fatalf("Iterator should not move into another table. Found key [%s]", itr.KeyData())
+	}
+	itr.Seek([]byte("d"))
+	if itr.Valid() {
+		t.Fatalf("Iterator should not move into another table. Found key [%s]", itr.KeyData())
+	}
+}
+
+func TestStartStopRetainsData(t *testing.T) {
+	startFreshDB()
+	writeBatch := openchaindb.NewWriteBatch()
+	writeBatch.Put(db.BlockchainCF, []byte("key"), []byte("value"))
+	writeBatch.Commit(db.SyncWrite)
+	stopDB()
+
+	openchaindb.Start()
+	defer stopDB()
+	assertValue(t, openchaindb.GetFromBlockchain, "key", "value")
+}
+
+func TestConformance(t *testing.T) {
+	dbtest.RunConformanceTests(t, &OpenchainLevelDB{})
+}
+
+// db helper functions
+func testIterator(t *testing.T, itr db.Iterator, expectedKeys []string) {
+	var keys []string
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.KeyData()))
+	}
+	if len(keys) != len(expectedKeys) {
+		t.Fatalf("Expected keys %v from iterator, found %v", expectedKeys, keys)
+	}
+	for i := range keys {
+		if keys[i] != expectedKeys[i] {
+			t.Fatalf("Expected keys %v from iterator, found %v", expectedKeys, keys)
+		}
+	}
+}
+
+func assertValue(t *testing.T, get func([]byte) ([]byte, error), key string, expectedValue string) {
+	value, err := get([]byte(key))
+	if err != nil {
+		t.Fatalf("Error while getting key [%s]: %s", key, err)
+	}
+	if !bytes.Equal(value, []byte(expectedValue)) {
+		t.Fatalf("Expected value [%s] for key [%s], found [%s]", expectedValue, key, value)
+	}
+}
+
+func assertNil(t *testing.T, get func([]byte) ([]byte, error), key string) {
+	value, err := get([]byte(key))
+	if err != nil {
+		t.Fatalf("Error while getting key [%s]: %s", key, err)
+	}
+	if value != nil {
+		t.Fatalf("A nil value expected for key [%s]. Found [%s]", key, value)
+	}
+}
+
+func startFreshDB() {
+	deleteTestDBPath()
+	openchaindb.Start()
+}
+
+func stopDB() {
+	openchaindb.Stop()
+}
+
+func deleteTestDBPath() {
+	os.RemoveAll(viper.GetString("peer.fileSystemPath"))
+}
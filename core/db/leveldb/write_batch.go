@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leveldb
+
+import (
+	"github.com/hyperledger/fabric/core/db"
+	goleveldb "github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// WriteBatch implements the interface 'db.WriteBatch' on top of a goleveldb batch
+type WriteBatch struct {
+	openchainDB *OpenchainLevelDB
+	batch       *goleveldb.Batch
+}
+
+// NewWriteBatch returns an empty batch for this db
+func (openchainDB *OpenchainLevelDB) NewWriteBatch() db.WriteBatch {
+	return &WriteBatch{openchainDB, new(goleveldb.Batch)}
+}
+
+// Put - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Put(cf string, key []byte, value []byte) {
+	writeBatch.batch.Put(tableKey(cf, key), value)
+}
+
+// Delete - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Delete(cf string, key []byte) {
+	writeBatch.batch.Delete(tableKey(cf, key))
+}
+
+// Commit - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Commit(opts *db.WriteOptions) error {
+	wo := &opt.WriteOptions{}
+	if opts != nil {
+		wo.Sync = opts.Sync
+	}
+	err := writeBatch.openchainDB.DB.Write(writeBatch.batch, wo)
+	if err != nil {
+		dbLogger.Errorf("Error while committing write batch: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Destroy - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Destroy() {
+	writeBatch.batch.Reset()
+}
@@ -0,0 +1,121 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"bytes"
+	"sort"
+	"sync/atomic"
+)
+
+// DbIterator implements the interface 'db.Iterator'. The iterator reads the version
+// of the table that was current when it was created, later writes are not visible
+type DbIterator struct {
+	table    *table
+	position int
+	closed   int32
+}
+
+// newIterator expects the caller to have taken a reference on t
+func newIterator(t *table) *DbIterator {
+	return &DbIterator{table: t, position: len(t.keys)}
+}
+
+// Valid returns true if the iterator is positioned at a key
+func (iterator *DbIterator) Valid() bool {
+	return iterator.position >= 0 && iterator.position < len(iterator.table.keys)
+}
+
+// Next moves to the next key. Calling Next after moving before the first key
+// positions the iterator at the first key
+func (iterator *DbIterator) Next() {
+	if iterator.position < len(iterator.table.keys) {
+		iterator.position++
+	}
+}
+
+// Prev moves to the previous key. Calling Prev after moving past the last key
+// positions the iterator at the last key
+func (iterator *DbIterator) Prev() {
+	if iterator.position >= 0 {
+		iterator.position--
+	}
+}
+
+// SeekToFirst moves to the first key
+func (iterator *DbIterator) SeekToFirst() {
+	iterator.position = 0
+}
+
+// SeekToLast moves to the last key
+func (iterator *DbIterator) SeekToLast() {
+	iterator.position = len(iterator.table.keys) - 1
+}
+
+// Seek moves to the first key that is greater than or equal to the given key
+func (iterator *DbIterator) Seek(key []byte) {
+	iterator.position = sort.SearchStrings(iterator.table.keys, string(key))
+}
+
+// KeyData returns the key at the current position
+func (iterator *DbIterator) KeyData() []byte {
+	if !iterator.Valid() {
+		return nil
+	}
+	return []byte(iterator.table.keys[iterator.position])
+}
+
+// KeySize returns the length of the key at the current position
+func (iterator *DbIterator) KeySize() int {
+	if !iterator.Valid() {
+		return 0
+	}
+	return len(iterator.table.keys[iterator.position])
+}
+
+// FreeKey is a no-op, keys are garbage collected
+func (iterator *DbIterator) FreeKey() {
+}
+
+// ValueData returns the value at the current position. The bytes must not be modified
+func (iterator *DbIterator) ValueData() []byte {
+	if !iterator.Valid() {
+		return nil
+	}
+	return iterator.table.values[iterator.table.keys[iterator.position]]
+}
+
+// ValueSize returns the length of the value at the current position
+func (iterator *DbIterator) ValueSize() int {
+	return len(iterator.ValueData())
+}
+
+// FreeValue is a no-op, values are garbage collected
+func (iterator *DbIterator) FreeValue() {
+}
+
+// ValidForPrefix returns true if the iterator is positioned at a key that starts with prefix
+func (iterator *DbIterator) ValidForPrefix(prefix []byte) bool {
+	return iterator.Valid() && bytes.HasPrefix(iterator.KeyData(), prefix)
+}
+
+// Close releases the reference on the table
+func (iterator *DbIterator) Close() {
+	if atomic.CompareAndSwapInt32(&iterator.closed, 0, 1) {
+		atomic.AddInt32(&iterator.table.refs, -1)
+	}
+}
@@ -0,0 +1,333 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package memory is a datastore plugin that keeps all the tables in memory. Nothing
+// is written to disk; the data lives as long as the process (it survives Stop/Start,
+// so a restart within a test behaves like it does for the on-disk stores). It is meant
+// for tests and throwaway development peers.
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/core/db"
+)
+
+// Name is the name under which this datastore is registered with 'db.Registry'
+const Name = `memory`
+
+var errNotStarted = errors.New("The memory datastore is not started")
+
+// OpenchainMemoryDB implements the interface 'db.OpenchainDB' in memory
+type OpenchainMemoryDB struct {
+	lock    sync.RWMutex
+	started bool
+	tables  map[string]*table
+}
+
+// table is one column family. The keys are kept sorted for iteration. Iterators and
+// snapshots hold a reference to the table they read; a table with references is never
+// modified, writers replace it with a copy instead (see 'writableTable')
+type table struct {
+	keys   []string
+	values map[string][]byte
+	refs   int32
+}
+
+// DbSnapshot implements the interface 'db.Snapshot'
+type DbSnapshot struct {
+	tables   map[string]*table
+	released int32
+}
+
+func init() {
+	db.Registry.Add(Name, func() db.OpenchainDB { return &OpenchainMemoryDB{} })
+}
+
+// Type returns the name of this datastore
+func (openchainDB *OpenchainMemoryDB) Type() string {
+	return Name
+}
+
+// Start makes the datastore available. The tables are created on the first start
+func (openchainDB *OpenchainMemoryDB) Start() {
+	openchainDB.lock.Lock()
+	defer openchainDB.lock.Unlock()
+	if openchainDB.tables == nil {
+		openchainDB.tables = newTables()
+	}
+	openchainDB.started = true
+}
+
+// Stop makes the datastore unavailable. The data is kept until Purge is called
+func (openchainDB *OpenchainMemoryDB) Stop() {
+	openchainDB.lock.Lock()
+	defer openchainDB.lock.Unlock()
+	openchainDB.started = false
+}
+
+// Purge drops all the data. It is the in-memory equivalent of removing the db directory
+func (openchainDB *OpenchainMemoryDB) Purge() {
+	openchainDB.lock.Lock()
+	defer openchainDB.lock.Unlock()
+	openchainDB.tables = nil
+	if openchainDB.started {
+		openchainDB.tables = newTables()
+	}
+}
+
+// GetFromBlockchain get value for given key from table - blockchainCF
+func (openchainDB *OpenchainMemoryDB) GetFromBlockchain(key []byte) ([]byte, error) {
+	return openchainDB.get(db.BlockchainCF, key)
+}
+
+// GetFromState get value for given key from table - stateCF
+func (openchainDB *OpenchainMemoryDB) GetFromState(key []byte) ([]byte, error) {
+	return openchainDB.get(db.StateCF, key)
+}
+
+// GetFromStateDelta get value for given key from table - stateDeltaCF
+func (openchainDB *OpenchainMemoryDB) GetFromStateDelta(key []byte) ([]byte, error) {
+	return openchainDB.get(db.StateDeltaCF, key)
+}
+
+// GetFromPersistence get value for given key from table - persistCF
+func (openchainDB *OpenchainMemoryDB) GetFromPersistence(key []byte) ([]byte, error) {
+	return openchainDB.get(db.PersistCF, key)
+}
+
+// GetFromIndexes get value for given key from table - indexesCF
+func (openchainDB *OpenchainMemoryDB) GetFromIndexes(key []byte) ([]byte, error) {
+	return openchainDB.get(db.IndexesCF, key)
+}
+
+// GetSnapshot returns a point-in-time view of the DB. You MUST call snapshot.Release()
+// when you are done with the snapshot.
+func (openchainDB *OpenchainMemoryDB) GetSnapshot() db.Snapshot {
+	openchainDB.lock.RLock()
+	defer openchainDB.lock.RUnlock()
+	openchainDB.mustBeStarted()
+	snapshot := &DbSnapshot{tables: make(map[string]*table, len(openchainDB.tables))}
+	for cf, t := range openchainDB.tables {
+		atomic.AddInt32(&t.refs, 1)
+		snapshot.tables[cf] = t
+	}
+	return snapshot
+}
+
+// Release - see interface 'db.Snapshot' for details
+func (snapshot *DbSnapshot) Release() {
+	if !atomic.CompareAndSwapInt32(&snapshot.released, 0, 1) {
+		return
+	}
+	for _, t := range snapshot.tables {
+		atomic.AddInt32(&t.refs, -1)
+	}
+}
+
+// GetFromBlockchainSnapshot get value for given key from table - blockchainCF, as of the snapshot
+func (openchainDB *OpenchainMemoryDB) GetFromBlockchainSnapshot(snapshot db.Snapshot, key []byte) ([]byte, error) {
+	value, _ := snapshot.(*DbSnapshot).tables[db.BlockchainCF].get(key)
+	return value, nil
+}
+
+// GetBlockchainIterator get iterator for table - blockchainCF
+func (openchainDB *OpenchainMemoryDB) GetBlockchainIterator() db.Iterator {
+	return openchainDB.getIterator(db.BlockchainCF)
+}
+
+// GetStateIterator get iterator for table - stateCF
+func (openchainDB *OpenchainMemoryDB) GetStateIterator() db.Iterator {
+	return openchainDB.getIterator(db.StateCF)
+}
+
+// GetStateDeltaIterator get iterator for table - stateDeltaCF
+func (openchainDB *OpenchainMemoryDB) GetStateDeltaIterator() db.Iterator {
+	return openchainDB.getIterator(db.StateDeltaCF)
+}
+
+// GetPersistenceIterator get iterator for table - persistCF
+func (openchainDB *OpenchainMemoryDB) GetPersistenceIterator() db.Iterator {
+	return openchainDB.getIterator(db.PersistCF)
+}
+
+// GetIndexesIterator get iterator for table - indexCF
+func (openchainDB *OpenchainMemoryDB) GetIndexesIterator() db.Iterator {
+	return openchainDB.getIterator(db.IndexesCF)
+}
+
+// GetStateSnapshotIterator get iterator for table - stateCF, as of the snapshot.
+// Remember to call iterator.Close() when you are done.
+func (openchainDB *OpenchainMemoryDB) GetStateSnapshotIterator(snapshot db.Snapshot) db.Iterator {
+	t := snapshot.(*DbSnapshot).tables[db.StateCF]
+	atomic.AddInt32(&t.refs, 1)
+	return newIterator(t)
+}
+
+// PutToPersistence saves the key/value in table - persistCF
+func (openchainDB *OpenchainMemoryDB) PutToPersistence(key []byte, value []byte) error {
+	openchainDB.lock.Lock()
+	defer openchainDB.lock.Unlock()
+	if !openchainDB.started {
+		return errNotStarted
+	}
+	openchainDB.writableTable(db.PersistCF).put(string(key), makeCopy(value))
+	return nil
+}
+
+// DeleteState deletes ALL state keys/values from the DB. This is generally
+// only used during state synchronization when creating a new state from
+// a snapshot.
+func (openchainDB *OpenchainMemoryDB) DeleteState() error {
+	openchainDB.lock.Lock()
+	defer openchainDB.lock.Unlock()
+	if !openchainDB.started {
+		return errNotStarted
+	}
+	openchainDB.tables[db.StateCF] = newTable()
+	openchainDB.tables[db.StateDeltaCF] = newTable()
+	return nil
+}
+
+// Stats counts the keys and the size of each table. DiskSize is always 0
+func (openchainDB *OpenchainMemoryDB) Stats() (*db.Stats, error) {
+	openchainDB.lock.RLock()
+	defer openchainDB.lock.RUnlock()
+	if !openchainDB.started {
+		return nil, errNotStarted
+	}
+	stats := &db.Stats{Type: Name}
+	for _, cf := range db.ColumnFamilies {
+		t := openchainDB.tables[cf]
+		tableStats := &db.TableStats{Name: cf, Keys: uint64(len(t.keys))}
+		for key, value := range t.values {
+			tableStats.Size += uint64(len(key) + len(value))
+		}
+		stats.Tables = append(stats.Tables, tableStats)
+	}
+	return stats, nil
+}
+
+func (openchainDB *OpenchainMemoryDB) get(cf string, key []byte) ([]byte, error) {
+	openchainDB.lock.RLock()
+	defer openchainDB.lock.RUnlock()
+	if !openchainDB.started {
+		return nil, errNotStarted
+	}
+	value, _ := openchainDB.tables[cf].get(key)
+	return value, nil
+}
+
+func (openchainDB *OpenchainMemoryDB) getIterator(cf string) db.Iterator {
+	openchainDB.lock.RLock()
+	defer openchainDB.lock.RUnlock()
+	openchainDB.mustBeStarted()
+	t := openchainDB.tables[cf]
+	atomic.AddInt32(&t.refs, 1)
+	return newIterator(t)
+}
+
+// writableTable returns the table for cf, first replacing it with a private copy if
+// iterators or snapshots still read it. The caller must hold the write lock
+func (openchainDB *OpenchainMemoryDB) writableTable(cf string) *table {
+	t := openchainDB.tables[cf]
+	if atomic.LoadInt32(&t.refs) > 0 {
+		t = t.clone()
+		openchainDB.tables[cf] = t
+	}
+	return t
+}
+
+// mustBeStarted panics if the datastore is not started. The methods that cannot
+// return an error (iterators and snapshots) use it, like the on-disk stores they fail
+// loudly when used before Start
+func (openchainDB *OpenchainMemoryDB) mustBeStarted() {
+	if !openchainDB.started {
+		panic(errNotStarted.Error())
+	}
+}
+
+// checkColumnFamily panics if cf is not one of 'db.ColumnFamilies'. Batches check the
+// names as changes are added so that Commit never applies a batch partially
+func checkColumnFamily(cf string) {
+	for _, name := range db.ColumnFamilies {
+		if name == cf {
+			return
+		}
+	}
+	panic(fmt.Sprintf("Unknown column family [%s]", cf))
+}
+
+func newTables() map[string]*table {
+	tables := make(map[string]*table, len(db.ColumnFamilies))
+	for _, cf := range db.ColumnFamilies {
+		tables[cf] = newTable()
+	}
+	return tables
+}
+
+func newTable() *table {
+	return &table{values: make(map[string][]byte)}
+}
+
+func (t *table) clone() *table {
+	keys := make([]string, len(t.keys))
+	copy(keys, t.keys)
+	values := make(map[string][]byte, len(t.values))
+	for k, v := range t.values {
+		values[k] = v
+	}
+	return &table{keys: keys, values: values}
+}
+
+// get returns a copy of the value, so that callers are free to modify it
+func (t *table) get(key []byte) ([]byte, bool) {
+	value, ok := t.values[string(key)]
+	if !ok {
+		return nil, false
+	}
+	return makeCopy(value), true
+}
+
+// put stores value as is. Callers pass a private copy
+func (t *table) put(key string, value []byte) {
+	if _, ok := t.values[key]; !ok {
+		i := sort.SearchStrings(t.keys, key)
+		t.keys = append(t.keys, "")
+		copy(t.keys[i+1:], t.keys[i:])
+		t.keys[i] = key
+	}
+	t.values[key] = value
+}
+
+func (t *table) delete(key string) {
+	if _, ok := t.values[key]; !ok {
+		return
+	}
+	delete(t.values, key)
+	i := sort.SearchStrings(t.keys, key)
+	t.keys = append(t.keys[:i], t.keys[i+1:]...)
+}
+
+func makeCopy(src []byte) []byte {
+	dest := make([]byte, len(src))
+	copy(dest, src)
+	return dest
+}
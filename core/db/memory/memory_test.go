@@ -0,0 +1,255 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/dbtest"
+)
+
+// openchaindb is the instance the tests start and stop directly, the registry
+// keeps its own
+var openchaindb = &OpenchainMemoryDB{}
+
+func TestRegistered(t *testing.T) {
+	openchainDB, err := db.Registry.Open(Name)
+	if err != nil {
+		t.Fatalf("Error while opening [%s] from the registry: %s", Name, err)
+	}
+	defer db.Registry.Close(Name)
+	if _, ok := openchainDB.(*OpenchainMemoryDB); !ok {
+		t.Fatalf("Expected an instance of OpenchainMemoryDB, found [%T]", openchainDB)
+	}
+	if openchainDB.Type() != Name {
+		t.Fatalf("Expected type [%s], found [%s]", Name, openchainDB.Type())
+	}
+}
+
+func TestNotStarted(t *testing.T) {
+	startFreshDB()
+	stopDB()
+	if _, err := openchaindb.GetFromState([]byte("key")); err != errNotStarted {
+		t.Fatalf("Expected error [%s], found [%v]", errNotStarted, err)
+	}
+	writeBatch := openchaindb.NewWriteBatch()
+	writeBatch.Put(db.StateCF, []byte("key"), []byte("value"))
+	if err := writeBatch.Commit(db.SyncWrite); err != errNotStarted {
+		t.Fatalf("Expected error [%s], found [%v]", errNotStarted, err)
+	}
+}
+
+func TestWriteBatchAndTables(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+
+	value := []byte("value")
+	writeBatch := openchaindb.NewWriteBatch()
+	for _, cf := range db.ColumnFamilies {
+		writeBatch.Put(cf, []byte("key"), []byte("value_"+cf))
+	}
+	writeBatch.Put(db.PersistCF, []byte("key2"), value)
+	// the batch keeps its own copy
+	value[0] = 'V'
+	assertNil(t, openchaindb.GetFromState, "key")
+	if err := writeBatch.Commit(db.SyncWrite); err != nil {
+		t.Fatalf("Error while committing batch: %s", err)
+	}
+
+	assertValue(t, openchaindb.GetFromBlockchain, "key", "value_"+db.BlockchainCF)
+	assertValue(t, openchaindb.GetFromState, "key", "value_"+db.StateCF)
+	assertValue(t, openchaindb.GetFromStateDelta, "key", "value_"+db.StateDeltaCF)
+	assertValue(t, openchaindb.GetFromIndexes, "key", "value_"+db.IndexesCF)
+	assertValue(t, openchaindb.GetFromPersistence, "key", "value_"+db.PersistCF)
+	assertValue(t, openchaindb.GetFromPersistence, "key2", "value")
+
+	writeBatch = openchaindb.NewWriteBatch()
+	writeBatch.Delete(db.StateCF, []byte("key"))
+	writeBatch.Commit(nil)
+	assertNil(t, openchaindb.GetFromState, "key")
+	assertValue(t, openchaindb.GetFromStateDelta, "key", "value_"+db.StateDeltaCF)
+}
+
+func TestUnknownColumnFamily(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("A panic should have been caused for an unknown column family")
+		}
+	}()
+	openchaindb.NewWriteBatch().Put("unknownCF", []byte("key"), []byte("value"))
+}
+
+func TestDataSurvivesRestartUntilPurge(t *testing.T) {
+	startFreshDB()
+	openchaindb.PutToPersistence([]byte("key"), []byte("value"))
+	stopDB()
+	openchaindb.Start()
+	assertValue(t, openchaindb.GetFromPersistence, "key", "value")
+	stopDB()
+	openchaindb.Purge()
+	openchaindb.Start()
+	defer stopDB()
+	assertNil(t, openchaindb.GetFromPersistence, "key")
+}
+
+func TestDeleteState(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+
+	writeBatch := openchaindb.NewWriteBatch()
+	writeBatch.Put(db.StateCF, []byte("key1"), []byte("value1"))
+	writeBatch.Put(db.StateDeltaCF, []byte("key2"), []byte("value2"))
+	writeBatch.Put(db.BlockchainCF, []byte("key3"), []byte("value3"))
+	writeBatch.Commit(db.SyncWrite)
+
+	if err := openchaindb.DeleteState(); err != nil {
+		t.Fatalf("Error while deleting state: %s", err)
+	}
+	assertNil(t, openchaindb.GetFromState, "key1")
+	assertNil(t, openchaindb.GetFromStateDelta, "key2")
+	assertValue(t, openchaindb.GetFromBlockchain, "key3", "value3")
+}
+
+func TestDBSnapshot(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+
+	writeBatch := openchaindb.NewWriteBatch()
+	writeBatch.Put(db.BlockchainCF, []byte("key1"), []byte("value1"))
+	writeBatch.Put(db.StateCF, []byte("key1"), []byte("value1"))
+	writeBatch.Commit(db.SyncWrite)
+
+	snapshot := openchaindb.GetSnapshot()
+	defer snapshot.Release()
+
+	writeBatch = openchaindb.NewWriteBatch()
+	writeBatch.Delete(db.BlockchainCF, []byte("key1"))
+	writeBatch.Put(db.BlockchainCF, []byte("key2"), []byte("value2"))
+	writeBatch.Put(db.StateCF, []byte("key2"), []byte("value2"))
+	writeBatch.Commit(db.SyncWrite)
+
+	value, _ := openchaindb.GetFromBlockchainSnapshot(snapshot, []byte("key1"))
+	if !bytes.Equal(value, []byte("value1")) {
+		t.Fatalf("Expected value from db snapshot [%s], found [%s]", "value1", value)
+	}
+	value, _ = openchaindb.GetFromBlockchainSnapshot(snapshot, []byte("key2"))
+	if value != nil {
+		t.Fatalf("Expected value from db snapshot is 'nil', found [%s]", value)
+	}
+
+	itr := openchaindb.GetStateSnapshotIterator(snapshot)
+	defer itr.Close()
+	testIterator(t, itr, []string{"key1"})
+
+	itr = openchaindb.GetStateIterator()
+	defer itr.Close()
+	testIterator(t, itr, []string{"key1", "key2"})
+}
+
+func TestIteratorIsolation(t *testing.T) {
+	startFreshDB()
+	defer stopDB()
+
+	writeBatch := openchaindb.NewWriteBatch()
+	for _, key := range []string{"a", "b1", "b2", "c"} {
+		writeBatch.Put(db.StateCF, []byte(key), []byte("value_"+key))
+	}
+	writeBatch.Commit(db.SyncWrite)
+
+	itr := openchaindb.GetStateIterator()
+	writeBatch = openchaindb.NewWriteBatch()
+	writeBatch.Delete(db.StateCF, []byte("a"))
+	writeBatch.Put(db.StateCF, []byte("d"), []byte("value_d"))
+	writeBatch.Commit(db.SyncWrite)
+	testIterator(t, itr, []string{"a", "b1", "b2", "c"})
+
+	itr.Seek([]byte("b"))
+	if !itr.ValidForPrefix([]byte("b")) || string(itr.KeyData()) != "b1" {
+		t.Fatalf("Expected iterator at [b1], found [%s]", itr.KeyData())
+	}
+	itr.Next()
+	if !itr.ValidForPrefix([]byte("b")) || string(itr.ValueData()) != "value_b2" {
+		t.Fatalf("Expected value [value_b2], found [%s]", itr.ValueData())
+	}
+	itr.Next()
+	if itr.ValidForPrefix([]byte("b")) {
+		t.Fatalf("Iterator should not be valid for prefix [b] at key [%s]", itr.KeyData())
+	}
+	itr.Next()
+	itr.Prev()
+	if string(itr.KeyData()) != "c" {
+		t.Fatalf("Expected iterator at [c], found [%s]", itr.KeyData())
+	}
+	itr.Close()
+
+	itr = openchaindb.GetStateIterator()
+	defer itr.Close()
+	testIterator(t, itr, []string{"b1", "b2", "c", "d"})
+}
+
+func TestConformance(t *testing.T) {
+	dbtest.RunConformanceTests(t, &OpenchainMemoryDB{})
+}
+
+// db helper functions
+func testIterator(t *testing.T, itr db.Iterator, expectedKeys []string) {
+	var keys []string
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.KeyData()))
+	}
+	if len(keys) != len(expectedKeys) {
+		t.Fatalf("Expected keys %v from iterator, found %v", expectedKeys, keys)
+	}
+	for i := range keys {
+		if keys[i] != expectedKeys[i] {
+			t.Fatalf("Expected keys %v from iterator, found %v", expectedKeys, keys)
+		}
+	}
+}
+
+func assertValue(t *testing.T, get func([]byte) ([]byte, error), key string, expectedValue string) {
+	value, err := get([]byte(key))
+	if err != nil {
+		t.Fatalf("Error while getting key [%s]: %s", key, err)
+	}
+	if !bytes.Equal(value, []byte(expectedValue)) {
+		t.Fatalf("Expected value [%s] for key [%s], found [%s]", expectedValue, key, value)
+	}
+}
+
+func assertNil(t *testing.T, get func([]byte) ([]byte, error), key string) {
+	value, err := get([]byte(key))
+	if err != nil {
+		t.Fatalf("Error while getting key [%s]: %s", key, err)
+	}
+	if value != nil {
+		t.Fatalf("A nil value expected for key [%s]. Found [%s]", key, value)
+	}
+}
+
+func startFreshDB() {
+	openchaindb.Purge()
+	openchaindb.Start()
+}
+
+func stopDB() {
+	openchaindb.Stop()
+}
@@ -0,0 +1,77 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"github.com/hyperledger/fabric/core/db"
+)
+
+// WriteBatch implements the interface 'db.WriteBatch'. The changes are recorded in
+// order and applied under the write lock on Commit, so readers see all or none of them
+type WriteBatch struct {
+	openchainDB *OpenchainMemoryDB
+	ops         []batchOp
+}
+
+type batchOp struct {
+	cf     string
+	key    string
+	value  []byte
+	delete bool
+}
+
+// NewWriteBatch returns an empty batch for this db
+func (openchainDB *OpenchainMemoryDB) NewWriteBatch() db.WriteBatch {
+	return &WriteBatch{openchainDB: openchainDB}
+}
+
+// Put - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Put(cf string, key []byte, value []byte) {
+	checkColumnFamily(cf)
+	writeBatch.ops = append(writeBatch.ops, batchOp{cf, string(key), makeCopy(value), false})
+}
+
+// Delete - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Delete(cf string, key []byte) {
+	checkColumnFamily(cf)
+	writeBatch.ops = append(writeBatch.ops, batchOp{cf, string(key), nil, true})
+}
+
+// Commit - see interface 'db.WriteBatch' for details. The write options have no
+// effect, there is nothing to flush
+func (writeBatch *WriteBatch) Commit(opts *db.WriteOptions) error {
+	openchainDB := writeBatch.openchainDB
+	openchainDB.lock.Lock()
+	defer openchainDB.lock.Unlock()
+	if !openchainDB.started {
+		return errNotStarted
+	}
+	for _, op := range writeBatch.ops {
+		t := openchainDB.writableTable(op.cf)
+		if op.delete {
+			t.delete(op.key)
+		} else {
+			t.put(op.key, op.value)
+		}
+	}
+	return nil
+}
+
+// Destroy - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Destroy() {
+	writeBatch.ops = nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"fmt"
+)
+
+// DefaultMigrateBatchSize is the number of keys Migrate writes per batch when no
+// batch size is given
+const DefaultMigrateBatchSize = 1000
+
+// MigrateProgress is called by Migrate after each batch is committed to the target,
+// with the table being copied and the number of keys copied so far from that table
+type MigrateProgress func(cf string, copied uint64)
+
+// Migrate copies every table of the source datastore into the target datastore. The
+// tables are read through their iterators and written in batches of batchSize keys,
+// so the whole store never has to fit in memory. Both datastores must be started and
+// the target must be empty. Migrate returns the number of keys copied per table
+func Migrate(source OpenchainDB, target OpenchainDB, batchSize int, progress MigrateProgress) (map[string]uint64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultMigrateBatchSize
+	}
+	for _, cf := range ColumnFamilies {
+		itr := tableIterator(target, cf)
+		itr.SeekToFirst()
+		empty := !itr.Valid()
+		itr.Close()
+		if !empty {
+			return nil, fmt.Errorf("Target datastore [%s] is not empty, table [%s] has data", target.Type(), cf)
+		}
+	}
+
+	copied := make(map[string]uint64)
+	for _, cf := range ColumnFamilies {
+		count, err := migrateTable(source, target, cf, batchSize, progress)
+		if err != nil {
+			return nil, fmt.Errorf("Error while copying table [%s]: %s", cf, err)
+		}
+		copied[cf] = count
+	}
+	return copied, nil
+}
+
+func migrateTable(source OpenchainDB, target OpenchainDB, cf string, batchSize int, progress MigrateProgress) (uint64, error) {
+	itr := tableIterator(source, cf)
+	defer itr.Close()
+
+	var copied uint64
+	writeBatch := target.NewWriteBatch()
+	pending := 0
+	commit := func() error {
+		defer writeBatch.Destroy()
+		if err := writeBatch.Commit(SyncWrite); err != nil {
+			return err
+		}
+		copied += uint64(pending)
+		pending = 0
+		if progress != nil {
+			progress(cf, copied)
+		}
+		return nil
+	}
+
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		// copy the key and value, the iterator may reuse the memory they point to
+		key := append([]byte(nil), itr.KeyData()...)
+		value := append([]byte(nil), itr.ValueData()...)
+		itr.FreeKey()
+		itr.FreeValue()
+		writeBatch.Put(cf, key, value)
+		pending++
+		if pending == batchSize {
+			if err := commit(); err != nil {
+				return copied, err
+			}
+			writeBatch = target.NewWriteBatch()
+		}
+	}
+	if pending == 0 {
+		writeBatch.Destroy()
+		return copied, nil
+	}
+	return copied, commit()
+}
+
+func tableIterator(openchainDB OpenchainDB, cf string) Iterator {
+	switch cf {
+	case BlockchainCF:
+		return openchainDB.GetBlockchainIterator()
+	case StateCF:
+		return openchainDB.GetStateIterator()
+	case StateDeltaCF:
+		return openchainDB.GetStateDeltaIterator()
+	case IndexesCF:
+		return openchainDB.GetIndexesIterator()
+	case PersistCF:
+		return openchainDB.GetPersistenceIterator()
+	}
+	panic(fmt.Errorf("Unknown table [%s]", cf))
+}
@@ -0,0 +1,75 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/memory"
+)
+
+func TestMigrate(t *testing.T) {
+	source := &memory.OpenchainMemoryDB{}
+	target := &memory.OpenchainMemoryDB{}
+	source.Start()
+	defer source.Stop()
+	target.Start()
+	defer target.Stop()
+
+	writeBatch := source.NewWriteBatch()
+	for _, cf := range db.ColumnFamilies {
+		for i := 0; i < 7; i++ {
+			writeBatch.Put(cf, []byte(fmt.Sprintf("%s_%d", cf, i)), []byte(fmt.Sprintf("value_%d", i)))
+		}
+	}
+	if err := writeBatch.Commit(db.SyncWrite); err != nil {
+		t.Fatalf("Error while writing the source: %s", err)
+	}
+	writeBatch.Destroy()
+
+	progress := make(map[string][]uint64)
+	copied, err := db.Migrate(source, target, 3, func(cf string, copied uint64) {
+		progress[cf] = append(progress[cf], copied)
+	})
+	if err != nil {
+		t.Fatalf("Error while migrating: %s", err)
+	}
+	for _, cf := range db.ColumnFamilies {
+		if copied[cf] != 7 {
+			t.Fatalf("Expected 7 keys copied from [%s], found [%d]", cf, copied[cf])
+		}
+		if fmt.Sprint(progress[cf]) != "[3 6 7]" {
+			t.Fatalf("Expected progress [3 6 7] for [%s], found %v", cf, progress[cf])
+		}
+	}
+
+	value, err := target.GetFromIndexes([]byte(db.IndexesCF + "_5"))
+	if err != nil || !bytes.Equal(value, []byte("value_5")) {
+		t.Fatalf("Expected [value_5] in the target indexes, found [%s], err [%v]", value, err)
+	}
+	value, err = target.GetFromStateDelta([]byte(db.StateDeltaCF + "_0"))
+	if err != nil || !bytes.Equal(value, []byte("value_0")) {
+		t.Fatalf("Expected [value_0] in the target state delta, found [%s], err [%v]", value, err)
+	}
+
+	if _, err := db.Migrate(source, target, 3, nil); err == nil {
+		t.Fatalf("Migrating into a datastore that is not empty should fail")
+	}
+}
@@ -36,11 +36,11 @@ func (iterator *DbIterator) ValueData() [] byte {
 }
 
 func (iterator *DbIterator) FreeValue() {
-	iterator.Iterator.Key().Free()
+	iterator.Iterator.Value().Free()
 }
 
 func (iterator *DbIterator) ValueSize() int {
-	return iterator.Iterator.Key().Size()
+	return iterator.Iterator.Value().Size()
 }
 
 func (iterator *DbIterator) Prev() {
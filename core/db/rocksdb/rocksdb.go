@@ -1,33 +1,19 @@
 package rocksdb
 
 import (
+	"bytes"
+	"fmt"
+	"os"
 	"path"
-	"github.com/op/go-logging"
+	"strconv"
+
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/op/go-logging"
 	"github.com/tecbot/gorocksdb"
-	"fmt"
-	"os"
-	"io"
-	"github.com/spf13/viper"
-	"strings"
 )
 
 const DBStoreType = "rocksdb"
 
-const blockchainCF = "blockchainCF"
-const stateCF = "stateCF"
-const stateDeltaCF = "stateDeltaCF"
-const indexesCF = "indexesCF"
-const persistCF = "persistCF"
-
-var columnfamilies = []string {
-	blockchainCF, // blocks of the block chain
-	stateCF,      // world state
-	stateDeltaCF, // open transaction state
-	indexesCF,    // tx uuid -> blockno
-	persistCF,    // persistent per-peer state (consensus)
-}
-
 var dbLogger = logging.MustGetLogger("db")
 
 var openchaindb db.OpenchainDB
@@ -54,8 +40,11 @@ type OpenchainRocksDB struct {
 }
 
 func init() {
-	openchaindb, _ = (db.Registry.Add(Name, func() db.OpenchainDB { return &OpenchainRocksDB{}}))
-	openchaindb_ptr = openchaindb.(*OpenchainRocksDB)
+	// the registry builds the instance the ledger uses, openchaindb is the one the
+	// tests of this package start and stop directly
+	openchaindb_ptr = &OpenchainRocksDB{}
+	openchaindb = openchaindb_ptr
+	db.Registry.Add(Name, func() db.OpenchainDB { return &OpenchainRocksDB{} })
 }
 
 func (openchainDB *OpenchainRocksDB) Type() string {
@@ -64,9 +53,9 @@ func (openchainDB *OpenchainRocksDB) Type() string {
 
 
 func (openchainDB *OpenchainRocksDB) Start() {
-	dbPath := getDBPath()
-	fmt.Printf("dbPath inside Start",dbPath)
-	missing, err := dirMissingOrEmpty(dbPath)
+	dbPath := db.GetDBPath("db")
+	dbLogger.Debugf("Opening DB at path [%s]", dbPath)
+	missing, err := db.DirMissingOrEmpty(dbPath)
 	if err != nil {
 		panic(fmt.Sprintf("Error while trying to open DB: %s", err))
 	}
@@ -80,17 +69,25 @@ func (openchainDB *OpenchainRocksDB) Start() {
 		}
 	}
 
-	opts := gorocksdb.NewDefaultOptions()
+	opts, err := newCFOptions(db.DefaultTableOptions)
+	if err != nil {
+		panic(fmt.Sprintf("Error opening DB: %s", err))
+	}
 	defer opts.Destroy()
 
 	opts.SetCreateIfMissing(missing)
 	opts.SetCreateIfMissingColumnFamilies(true)
 
 	cfNames := []string{"default"}
-	cfNames = append(cfNames, columnfamilies...)
-	var cfOpts []*gorocksdb.Options
-	for range cfNames {
-		cfOpts = append(cfOpts, opts)
+	cfNames = append(cfNames, db.ColumnFamilies...)
+	cfOpts := []*gorocksdb.Options{opts}
+	for _, cf := range db.ColumnFamilies {
+		cfOpt, err := newCFOptions(cf)
+		if err != nil {
+			panic(fmt.Sprintf("Error opening DB: %s", err))
+		}
+		defer cfOpt.Destroy()
+		cfOpts = append(cfOpts, cfOpt)
 	}
 
 	db, cfHandlers, err := gorocksdb.OpenDbColumnFamilies(opts, dbPath, cfNames, cfOpts)
@@ -187,22 +184,19 @@ func (openchainDB *OpenchainRocksDB) GetStateDeltaIterator() db.Iterator {
 	return openchainDB.GetCFIterator(openchainDB.StateDeltaCF)
 }
 
-// GetSnapshot returns a point-in-time view of the DB. You MUST call snapshot.Release()
-// when you are done with the snapshot.
-
-func getDBPath() string {
-
-	dbPath := viper.GetString("peer.fileSystemPath")
+// GetPersistenceIterator get iterator for column family - persistCF
+func (openchainDB *OpenchainRocksDB) GetPersistenceIterator() db.Iterator {
+	return openchainDB.GetCFIterator(openchainDB.PersistCF)
+}
 
-	if dbPath == "" {
-		panic("DB path not specified in configuration file. Please check that property 'peer.fileSystemPath' is set")
-	}
-	if !strings.HasSuffix(dbPath, "/") {
-		dbPath = dbPath + "/"
-	}
-	return dbPath + "db"
+// GetIndexesIterator get iterator for column family - indexCF
+func (openchainDB *OpenchainRocksDB) GetIndexesIterator() db.Iterator {
+	return openchainDB.GetCFIterator(openchainDB.IndexesCF)
 }
 
+// GetSnapshot returns a point-in-time view of the DB. You MUST call snapshot.Release()
+// when you are done with the snapshot.
+
 //// DeleteState delets ALL state keys/values from the DB. This is generally
 //// only used during state synchronization when creating a new state from
 //// a snapshot.
@@ -217,14 +211,22 @@ func (openchainDB *OpenchainRocksDB) DeleteState() error {
 		dbLogger.Errorf("Error dropping state delta CF: %s", err)
 		return err
 	}
-	opts := gorocksdb.NewDefaultOptions()
-	defer opts.Destroy()
-	openchainDB.StateCF, err = openchainDB.DB.CreateColumnFamily(opts, stateCF)
+	stateOpts, err := newCFOptions(db.StateCF)
+	if err != nil {
+		return err
+	}
+	defer stateOpts.Destroy()
+	openchainDB.StateCF, err = openchainDB.DB.CreateColumnFamily(stateOpts, db.StateCF)
 	if err != nil {
 		dbLogger.Errorf("Error creating state CF: %s", err)
 		return err
 	}
-	openchainDB.StateDeltaCF, err = openchainDB.DB.CreateColumnFamily(opts, stateDeltaCF)
+	stateDeltaOpts, err := newCFOptions(db.StateDeltaCF)
+	if err != nil {
+		return err
+	}
+	defer stateDeltaOpts.Destroy()
+	openchainDB.StateDeltaCF, err = openchainDB.DB.CreateColumnFamily(stateDeltaOpts, db.StateDeltaCF)
 	if err != nil {
 		dbLogger.Errorf("Error creating state delta CF: %s", err)
 		return err
@@ -252,7 +254,7 @@ func (openchainDB *OpenchainRocksDB) Get(cfHandler *gorocksdb.ColumnFamilyHandle
 }
 
 func (openchainDB *OpenchainRocksDB) PutToPersistence(key []byte, value []byte) error {
-	return openchainDB.Put(openchainDB.StateDeltaCF, key,value)
+	return openchainDB.Put(openchainDB.PersistCF, key, value)
 }
 
 // Put saves the key/value in the given column family
@@ -312,48 +314,97 @@ func (openchainDB *OpenchainRocksDB) getSnapshotIterator(snapshot db.Snapshot, c
 	return &DbIterator{openchainDB.DB.NewIteratorCF(opt,cfHandler)}
 }
 
-func dirMissingOrEmpty(path string) (bool, error) {
-	dirExists, err := dirExists(path)
-	if err != nil {
-		return false, err
+// Stats reports the estimates RocksDB keeps for each column family. DiskSize is the
+// size of the live sst files and Compaction the 'rocksdb.cfstats' of every column
+// family
+func (openchainDB *OpenchainRocksDB) Stats() (*db.Stats, error) {
+	stats := &db.Stats{Type: Name}
+	for _, file := range openchainDB.DB.GetLiveFilesMetaData() {
+		stats.DiskSize += uint64(file.Size)
 	}
-	if !dirExists {
-		return true, nil
-	}
-
-	dirEmpty, err := dirEmpty(path)
-	if err != nil {
-		return false, err
+	var compaction bytes.Buffer
+	for _, cf := range db.ColumnFamilies {
+		cfHandler := openchainDB.getCFHandler(cf)
+		keys, err := getUintProperty(openchainDB.DB, "rocksdb.estimate-num-keys", cfHandler)
+		if err != nil {
+			return nil, err
+		}
+		size, err := getUintProperty(openchainDB.DB, "rocksdb.estimate-live-data-size", cfHandler)
+		if err != nil {
+			return nil, err
+		}
+		stats.Tables = append(stats.Tables, &db.TableStats{Name: cf, Keys: keys, Size: size, Estimated: true})
+		compaction.WriteString(openchainDB.DB.GetPropertyCF("rocksdb.cfstats", cfHandler))
 	}
-	if dirEmpty {
-		return true, nil
+	stats.Compaction = compaction.String()
+	return stats, nil
+}
+
+func (openchainDB *OpenchainRocksDB) getCFHandler(cf string) *gorocksdb.ColumnFamilyHandle {
+	switch cf {
+	case db.BlockchainCF:
+		return openchainDB.BlockchainCF
+	case db.StateCF:
+		return openchainDB.StateCF
+	case db.StateDeltaCF:
+		return openchainDB.StateDeltaCF
+	case db.IndexesCF:
+		return openchainDB.IndexesCF
+	case db.PersistCF:
+		return openchainDB.PersistCF
 	}
-	return false, nil
+	panic(fmt.Sprintf("Unknown column family [%s]", cf))
 }
 
-func dirExists(path string) (bool, error) {
-	_, err := os.Stat(path)
-	if err == nil {
-		return true, nil
+// getUintProperty reads a numeric property, RocksDB returns an empty string for a
+// property it does not know
+func getUintProperty(rocksDB *gorocksdb.DB, propName string, cfHandler *gorocksdb.ColumnFamilyHandle) (uint64, error) {
+	value := rocksDB.GetPropertyCF(propName, cfHandler)
+	if value == "" {
+		return 0, nil
 	}
-	if os.IsNotExist(err) {
-		return false, nil
+	number, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid value [%s] for property [%s]: %s", value, propName, err)
 	}
-	return false, err
+	return number, nil
 }
 
-func dirEmpty(path string) (bool, error) {
-	f, err := os.Open(path)
+// newCFOptions builds the options of column family cf from
+// 'datastore.rocksdb.tables.<cf>'. The caller destroys the options once the column
+// family is open, RocksDB keeps its own copy of the table factory and block cache
+func newCFOptions(cf string) (*gorocksdb.Options, error) {
+	tableOptions, err := db.GetTableOptions(Name, cf)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	defer f.Close()
-
-	_, err = f.Readdir(1)
-	if err == io.EOF {
-		return true, nil
+	opts := gorocksdb.NewDefaultOptions()
+	if tableOptions.WriteBufferSize > 0 {
+		opts.SetWriteBufferSize(tableOptions.WriteBufferSize * 1024 * 1024)
+	}
+	switch tableOptions.Compression {
+	case "":
+	case db.NoCompression:
+		opts.SetCompression(gorocksdb.NoCompression)
+	case db.SnappyCompression:
+		opts.SetCompression(gorocksdb.SnappyCompression)
+	case db.ZlibCompression:
+		opts.SetCompression(gorocksdb.ZLibCompression)
+	case db.Bz2Compression:
+		opts.SetCompression(gorocksdb.Bz2Compression)
+	}
+	if tableOptions.BlockCacheSize > 0 || tableOptions.BloomFilterBits > 0 {
+		blockOpts := gorocksdb.NewDefaultBlockBasedTableOptions()
+		if tableOptions.BlockCacheSize > 0 {
+			blockOpts.SetBlockCache(gorocksdb.NewLRUCache(tableOptions.BlockCacheSize * 1024 * 1024))
+		}
+		if tableOptions.BloomFilterBits > 0 {
+			blockOpts.SetFilterPolicy(gorocksdb.NewBloomFilter(tableOptions.BloomFilterBits))
+		}
+		opts.SetBlockBasedTableFactory(blockOpts)
 	}
-	return false, err
+	dbLogger.Debugf("Options of column family [%s]: %+v", cf, tableOptions)
+	return opts, nil
 }
 
 func makeCopy(src []byte) []byte {
@@ -24,8 +24,8 @@ import (
 	"io/ioutil"
 	"fmt"
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/dbtest"
 	"bytes"
-	"github.com/tecbot/gorocksdb"
 )
 
 const dbName = `rocksdb`
@@ -222,6 +222,43 @@ func TestDBIteratorAndSnapshotIterator(t *testing.T) {
 	testIterator(t, itr, map[string][]byte{"key6": []byte("value6"), "key7": []byte("value7")})
 }
 
+func TestWriteBatchDelete(t *testing.T) {
+	testDBWrapper := NewTestDBWrapper()
+	testDBWrapper.CleanDB(t)
+	openchainDB := openchaindb_ptr
+	defer testDBWrapper.cleanup()
+
+	openchainDB.Put(openchainDB.StateCF, []byte("key1"), []byte("value1"))
+	openchainDB.Put(openchainDB.PersistCF, []byte("key2"), []byte("value2"))
+
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Delete(db.StateCF, []byte("key1"))
+	writeBatch.Put(db.PersistCF, []byte("key2"), []byte("value2_new"))
+
+	// nothing is visible before the batch is committed
+	value, _ := openchainDB.GetFromState([]byte("key1"))
+	if !bytes.Equal(value, []byte("value1")) {
+		t.Fatalf("Expected value from db [%s], found [%s]", "value1", value)
+	}
+
+	if err := writeBatch.Commit(db.AsyncWrite); err != nil {
+		t.Fatalf("Error while committing batch: %s", err)
+	}
+	value, _ = openchainDB.GetFromState([]byte("key1"))
+	if value != nil {
+		t.Fatalf("A nil value expected. Found [%s]", value)
+	}
+	value, _ = openchainDB.GetFromPersistence([]byte("key2"))
+	if !bytes.Equal(value, []byte("value2_new")) {
+		t.Fatalf("Expected value from db [%s], found [%s]", "value2_new", value)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	dbtest.RunConformanceTests(t, &OpenchainRocksDB{})
+}
+
 // db helper functions
 func testIterator(t *testing.T, itr db.Iterator, expectedValues map[string][]byte) {
 	itrResults := make(map[string][]byte)
@@ -268,16 +305,14 @@ func setupTestConfig() {
 
 func performBasicReadWrite(openchainDB *OpenchainRocksDB, t *testing.T) {
 
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := openchainDB.NewWriteBatch()
 	defer writeBatch.Destroy()
 
-	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("dummyKey"), []byte("dummyValue"))
-	writeBatch.PutCF(openchainDB.StateCF, []byte("dummyKey1"), []byte("dummyValue1"))
-	writeBatch.PutCF(openchainDB.StateDeltaCF, []byte("dummyKey2"), []byte("dummyValue2"))
-	writeBatch.PutCF(openchainDB.IndexesCF, []byte("dummyKey3"), []byte("dummyValue3"))
-	err := openchainDB.DB.Write(opt, writeBatch)
+	writeBatch.Put(db.BlockchainCF, []byte("dummyKey"), []byte("dummyValue"))
+	writeBatch.Put(db.StateCF, []byte("dummyKey1"), []byte("dummyValue1"))
+	writeBatch.Put(db.StateDeltaCF, []byte("dummyKey2"), []byte("dummyValue2"))
+	writeBatch.Put(db.IndexesCF, []byte("dummyKey3"), []byte("dummyValue3"))
+	err := writeBatch.Commit(db.SyncWrite)
 
 	if err != nil {
 		t.Fatalf("Error while writing to db: %s", err)
@@ -20,6 +20,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/spf13/viper"
 	"github.com/tecbot/gorocksdb"
 )
@@ -63,7 +64,7 @@ func (testDB *TestDBWrapper) CreateFreshDBGinkgo() {
 
 func (testDB *TestDBWrapper) cleanup() {
 	if testDB.performCleanup {
-		//openchaindb.Start()
+		openchaindb.Stop()
 		testDB.performCleanup = false
 	}
 }
@@ -74,10 +75,8 @@ func (testDB *TestDBWrapper) removeDBPath() {
 }
 
 // WriteToDB tests can use this method for persisting a given batch to db
-func (testDB *TestDBWrapper) WriteToDB(t testing.TB, writeBatch *gorocksdb.WriteBatch) {
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err := openchaindb_ptr.DB.Write(opt, writeBatch)
+func (testDB *TestDBWrapper) WriteToDB(t testing.TB, writeBatch db.WriteBatch) {
+	err := writeBatch.Commit(db.AsyncWrite)
 	if err != nil {
 		t.Fatalf("Error while writing to db. Error:%s", err)
 	}
@@ -120,11 +119,13 @@ func (testDB *TestDBWrapper) GetFromStateDeltaCF(t testing.TB, key []byte) []byt
 // CloseDB closes the db
 func (testDB *TestDBWrapper) CloseDB(t testing.TB) {
 	openchaindb.Stop()
+	testDB.performCleanup = false
 }
 
 // OpenDB opens the db
 func (testDB *TestDBWrapper) OpenDB(t testing.TB) {
 	openchaindb.Start()
+	testDB.performCleanup = true
 }
 
 // GetEstimatedNumKeys returns estimated number of key-values in db. This is not accurate in all the cases
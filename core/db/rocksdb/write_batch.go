@@ -0,0 +1,82 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rocksdb
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/tecbot/gorocksdb"
+)
+
+// WriteBatch implements the interface 'db.WriteBatch' on top of a rocksdb write batch
+type WriteBatch struct {
+	openchainDB *OpenchainRocksDB
+	batch       *gorocksdb.WriteBatch
+}
+
+// NewWriteBatch returns an empty batch for this db
+func (openchainDB *OpenchainRocksDB) NewWriteBatch() db.WriteBatch {
+	return &WriteBatch{openchainDB, gorocksdb.NewWriteBatch()}
+}
+
+// Put - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Put(cf string, key []byte, value []byte) {
+	writeBatch.batch.PutCF(writeBatch.openchainDB.getCFHandle(cf), key, value)
+}
+
+// Delete - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Delete(cf string, key []byte) {
+	writeBatch.batch.DeleteCF(writeBatch.openchainDB.getCFHandle(cf), key)
+}
+
+// Commit - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Commit(opts *db.WriteOptions) error {
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	if opts != nil {
+		opt.SetSync(opts.Sync)
+	}
+	err := writeBatch.openchainDB.DB.Write(opt, writeBatch.batch)
+	if err != nil {
+		dbLogger.Errorf("Error while committing write batch: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Destroy - see interface 'db.WriteBatch' for details
+func (writeBatch *WriteBatch) Destroy() {
+	writeBatch.batch.Destroy()
+}
+
+// getCFHandle maps a table name from 'db.ColumnFamilies' to the column family handle
+func (openchainDB *OpenchainRocksDB) getCFHandle(cf string) *gorocksdb.ColumnFamilyHandle {
+	switch cf {
+	case db.BlockchainCF:
+		return openchainDB.BlockchainCF
+	case db.StateCF:
+		return openchainDB.StateCF
+	case db.StateDeltaCF:
+		return openchainDB.StateDeltaCF
+	case db.IndexesCF:
+		return openchainDB.IndexesCF
+	case db.PersistCF:
+		return openchainDB.PersistCF
+	}
+	panic(fmt.Sprintf("Unknown column family [%s]", cf))
+}
@@ -1,6 +1,9 @@
 package db
 
+// Snapshot is a point-in-time, read-only view of the datastore. Snapshots
+// are obtained from StateManager.GetSnapshot and can be passed back to the
+// snapshot aware methods of the same datastore. Release MUST be called once
+// you are done with the snapshot.
 type Snapshot interface {
-
+	Release()
 }
-
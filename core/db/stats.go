@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Stats describes the content of a datastore, as returned by OpenchainDB.Stats
+type Stats struct {
+	Type string `json:"type"`
+	// DiskSize is the space used by the files of the datastore in bytes, 0 for a
+	// datastore that is not kept on disk
+	DiskSize uint64        `json:"diskSize"`
+	Tables   []*TableStats `json:"tables"`
+	// Compaction is the compaction report of the datastore, in the format of the
+	// underlying engine. It is empty for the datastores that do not compact
+	Compaction string `json:"compaction,omitempty"`
+}
+
+// TableStats describes one of the tables listed in ColumnFamilies
+type TableStats struct {
+	Name string `json:"name"`
+	Keys uint64 `json:"keys"`
+	// Size is the size of the keys and values of the table in bytes
+	Size uint64 `json:"size"`
+	// Estimated is set when Keys and Size are estimates of the engine rather than
+	// counted
+	Estimated bool `json:"estimated"`
+}
+
+// ScanTableStats counts the keys of a table and the size of its keys and values.
+// The counts are exact, but the whole table is read
+func ScanTableStats(openchainDB OpenchainDB, cf string) *TableStats {
+	stats := &TableStats{Name: cf}
+	itr := tableIterator(openchainDB, cf)
+	defer itr.Close()
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		stats.Keys++
+		stats.Size += uint64(itr.KeySize() + itr.ValueSize())
+	}
+	return stats
+}
+
+// DirSize returns the total size of the files under path
+func DirSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Compression algorithms accepted in 'datastore.<name>.tables.<table>.compression'.
+// A datastore that does not support an algorithm fails to start
+const (
+	NoCompression     = "none"
+	SnappyCompression = "snappy"
+	ZlibCompression   = "zlib"
+	Bz2Compression    = "bz2"
+)
+
+// DefaultTableOptions is the key under 'datastore.<name>.tables' whose options apply
+// to every table that has no options of its own
+const DefaultTableOptions = "default"
+
+// TableOptions tunes the storage of one table. A zero value leaves the setting to the
+// datastore
+type TableOptions struct {
+	// BlockCacheSize is the size of the block cache in MB
+	BlockCacheSize int
+	// BloomFilterBits is the number of bits per key of the bloom filter, 0 for no filter
+	BloomFilterBits int
+	// Compression is one of NoCompression, SnappyCompression, ZlibCompression or
+	// Bz2Compression
+	Compression string
+	// WriteBufferSize is the size of the memtable in MB
+	WriteBufferSize int
+}
+
+// GetTableOptions reads the options of table cf of the datastore name from
+// 'datastore.<name>.tables.<cf>'. A setting that is not given for the table is taken
+// from 'datastore.<name>.tables.default'
+func GetTableOptions(name string, cf string) (*TableOptions, error) {
+	get := func(setting string) string {
+		key := fmt.Sprintf("datastore.%s.tables.%s.%s", name, cf, setting)
+		if viper.IsSet(key) {
+			return key
+		}
+		return fmt.Sprintf("datastore.%s.tables.%s.%s", name, DefaultTableOptions, setting)
+	}
+	options := &TableOptions{
+		BlockCacheSize:  viper.GetInt(get("blockCacheSize")),
+		BloomFilterBits: viper.GetInt(get("bloomFilterBits")),
+		Compression:     strings.ToLower(viper.GetString(get("compression"))),
+		WriteBufferSize: viper.GetInt(get("writeBufferSize")),
+	}
+	if options.BlockCacheSize < 0 || options.BloomFilterBits < 0 || options.WriteBufferSize < 0 {
+		return nil, fmt.Errorf("Invalid options for table [%s] of datastore [%s]: sizes cannot be negative", cf, name)
+	}
+	switch options.Compression {
+	case "", NoCompression, SnappyCompression, ZlibCompression, Bz2Compression:
+	default:
+		return nil, fmt.Errorf("Invalid options for table [%s] of datastore [%s]: unknown compression [%s]", cf, name, options.Compression)
+	}
+	return options, nil
+}
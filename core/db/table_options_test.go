@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestGetTableOptions(t *testing.T) {
+	viper.Set("datastore.testdb.tables.default.blockCacheSize", 64)
+	viper.Set("datastore.testdb.tables.default.compression", "snappy")
+	viper.Set("datastore.testdb.tables.stateCF.bloomFilterBits", 10)
+	viper.Set("datastore.testdb.tables.stateCF.compression", "None")
+
+	options, err := GetTableOptions("testdb", StateCF)
+	if err != nil {
+		t.Fatalf("Error while reading table options: %s", err)
+	}
+	expected := TableOptions{BlockCacheSize: 64, BloomFilterBits: 10, Compression: NoCompression}
+	if *options != expected {
+		t.Fatalf("Expected %+v, found %+v", expected, *options)
+	}
+
+	options, err = GetTableOptions("testdb", BlockchainCF)
+	if err != nil {
+		t.Fatalf("Error while reading table options: %s", err)
+	}
+	expected = TableOptions{BlockCacheSize: 64, Compression: SnappyCompression}
+	if *options != expected {
+		t.Fatalf("Expected %+v, found %+v", expected, *options)
+	}
+
+	viper.Set("datastore.testdb.tables.indexesCF.compression", "lzma")
+	if _, err = GetTableOptions("testdb", IndexesCF); err == nil {
+		t.Fatalf("An unknown compression should fail")
+	}
+}
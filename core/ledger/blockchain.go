@@ -21,13 +21,11 @@ import (
 	"encoding/binary"
 	"strconv"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
-	"github.com/tecbot/gorocksdb"
 	"golang.org/x/net/context"
-	"github.com/hyperledger/fabric/core/comm"
-	"github.com/hyperledger/fabric/core/db/rocksdb"
 )
 
 // Blockchain holds basic information in memory. Operations on Blockchain are not thread-safe
@@ -37,6 +35,9 @@ type blockchain struct {
 	previousBlockHash  []byte
 	indexer            blockchainIndexer
 	lastProcessedBlock *lastProcessedBlock
+	pruned             *prunedBlocks
+	// pendingPruned is the pruned range once the block being committed is persisted
+	pendingPruned *prunedBlocks
 }
 
 type lastProcessedBlock struct {
@@ -52,7 +53,11 @@ func newBlockchain() (*blockchain, error) {
 	if err != nil {
 		return nil, err
 	}
-	blockchain := &blockchain{0, nil, nil, nil}
+	pruned, err := fetchPrunedBlocksFromDB()
+	if err != nil {
+		return nil, err
+	}
+	blockchain := &blockchain{0, nil, nil, nil, pruned, nil}
 	blockchain.size = size
 	if size > 0 {
 		previousBlock, err := fetchBlockFromDB(size - 1)
@@ -96,8 +101,11 @@ func (blockchain *blockchain) getSize() uint64 {
 	return blockchain.size
 }
 
-// getBlock get block at arbitrary height in block chain
+// getBlock get block at arbitrary height in block chain. Returns ErrBlockPruned if the block has been pruned
 func (blockchain *blockchain) getBlock(blockNumber uint64) (*protos.Block, error) {
+	if blockchain.isPruned(blockNumber) {
+		return nil, ErrBlockPruned
+	}
 	return fetchBlockFromDB(blockNumber)
 }
 
@@ -123,6 +131,18 @@ func (blockchain *blockchain) getTransactionByID(txID string) (*protos.Transacti
 	return transaction, nil
 }
 
+func (blockchain *blockchain) getTransactionProof(txID string) (*protos.TransactionProof, error) {
+	blockNumber, txIndex, err := blockchain.indexer.fetchTransactionIndexByID(txID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := blockchain.getBlock(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return block.GetTransactionProof(blockNumber, int(txIndex))
+}
+
 // getTransactions get all transactions in a block identified by block number
 func (blockchain *blockchain) getTransactions(blockNumber uint64) ([]*protos.Transaction, error) {
 	block, err := blockchain.getBlock(blockNumber)
@@ -190,7 +210,7 @@ func (blockchain *blockchain) buildBlock(block *protos.Block, stateHash []byte)
 }
 
 func (blockchain *blockchain) addPersistenceChangesForNewBlock(ctx context.Context,
-	block *protos.Block, stateHash []byte, writeBatch *gorocksdb.WriteBatch) (uint64, error) {
+	block *protos.Block, stateHash []byte, writeBatch db.WriteBatch) (uint64, error) {
 	block = blockchain.buildBlock(block, stateHash)
 	if block.NonHashData == nil {
 		block.NonHashData = &protos.NonHashData{LocalLedgerCommitTimestamp: util.CreateUtcTimestamp()}
@@ -206,10 +226,8 @@ func (blockchain *blockchain) addPersistenceChangesForNewBlock(ctx context.Conte
 	if blockBytesErr != nil {
 		return 0, blockBytesErr
 	}
-	openchainDB,_ := db.Registry.Get(comm.DbPluginName())
-        openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
-	writeBatch.PutCF(openchainDB_ptr.BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
-	writeBatch.PutCF(openchainDB_ptr.BlockchainCF, blockCountKey, encodeUint64(blockNumber+1))
+	writeBatch.Put(db.BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	writeBatch.Put(db.BlockchainCF, blockCountKey, encodeUint64(blockNumber+1))
 	if blockchain.indexer.isSynchronous() {
 		blockchain.indexer.createIndexes(block, blockNumber, blockHash, writeBatch)
 	}
@@ -219,26 +237,37 @@ func (blockchain *blockchain) addPersistenceChangesForNewBlock(ctx context.Conte
 
 func (blockchain *blockchain) blockPersistenceStatus(success bool) {
 	if success {
+		if blockchain.pendingPruned != nil {
+			blockchain.pruned = blockchain.pendingPruned
+		}
 		blockchain.size++
 		blockchain.previousBlockHash = blockchain.lastProcessedBlock.blockHash
 		if !blockchain.indexer.isSynchronous() {
-			writeBatch := gorocksdb.NewWriteBatch()
-			defer writeBatch.Destroy()
 			blockchain.indexer.createIndexes(blockchain.lastProcessedBlock.block,
-				blockchain.lastProcessedBlock.blockNumber, blockchain.lastProcessedBlock.blockHash, writeBatch)
+				blockchain.lastProcessedBlock.blockNumber, blockchain.lastProcessedBlock.blockHash, nil)
 		}
 	}
 	blockchain.lastProcessedBlock = nil
+	blockchain.pendingPruned = nil
 }
 
 func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber uint64) error {
+	if blockchain.isPruned(blockNumber) {
+		// it would never be pruned again
+		ledgerLogger.Debugf("Not persisting block number [%d] as it falls in the pruned range", blockNumber)
+		return nil
+	}
 	blockBytes, blockBytesErr := block.Bytes()
 	if blockBytesErr != nil {
 		return blockBytesErr
 	}
-	writeBatch := gorocksdb.NewWriteBatch()
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return err
+	}
+	writeBatch := openchainDB.NewWriteBatch()
 	defer writeBatch.Destroy()
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	writeBatch.Put(db.BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
 
 	blockHash, err := block.GetHash()
 	if err != nil {
@@ -249,7 +278,7 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 	// real blockchain height, not size.
 	if blockchain.getSize() < blockNumber+1 {
 		sizeBytes := encodeUint64(blockNumber + 1)
-		writeBatch.PutCF(db.GetDBHandle().BlockchainCF, blockCountKey, sizeBytes)
+		writeBatch.Put(db.BlockchainCF, blockCountKey, sizeBytes)
 		blockchain.size = blockNumber + 1
 		blockchain.previousBlockHash = blockHash
 	}
@@ -258,17 +287,22 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 		blockchain.indexer.createIndexes(block, blockNumber, blockHash, writeBatch)
 	}
 
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err = db.GetDBHandle().DB.Write(opt, writeBatch)
+	err = writeBatch.Commit(db.AsyncWrite)
 	if err != nil {
 		return err
 	}
+	if !blockchain.indexer.isSynchronous() {
+		blockchain.indexer.createIndexes(block, blockNumber, blockHash, nil)
+	}
 	return nil
 }
 
 func fetchBlockFromDB(blockNumber uint64) (*protos.Block, error) {
-	blockBytes, err := db.GetDBHandle().GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	blockBytes, err := openchainDB.GetFromBlockchain(encodeBlockNumberDBKey(blockNumber))
 	if err != nil {
 		return nil, err
 	}
@@ -279,7 +313,11 @@ func fetchBlockFromDB(blockNumber uint64) (*protos.Block, error) {
 }
 
 func fetchBlockchainSizeFromDB() (uint64, error) {
-	bytes, err := db.GetDBHandle().GetFromBlockchainCF(blockCountKey)
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return 0, err
+	}
+	bytes, err := openchainDB.GetFromBlockchain(blockCountKey)
 	if err != nil {
 		return 0, err
 	}
@@ -289,8 +327,12 @@ func fetchBlockchainSizeFromDB() (uint64, error) {
 	return decodeToUint64(bytes), nil
 }
 
-func fetchBlockchainSizeFromSnapshot(snapshot *gorocksdb.Snapshot) (uint64, error) {
-	blockNumberBytes, err := db.GetDBHandle().GetFromBlockchainCFSnapshot(snapshot, blockCountKey)
+func fetchBlockchainSizeFromSnapshot(snapshot db.Snapshot) (uint64, error) {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return 0, err
+	}
+	blockNumberBytes, err := openchainDB.GetFromBlockchainSnapshot(snapshot, blockCountKey)
 	if err != nil {
 		return 0, err
 	}
@@ -320,7 +362,7 @@ func decodeToUint64(bytes []byte) uint64 {
 func (blockchain *blockchain) String() string {
 	var buffer bytes.Buffer
 	size := blockchain.getSize()
-	for i := uint64(0); i < size; i++ {
+	for i := blockchain.pruned.count; i < size; i++ {
 		block, blockErr := blockchain.getBlock(i)
 		if blockErr != nil {
 			return ""
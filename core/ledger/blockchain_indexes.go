@@ -20,28 +20,55 @@ import (
 	"fmt"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var indexLogger = logging.MustGetLogger("indexes")
 var prefixBlockHashKey = byte(1)
 var prefixTxIDKey = byte(2)
 var prefixAddressBlockNumCompositeKey = byte(3)
+var prefixChaincodeIDTxKey = byte(5)
+var prefixSubmitterCertTxKey = byte(6)
+var prefixEnrollmentIDTxKey = byte(7)
+
+// The key history index (history.go) uses the prefix byte(4) and the rich query
+// document index (statemgmt/richquery) the prefix byte(8) of the indexes table
 
 type blockchainIndexer interface {
 	isSynchronous() bool
 	start(blockchain *blockchain) error
-	createIndexes(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error
+	createIndexes(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch db.WriteBatch) error
 	fetchBlockNumberByBlockHash(blockHash []byte) (uint64, error)
 	fetchTransactionIndexByID(txID string) (uint64, uint64, error)
+	// waitForIndexes returns once the indexes of the blocks up to blockNumber can be read,
+	// or an error of type ErrorTypeNotYetIndexed if they are not indexed in time
+	waitForIndexes(blockNumber uint64) error
+	status() *IndexerStatus
 	stop()
 }
 
+// IndexerStatus tells how far the indexing of the committed blocks is. The blocks are
+// indexed with the commit unless the indexer is asynchronous
+type IndexerStatus struct {
+	Asynchronous    bool   `json:"asynchronous"`
+	CommittedBlocks uint64 `json:"committedBlocks"`
+	IndexedBlocks   uint64 `json:"indexedBlocks"`
+	// Lag is the number of committed blocks not indexed yet
+	Lag uint64 `json:"lag"`
+	// Failures is the number of times the indexing of blocks failed since the start
+	Failures uint64 `json:"failures"`
+	// LastError is the error of the last failure while the indexing is failing
+	LastError string `json:"lastError,omitempty"`
+}
+
 // Implementation for sync indexer
 type blockchainIndexerSync struct {
+	blockchain *blockchain
 }
 
 func newBlockchainIndexerSync() *blockchainIndexerSync {
@@ -53,11 +80,12 @@ func (indexer *blockchainIndexerSync) isSynchronous() bool {
 }
 
 func (indexer *blockchainIndexerSync) start(blockchain *blockchain) error {
+	indexer.blockchain = blockchain
 	return nil
 }
 
 func (indexer *blockchainIndexerSync) createIndexes(
-	block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
+	block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch db.WriteBatch) error {
 	return addIndexDataForPersistence(block, blockNumber, blockHash, writeBatch)
 }
 
@@ -69,18 +97,26 @@ func (indexer *blockchainIndexerSync) fetchTransactionIndexByID(txID string) (ui
 	return fetchTransactionIndexByIDFromDB(txID)
 }
 
+func (indexer *blockchainIndexerSync) waitForIndexes(blockNumber uint64) error {
+	return nil
+}
+
+func (indexer *blockchainIndexerSync) status() *IndexerStatus {
+	size := indexer.blockchain.getSize()
+	return &IndexerStatus{CommittedBlocks: size, IndexedBlocks: size}
+}
+
 func (indexer *blockchainIndexerSync) stop() {
 	return
 }
 
 // Functions for persisting and retrieving index data
-func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
-	openchainDB := db.GetDBHandle()
-	cf := openchainDB.IndexesCF
+func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch db.WriteBatch) error {
+	cf := db.IndexesCF
 
 	// add blockhash -> blockNumber
 	indexLogger.Debugf("Indexing block number [%d] by hash = [%x]", blockNumber, blockHash)
-	writeBatch.PutCF(cf, encodeBlockHashKey(blockHash), encodeBlockNumber(blockNumber))
+	writeBatch.Put(cf, encodeBlockHashKey(blockHash), encodeBlockNumber(blockNumber))
 
 	addressToTxIndexesMap := make(map[string][]uint64)
 	addressToChaincodeIDsMap := make(map[string][]*protos.ChaincodeID)
@@ -88,7 +124,12 @@ func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHa
 	transactions := block.GetTransactions()
 	for txIndex, tx := range transactions {
 		// add TxID -> (blockNumber,indexWithinBlock)
-		writeBatch.PutCF(cf, encodeTxIDKey(tx.Txid), encodeBlockNumTxIndex(blockNumber, uint64(txIndex)))
+		writeBatch.Put(cf, encodeTxIDKey(tx.Txid), encodeBlockNumTxIndex(blockNumber, uint64(txIndex)))
+
+		// add (chaincodeID|submitter,blockNumber,indexWithinBlock) -> TxID
+		for _, txLookupKey := range getTxLookupKeys(tx, blockNumber, uint64(txIndex)) {
+			writeBatch.Put(cf, txLookupKey, []byte(tx.Txid))
+		}
 
 		txExecutingAddress := getTxExecutingAddress(tx)
 		addressToTxIndexesMap[txExecutingAddress] = append(addressToTxIndexesMap[txExecutingAddress], uint64(txIndex))
@@ -102,14 +143,18 @@ func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHa
 		}
 	}
 	for address, txsIndexes := range addressToTxIndexesMap {
-		writeBatch.PutCF(cf, encodeAddressBlockNumCompositeKey(address, blockNumber), encodeListTxIndexes(txsIndexes))
+		writeBatch.Put(cf, encodeAddressBlockNumCompositeKey(address, blockNumber), encodeListTxIndexes(txsIndexes))
 	}
 	return nil
 }
 
 func fetchBlockNumberByBlockHashFromDB(blockHash []byte) (uint64, error) {
 	indexLogger.Debugf("fetchBlockNumberByBlockHashFromDB() for blockhash [%x]", blockHash)
-	blockNumberBytes, err := db.GetDBHandle().GetFromIndexesCF(encodeBlockHashKey(blockHash))
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return 0, err
+	}
+	blockNumberBytes, err := openchainDB.GetFromIndexes(encodeBlockHashKey(blockHash))
 	if err != nil {
 		return 0, err
 	}
@@ -122,7 +167,11 @@ func fetchBlockNumberByBlockHashFromDB(blockHash []byte) (uint64, error) {
 }
 
 func fetchTransactionIndexByIDFromDB(txID string) (uint64, uint64, error) {
-	blockNumTxIndexBytes, err := db.GetDBHandle().GetFromIndexesCF(encodeTxIDKey(txID))
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return 0, 0, err
+	}
+	blockNumTxIndexBytes, err := openchainDB.GetFromIndexes(encodeTxIDKey(txID))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -132,6 +181,38 @@ func fetchTransactionIndexByIDFromDB(txID string) (uint64, uint64, error) {
 	return decodeBlockNumTxIndex(blockNumTxIndexBytes)
 }
 
+// getTxLookupKeys returns the keys under which a transaction is found by chaincode ID, by
+// submitter certificate and, if it is signed with an enrollment certificate, by enrollment ID.
+// The enrollment ID is encrypted in transaction certificates, so those transactions are only
+// found by certificate. A chaincode ID that is encrypted for confidentiality is not indexed
+func getTxLookupKeys(tx *protos.Transaction, blockNumber uint64, txIndex uint64) [][]byte {
+	var keys [][]byte
+	cID := &protos.ChaincodeID{}
+	if err := proto.Unmarshal(tx.ChaincodeID, cID); err == nil && cID.Name != "" {
+		keys = append(keys, encodeTxLookupKey(prefixChaincodeIDTxKey, []byte(cID.Name), blockNumber, txIndex))
+	}
+	if len(tx.Cert) > 0 {
+		keys = append(keys, encodeTxLookupKey(prefixSubmitterCertTxKey, util.ComputeCryptoHash(tx.Cert), blockNumber, txIndex))
+		if enrollmentID := getEnrollmentID(tx.Cert); enrollmentID != "" {
+			keys = append(keys, encodeTxLookupKey(prefixEnrollmentIDTxKey, []byte(enrollmentID), blockNumber, txIndex))
+		}
+	}
+	return keys
+}
+
+// getEnrollmentID returns the common name of an enrollment certificate, which is the
+// enrollment ID. It returns "" for transaction certificates and certificates that do not parse
+func getEnrollmentID(cert []byte) string {
+	x509Cert, err := primitives.DERToX509Certificate(cert)
+	if err != nil {
+		return ""
+	}
+	if _, err = primitives.GetCriticalExtension(x509Cert, primitives.TCertEncTCertIndex); err == nil {
+		return ""
+	}
+	return x509Cert.Subject.CommonName
+}
+
 func getTxExecutingAddress(tx *protos.Transaction) string {
 	// TODO Fetch address form tx
 	return "address1"
@@ -198,6 +279,30 @@ func encodeAddressBlockNumCompositeKey(address string, blockNumber uint64) []byt
 	return b.Bytes()
 }
 
+// encodeTxLookupKeyPrefix encodes the part of a lookup key shared by the transactions of
+// one chaincode or submitter. It is followed by the 8 byte big-endian block number and
+// index within the block, so that the transactions are kept in the order of the chain
+func encodeTxLookupKeyPrefix(prefix byte, id []byte) []byte {
+	b := proto.NewBuffer([]byte{prefix})
+	b.EncodeRawBytes(id)
+	return b.Bytes()
+}
+
+func encodeTxLookupKey(prefix byte, id []byte, blockNumber uint64, txIndex uint64) []byte {
+	return append(encodeTxLookupKeyPrefix(prefix, id), encodeTxLocation(blockNumber, txIndex)...)
+}
+
+func encodeTxLocation(blockNumber uint64, txIndex uint64) []byte {
+	return append(encodeUint64(blockNumber), encodeUint64(txIndex)...)
+}
+
+func decodeTxLocation(location []byte) (blockNumber uint64, txIndex uint64, err error) {
+	if len(location) != 16 {
+		return 0, 0, fmt.Errorf("Invalid transaction location [%x]", location)
+	}
+	return decodeToUint64(location[:8]), decodeToUint64(location[8:]), nil
+}
+
 func encodeListTxIndexes(listTx []uint64) []byte {
 	b := proto.NewBuffer([]byte{})
 	for i := range listTx {
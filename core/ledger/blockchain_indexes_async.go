@@ -19,25 +19,39 @@ package ledger
 import (
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/protos"
-	"github.com/tecbot/gorocksdb"
 )
 
+// lastIndexedBlockKey holds the number of the last block indexed by the async indexer,
+// its high-water mark. It is written in the same batch as the indexes of the blocks,
+// so after a crash the indexer carries on from the last batch written
 var lastIndexedBlockKey = []byte{byte(0)}
 
-type blockWrapper struct {
-	block       *protos.Block
-	blockNumber uint64
-	blockHash   []byte
-	stopNow     bool
-}
+// asyncIndexerBatchSize is the largest number of blocks indexed in a single write batch
+var asyncIndexerBatchSize = uint64(100)
+
+// asyncIndexerWaitTimeout is how long a lookup waits for the committed blocks to be
+// indexed before failing with ErrorTypeNotYetIndexed
+var asyncIndexerWaitTimeout = 2 * time.Second
 
+// asyncIndexerRetryInterval is how long the async indexer waits after a failure before
+// indexing again
+var asyncIndexerRetryInterval = 5 * time.Second
+
+// blockchainIndexerAsync indexes the committed blocks in the background. The blocks are
+// read back from the db, from the high-water mark up to the size of the chain, so that
+// nothing is held in memory for blocks that are not indexed yet and a restart does not
+// have to wait for the indexer to catch up
 type blockchainIndexerAsync struct {
 	blockchain *blockchain
-	// Channel for transferring block from block chain for indexing
-	blockChan    chan blockWrapper
+	// newBlocks wakes up the indexing loop when blocks are committed
+	newBlocks    chan struct{}
+	stopChan     chan struct{}
+	stopped      chan struct{}
 	indexerState *blockchainIndexerState
 }
 
@@ -51,274 +65,312 @@ func (indexer *blockchainIndexerAsync) isSynchronous() bool {
 
 func (indexer *blockchainIndexerAsync) start(blockchain *blockchain) error {
 	indexer.blockchain = blockchain
-	indexerState, err := newBlockchainIndexerState(indexer)
+	indexerState, err := newBlockchainIndexerState(blockchain.getSize())
 	if err != nil {
 		return err
 	}
 	indexer.indexerState = indexerState
-	indexLogger.Debugf("staring indexer, lastIndexedBlockNum = [%d]",
-		indexer.indexerState.getLastIndexedBlockNumber())
+	indexLogger.Debugf("Starting indexer, indexed blocks = [%d], committed blocks = [%d]",
+		indexerState.getIndexedCount(), blockchain.getSize())
+	indexer.newBlocks = make(chan struct{}, 1)
+	indexer.stopChan = make(chan struct{})
+	indexer.stopped = make(chan struct{})
+	go indexer.run()
+	return nil
+}
 
-	err = indexer.indexPendingBlocks()
-	if err != nil {
-		return err
-	}
-	indexLogger.Debugf("staring indexer, lastIndexedBlockNum = [%d] after processing pending blocks",
-		indexer.indexerState.getLastIndexedBlockNumber())
-	indexer.blockChan = make(chan blockWrapper)
-	go func() {
-		for {
-			indexLogger.Debug("Going to wait on channel for next block to index")
-			blockWrapper := <-indexer.blockChan
-
-			indexLogger.Debugf("Blockwrapper received on channel: block number = [%d]", blockWrapper.blockNumber)
-
-			if blockWrapper.stopNow {
-				indexLogger.Debug("stop command received on channel")
-				indexer.blockChan <- blockWrapper
-				return
-			}
-			if indexer.indexerState.hasError() {
-				indexLogger.Debugf("Not indexing block number [%d]. Because of previous error: %s.",
-					blockWrapper.blockNumber, indexer.indexerState.getError())
-				continue
-			}
-
-			err := indexer.createIndexesInternal(blockWrapper.block, blockWrapper.blockNumber, blockWrapper.blockHash)
-			if err != nil {
-				indexer.indexerState.setError(err)
-				indexLogger.Debugf(
-					"Error occured while indexing block number [%d]. Error: %s. Further blocks will not be indexed",
-					blockWrapper.blockNumber, err)
-
-			} else {
-				indexLogger.Debugf("Finished indexing block number [%d]", blockWrapper.blockNumber)
-			}
+// run indexes the pending blocks each time blocks are committed, and after a failure
+// once asyncIndexerRetryInterval has passed
+func (indexer *blockchainIndexerAsync) run() {
+	defer close(indexer.stopped)
+	for {
+		newBlocks := indexer.newBlocks
+		var retry <-chan time.Time
+		if err := indexer.indexPendingBlocks(); err != nil {
+			indexer.indexerState.setError(err)
+			indexLogger.Errorf("Error while indexing blocks, retrying in %s: %s", asyncIndexerRetryInterval, err)
+			newBlocks = nil
+			retry = time.After(asyncIndexerRetryInterval)
 		}
-	}()
-	return nil
+		select {
+		case <-indexer.stopChan:
+			return
+		case <-newBlocks:
+		case <-retry:
+		}
+	}
 }
 
-func (indexer *blockchainIndexerAsync) createIndexes(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
-	indexer.blockChan <- blockWrapper{block, blockNumber, blockHash, false}
+func (indexer *blockchainIndexerAsync) createIndexes(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch db.WriteBatch) error {
+	indexer.blockCommitted(blockNumber)
 	return nil
 }
 
-// createIndexes adds entries into db for creating indexes on various attributes
-func (indexer *blockchainIndexerAsync) createIndexesInternal(block *protos.Block, blockNumber uint64, blockHash []byte) error {
-	openchainDB := db.GetDBHandle()
-	writeBatch := gorocksdb.NewWriteBatch()
+// blockCommitted lets the indexing loop know that the blocks up to blockNumber are in
+// the db
+func (indexer *blockchainIndexerAsync) blockCommitted(blockNumber uint64) {
+	indexer.indexerState.setCommittedCount(blockNumber + 1)
+	select {
+	case indexer.newBlocks <- struct{}{}:
+	default:
+		// the loop has not picked up the previous signal yet
+	}
+}
+
+// indexPendingBlocks indexes the committed blocks past the high-water mark, batch by
+// batch, until it reaches a block that is not in the db yet, which happens while the
+// blocks are synchronized out of order
+func (indexer *blockchainIndexerAsync) indexPendingBlocks() error {
+	for {
+		select {
+		case <-indexer.stopChan:
+			return nil
+		default:
+		}
+		from := indexer.indexerState.getIndexedCount()
+		to := indexer.indexerState.getCommittedCount()
+		if from >= to {
+			return nil
+		}
+		if to-from > asyncIndexerBatchSize {
+			to = from + asyncIndexerBatchSize
+		}
+		indexed, err := indexer.indexBlocks(from, to)
+		if err != nil {
+			return err
+		}
+		if indexed < to {
+			indexLogger.Debugf("Block number [%d] is not committed yet, waiting for it", indexed)
+			return nil
+		}
+	}
+}
+
+// indexBlocks indexes the blocks [from, to) in a single batch along with the new
+// high-water mark, and returns the number of blocks indexed from the start of the chain.
+// If a block cannot be indexed, the blocks before it are still written
+func (indexer *blockchainIndexerAsync) indexBlocks(from uint64, to uint64) (uint64, error) {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return from, err
+	}
+	writeBatch := openchainDB.NewWriteBatch()
 	defer writeBatch.Destroy()
-	addIndexDataForPersistence(block, blockNumber, blockHash, writeBatch)
-	writeBatch.PutCF(openchainDB.IndexesCF, lastIndexedBlockKey, encodeBlockNumber(blockNumber))
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err := openchainDB.DB.Write(opt, writeBatch)
+	next := from
+	var indexingErr error
+	for ; next < to; next++ {
+		block, err := indexer.blockchain.getBlock(next)
+		if err == ErrBlockPruned {
+			// only the header of the block was kept, it has nothing to index
+			continue
+		}
+		if err != nil {
+			indexingErr = fmt.Errorf("Error while reading block number [%d]: %s", next, err)
+			break
+		}
+		if block == nil {
+			break
+		}
+		if indexingErr = indexer.addBlockIndexes(block, next, writeBatch); indexingErr != nil {
+			break
+		}
+	}
+	if next == from {
+		return from, indexingErr
+	}
+	writeBatch.Put(db.IndexesCF, lastIndexedBlockKey, encodeBlockNumber(next-1))
+	if err := writeBatch.Commit(db.AsyncWrite); err != nil {
+		return from, err
+	}
+	indexLogger.Debugf("Finished indexing block numbers [%d] to [%d]", from, next-1)
+	indexer.indexerState.blocksIndexed(next)
+	return next, indexingErr
+}
+
+// addBlockIndexes adds the indexes of the block to writeBatch
+func (indexer *blockchainIndexerAsync) addBlockIndexes(block *protos.Block, blockNumber uint64, writeBatch db.WriteBatch) error {
+	blockHash, err := block.GetHash()
 	if err != nil {
 		return err
 	}
-	indexer.indexerState.blockIndexed(blockNumber)
-	return nil
+	return addIndexDataForPersistence(block, blockNumber, blockHash, writeBatch)
 }
 
 func (indexer *blockchainIndexerAsync) fetchBlockNumberByBlockHash(blockHash []byte) (uint64, error) {
-	err := indexer.indexerState.checkError()
-	if err != nil {
-		indexLogger.Debug("Async indexer has a previous error. Returing the error")
+	blockNumber, err := fetchBlockNumberByBlockHashFromDB(blockHash)
+	if ledgerErr, ok := err.(*Error); !ok || ledgerErr.Type() != ErrorTypeBlockNotFound {
+		return blockNumber, err
+	}
+	// the block may be committed but not indexed yet
+	if err := indexer.waitForCommittedBlocks(); err != nil {
 		return 0, err
 	}
-	indexer.indexerState.waitForLastCommittedBlock()
 	return fetchBlockNumberByBlockHashFromDB(blockHash)
 }
 
 func (indexer *blockchainIndexerAsync) fetchTransactionIndexByID(txID string) (uint64, uint64, error) {
-	err := indexer.indexerState.checkError()
-	if err != nil {
+	blockNumber, txIndex, err := fetchTransactionIndexByIDFromDB(txID)
+	if err != ErrResourceNotFound {
+		return blockNumber, txIndex, err
+	}
+	// the transaction may be committed but not indexed yet
+	if err := indexer.waitForCommittedBlocks(); err != nil {
 		return 0, 0, err
 	}
-	indexer.indexerState.waitForLastCommittedBlock()
 	return fetchTransactionIndexByIDFromDB(txID)
 }
 
-func (indexer *blockchainIndexerAsync) indexPendingBlocks() error {
-	blockchain := indexer.blockchain
-	if blockchain.getSize() == 0 {
-		// chain is empty as yet
-		return nil
-	}
-
-	lastCommittedBlockNum := blockchain.getSize() - 1
-	lastIndexedBlockNum := indexer.indexerState.getLastIndexedBlockNumber()
-	zerothBlockIndexed := indexer.indexerState.isZerothBlockIndexed()
-
-	indexLogger.Debugf("lastCommittedBlockNum=[%d], lastIndexedBlockNum=[%d], zerothBlockIndexed=[%t]",
-		lastCommittedBlockNum, lastIndexedBlockNum, zerothBlockIndexed)
-
-	// block numbers use uint64 - so, 'lastIndexedBlockNum = 0' is ambiguous.
-	// So, explicitly checking whether zero-th block has been indexed
-	if !zerothBlockIndexed {
-		err := indexer.fetchBlockFromDBAndCreateIndexes(0)
-		if err != nil {
-			return err
-		}
-	}
+func (indexer *blockchainIndexerAsync) waitForIndexes(blockNumber uint64) error {
+	return indexer.indexerState.waitForBlock(blockNumber, asyncIndexerWaitTimeout)
+}
 
-	if lastCommittedBlockNum == lastIndexedBlockNum {
-		// all committed blocks are indexed
+// waitForCommittedBlocks waits for the blocks committed so far to be indexed
+func (indexer *blockchainIndexerAsync) waitForCommittedBlocks() error {
+	committedCount := indexer.indexerState.getCommittedCount()
+	if committedCount == 0 {
 		return nil
 	}
-
-	for ; lastIndexedBlockNum < lastCommittedBlockNum; lastIndexedBlockNum++ {
-		blockNumToIndex := lastIndexedBlockNum + 1
-		err := indexer.fetchBlockFromDBAndCreateIndexes(blockNumToIndex)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return indexer.waitForIndexes(committedCount - 1)
 }
 
-func (indexer *blockchainIndexerAsync) fetchBlockFromDBAndCreateIndexes(blockNumber uint64) error {
-	blockchain := indexer.blockchain
-	blockToIndex, errBlockFetch := blockchain.getBlock(blockNumber)
-	if errBlockFetch != nil {
-		return errBlockFetch
-	}
-
-	blockHash, errBlockHash := blockToIndex.GetHash()
-	if errBlockHash != nil {
-		return errBlockHash
-	}
-	indexer.createIndexesInternal(blockToIndex, blockNumber, blockHash)
-	return nil
+func (indexer *blockchainIndexerAsync) status() *IndexerStatus {
+	return indexer.indexerState.status()
 }
 
 func (indexer *blockchainIndexerAsync) stop() {
-	indexer.indexerState.waitForLastCommittedBlock()
-	indexer.blockChan <- blockWrapper{nil, 0, nil, true}
-	<-indexer.blockChan
-	close(indexer.blockChan)
+	close(indexer.stopChan)
+	<-indexer.stopped
 }
 
-// Code related to tracking the block number that has been indexed
-// and if there has been an error in indexing a block
-// Since, we index blocks asynchronously, there may be a case when
-// a client query arrives before a block has been indexed.
-//
-// Do we really need strict semantics such that an index query results
-// should include up to block number (or higher) that may have been committed
-// when user query arrives?
-// If a delay of a couple of blocks are allowed, we can get rid of this synchronization stuff
+// blockchainIndexerState tracks how far the async indexer is, and the failures of the
+// indexing, for the lookups that wait for blocks to be indexed and for the status
 type blockchainIndexerState struct {
-	indexer *blockchainIndexerAsync
-
-	zerothBlockIndexed bool
-	lastBlockIndexed   uint64
-	err                error
-	lock               *sync.RWMutex
-	newBlockIndexed    *sync.Cond
+	lock sync.Mutex
+	// indexedCount is the number of blocks indexed from the start of the chain
+	indexedCount uint64
+	// committedCount is the number of blocks known to be in the db
+	committedCount uint64
+	failures       uint64
+	err            error
+	// progress is closed, and replaced, each time indexedCount or err change
+	progress chan struct{}
 }
 
-func newBlockchainIndexerState(indexer *blockchainIndexerAsync) (*blockchainIndexerState, error) {
-	var lock sync.RWMutex
-	zerothBlockIndexed, lastIndexedBlockNum, err := fetchLastIndexedBlockNumFromDB()
+func newBlockchainIndexerState(committedCount uint64) (*blockchainIndexerState, error) {
+	indexedCount, err := fetchIndexedBlockCountFromDB()
 	if err != nil {
 		return nil, err
 	}
-	return &blockchainIndexerState{indexer, zerothBlockIndexed, lastIndexedBlockNum, nil, &lock, sync.NewCond(&lock)}, nil
+	return &blockchainIndexerState{indexedCount: indexedCount, committedCount: committedCount,
+		progress: make(chan struct{})}, nil
 }
 
-func (indexerState *blockchainIndexerState) blockIndexed(blockNumber uint64) {
-	indexerState.newBlockIndexed.L.Lock()
-	defer indexerState.newBlockIndexed.L.Unlock()
-	indexerState.lastBlockIndexed = blockNumber
-	indexerState.zerothBlockIndexed = true
-	indexerState.newBlockIndexed.Broadcast()
+// notifyProgress wakes up the lookups waiting for blocks to be indexed. The caller
+// holds the lock
+func (indexerState *blockchainIndexerState) notifyProgress() {
+	close(indexerState.progress)
+	indexerState.progress = make(chan struct{})
 }
 
-func (indexerState *blockchainIndexerState) getLastIndexedBlockNumber() uint64 {
-	indexerState.lock.RLock()
-	defer indexerState.lock.RUnlock()
-	return indexerState.lastBlockIndexed
+func (indexerState *blockchainIndexerState) blocksIndexed(indexedCount uint64) {
+	indexerState.lock.Lock()
+	defer indexerState.lock.Unlock()
+	indexerState.indexedCount = indexedCount
+	indexerState.err = nil
+	indexerState.notifyProgress()
 }
 
-func (indexerState *blockchainIndexerState) isZerothBlockIndexed() bool {
-	indexerState.lock.RLock()
-	defer indexerState.lock.RUnlock()
-	return indexerState.zerothBlockIndexed
+func (indexerState *blockchainIndexerState) setError(err error) {
+	indexerState.lock.Lock()
+	defer indexerState.lock.Unlock()
+	indexerState.err = err
+	indexerState.failures++
+	indexerState.notifyProgress()
 }
 
-func (indexerState *blockchainIndexerState) waitForLastCommittedBlock() error {
-	indexLogger.Debugf("waitForLastCommittedBlock() indexerState.err = %#v", indexerState.err)
-	chain := indexerState.indexer.blockchain
+func (indexerState *blockchainIndexerState) setCommittedCount(committedCount uint64) {
 	indexerState.lock.Lock()
 	defer indexerState.lock.Unlock()
-	if indexerState.err != nil {
-		return indexerState.err
-	}
-
-	if chain.getSize() == 0 {
-		return nil
+	if committedCount > indexerState.committedCount {
+		indexerState.committedCount = committedCount
 	}
-
-	lastBlockCommitted := chain.getSize() - 1
-
-	if !indexerState.zerothBlockIndexed {
-		indexLogger.Debugf(
-			"Waiting for zeroth block to be indexed. lastBlockCommitted=[%d] and lastBlockIndexed=[%d]",
-			lastBlockCommitted, indexerState.lastBlockIndexed)
-		indexerState.newBlockIndexed.Wait()
-	}
-
-	for indexerState.lastBlockIndexed < lastBlockCommitted && indexerState.err == nil {
-		indexLogger.Debugf(
-			"Waiting for index to catch up with block chain. lastBlockCommitted=[%d] and lastBlockIndexed=[%d]",
-			lastBlockCommitted, indexerState.lastBlockIndexed)
-		indexerState.newBlockIndexed.Wait()
-	}
-	return indexerState.err
 }
 
-func (indexerState *blockchainIndexerState) setError(err error) {
+func (indexerState *blockchainIndexerState) getIndexedCount() uint64 {
 	indexerState.lock.Lock()
 	defer indexerState.lock.Unlock()
-	indexerState.err = err
-	indexLogger.Debugf("setError() indexerState.err = %#v", indexerState.err)
-	indexerState.newBlockIndexed.Broadcast()
+	return indexerState.indexedCount
 }
 
-func (indexerState *blockchainIndexerState) hasError() bool {
-	indexerState.lock.RLock()
-	defer indexerState.lock.RUnlock()
-	return indexerState.err != nil
+func (indexerState *blockchainIndexerState) getCommittedCount() uint64 {
+	indexerState.lock.Lock()
+	defer indexerState.lock.Unlock()
+	return indexerState.committedCount
 }
 
-func (indexerState *blockchainIndexerState) getError() error {
-	indexerState.lock.RLock()
-	defer indexerState.lock.RUnlock()
-	return indexerState.err
+// waitForBlock waits up to timeout for the blocks up to blockNumber to be indexed. It
+// returns a not yet indexed error right away if the indexing is failing
+func (indexerState *blockchainIndexerState) waitForBlock(blockNumber uint64, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		indexerState.lock.Lock()
+		indexed, err, progress := blockNumber < indexerState.indexedCount, indexerState.err, indexerState.progress
+		indexerState.lock.Unlock()
+		if indexed {
+			return nil
+		}
+		if err != nil {
+			return newNotYetIndexedError(blockNumber, err)
+		}
+		indexLogger.Debugf("Waiting for block number [%d] to be indexed", blockNumber)
+		select {
+		case <-progress:
+		case <-timer.C:
+			return newNotYetIndexedError(blockNumber, nil)
+		}
+	}
 }
 
-func (indexerState *blockchainIndexerState) checkError() error {
-	indexerState.lock.RLock()
-	defer indexerState.lock.RUnlock()
+func (indexerState *blockchainIndexerState) status() *IndexerStatus {
+	indexerState.lock.Lock()
+	defer indexerState.lock.Unlock()
+	status := &IndexerStatus{
+		Asynchronous:    true,
+		CommittedBlocks: indexerState.committedCount,
+		IndexedBlocks:   indexerState.indexedCount,
+		Failures:        indexerState.failures,
+	}
+	if status.CommittedBlocks > status.IndexedBlocks {
+		status.Lag = status.CommittedBlocks - status.IndexedBlocks
+	}
 	if indexerState.err != nil {
-		return fmt.Errorf(
-			"An error had occured during indexing block number [%d]. So, index is out of sync. Detail of the error = %s",
-			indexerState.getLastIndexedBlockNumber()+1, indexerState.err)
+		status.LastError = indexerState.err.Error()
+	}
+	return status
+}
+
+func newNotYetIndexedError(blockNumber uint64, indexingErr error) *Error {
+	msg := fmt.Sprintf("ledger: block number [%d] is not indexed yet", blockNumber)
+	if indexingErr != nil {
+		msg = fmt.Sprintf("%s, indexing is failing: %s", msg, indexingErr)
 	}
-	return indexerState.err
+	return newLedgerError(ErrorTypeNotYetIndexed, msg)
 }
 
-func fetchLastIndexedBlockNumFromDB() (zerothBlockIndexed bool, lastIndexedBlockNum uint64, err error) {
-	lastIndexedBlockNumberBytes, err := db.GetDBHandle().GetFromIndexesCF(lastIndexedBlockKey)
+// fetchIndexedBlockCountFromDB returns the number of blocks indexed from the start of
+// the chain according to the high-water mark
+func fetchIndexedBlockCountFromDB() (uint64, error) {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return 0, err
+	}
+	lastIndexedBlockNumberBytes, err := openchainDB.GetFromIndexes(lastIndexedBlockKey)
 	if err != nil {
-		return
+		return 0, err
 	}
 	if lastIndexedBlockNumberBytes == nil {
-		return
+		return 0, nil
 	}
-	lastIndexedBlockNum = decodeBlockNumber(lastIndexedBlockNumberBytes)
-	zerothBlockIndexed = true
-	return
+	return decodeBlockNumber(lastIndexedBlockNumberBytes) + 1, nil
 }
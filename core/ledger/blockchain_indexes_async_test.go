@@ -17,14 +17,13 @@ limitations under the License.
 package ledger
 
 import (
-	"errors"
-	"fmt"
 	"testing"
 	"time"
 
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/hyperledger/fabric/protos"
-	"github.com/tecbot/gorocksdb"
 )
 
 func TestIndexesAsync_GetBlockByBlockNumber(t *testing.T) {
@@ -72,78 +71,185 @@ func TestIndexesAsync_GetTransactionByID(t *testing.T) {
 func TestIndexesAsync_IndexingErrorScenario(t *testing.T) {
 	defaultSetting := indexBlockDataSynchronously
 	indexBlockDataSynchronously = false
-	defer func() { indexBlockDataSynchronously = defaultSetting }()
+	defaultRetryInterval := asyncIndexerRetryInterval
+	asyncIndexerRetryInterval = 50 * time.Millisecond
+	defer func() {
+		indexBlockDataSynchronously = defaultSetting
+		asyncIndexerRetryInterval = defaultRetryInterval
+	}()
 
 	testDBWrapper.CleanDB(t)
 	testBlockchainWrapper := newTestBlockchainWrapper(t)
 	chain := testBlockchainWrapper.blockchain
-	asyncIndexer, _ := chain.indexer.(*blockchainIndexerAsync)
-
-	defer func() {
-		// first stop and then set the error to nil.
-		// Otherwise stop may hang (waiting for catching up the index with the committing block)
-		testBlockchainWrapper.blockchain.indexer.stop()
-		asyncIndexer.indexerState.setError(nil)
-	}()
-
+	chain.indexer.stop()
+	chain.indexer = &NoopIndexer{}
 	blocks, _, err := testBlockchainWrapper.populateBlockChainWithSampleData()
 	if err != nil {
-		t.Logf("Error populating block chain with sample data: %s", err)
-		t.Fail()
+		t.Fatalf("Error populating block chain with sample data: %s", err)
 	}
 
-	t.Log("Setting an error artificially so as to client query gets an error")
-	asyncIndexer.indexerState.setError(errors.New("Error created for testing"))
+	t.Log("Corrupting the second block in the db so as to make the indexing fail")
+	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	blockBytes, err := openchainDB.GetFromBlockchain(encodeBlockNumberDBKey(1))
+	testutil.AssertNoError(t, err, "Error while reading block")
+	putBlockBytes(t, 1, []byte("garbage"))
 
-	// populate more data after error
-	_, _, err = testBlockchainWrapper.populateBlockChainWithSampleData()
-	if err != nil {
-		t.Logf("Error populating block chain with sample data: %s", err)
-		t.Fail()
-	}
-	fmt.Println("Going to execute QUERY")
-	blockHash, _ := blocks[0].GetHash()
-	// index query should throw error
+	err = chain.startIndexer()
+	testutil.AssertNoError(t, err, "Error while starting indexer")
+	defer func() { chain.indexer.stop() }()
+	status := waitForIndexerStatus(t, chain, func(status *IndexerStatus) bool { return status.Failures > 0 })
+	testutil.AssertEquals(t, status.IndexedBlocks, uint64(1))
+	testutil.AssertEquals(t, status.Lag, uint64(len(blocks)-1))
+	testutil.AssertNotEquals(t, status.LastError, "")
 
+	// the blocks indexed before the failure are found, the others fail right away
+	blockHash, _ := blocks[0].GetHash()
+	testutil.AssertEquals(t, testBlockchainWrapper.getBlockByHash(blockHash), blocks[0])
+	blockHash, _ = blocks[2].GetHash()
 	_, err = chain.getBlockByHash(blockHash)
-	fmt.Println("executed QUERY")
-	if err == nil {
-		t.Fatal("Error expected during execution of client query")
-	}
+	assertLedgerErrorType(t, err, ErrorTypeNotYetIndexed)
+
+	t.Log("Restoring the block, the indexer recovers on its next attempt")
+	putBlockBytes(t, 1, blockBytes)
+	status = waitForIndexerStatus(t, chain, func(status *IndexerStatus) bool { return status.Lag == 0 })
+	testutil.AssertEquals(t, status.LastError, "")
+	testutil.AssertEquals(t, testBlockchainWrapper.getBlockByHash(blockHash), blocks[2])
 }
 
 func TestIndexesAsync_ClientWaitScenario(t *testing.T) {
 	defaultSetting := indexBlockDataSynchronously
 	indexBlockDataSynchronously = false
-	defer func() { indexBlockDataSynchronously = defaultSetting }()
+	defaultWaitTimeout := asyncIndexerWaitTimeout
+	asyncIndexerWaitTimeout = 10 * time.Second
+	defer func() {
+		indexBlockDataSynchronously = defaultSetting
+		asyncIndexerWaitTimeout = defaultWaitTimeout
+	}()
 
 	testDBWrapper.CleanDB(t)
 	testBlockchainWrapper := newTestBlockchainWrapper(t)
 	defer func() { testBlockchainWrapper.blockchain.indexer.stop() }()
 
 	chain := testBlockchainWrapper.blockchain
-	blocks, _, err := testBlockchainWrapper.populateBlockChainWithSampleData()
+	_, _, err := testBlockchainWrapper.populateBlockChainWithSampleData()
 	if err != nil {
-		t.Logf("Error populating block chain with sample data: %s", err)
-		t.Fail()
+		t.Fatalf("Error populating block chain with sample data: %s", err)
 	}
-	t.Log("Increasing size of blockchain by one artificially so as to make client wait")
-	chain.size = chain.size + 1
-	t.Log("Resetting size of blockchain to original and adding one block in a separate go routine so as to wake up the client")
+	t.Log("Telling the indexer artificially that one more block is committed so as to make client wait")
+	chain.indexer.(*blockchainIndexerAsync).blockCommitted(chain.getSize())
+	t.Log("Adding the block in a separate go routine so as to wake up the client")
 	go func() {
-		time.Sleep(2 * time.Second)
-		chain.size = chain.size - 1
-		blk, err := buildTestBlock(t)
-		if err != nil {
-			t.Logf("Error building test block: %s", err)
-			t.Fail()
-		}
+		time.Sleep(500 * time.Millisecond)
+		blk, _ := buildTestBlock(t)
 		testBlockchainWrapper.addNewBlock(blk, []byte("stateHash"))
 	}()
-	t.Log("Executing client query. The client would wait and will be woken up")
-	blockHash, _ := blocks[0].GetHash()
-	block := testBlockchainWrapper.getBlockByHash(blockHash)
-	testutil.AssertEquals(t, block, blocks[0])
+	t.Log("Executing client query. The client would wait for the block to be indexed")
+	start := time.Now()
+	_, err = chain.getBlockByHash([]byte("NonExistentHash"))
+	assertLedgerErrorType(t, err, ErrorTypeBlockNotFound)
+	if time.Since(start) < 500*time.Millisecond {
+		t.Fatal("Expected the client to wait for the block to be indexed")
+	}
+}
+
+func TestIndexesAsync_NotYetIndexed(t *testing.T) {
+	defaultSetting := indexBlockDataSynchronously
+	indexBlockDataSynchronously = false
+	defaultWaitTimeout := asyncIndexerWaitTimeout
+	asyncIndexerWaitTimeout = 100 * time.Millisecond
+	defer func() {
+		indexBlockDataSynchronously = defaultSetting
+		asyncIndexerWaitTimeout = defaultWaitTimeout
+	}()
+
+	testDBWrapper.CleanDB(t)
+	testBlockchainWrapper := newTestBlockchainWrapper(t)
+	chain := testBlockchainWrapper.blockchain
+	_, _, err := testBlockchainWrapper.populateBlockChainWithSampleData()
+	if err != nil {
+		t.Fatalf("Error populating block chain with sample data: %s", err)
+	}
+	status := waitForIndexerStatus(t, chain, func(status *IndexerStatus) bool { return status.Lag == 0 })
+	testutil.AssertEquals(t, status, &IndexerStatus{Asynchronous: true, CommittedBlocks: 3, IndexedBlocks: 3})
+
+	t.Log("Committing a block while the indexer is stopped")
+	chain.indexer.stop()
+	blk, _ := buildTestBlock(t)
+	blockNumber := testBlockchainWrapper.addNewBlock(blk, []byte("stateHash"))
+	blockHash, _ := testBlockchainWrapper.getBlock(blockNumber).GetHash()
+	testutil.AssertEquals(t, chain.indexer.status().Lag, uint64(1))
+	_, err = chain.getBlockByHash(blockHash)
+	assertLedgerErrorType(t, err, ErrorTypeNotYetIndexed)
+	_, err = chain.getTransactionByID(blk.Transactions[0].Txid)
+	assertLedgerErrorType(t, err, ErrorTypeNotYetIndexed)
+
+	t.Log("Restarting the indexer, which catches up in the background")
+	err = chain.startIndexer()
+	testutil.AssertNoError(t, err, "Error while starting indexer")
+	defer func() { chain.indexer.stop() }()
+	waitForIndexerStatus(t, chain, func(status *IndexerStatus) bool { return status.Lag == 0 })
+	testutil.AssertEquals(t, testBlockchainWrapper.getBlockByHash(blockHash), testBlockchainWrapper.getBlock(blockNumber))
+	testutil.AssertEquals(t, testBlockchainWrapper.getTransactionByID(blk.Transactions[0].Txid), blk.Transactions[0])
+}
+
+func TestIndexesAsync_CatchUpInBatches(t *testing.T) {
+	defaultSetting := indexBlockDataSynchronously
+	indexBlockDataSynchronously = false
+	defaultBatchSize := asyncIndexerBatchSize
+	asyncIndexerBatchSize = 2
+	defer func() {
+		indexBlockDataSynchronously = defaultSetting
+		asyncIndexerBatchSize = defaultBatchSize
+	}()
+
+	testDBWrapper.CleanDB(t)
+	testBlockchainWrapper := newTestBlockchainWrapper(t)
+	chain := testBlockchainWrapper.blockchain
+	chain.indexer.stop()
+	chain.indexer = &NoopIndexer{}
+	var blocks []*protos.Block
+	for i := 0; i < 5; i++ {
+		blk, _ := buildTestBlock(t)
+		testBlockchainWrapper.addNewBlock(blk, []byte("stateHash"))
+		blocks = append(blocks, blk)
+	}
+
+	err := chain.startIndexer()
+	testutil.AssertNoError(t, err, "Error while starting indexer")
+	defer func() { chain.indexer.stop() }()
+	waitForIndexerStatus(t, chain, func(status *IndexerStatus) bool { return status.Lag == 0 })
+	indexedBlockCount, err := fetchIndexedBlockCountFromDB()
+	testutil.AssertNoError(t, err, "Error while reading the high-water mark")
+	testutil.AssertEquals(t, indexedBlockCount, uint64(5))
+	for _, blk := range blocks {
+		blockHash, _ := blk.GetHash()
+		testutil.AssertEquals(t, testBlockchainWrapper.getBlockByHash(blockHash), blk)
+	}
+}
+
+func putBlockBytes(t *testing.T, blockNumber uint64, blockBytes []byte) {
+	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Put(db.BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	testutil.AssertNoError(t, writeBatch.Commit(db.SyncWrite), "Error while writing block")
+}
+
+func waitForIndexerStatus(t *testing.T, chain *blockchain, condition func(status *IndexerStatus) bool) *IndexerStatus {
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if status := chain.indexer.status(); condition(status) {
+			return status
+		}
+	}
+	t.Fatalf("Indexer status not reached, last status = %#v", chain.indexer.status())
+	return nil
+}
+
+func assertLedgerErrorType(t *testing.T, err error, errType ErrorType) {
+	ledgerErr, ok := err.(*Error)
+	if !(ok && ledgerErr.Type() == errType) {
+		t.Fatalf("Expected a ledger error of type %s, got %#v", errType, err)
+	}
 }
 
 type NoopIndexer struct {
@@ -155,7 +261,7 @@ func (noop *NoopIndexer) isSynchronous() bool {
 func (noop *NoopIndexer) start(blockchain *blockchain) error {
 	return nil
 }
-func (noop *NoopIndexer) createIndexes(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
+func (noop *NoopIndexer) createIndexes(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch db.WriteBatch) error {
 	return nil
 }
 func (noop *NoopIndexer) fetchBlockNumberByBlockHash(blockHash []byte) (uint64, error) {
@@ -164,6 +270,12 @@ func (noop *NoopIndexer) fetchBlockNumberByBlockHash(blockHash []byte) (uint64,
 func (noop *NoopIndexer) fetchTransactionIndexByID(txID string) (uint64, uint64, error) {
 	return 0, 0, nil
 }
+func (noop *NoopIndexer) waitForIndexes(blockNumber uint64) error {
+	return nil
+}
+func (noop *NoopIndexer) status() *IndexerStatus {
+	return &IndexerStatus{}
+}
 func (noop *NoopIndexer) stop() {
 }
 
@@ -190,7 +302,7 @@ func TestIndexesAsync_IndexPendingBlocks(t *testing.T) {
 	// the indexer should index the pending blocks
 	testDBWrapper.OpenDB(t)
 	testBlockchainWrapper = newTestBlockchainWrapper(t)
-	defer chain.indexer.stop()
+	defer func() { testBlockchainWrapper.blockchain.indexer.stop() }()
 
 	blockHash, _ := blocks[0].GetHash()
 	block := testBlockchainWrapper.getBlockByHash(blockHash)
@@ -0,0 +1,476 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+// A checkpoint archive holds what a new peer needs to start from the current block
+// without replaying the chain:
+//
+//   magic, version
+//   info record: block count, first full block, state hash
+//   header records: the blocks before the first full block, without their transactions
+//   block records: the last 'ledger.checkpoint.fullBlocks' blocks
+//   state records: the state at the last block, as state deltas
+//   end record
+//
+// Each record is its type and its length as uvarints, followed by its payload. The
+// headers are those of the blocks right before the first full block, back to the first
+// block without a header (a pruned block without a checkpoint), so that each of them
+// is linked to the next block by its hash. The archive is verified on import: the
+// headers and blocks must form a chain, the header of a block with a transactions
+// Merkle root must hash to its block hash, and the hash of the imported state must be
+// the state hash of the last block. The header of a block committed before the
+// transactions Merkle root was introduced cannot be checked against its block hash,
+// only its previous block hash is part of the chain check, so its other fields are
+// taken on trust from the archive. The blocks before the first full block are
+// installed as pruned blocks with a header checkpoint, see pruning.go. The indexes of
+// the full blocks are rebuilt, the key history is not
+
+var checkpointMagic = []byte("fabric-ledger-checkpoint")
+
+const checkpointVersion = 1
+
+const (
+	checkpointRecordInfo = iota + 1
+	checkpointRecordHeader
+	checkpointRecordBlock
+	checkpointRecordState
+	checkpointRecordEnd
+)
+
+// checkpointStateChunkSize is the number of state keys in each state record
+const checkpointStateChunkSize = 1000
+
+// maxCheckpointRecordSize bounds the memory allocated for a record of a corrupt archive
+const maxCheckpointRecordSize = 1 << 30
+
+// checkpointInfo is the content of the info record
+type checkpointInfo struct {
+	blockCount     uint64
+	firstFullBlock uint64
+	stateHash      []byte
+}
+
+// ExportCheckpoint writes a checkpoint archive of the ledger at its current block to w.
+// The archive holds the state, the headers of all blocks and the last
+// 'ledger.checkpoint.fullBlocks' blocks, see ImportCheckpoint. The blocks and the state
+// are read from the same snapshot of the datastore, so that transactions can be committed
+// while the archive is written. Headers are only available for the pruned blocks that
+// have a checkpoint, and only those after the last pruned block without one are written
+func (ledger *Ledger) ExportCheckpoint(w io.Writer) error {
+	fullBlocks := viper.GetInt("ledger.checkpoint.fullBlocks")
+	if fullBlocks < 1 {
+		return fmt.Errorf("ledger.checkpoint.fullBlocks must be at least 1, found [%d]", fullBlocks)
+	}
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return err
+	}
+	dbSnapshot := openchainDB.GetSnapshot()
+	blockCount, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
+	if err != nil {
+		dbSnapshot.Release()
+		return err
+	}
+	if blockCount == 0 {
+		dbSnapshot.Release()
+		return fmt.Errorf("Blockchain has no blocks, cannot export a checkpoint")
+	}
+	stateSnapshot, err := ledger.state.GetSnapshot(blockCount-1, dbSnapshot)
+	if err != nil {
+		dbSnapshot.Release()
+		return err
+	}
+	defer stateSnapshot.Release()
+
+	getFromSnapshot := func(key []byte) ([]byte, error) {
+		return openchainDB.GetFromBlockchainSnapshot(dbSnapshot, key)
+	}
+	prunedBytes, err := getFromSnapshot(prunedBlockCountKey)
+	if err != nil {
+		return err
+	}
+	prunedCount := uint64(0)
+	if prunedBytes != nil {
+		prunedCount = decodeToUint64(prunedBytes)
+	}
+	info := &checkpointInfo{blockCount: blockCount, firstFullBlock: prunedCount}
+	if blockCount-prunedCount > uint64(fullBlocks) {
+		info.firstFullBlock = blockCount - uint64(fullBlocks)
+	}
+	lastBlock, err := fetchBlockFromSnapshot(getFromSnapshot, blockCount-1)
+	if err != nil {
+		return err
+	}
+	info.stateHash = lastBlock.StateHash
+
+	writer := bufio.NewWriter(w)
+	writer.Write(checkpointMagic)
+	writeUvarint(writer, checkpointVersion)
+	writeCheckpointRecord(writer, checkpointRecordInfo, encodeCheckpointInfo(info))
+
+	var headerRecords [][]byte
+	for blockNumber := uint64(0); blockNumber < info.firstFullBlock; blockNumber++ {
+		var headerBytes []byte
+		if blockNumber < prunedCount {
+			headerBytes, err = getFromSnapshot(encodeCheckpointKey(blockNumber))
+		} else {
+			var block *protos.Block
+			block, err = fetchBlockFromSnapshot(getFromSnapshot, blockNumber)
+			if err == nil {
+				headerBytes, err = encodeBlockHeader(block)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if headerBytes == nil {
+			// the headers before a gap cannot be linked to the chain
+			headerRecords = nil
+			continue
+		}
+		headerRecords = append(headerRecords, append(proto.EncodeVarint(blockNumber), headerBytes...))
+	}
+	for _, headerRecord := range headerRecords {
+		writeCheckpointRecord(writer, checkpointRecordHeader, headerRecord)
+	}
+
+	for blockNumber := info.firstFullBlock; blockNumber < blockCount; blockNumber++ {
+		blockBytes, err := getFromSnapshot(encodeBlockNumberDBKey(blockNumber))
+		if err != nil {
+			return err
+		}
+		if blockBytes == nil {
+			return fmt.Errorf("Block [%d] is missing, cannot export a checkpoint", blockNumber)
+		}
+		writeCheckpointRecord(writer, checkpointRecordBlock, append(proto.EncodeVarint(blockNumber), blockBytes...))
+	}
+
+	delta := statemgmt.NewStateDelta()
+	keys := 0
+	for stateSnapshot.Next() {
+		k, v := stateSnapshot.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(k)
+		delta.Set(chaincodeID, key, v, nil)
+		keys++
+		if keys%checkpointStateChunkSize == 0 {
+			writeCheckpointRecord(writer, checkpointRecordState, delta.Marshal())
+			delta = statemgmt.NewStateDelta()
+		}
+	}
+	if !delta.IsEmpty() {
+		writeCheckpointRecord(writer, checkpointRecordState, delta.Marshal())
+	}
+	writeCheckpointRecord(writer, checkpointRecordEnd, nil)
+	ledgerLogger.Infof("Exported a checkpoint at block [%d] with [%d] full blocks and [%d] state keys",
+		blockCount-1, blockCount-info.firstFullBlock, keys)
+	return writer.Flush()
+}
+
+// ImportCheckpoint installs a checkpoint archive written by ExportCheckpoint into an
+// empty ledger. Nothing is written until the headers and blocks of the archive have
+// been found to form a chain. The state is then installed, and removed again unless
+// its hash is the state hash of the last block of the archive. The headers, the blocks
+// and the size of the blockchain are written last, in one write batch, so that the
+// ledger stays empty, and a failed import can be retried, until the import succeeds
+func (ledger *Ledger) ImportCheckpoint(r io.Reader) error {
+	if ledger.blockchain.getSize() != 0 {
+		return fmt.Errorf("The ledger has [%d] blocks, a checkpoint can only be imported into an empty ledger", ledger.blockchain.getSize())
+	}
+	reader := bufio.NewReader(r)
+	magic := make([]byte, len(checkpointMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || !bytes.Equal(magic, checkpointMagic) {
+		return fmt.Errorf("Not a ledger checkpoint archive")
+	}
+	version, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return err
+	}
+	if version != checkpointVersion {
+		return fmt.Errorf("Unsupported checkpoint archive version [%d]", version)
+	}
+
+	recordType, payload, err := readCheckpointRecord(reader)
+	if err != nil {
+		return err
+	}
+	if recordType != checkpointRecordInfo {
+		return fmt.Errorf("Checkpoint archive does not start with an info record")
+	}
+	info, err := decodeCheckpointInfo(payload)
+	if err != nil {
+		return err
+	}
+
+	// headers and blocks
+	var headers []*BlockCheckpoint
+	var blocks []*protos.Block
+	var previousNumber uint64
+	var previousHash []byte
+	for {
+		recordType, payload, err = readCheckpointRecord(reader)
+		if err != nil {
+			return err
+		}
+		if recordType != checkpointRecordHeader && recordType != checkpointRecordBlock {
+			break
+		}
+		blockNumber, n := proto.DecodeVarint(payload)
+		if n == 0 {
+			return fmt.Errorf("Invalid block number in checkpoint archive")
+		}
+		var blockHash, previousBlockHash []byte
+		if recordType == checkpointRecordHeader {
+			if blockNumber >= info.firstFullBlock || len(blocks) > 0 || (len(headers) > 0 && blockNumber <= previousNumber) {
+				return fmt.Errorf("Unexpected header of block [%d] in checkpoint archive", blockNumber)
+			}
+			header, err := decodeCheckpoint(payload[n:])
+			if err != nil {
+				return err
+			}
+			header.BlockNumber = blockNumber
+			if header.Header.TransactionsMerkleRoot != nil {
+				headerHash, err := header.Header.GetHeaderHash()
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(headerHash, header.BlockHash) {
+					return fmt.Errorf("Header of block [%d] of checkpoint archive does not match its block hash", blockNumber)
+				}
+			}
+			headers = append(headers, header)
+			blockHash, previousBlockHash = header.BlockHash, header.Header.PreviousBlockHash
+		} else {
+			if blockNumber != info.firstFullBlock+uint64(len(blocks)) {
+				return fmt.Errorf("Unexpected block [%d] in checkpoint archive", blockNumber)
+			}
+			block, err := protos.UnmarshallBlock(payload[n:])
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, block)
+			if blockHash, err = block.GetHash(); err != nil {
+				return err
+			}
+			previousBlockHash = block.PreviousBlockHash
+		}
+		if previousHash != nil && blockNumber != previousNumber+1 {
+			return fmt.Errorf("Checkpoint archive skips from block [%d] to block [%d]", previousNumber, blockNumber)
+		}
+		if previousHash != nil && !bytes.Equal(previousBlockHash, previousHash) {
+			return fmt.Errorf("Block [%d] of checkpoint archive does not follow block [%d]", blockNumber, previousNumber)
+		}
+		previousNumber, previousHash = blockNumber, blockHash
+	}
+	if uint64(len(blocks)) != info.blockCount-info.firstFullBlock {
+		return fmt.Errorf("Checkpoint archive has [%d] blocks, expected [%d]", len(blocks), info.blockCount-info.firstFullBlock)
+	}
+	if !bytes.Equal(blocks[len(blocks)-1].StateHash, info.stateHash) {
+		return fmt.Errorf("State hash of checkpoint archive does not match its last block")
+	}
+
+	// state
+	err = ledger.DeleteALLStateKeysAndValues()
+	if err != nil {
+		return err
+	}
+	for ; recordType == checkpointRecordState; recordType, payload, err = readCheckpointRecord(reader) {
+		delta := &statemgmt.StateDelta{}
+		if err = delta.Unmarshal(payload); err != nil {
+			return fmt.Errorf("Invalid state in checkpoint archive: %s", err)
+		}
+		if err = ledger.ApplyStateDelta("checkpoint", delta); err != nil {
+			return err
+		}
+		if err = ledger.CommitStateDelta("checkpoint"); err != nil {
+			return err
+		}
+	}
+	if err == nil && recordType != checkpointRecordEnd {
+		err = fmt.Errorf("Unexpected record type [%d] in checkpoint archive", recordType)
+	}
+	if err == nil {
+		var stateHash []byte
+		stateHash, err = ledger.GetTempStateHash()
+		if err == nil && !bytes.Equal(stateHash, info.stateHash) {
+			err = fmt.Errorf("State hash mismatch: checkpoint archive has [%x], imported state has [%x]", info.stateHash, stateHash)
+		}
+	}
+	if err != nil {
+		ledger.DeleteALLStateKeysAndValues()
+		return err
+	}
+
+	err = ledger.blockchain.persistCheckpointBlocks(headers, blocks, info.firstFullBlock)
+	if err != nil {
+		ledger.DeleteALLStateKeysAndValues()
+		return err
+	}
+	ledgerLogger.Infof("Imported a checkpoint at block [%d] with [%d] full blocks", info.blockCount-1, len(blocks))
+	return nil
+}
+
+// persistCheckpointBlocks writes, in one write batch, the headers of the blocks before
+// firstFullBlock as pruned blocks, the blocks from firstFullBlock on and the size of
+// the blockchain
+func (blockchain *blockchain) persistCheckpointBlocks(headers []*BlockCheckpoint, blocks []*protos.Block, firstFullBlock uint64) error {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return err
+	}
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+
+	// headers of the blocks before the first full block, recorded as pruned blocks
+	var pruned *prunedBlocks
+	if firstFullBlock > 0 {
+		for _, header := range headers {
+			headerBytes, err := encodeCheckpoint(header.Header, header.BlockHash)
+			if err != nil {
+				return err
+			}
+			writeBatch.Put(db.BlockchainCF, encodeCheckpointKey(header.BlockNumber), headerBytes)
+		}
+		pruned = &prunedBlocks{firstFullBlock, blocks[0].PreviousBlockHash}
+		writeBatch.Put(db.BlockchainCF, prunedBlockCountKey, encodePrunedBlocks(pruned))
+	}
+
+	blockHashes := make([][]byte, len(blocks))
+	for i, block := range blocks {
+		blockNumber := firstFullBlock + uint64(i)
+		blockBytes, err := block.Bytes()
+		if err != nil {
+			return err
+		}
+		if blockHashes[i], err = block.GetHash(); err != nil {
+			return err
+		}
+		writeBatch.Put(db.BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
+		if blockchain.indexer.isSynchronous() {
+			blockchain.indexer.createIndexes(block, blockNumber, blockHashes[i], writeBatch)
+		}
+	}
+	size := firstFullBlock + uint64(len(blocks))
+	writeBatch.Put(db.BlockchainCF, blockCountKey, encodeUint64(size))
+	if err = writeBatch.Commit(db.SyncWrite); err != nil {
+		return err
+	}
+
+	if pruned != nil {
+		blockchain.pruned = pruned
+	}
+	blockchain.size = size
+	blockchain.previousBlockHash = blockHashes[len(blocks)-1]
+	if !blockchain.indexer.isSynchronous() {
+		for i, block := range blocks {
+			blockchain.indexer.createIndexes(block, firstFullBlock+uint64(i), blockHashes[i], nil)
+		}
+	}
+	return nil
+}
+
+func fetchBlockFromSnapshot(getFromSnapshot func(key []byte) ([]byte, error), blockNumber uint64) (*protos.Block, error) {
+	blockBytes, err := getFromSnapshot(encodeBlockNumberDBKey(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	if blockBytes == nil {
+		return nil, fmt.Errorf("Block [%d] is missing, cannot export a checkpoint", blockNumber)
+	}
+	return protos.UnmarshallBlock(blockBytes)
+}
+
+func encodeBlockHeader(block *protos.Block) ([]byte, error) {
+	blockHash, err := block.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	return encodeCheckpoint(block, blockHash)
+}
+
+func encodeCheckpointInfo(info *checkpointInfo) []byte {
+	b := proto.NewBuffer([]byte{})
+	b.EncodeVarint(info.blockCount)
+	b.EncodeVarint(info.firstFullBlock)
+	b.EncodeRawBytes(info.stateHash)
+	return b.Bytes()
+}
+
+func decodeCheckpointInfo(infoBytes []byte) (*checkpointInfo, error) {
+	b := proto.NewBuffer(infoBytes)
+	info := &checkpointInfo{}
+	var err error
+	if info.blockCount, err = b.DecodeVarint(); err != nil {
+		return nil, err
+	}
+	if info.firstFullBlock, err = b.DecodeVarint(); err != nil {
+		return nil, err
+	}
+	if info.stateHash, err = b.DecodeRawBytes(false); err != nil {
+		return nil, err
+	}
+	if info.firstFullBlock >= info.blockCount {
+		return nil, fmt.Errorf("Invalid checkpoint archive info: first full block [%d], block count [%d]", info.firstFullBlock, info.blockCount)
+	}
+	return info, nil
+}
+
+// writeCheckpointRecord writes a record to w. The errors are reported by the Flush
+// of the bufio.Writer
+func writeCheckpointRecord(w *bufio.Writer, recordType uint64, payload []byte) {
+	writeUvarint(w, recordType)
+	writeUvarint(w, uint64(len(payload)))
+	w.Write(payload)
+}
+
+func writeUvarint(w *bufio.Writer, x uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutUvarint(buf, x)])
+}
+
+func readCheckpointRecord(r *bufio.Reader) (uint64, []byte, error) {
+	recordType, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Truncated checkpoint archive: %s", err)
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Truncated checkpoint archive: %s", err)
+	}
+	if size > maxCheckpointRecordSize {
+		return 0, nil, fmt.Errorf("Invalid record size [%d] in checkpoint archive", size)
+	}
+	payload := make([]byte, size)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return 0, nil, fmt.Errorf("Truncated checkpoint archive: %s", err)
+	}
+	return recordType, payload, nil
+}
@@ -0,0 +1,215 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func buildCheckpointTestLedger(t *testing.T) (*ledgerTestWrapper, []*protos.Block) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	var blocks []*protos.Block
+	for i := 0; i < 5; i++ {
+		ledger.BeginTxBatch(i)
+		tx, txID := buildTestTx(t)
+		ledger.TxBegin(txID)
+		ledger.SetState("chaincode1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("checkpoint-value-%d", i)))
+		ledger.SetState("chaincode2", "key", []byte{byte(i)})
+		ledger.TxFinished(txID, true)
+		ledger.CommitTxBatch(i, []*protos.Transaction{tx}, nil, []byte("proof"))
+		blocks = append(blocks, ledgerTestWrapper.GetBlockByNumber(uint64(i)))
+	}
+	return ledgerTestWrapper, blocks
+}
+
+// rewriteCheckpointArchive returns a copy of the archive with the records for which keep
+// returns false left out
+func rewriteCheckpointArchive(t *testing.T, archive []byte, keep func(recordType uint64, payload []byte) bool) []byte {
+	reader := bufio.NewReader(bytes.NewReader(archive))
+	header := make([]byte, len(checkpointMagic)+1)
+	_, err := io.ReadFull(reader, header)
+	testutil.AssertNoError(t, err, "Error while reading the archive header")
+	rewritten := &bytes.Buffer{}
+	writer := bufio.NewWriter(rewritten)
+	writer.Write(header)
+	for {
+		recordType, payload, err := readCheckpointRecord(reader)
+		testutil.AssertNoError(t, err, "Error while reading an archive record")
+		if keep(recordType, payload) {
+			writeCheckpointRecord(writer, recordType, payload)
+		}
+		if recordType == checkpointRecordEnd {
+			break
+		}
+	}
+	writer.Flush()
+	return rewritten.Bytes()
+}
+
+func TestCheckpointExportImport(t *testing.T) {
+	defer viper.Set("ledger.checkpoint.fullBlocks", 0)
+	viper.Set("ledger.checkpoint.fullBlocks", 2)
+	ledgerTestWrapper, blocks := buildCheckpointTestLedger(t)
+	stateHash, _ := ledgerTestWrapper.ledger.GetTempStateHash()
+	archive := &bytes.Buffer{}
+	err := ledgerTestWrapper.ledger.ExportCheckpoint(archive)
+	testutil.AssertNoError(t, err, "Error while exporting a checkpoint")
+
+	importedLedgerWrapper := createFreshDBAndTestLedgerWrapper(t)
+	importedLedger := importedLedgerWrapper.ledger
+	err = importedLedger.ImportCheckpoint(bytes.NewReader(archive.Bytes()))
+	testutil.AssertNoError(t, err, "Error while importing a checkpoint")
+
+	testutil.AssertEquals(t, importedLedger.GetBlockchainSize(), uint64(5))
+	testutil.AssertEquals(t, importedLedger.GetPrunedBlockCount(), uint64(3))
+	importedStateHash, _ := importedLedger.GetTempStateHash()
+	testutil.AssertEquals(t, importedStateHash, stateHash)
+	for i := 0; i < 5; i++ {
+		testutil.AssertEquals(t, importedLedgerWrapper.GetState("chaincode1", fmt.Sprintf("key%d", i), true),
+			[]byte(fmt.Sprintf("checkpoint-value-%d", i)))
+	}
+	testutil.AssertEquals(t, importedLedgerWrapper.GetState("chaincode2", "key", true), []byte{4})
+
+	for i, block := range blocks {
+		blockHash, _ := block.GetHash()
+		if i < 3 {
+			_, err = importedLedger.GetBlockByNumber(uint64(i))
+			testutil.AssertSame(t, err, ErrBlockPruned)
+			checkpoint, err := importedLedger.GetBlockCheckpoint(uint64(i))
+			testutil.AssertNoError(t, err, "Error while getting the header of a block before the full blocks")
+			testutil.AssertEquals(t, checkpoint.BlockHash, blockHash)
+			headerHash, err := checkpoint.Header.GetHeaderHash()
+			testutil.AssertNoError(t, err, "Error while hashing the header of a block before the full blocks")
+			testutil.AssertEquals(t, headerHash, blockHash)
+			continue
+		}
+		importedBlock := importedLedgerWrapper.GetBlockByNumber(uint64(i))
+		importedBlockHash, _ := importedBlock.GetHash()
+		testutil.AssertEquals(t, importedBlockHash, blockHash)
+		tx, err := importedLedger.GetTransactionByID(block.Transactions[0].Txid)
+		testutil.AssertNoError(t, err, "Error while getting a transaction of a full block")
+		testutil.AssertEquals(t, tx.Txid, block.Transactions[0].Txid)
+	}
+	lowBlock, err := importedLedger.VerifyChain(4, 3)
+	testutil.AssertNoError(t, err, "Error while verifying the imported chain")
+	testutil.AssertEquals(t, lowBlock, uint64(3))
+
+	// a ledger with blocks cannot import a checkpoint
+	err = importedLedger.ImportCheckpoint(bytes.NewReader(archive.Bytes()))
+	testutil.AssertError(t, err, "Import into a non-empty ledger should fail")
+}
+
+func TestCheckpointImportInvalid(t *testing.T) {
+	defer viper.Set("ledger.checkpoint.fullBlocks", 0)
+	viper.Set("ledger.checkpoint.fullBlocks", 2)
+	ledgerTestWrapper, _ := buildCheckpointTestLedger(t)
+	archive := &bytes.Buffer{}
+	err := ledgerTestWrapper.ledger.ExportCheckpoint(archive)
+	testutil.AssertNoError(t, err, "Error while exporting a checkpoint")
+
+	// truncated
+	ledger := createFreshDBAndTestLedgerWrapper(t).ledger
+	err = ledger.ImportCheckpoint(bytes.NewReader(archive.Bytes()[:archive.Len()-1]))
+	testutil.AssertError(t, err, "Import of a truncated archive should fail")
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(0))
+
+	// tampered header, the consensus metadata of block 0 is in the first header record
+	tampered := bytes.Replace(archive.Bytes(), []byte("proof"), []byte("forge"), 1)
+	ledger = createFreshDBAndTestLedgerWrapper(t).ledger
+	err = ledger.ImportCheckpoint(bytes.NewReader(tampered))
+	testutil.AssertError(t, err, "Import of an archive with a tampered header should fail")
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(0))
+
+	// missing header, block 1 is in the second header record
+	headerRecords := 0
+	tampered = rewriteCheckpointArchive(t, archive.Bytes(), func(recordType uint64, payload []byte) bool {
+		if recordType != checkpointRecordHeader {
+			return true
+		}
+		headerRecords++
+		return headerRecords != 2
+	})
+	ledger = createFreshDBAndTestLedgerWrapper(t).ledger
+	err = ledger.ImportCheckpoint(bytes.NewReader(tampered))
+	testutil.AssertError(t, err, "Import of an archive with a gap between its headers should fail")
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(0))
+
+	// tampered state
+	tampered = bytes.Replace(archive.Bytes(), []byte("checkpoint-value-2"), []byte("checkpoint-value-X"), 1)
+	ledgerTestWrapper = createFreshDBAndTestLedgerWrapper(t)
+	err = ledgerTestWrapper.ledger.ImportCheckpoint(bytes.NewReader(tampered))
+	testutil.AssertError(t, err, "Import of an archive with a tampered state should fail")
+	testutil.AssertEquals(t, ledgerTestWrapper.ledger.GetBlockchainSize(), uint64(0))
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode1", "key0", true))
+}
+
+func TestCheckpointExportPruned(t *testing.T) {
+	defer setPruningConfig(false, 0, 0)
+	setPruningConfig(true, 3, 2)
+	defer viper.Set("ledger.checkpoint.fullBlocks", 0)
+	viper.Set("ledger.checkpoint.fullBlocks", 2)
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	for i := 0; i < 8; i++ {
+		ledger.BeginTxBatch(i)
+		tx, txID := buildTestTx(t)
+		ledger.TxBegin(txID)
+		ledger.SetState("chaincode1", "key1", []byte{byte(i)})
+		ledger.TxFinished(txID, true)
+		ledger.CommitTxBatch(i, []*protos.Transaction{tx}, nil, []byte("proof"))
+	}
+	testutil.AssertEquals(t, ledger.GetPrunedBlockCount(), uint64(5))
+	archive := &bytes.Buffer{}
+	err := ledger.ExportCheckpoint(archive)
+	testutil.AssertNoError(t, err, "Error while exporting a checkpoint of a pruned ledger")
+
+	// checkpoints are kept for blocks 0, 2 and 4, block 5 is not pruned. Only the
+	// headers after the last pruned block without a checkpoint are exported
+	importedLedger := createFreshDBAndTestLedgerWrapper(t).ledger
+	err = importedLedger.ImportCheckpoint(bytes.NewReader(archive.Bytes()))
+	testutil.AssertNoError(t, err, "Error while importing a checkpoint of a pruned ledger")
+	testutil.AssertEquals(t, importedLedger.GetBlockchainSize(), uint64(8))
+	testutil.AssertEquals(t, importedLedger.GetPrunedBlockCount(), uint64(6))
+	for i := uint64(0); i < 6; i++ {
+		checkpoint, err := importedLedger.GetBlockCheckpoint(i)
+		if i < 4 {
+			testutil.AssertSame(t, err, ErrResourceNotFound)
+			continue
+		}
+		testutil.AssertNoError(t, err, "Error while getting the header of a block before the full blocks")
+		sourceCheckpoint, _ := ledger.GetBlockCheckpoint(i)
+		if sourceCheckpoint == nil {
+			block, _ := ledger.GetBlockByNumber(i)
+			blockHash, _ := block.GetHash()
+			testutil.AssertEquals(t, checkpoint.BlockHash, blockHash)
+		} else {
+			testutil.AssertEquals(t, checkpoint.BlockHash, sourceCheckpoint.BlockHash)
+		}
+	}
+	lowBlock, err := importedLedger.VerifyChain(7, 0)
+	testutil.AssertNoError(t, err, "Error while verifying the imported chain")
+	testutil.AssertEquals(t, lowBlock, uint64(0))
+}
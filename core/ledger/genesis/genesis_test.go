@@ -28,6 +28,7 @@ import (
 	"google.golang.org/grpc/grpclog"
 
 	"github.com/hyperledger/fabric/core/chaincode"
+	_ "github.com/hyperledger/fabric/core/db/memory"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
@@ -0,0 +1,158 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"encoding/binary"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+// The key history index keeps every value written to a key, in indexesCF. The db key
+// of an entry is
+//   prefixHistoryKey, len(chaincodeID), chaincodeID, len(key), key, blockNumber, txIndex
+// with the lengths as varints and the numbers as 8 byte big-endian, so that the entries
+// of a key are next to each other in commit order. The lengths keep the entries of a key
+// apart from those of the keys it is a prefix of
+var prefixHistoryKey = byte(4)
+
+// historyEnabledFromConfig reads 'ledger.history.enabled'. The index only covers the
+// blocks committed while it is enabled
+func historyEnabledFromConfig() bool {
+	return viper.GetBool("ledger.history.enabled")
+}
+
+// KeyModification is one value written to a key by a committed transaction
+type KeyModification struct {
+	TxID        string
+	BlockNumber uint64
+	// TxIndex is the position of the transaction in its block
+	TxIndex  uint64
+	Value    []byte
+	IsDelete bool
+}
+
+// HistoryIterator iterates over the modifications of a key, oldest first
+type HistoryIterator struct {
+	dbItr   db.Iterator
+	prefix  []byte
+	started bool
+}
+
+// Next moves to the next modification and returns false when there is none left
+func (itr *HistoryIterator) Next() bool {
+	if !itr.started {
+		itr.started = true
+		itr.dbItr.Seek(itr.prefix)
+	} else {
+		itr.dbItr.Next()
+	}
+	return itr.dbItr.ValidForPrefix(itr.prefix)
+}
+
+// GetKeyModification returns the modification at the current position
+func (itr *HistoryIterator) GetKeyModification() (*KeyModification, error) {
+	blockNumber, txIndex := decodeHistoryKeySuffix(itr.dbItr.KeyData()[len(itr.prefix):])
+	modification := &KeyModification{BlockNumber: blockNumber, TxIndex: txIndex}
+	err := decodeHistoryValue(itr.dbItr.ValueData(), modification)
+	if err != nil {
+		return nil, err
+	}
+	return modification, nil
+}
+
+// Close releases the resources held by the iterator
+func (itr *HistoryIterator) Close() {
+	itr.dbItr.Close()
+}
+
+func newHistoryIterator(openchainDB db.OpenchainDB, chaincodeID string, key string) *HistoryIterator {
+	return &HistoryIterator{dbItr: openchainDB.GetIndexesIterator(), prefix: encodeHistoryKeyPrefix(chaincodeID, key)}
+}
+
+// addHistoryForPersistence adds an entry to writeBatch for every key changed by the
+// transactions of the block. txStateDeltas holds the changes of each successful
+// transaction by txID
+func addHistoryForPersistence(blockNumber uint64, transactions []*protos.Transaction,
+	txStateDeltas map[string]*statemgmt.StateDelta, writeBatch db.WriteBatch) {
+	for txIndex, tx := range transactions {
+		txStateDelta, ok := txStateDeltas[tx.Txid]
+		if !ok {
+			continue
+		}
+		for _, chaincodeID := range txStateDelta.GetUpdatedChaincodeIds(false) {
+			for key, updatedValue := range txStateDelta.GetUpdates(chaincodeID) {
+				historyKey := encodeHistoryKey(chaincodeID, key, blockNumber, uint64(txIndex))
+				writeBatch.Put(db.IndexesCF, historyKey, encodeHistoryValue(tx.Txid, updatedValue))
+			}
+		}
+	}
+}
+
+func encodeHistoryKeyPrefix(chaincodeID string, key string) []byte {
+	b := proto.NewBuffer([]byte{prefixHistoryKey})
+	b.EncodeStringBytes(chaincodeID)
+	b.EncodeStringBytes(key)
+	return b.Bytes()
+}
+
+func encodeHistoryKey(chaincodeID string, key string, blockNumber uint64, txIndex uint64) []byte {
+	historyKey := encodeHistoryKeyPrefix(chaincodeID, key)
+	suffix := make([]byte, 16)
+	binary.BigEndian.PutUint64(suffix, blockNumber)
+	binary.BigEndian.PutUint64(suffix[8:], txIndex)
+	return append(historyKey, suffix...)
+}
+
+func decodeHistoryKeySuffix(suffix []byte) (blockNumber uint64, txIndex uint64) {
+	return binary.BigEndian.Uint64(suffix), binary.BigEndian.Uint64(suffix[8:])
+}
+
+func encodeHistoryValue(txID string, updatedValue *statemgmt.UpdatedValue) []byte {
+	b := proto.NewBuffer([]byte{})
+	b.EncodeStringBytes(txID)
+	if updatedValue.IsDeleted() {
+		b.EncodeVarint(1)
+	} else {
+		b.EncodeVarint(0)
+		b.EncodeRawBytes(updatedValue.GetValue())
+	}
+	return b.Bytes()
+}
+
+func decodeHistoryValue(bytes []byte, modification *KeyModification) error {
+	b := proto.NewBuffer(bytes)
+	txID, err := b.DecodeStringBytes()
+	if err != nil {
+		return err
+	}
+	deleted, err := b.DecodeVarint()
+	if err != nil {
+		return err
+	}
+	modification.TxID = txID
+	modification.IsDelete = deleted == 1
+	if !modification.IsDelete {
+		// DecodeRawBytes(true) returns a copy, the iterator may reuse its buffer
+		modification.Value, err = b.DecodeRawBytes(true)
+	}
+	return err
+}
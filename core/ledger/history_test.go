@@ -0,0 +1,80 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func TestGetHistoryForKeyNotEnabled(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	_, err := ledgerTestWrapper.ledger.GetHistoryForKey("chaincode1", "key1")
+	testutil.AssertSame(t, err, ErrHistoryNotEnabled)
+}
+
+func TestGetHistoryForKey(t *testing.T) {
+	viper.Set("ledger.history.enabled", true)
+	defer viper.Set("ledger.history.enabled", false)
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	// block 0: tx0 sets key1, tx1 fails, tx2 sets key1 again and key10
+	ledger.BeginTxBatch(0)
+	tx0, txID0 := buildTestTx(t)
+	ledger.TxBegin(txID0)
+	ledger.SetState("chaincode1", "key1", []byte("value1"))
+	ledger.TxFinished(txID0, true)
+	tx1, txID1 := buildTestTx(t)
+	ledger.TxBegin(txID1)
+	ledger.SetState("chaincode1", "key1", []byte("failed"))
+	ledger.TxFinished(txID1, false)
+	tx2, txID2 := buildTestTx(t)
+	ledger.TxBegin(txID2)
+	ledger.SetState("chaincode1", "key1", []byte("value2"))
+	ledger.SetState("chaincode1", "key10", []byte("value10"))
+	ledger.SetState("chaincode2", "key1", []byte("other"))
+	ledger.TxFinished(txID2, true)
+	ledger.CommitTxBatch(0, []*protos.Transaction{tx0, tx1, tx2}, nil, []byte("proof"))
+
+	// block 1: key1 is deleted
+	ledger.BeginTxBatch(1)
+	tx3, txID3 := buildTestTx(t)
+	ledger.TxBegin(txID3)
+	ledger.DeleteState("chaincode1", "key1")
+	ledger.TxFinished(txID3, true)
+	ledger.CommitTxBatch(1, []*protos.Transaction{tx3}, nil, []byte("proof"))
+
+	itr, err := ledger.GetHistoryForKey("chaincode1", "key1")
+	testutil.AssertNoError(t, err, "Error while getting history")
+	defer itr.Close()
+	expected := []*KeyModification{
+		{TxID: txID0, BlockNumber: 0, TxIndex: 0, Value: []byte("value1")},
+		{TxID: txID2, BlockNumber: 0, TxIndex: 2, Value: []byte("value2")},
+		{TxID: txID3, BlockNumber: 1, TxIndex: 0, IsDelete: true},
+	}
+	var found []*KeyModification
+	for itr.Next() {
+		modification, err := itr.GetKeyModification()
+		testutil.AssertNoError(t, err, "Error while reading history")
+		found = append(found, modification)
+	}
+	testutil.AssertEquals(t, found, expected)
+}
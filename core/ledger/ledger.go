@@ -23,12 +23,14 @@ import (
 	"sync"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/richquery"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/events/producer"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 
 	"github.com/hyperledger/fabric/protos"
 	"golang.org/x/net/context"
@@ -48,6 +50,14 @@ const (
 	ErrorTypeResourceNotFound = ErrorType("ResourceNotFound")
 	//ErrorTypeBlockNotFound used to indicate if a block is not found when looked up by it's hash
 	ErrorTypeBlockNotFound = ErrorType("ErrorTypeBlockNotFound")
+	//ErrorTypeHistoryNotEnabled used to indicate that the key history index is not kept
+	ErrorTypeHistoryNotEnabled = ErrorType("HistoryNotEnabled")
+	//ErrorTypeBlockPruned used to indicate that a block has been removed by pruning
+	ErrorTypeBlockPruned = ErrorType("BlockPruned")
+	//ErrorTypeRichQueryNotEnabled used to indicate that the document index of the rich queries is not kept
+	ErrorTypeRichQueryNotEnabled = ErrorType("RichQueryNotEnabled")
+	//ErrorTypeNotYetIndexed used to indicate that a block is committed but the async indexer has not indexed it yet
+	ErrorTypeNotYetIndexed = ErrorType("NotYetIndexed")
 )
 
 //Error can be used for throwing an error from ledger code.
@@ -75,13 +85,24 @@ var (
 
 	// ErrResourceNotFound is returned if a resource is not found
 	ErrResourceNotFound = newLedgerError(ErrorTypeResourceNotFound, "ledger: resource not found")
+
+	// ErrHistoryNotEnabled is returned by GetHistoryForKey if 'ledger.history.enabled' is false
+	ErrHistoryNotEnabled = newLedgerError(ErrorTypeHistoryNotEnabled, "ledger: key history is not enabled")
+
+	// ErrBlockPruned is returned if a block is looked up that has been pruned
+	ErrBlockPruned = newLedgerError(ErrorTypeBlockPruned, "ledger: block has been pruned")
+
+	// ErrRichQueryNotEnabled is returned by GetQueryResult if 'ledger.state.richQuery.enabled' is false
+	ErrRichQueryNotEnabled = newLedgerError(ErrorTypeRichQueryNotEnabled, "ledger: rich queries are not enabled")
 )
 
 // Ledger - the struct for openchain ledger
 type Ledger struct {
-	blockchain *blockchain
-	state      *state.State
-	currentID  interface{}
+	blockchain     *blockchain
+	state          *state.State
+	currentID      interface{}
+	historyEnabled bool
+	pruning        *pruningConfig
 }
 
 var ledger *Ledger
@@ -103,8 +124,13 @@ func GetNewLedger() (*Ledger, error) {
 		return nil, err
 	}
 
+	pruning, err := pruningConfigFromViper()
+	if err != nil {
+		return nil, err
+	}
+
 	state := state.NewState()
-	return &Ledger{blockchain, state, nil}, nil
+	return &Ledger{blockchain, state, nil, historyEnabledFromConfig(), pruning}, nil
 }
 
 /////////////////// Transaction-batch related methods ///////////////////////////////
@@ -126,7 +152,7 @@ func (ledger *Ledger) BeginTxBatch(id interface{}) error {
 // state is modified by a transaction between these two calls, the
 // contained hash will be different.
 func (ledger *Ledger) GetTXBatchPreviewBlockInfo(id interface{},
-	transactions []*protos.Transaction, metadata []byte) (*protos.BlockchainInfo, error) {
+	transactions []*protos.Transaction, transactionResults []*protos.TransactionResult, metadata []byte) (*protos.BlockchainInfo, error) {
 	err := ledger.checkValidIDCommitORRollback(id)
 	if err != nil {
 		return nil, err
@@ -135,7 +161,11 @@ func (ledger *Ledger) GetTXBatchPreviewBlockInfo(id interface{},
 	if err != nil {
 		return nil, err
 	}
-	block := ledger.blockchain.buildBlock(protos.NewBlock(transactions, metadata), stateHash)
+	block := protos.NewBlock(transactions, metadata)
+	if err = block.SetTransactionResults(transactionResults); err != nil {
+		return nil, err
+	}
+	block = ledger.blockchain.buildBlock(block, stateHash)
 	info := ledger.blockchain.getBlockchainInfoForBlock(ledger.blockchain.getSize()+1, block)
 	return info, nil
 }
@@ -156,9 +186,20 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 		return err
 	}
 
-	writeBatch := gorocksdb.NewWriteBatch()
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		ledger.resetForNextTxGroup(false)
+		ledger.blockchain.blockPersistenceStatus(false)
+		return err
+	}
+	writeBatch := openchainDB.NewWriteBatch()
 	defer writeBatch.Destroy()
 	block := protos.NewBlock(transactions, metadata)
+	if err = block.SetTransactionResults(transactionResults); err != nil {
+		ledger.resetForNextTxGroup(false)
+		ledger.blockchain.blockPersistenceStatus(false)
+		return err
+	}
 
 	ccEvents := []*protos.ChaincodeEvent{}
 
@@ -191,9 +232,21 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 		return err
 	}
 	ledger.state.AddChangesForPersistence(newBlockNumber, writeBatch)
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	dbErr := db.GetDBHandle().DB.Write(opt, writeBatch)
+	if ledger.historyEnabled {
+		addHistoryForPersistence(newBlockNumber, transactions, ledger.state.GetTxStateDeltas(), writeBatch)
+	}
+	if ledger.pruning.enabled {
+		prunedFrom, prunedTo, err := ledger.blockchain.addPruningChangesForPersistence(ledger.pruning, writeBatch)
+		if err != nil {
+			ledger.resetForNextTxGroup(false)
+			ledger.blockchain.blockPersistenceStatus(false)
+			return err
+		}
+		for blockNumber := prunedFrom; blockNumber < prunedTo; blockNumber++ {
+			ledger.state.DeleteStateDeltaForPersistence(blockNumber, writeBatch)
+		}
+	}
+	dbErr := writeBatch.Commit(db.AsyncWrite)
 	if dbErr != nil {
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
@@ -269,6 +322,58 @@ func (ledger *Ledger) GetStateRangeScanIterator(chaincodeID string, startKey str
 	return ledger.state.GetRangeScanIterator(chaincodeID, startKey, endKey, committed)
 }
 
+// GetStateRangeScanPage returns a page of the keys (and values) between startKey and endKey for a
+// chaincodeID, in lexical order of the keys, or in reverse with options.Reverse. The page holds up to
+// options.Limit keys, all of them if the limit is 0, and starts after options.Bookmark. The
+// GetBookmark of the returned iterator is the bookmark of the next page, empty on the last page.
+// The committed flag is the same as for GetStateRangeScanIterator, so that uncommitted writes and
+// deletions are taken into account when it is false
+func (ledger *Ledger) GetStateRangeScanPage(chaincodeID string, startKey string, endKey string, committed bool,
+	options statemgmt.RangeScanOptions) (*statemgmt.PagedRangeScanIterator, error) {
+	if options.Limit < 0 {
+		return nil, newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("ledger: invalid range scan limit [%d]", options.Limit))
+	}
+	return ledger.state.GetRangeScanPage(chaincodeID, startKey, endKey, committed, options)
+}
+
+// GetStateProof returns a Merkle proof of the committed value of the key against the
+// StateHash of the last block, or an error if the key is not set. The proof can be
+// checked without a ledger with state.VerifyStateProof
+func (ledger *Ledger) GetStateProof(chaincodeID string, key string) (*statemgmt.StateProof, error) {
+	return ledger.state.GetProof(chaincodeID, key)
+}
+
+// GetHistoryForKey returns an iterator over the values written to the key by committed
+// transactions, oldest first, with the transaction and block that wrote each of them.
+// It returns ErrHistoryNotEnabled unless 'ledger.history.enabled' is set. Blocks
+// committed while the history was disabled, or received through state transfer, are
+// not covered. The iterator must be closed
+func (ledger *Ledger) GetHistoryForKey(chaincodeID string, key string) (*HistoryIterator, error) {
+	if !ledger.historyEnabled {
+		return nil, ErrHistoryNotEnabled
+	}
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	return newHistoryIterator(openchainDB, chaincodeID, key), nil
+}
+
+// GetQueryResult returns an iterator over the committed values of the chaincode that
+// are JSON objects selected by the rich query, in the order of the keys. See
+// richquery.Query for the form of the query. It returns ErrRichQueryNotEnabled unless
+// 'ledger.state.richQuery.enabled' is set. The iterator must be closed
+func (ledger *Ledger) GetQueryResult(chaincodeID string, query string) (*richquery.QueryIterator, error) {
+	if !ledger.state.IsRichQueryEnabled() {
+		return nil, ErrRichQueryNotEnabled
+	}
+	parsedQuery, err := richquery.ParseQuery(query)
+	if err != nil {
+		return nil, newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("ledger: %s", err))
+	}
+	return ledger.state.GetQueryResult(chaincodeID, parsedQuery)
+}
+
 // SetState sets state to given value for chaincodeID and key. Does not immideatly writes to DB
 func (ledger *Ledger) SetState(chaincodeID string, key string, value []byte) error {
 	if key == "" || value == nil {
@@ -304,7 +409,11 @@ func (ledger *Ledger) SetStateMultipleKeys(chaincodeID string, kvs map[string][]
 // should be used when transferring the state from one peer to another peer. You must call
 // stateSnapshot.Release() once you are done with the snapshot to free up resources.
 func (ledger *Ledger) GetStateSnapshot() (*state.StateSnapshot, error) {
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	dbSnapshot := openchainDB.GetSnapshot()
 	blockHeight, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
 	if err != nil {
 		dbSnapshot.Release()
@@ -393,7 +502,8 @@ func (ledger *Ledger) GetBlockchainInfo() (*protos.BlockchainInfo, error) {
 }
 
 // GetBlockByNumber return block given the number of the block on blockchain.
-// Lowest block on chain is block number zero
+// Lowest block on chain is block number zero. Returns ErrBlockPruned if the block
+// has been pruned
 func (ledger *Ledger) GetBlockByNumber(blockNumber uint64) (*protos.Block, error) {
 	if blockNumber >= ledger.GetBlockchainSize() {
 		return nil, ErrOutOfBounds
@@ -401,16 +511,69 @@ func (ledger *Ledger) GetBlockByNumber(blockNumber uint64) (*protos.Block, error
 	return ledger.blockchain.getBlock(blockNumber)
 }
 
+// GetPrunedBlockCount returns the number of blocks that have been pruned. The
+// blocks [0, GetPrunedBlockCount()) are no longer available
+func (ledger *Ledger) GetPrunedBlockCount() uint64 {
+	return ledger.blockchain.pruned.count
+}
+
+// GetBlockCheckpoint returns what has been kept of a pruned block, if the block
+// number falls on 'ledger.pruning.checkpointInterval'. Returns ErrResourceNotFound
+// for the other block numbers
+func (ledger *Ledger) GetBlockCheckpoint(blockNumber uint64) (*BlockCheckpoint, error) {
+	return ledger.blockchain.getBlockCheckpoint(blockNumber)
+}
+
 // GetBlockchainSize returns number of blocks in blockchain
 func (ledger *Ledger) GetBlockchainSize() uint64 {
 	return ledger.blockchain.getSize()
 }
 
+// GetIndexerStatus returns how far the indexing of the committed blocks is. With the
+// async indexer, lookups by block hash, transaction ID, chaincode ID, certificate or
+// enrollment ID that need blocks not indexed yet return an error of type
+// ErrorTypeNotYetIndexed
+func (ledger *Ledger) GetIndexerStatus() *IndexerStatus {
+	return ledger.blockchain.indexer.status()
+}
+
 // GetTransactionByID return transaction by it's txId
 func (ledger *Ledger) GetTransactionByID(txID string) (*protos.Transaction, error) {
 	return ledger.blockchain.getTransactionByID(txID)
 }
 
+// GetTransactionProof returns the proof that the transaction with the given ID and its
+// result are in its block, which can be checked against the block hash with
+// TransactionProof.Verify. Blocks committed before the transactions Merkle root was
+// introduced have no proofs
+func (ledger *Ledger) GetTransactionProof(txID string) (*protos.TransactionProof, error) {
+	return ledger.blockchain.getTransactionProof(txID)
+}
+
+// GetTransactionsByChaincodeID returns a page of the transactions that deployed or invoked
+// the chaincode with the given name in the blocks [fromBlock, toBlock], in the order of the
+// chain. Pass "" as bookmark for the first page and the Bookmark of the returned page for
+// the next one. Confidential transactions, whose chaincode ID is encrypted, are not indexed
+func (ledger *Ledger) GetTransactionsByChaincodeID(chaincodeID string, fromBlock uint64, toBlock uint64, bookmark string, limit int) (*TransactionPage, error) {
+	return ledger.blockchain.getTransactionsByLookupKey(encodeTxLookupKeyPrefix(prefixChaincodeIDTxKey, []byte(chaincodeID)),
+		fromBlock, toBlock, bookmark, limit)
+}
+
+// GetTransactionsBySubmitterCert returns a page of the transactions signed with the given
+// certificate, see GetTransactionsByChaincodeID for the paging
+func (ledger *Ledger) GetTransactionsBySubmitterCert(cert []byte, fromBlock uint64, toBlock uint64, bookmark string, limit int) (*TransactionPage, error) {
+	return ledger.blockchain.getTransactionsByLookupKey(encodeTxLookupKeyPrefix(prefixSubmitterCertTxKey, util.ComputeCryptoHash(cert)),
+		fromBlock, toBlock, bookmark, limit)
+}
+
+// GetTransactionsByEnrollmentID returns a page of the transactions signed with an enrollment
+// certificate of the given user, see GetTransactionsByChaincodeID for the paging. The
+// transactions signed with transaction certificates are not linked to the user
+func (ledger *Ledger) GetTransactionsByEnrollmentID(enrollmentID string, fromBlock uint64, toBlock uint64, bookmark string, limit int) (*TransactionPage, error) {
+	return ledger.blockchain.getTransactionsByLookupKey(encodeTxLookupKeyPrefix(prefixEnrollmentIDTxKey, []byte(enrollmentID)),
+		fromBlock, toBlock, bookmark, limit)
+}
+
 // PutRawBlock puts a raw block on the chain. This function should only be
 // used for synchronization between peers.
 func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error {
@@ -436,6 +599,11 @@ func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error
 // wish to verify the entire chain, use ledger.GetBlockchainSize() - 1.
 // lowBlock is the low block in the chain to include in verification. If
 // you wish to verify the entire chain, use 0 for the genesis block.
+// On a pruned ledger, the verification stops at the first retained block, whose
+// previous block hash is checked against the hash recorded for the last pruned
+// block. If they match, the pruned blocks of the range count as verified and
+// lowBlock is returned, otherwise the first retained block is returned.
+// ErrBlockPruned is returned if highBlock has been pruned.
 func (ledger *Ledger) VerifyChain(highBlock, lowBlock uint64) (uint64, error) {
 	if highBlock >= ledger.GetBlockchainSize() {
 		return highBlock, ErrOutOfBounds
@@ -443,6 +611,14 @@ func (ledger *Ledger) VerifyChain(highBlock, lowBlock uint64) (uint64, error) {
 	if highBlock < lowBlock {
 		return lowBlock, ErrOutOfBounds
 	}
+	pruned := ledger.blockchain.pruned
+	if highBlock < pruned.count {
+		return highBlock, ErrBlockPruned
+	}
+	verifyFrom := lowBlock
+	if verifyFrom < pruned.count {
+		verifyFrom = pruned.count
+	}
 
 	currentBlock, err := ledger.GetBlockByNumber(highBlock)
 	if err != nil {
@@ -452,7 +628,7 @@ func (ledger *Ledger) VerifyChain(highBlock, lowBlock uint64) (uint64, error) {
 		return highBlock, fmt.Errorf("Block %d is nil.", highBlock)
 	}
 
-	for i := highBlock; i > lowBlock; i-- {
+	for i := highBlock; i > verifyFrom; i-- {
 		previousBlock, err := ledger.GetBlockByNumber(i - 1)
 		if err != nil {
 			return i, nil
@@ -470,9 +646,24 @@ func (ledger *Ledger) VerifyChain(highBlock, lowBlock uint64) (uint64, error) {
 		currentBlock = previousBlock
 	}
 
+	if verifyFrom > lowBlock {
+		if pruned.lastBlockHash == nil || !bytes.Equal(pruned.lastBlockHash, currentBlock.PreviousBlockHash) {
+			return verifyFrom, nil
+		}
+	}
 	return lowBlock, nil
 }
 
+// GetDatastoreStats returns the size, the number of keys and the compaction details
+// of the datastore the ledger is kept in
+func (ledger *Ledger) GetDatastoreStats() (*db.Stats, error) {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	return openchainDB.Stats()
+}
+
 func (ledger *Ledger) checkValidIDBegin() error {
 	if ledger.currentID != nil {
 		return fmt.Errorf("Another TxGroup [%s] already in-progress", ledger.currentID)
@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/hyperledger/fabric/protos"
 )
@@ -673,7 +674,7 @@ func TestPreviewTXBatchBlock(t *testing.T) {
 	ledger.TxFinished("txUuid1", true)
 	transaction, _ := buildTestTx(t)
 
-	previewBlockInfo, err := ledger.GetTXBatchPreviewBlockInfo(0, []*protos.Transaction{transaction}, []byte("proof"))
+	previewBlockInfo, err := ledger.GetTXBatchPreviewBlockInfo(0, []*protos.Transaction{transaction}, nil, []byte("proof"))
 	testutil.AssertNoError(t, err, "Error fetching preview block info.")
 
 	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, nil, []byte("proof"))
@@ -706,6 +707,38 @@ func TestGetTransactionByID(t *testing.T) {
 	testutil.AssertNil(t, ledgerTransaction)
 }
 
+func TestGetTransactionProof(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1A"))
+	ledger.TxFinished("txUuid1", true)
+	var transactions []*protos.Transaction
+	var results []*protos.TransactionResult
+	for i := 0; i < 3; i++ {
+		transaction, uuid := buildTestTx(t)
+		transactions = append(transactions, transaction)
+		results = append(results, &protos.TransactionResult{Txid: uuid, Result: []byte(strconv.Itoa(i))})
+	}
+	previewBlockInfo, err := ledger.GetTXBatchPreviewBlockInfo(0, transactions, results, []byte("proof"))
+	testutil.AssertNoError(t, err, "Error fetching preview block info.")
+	ledger.CommitTxBatch(0, transactions, results, []byte("proof"))
+	committedBlockInfo, _ := ledger.GetBlockchainInfo()
+	testutil.AssertEquals(t, previewBlockInfo, committedBlockInfo)
+
+	proof, err := ledger.GetTransactionProof(transactions[2].Txid)
+	testutil.AssertNoError(t, err, "Error fetching transaction proof.")
+	testutil.AssertEquals(t, proof.BlockNumber, uint64(0))
+	testutil.AssertEquals(t, proof.Transaction, transactions[2])
+	testutil.AssertEquals(t, proof.TransactionResult.Result, []byte("2"))
+	testutil.AssertNoError(t, proof.Verify(committedBlockInfo.CurrentBlockHash), "Error verifying transaction proof.")
+
+	_, err = ledger.GetTransactionProof("InvalidID")
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+}
+
 func TestRangeScanIterator(t *testing.T) {
 	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
 	ledger := ledgerTestWrapper.ledger
@@ -830,6 +863,64 @@ func TestRangeScanIterator(t *testing.T) {
 	itr.Close()
 }
 
+func collectStateRangeScanPage(t *testing.T, ledger *Ledger, committed bool, options statemgmt.RangeScanOptions) ([]string, string) {
+	itr, err := ledger.GetStateRangeScanPage("chaincodeID2", "", "", committed, options)
+	testutil.AssertNoError(t, err, "Error while getting range scan page")
+	defer itr.Close()
+	var keys []string
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		keys = append(keys, key+"="+string(value))
+	}
+	return keys, itr.GetBookmark()
+}
+
+func TestGetStateRangeScanPage(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	for i := 1; i <= 6; i++ {
+		ledger.SetState("chaincodeID2", "key"+strconv.Itoa(i), []byte("value"+strconv.Itoa(i)))
+	}
+	ledger.SetState("chaincodeID3", "key1", []byte("value1"))
+	ledger.TxFinished("txUuid1", true)
+	tx, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{tx}, nil, nil)
+
+	// uncommitted writes and deletions of the batch and of the running transaction
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid2")
+	ledger.DeleteState("chaincodeID2", "key3")
+	ledger.SetState("chaincodeID2", "key2", []byte("value2-new"))
+	ledger.TxFinished("txUuid2", true)
+	ledger.TxBegin("txUuid3")
+	ledger.SetState("chaincodeID2", "key7", []byte("value7"))
+	ledger.DeleteState("chaincodeID2", "key5")
+
+	keys, bookmark := collectStateRangeScanPage(t, ledger, false, statemgmt.RangeScanOptions{Limit: 2})
+	testutil.AssertEquals(t, keys, []string{"key1=value1", "key2=value2-new"})
+	testutil.AssertEquals(t, bookmark, "key2")
+	keys, bookmark = collectStateRangeScanPage(t, ledger, false, statemgmt.RangeScanOptions{Limit: 2, Bookmark: bookmark})
+	testutil.AssertEquals(t, keys, []string{"key4=value4", "key6=value6"})
+	keys, bookmark = collectStateRangeScanPage(t, ledger, false, statemgmt.RangeScanOptions{Limit: 2, Bookmark: bookmark})
+	testutil.AssertEquals(t, keys, []string{"key7=value7"})
+	testutil.AssertEquals(t, bookmark, "")
+
+	keys, bookmark = collectStateRangeScanPage(t, ledger, false, statemgmt.RangeScanOptions{Limit: 3, Reverse: true})
+	testutil.AssertEquals(t, keys, []string{"key7=value7", "key6=value6", "key4=value4"})
+	keys, bookmark = collectStateRangeScanPage(t, ledger, false, statemgmt.RangeScanOptions{Limit: 3, Reverse: true, Bookmark: bookmark})
+	testutil.AssertEquals(t, keys, []string{"key2=value2-new", "key1=value1"})
+	testutil.AssertEquals(t, bookmark, "")
+
+	// the committed state is not changed by the running batch
+	keys, _ = collectStateRangeScanPage(t, ledger, true, statemgmt.RangeScanOptions{Reverse: true, Bookmark: "key5"})
+	testutil.AssertEquals(t, keys, []string{"key4=value4", "key3=value3", "key2=value2", "key1=value1"})
+
+	_, err := ledger.GetStateRangeScanPage("chaincodeID2", "", "", true, statemgmt.RangeScanOptions{Limit: -1})
+	testutil.AssertError(t, err, "A negative limit should fail")
+}
+
 func TestGetSetMultipleKeys(t *testing.T) {
 	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
 	l := ledgerTestWrapper.ledger
@@ -916,3 +1007,35 @@ func TestLedgerInvalidInput(t *testing.T) {
 	value, _ := l.GetState("chaincodeID1", "key1", true)
 	testutil.AssertEquals(t, value, []byte("value1"))
 }
+
+func TestLedgerGetStateProof(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid")
+	ledger.SetState("chaincode1", "key1", []byte("value1"))
+	ledger.SetState("chaincode2", "key2", []byte("value2"))
+	ledger.TxFinished("txUuid", true)
+	tx, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{tx}, nil, []byte("proof"))
+	stateHash := ledgerTestWrapper.GetBlockByNumber(0).StateHash
+
+	// uncommitted changes are not part of the proof
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid")
+	ledger.SetState("chaincode1", "key1", []byte("value1-new"))
+	ledger.TxFinished("txUuid", true)
+
+	proof, err := ledger.GetStateProof("chaincode1", "key1")
+	testutil.AssertNoError(t, err, "Error while getting state proof")
+	testutil.AssertEquals(t, proof.Value, []byte("value1"))
+	testutil.AssertNoError(t, state.VerifyStateProof(stateHash, proof), "Error while verifying state proof")
+
+	_, err = ledger.GetStateProof("chaincode1", "key3")
+	testutil.AssertError(t, err, "State proof of a key that is not set should fail")
+
+	proof.Value = []byte("value3")
+	testutil.AssertError(t, state.VerifyStateProof(stateHash, proof), "Tampered state proof should fail")
+	proof.Type = "unknown"
+	testutil.AssertError(t, state.VerifyStateProof(stateHash, proof), "State proof of unknown type should fail")
+}
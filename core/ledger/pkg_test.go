@@ -20,11 +20,16 @@ import (
 	"os"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
+	_ "github.com/hyperledger/fabric/core/db/boltdb"
+	_ "github.com/hyperledger/fabric/core/db/leveldb"
+	_ "github.com/hyperledger/fabric/core/db/memory"
+	_ "github.com/hyperledger/fabric/core/db/rocksdb"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
-	"github.com/tecbot/gorocksdb"
 	"golang.org/x/net/context"
 )
 
@@ -48,7 +53,8 @@ func newTestBlockchainWrapper(t *testing.T) *blockchainTestWrapper {
 }
 
 func (testWrapper *blockchainTestWrapper) addNewBlock(block *protos.Block, stateHash []byte) uint64 {
-	writeBatch := gorocksdb.NewWriteBatch()
+	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	writeBatch := openchainDB.NewWriteBatch()
 	defer writeBatch.Destroy()
 	newBlockNumber, err := testWrapper.blockchain.addPersistenceChangesForNewBlock(context.TODO(), block, stateHash, writeBatch)
 	testutil.AssertNoError(testWrapper.t, err, "Error while adding a new block")
@@ -0,0 +1,245 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+// Pruning drops the blocks that are older than the last 'ledger.pruning.retainBlocks'
+// blocks, together with their transaction index entries and state deltas. The blocks
+// are always pruned from block 0 onwards, so the pruned blocks are the range
+// [0, prunedBlockCount). Of every 'ledger.pruning.checkpointInterval'-th pruned block a
+// checkpoint is kept: the block without its transactions, along with the hash of the
+// block it was taken from, so that the previousBlockHash chain can still be followed.
+// The key history index is not pruned
+
+// prunedBlockCountKey holds the number of pruned blocks followed by the hash of the
+// last pruned block, in blockchainCF
+var prunedBlockCountKey = []byte("prunedBlockCount")
+
+// checkpointKeyPrefix is followed by the block number in the keys of the checkpoints,
+// in blockchainCF. The keys are longer than the 8 byte keys of the blocks
+var checkpointKeyPrefix = []byte("checkpoint")
+
+// maxBlocksPrunedPerCommit bounds the work added to the commit of a block. When pruning
+// is first enabled on a long chain, the old blocks are pruned over the next commits
+const maxBlocksPrunedPerCommit = 100
+
+type pruningConfig struct {
+	enabled            bool
+	retainBlocks       uint64
+	checkpointInterval uint64
+}
+
+// pruningConfigFromViper reads 'ledger.pruning'. When pruning is enabled, at least one
+// block has to be retained and the checkpoint interval must be positive
+func pruningConfigFromViper() (*pruningConfig, error) {
+	config := &pruningConfig{enabled: viper.GetBool("ledger.pruning.enabled")}
+	if !config.enabled {
+		return config, nil
+	}
+	retainBlocks := viper.GetInt("ledger.pruning.retainBlocks")
+	if retainBlocks < 1 {
+		return nil, fmt.Errorf("ledger.pruning.retainBlocks must be at least 1, found [%d]", retainBlocks)
+	}
+	checkpointInterval := viper.GetInt("ledger.pruning.checkpointInterval")
+	if checkpointInterval < 1 {
+		return nil, fmt.Errorf("ledger.pruning.checkpointInterval must be at least 1, found [%d]", checkpointInterval)
+	}
+	config.retainBlocks = uint64(retainBlocks)
+	config.checkpointInterval = uint64(checkpointInterval)
+	return config, nil
+}
+
+// BlockCheckpoint is what is kept of a pruned block at every checkpoint interval
+type BlockCheckpoint struct {
+	BlockNumber uint64
+	// BlockHash is the hash of the block before it was pruned
+	BlockHash []byte
+	// Header is the block without its transactions, their results and NonHashData.
+	// If it has a TransactionsMerkleRoot, Header.GetHeaderHash() is BlockHash
+	Header *protos.Block
+}
+
+// prunedBlocks is the pruned range of the blockchain
+type prunedBlocks struct {
+	count         uint64
+	lastBlockHash []byte
+}
+
+func (blockchain *blockchain) isPruned(blockNumber uint64) bool {
+	return blockNumber < blockchain.pruned.count
+}
+
+// addPruningChangesForPersistence adds to writeBatch the deletion of the blocks that fall
+// out of the retained range once the block being committed is added, and returns the
+// range [from, to) of the newly pruned blocks. The new pruned range is kept aside until
+// blockPersistenceStatus reports the outcome of the commit
+func (blockchain *blockchain) addPruningChangesForPersistence(config *pruningConfig, writeBatch db.WriteBatch) (from uint64, to uint64, err error) {
+	from = blockchain.pruned.count
+	height := blockchain.size + 1
+	if height <= config.retainBlocks {
+		return from, from, nil
+	}
+	pruneBefore := height - config.retainBlocks
+	if indexed, ok := blockchain.indexedBlockCount(); ok && indexed < pruneBefore {
+		// the async indexer may still write the index entries of these blocks
+		pruneBefore = indexed
+	}
+	if pruneBefore > blockchain.pruned.count+maxBlocksPrunedPerCommit {
+		pruneBefore = blockchain.pruned.count + maxBlocksPrunedPerCommit
+	}
+	if pruneBefore <= from {
+		return from, from, nil
+	}
+
+	pruned := &prunedBlocks{from, blockchain.pruned.lastBlockHash}
+	for blockNumber := from; blockNumber < pruneBefore; blockNumber++ {
+		block, err := fetchBlockFromDB(blockNumber)
+		if err != nil {
+			return from, from, err
+		}
+		pruned.count = blockNumber + 1
+		pruned.lastBlockHash = nil
+		if block == nil {
+			// never received, see persistRawBlock
+			continue
+		}
+		blockHash, err := block.GetHash()
+		if err != nil {
+			return from, from, err
+		}
+		pruned.lastBlockHash = blockHash
+		removeIndexDataForPersistence(block, blockNumber, writeBatch)
+		writeBatch.Delete(db.BlockchainCF, encodeBlockNumberDBKey(blockNumber))
+		if blockNumber%config.checkpointInterval == 0 {
+			checkpointBytes, err := encodeCheckpoint(block, blockHash)
+			if err != nil {
+				return from, from, err
+			}
+			writeBatch.Put(db.BlockchainCF, encodeCheckpointKey(blockNumber), checkpointBytes)
+		}
+	}
+	ledgerLogger.Debugf("Pruning blocks [%d] to [%d]", from, pruned.count-1)
+	writeBatch.Put(db.BlockchainCF, prunedBlockCountKey, encodePrunedBlocks(pruned))
+	blockchain.pendingPruned = pruned
+	return from, pruned.count, nil
+}
+
+// indexedBlockCount returns the number of blocks the async indexer is done with. ok is
+// false when the indexes are created with the block
+func (blockchain *blockchain) indexedBlockCount() (count uint64, ok bool) {
+	indexer, isAsync := blockchain.indexer.(*blockchainIndexerAsync)
+	if !isAsync {
+		return 0, false
+	}
+	return indexer.indexerState.getIndexedCount(), true
+}
+
+func (blockchain *blockchain) getBlockCheckpoint(blockNumber uint64) (*BlockCheckpoint, error) {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	checkpointBytes, err := openchainDB.GetFromBlockchain(encodeCheckpointKey(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	if checkpointBytes == nil {
+		return nil, ErrResourceNotFound
+	}
+	checkpoint, err := decodeCheckpoint(checkpointBytes)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint.BlockNumber = blockNumber
+	return checkpoint, nil
+}
+
+// removeIndexDataForPersistence deletes the index entries of the transactions of a block.
+// The block hash entry is kept, so that a lookup by hash reports the block as pruned
+func removeIndexDataForPersistence(block *protos.Block, blockNumber uint64, writeBatch db.WriteBatch) {
+	addresses := make(map[string]bool)
+	for txIndex, tx := range block.GetTransactions() {
+		writeBatch.Delete(db.IndexesCF, encodeTxIDKey(tx.Txid))
+		for _, txLookupKey := range getTxLookupKeys(tx, blockNumber, uint64(txIndex)) {
+			writeBatch.Delete(db.IndexesCF, txLookupKey)
+		}
+		addresses[getTxExecutingAddress(tx)] = true
+	}
+	for address := range addresses {
+		writeBatch.Delete(db.IndexesCF, encodeAddressBlockNumCompositeKey(address, blockNumber))
+	}
+}
+
+func fetchPrunedBlocksFromDB() (*prunedBlocks, error) {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	prunedBytes, err := openchainDB.GetFromBlockchain(prunedBlockCountKey)
+	if err != nil {
+		return nil, err
+	}
+	if prunedBytes == nil {
+		return &prunedBlocks{}, nil
+	}
+	return &prunedBlocks{decodeToUint64(prunedBytes), prunedBytes[8:]}, nil
+}
+
+func encodePrunedBlocks(pruned *prunedBlocks) []byte {
+	return append(encodeUint64(pruned.count), pruned.lastBlockHash...)
+}
+
+func encodeCheckpointKey(blockNumber uint64) []byte {
+	return append(append([]byte{}, checkpointKeyPrefix...), encodeUint64(blockNumber)...)
+}
+
+func encodeCheckpoint(block *protos.Block, blockHash []byte) ([]byte, error) {
+	headerBytes, err := block.GetHeader().Bytes()
+	if err != nil {
+		return nil, err
+	}
+	b := proto.NewBuffer([]byte{})
+	b.EncodeRawBytes(blockHash)
+	b.EncodeRawBytes(headerBytes)
+	return b.Bytes(), nil
+}
+
+func decodeCheckpoint(checkpointBytes []byte) (*BlockCheckpoint, error) {
+	b := proto.NewBuffer(checkpointBytes)
+	blockHash, err := b.DecodeRawBytes(false)
+	if err != nil {
+		return nil, err
+	}
+	headerBytes, err := b.DecodeRawBytes(false)
+	if err != nil {
+		return nil, err
+	}
+	header, err := protos.UnmarshallBlock(headerBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockCheckpoint{BlockHash: blockHash, Header: header}, nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func setPruningConfig(enabled bool, retainBlocks int, checkpointInterval int) {
+	viper.Set("ledger.pruning.enabled", enabled)
+	viper.Set("ledger.pruning.retainBlocks", retainBlocks)
+	viper.Set("ledger.pruning.checkpointInterval", checkpointInterval)
+}
+
+func TestPruningConfigInvalid(t *testing.T) {
+	defer setPruningConfig(false, 0, 0)
+	setPruningConfig(true, 0, 10)
+	_, err := pruningConfigFromViper()
+	testutil.AssertError(t, err, "retainBlocks 0 should be rejected")
+	setPruningConfig(true, 10, 0)
+	_, err = pruningConfigFromViper()
+	testutil.AssertError(t, err, "checkpointInterval 0 should be rejected")
+}
+
+func TestPruneBlocks(t *testing.T) {
+	defer setPruningConfig(false, 0, 0)
+	setPruningConfig(true, 3, 2)
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	var blocks []*protos.Block
+	var txIDs []string
+	for i := 0; i < 8; i++ {
+		ledger.BeginTxBatch(i)
+		tx, txID := buildTestTx(t)
+		ledger.TxBegin(txID)
+		ledger.SetState("chaincode1", "key1", []byte{byte(i)})
+		ledger.TxFinished(txID, true)
+		ledger.CommitTxBatch(i, []*protos.Transaction{tx}, nil, []byte("proof"))
+		if i < 5 {
+			// keep a copy of the blocks that are going to be pruned
+			blocks = append(blocks, ledgerTestWrapper.GetBlockByNumber(uint64(i)))
+		}
+		txIDs = append(txIDs, txID)
+	}
+
+	testutil.AssertEquals(t, ledger.GetPrunedBlockCount(), uint64(5))
+	for i := uint64(0); i < 5; i++ {
+		_, err := ledger.GetBlockByNumber(i)
+		testutil.AssertSame(t, err, ErrBlockPruned)
+		_, err = ledger.GetTransactionByID(txIDs[i])
+		testutil.AssertSame(t, err, ErrResourceNotFound)
+		testutil.AssertNil(t, ledgerTestWrapper.GetStateDelta(i))
+	}
+	for i := uint64(5); i < 8; i++ {
+		testutil.AssertNotNil(t, ledgerTestWrapper.GetBlockByNumber(i))
+		tx, err := ledger.GetTransactionByID(txIDs[i])
+		testutil.AssertNoError(t, err, "Error while getting a retained transaction")
+		testutil.AssertEquals(t, tx.Txid, txIDs[i])
+	}
+
+	// checkpoints are kept for blocks 0, 2 and 4
+	for i, block := range blocks {
+		checkpoint, err := ledger.GetBlockCheckpoint(uint64(i))
+		if i%2 != 0 {
+			testutil.AssertSame(t, err, ErrResourceNotFound)
+			continue
+		}
+		testutil.AssertNoError(t, err, "Error while getting a checkpoint")
+		blockHash, _ := block.GetHash()
+		testutil.AssertEquals(t, checkpoint.BlockHash, blockHash)
+		testutil.AssertEquals(t, checkpoint.Header.PreviousBlockHash, block.PreviousBlockHash)
+		testutil.AssertEquals(t, checkpoint.Header.StateHash, block.StateHash)
+		testutil.AssertNil(t, checkpoint.Header.Transactions)
+		headerHash, err := checkpoint.Header.GetHeaderHash()
+		testutil.AssertNoError(t, err, "Error while hashing a checkpoint header")
+		testutil.AssertEquals(t, headerHash, checkpoint.BlockHash)
+	}
+
+	// the chain from the retained blocks to the last pruned block is intact
+	lastPrunedHash, _ := blocks[4].GetHash()
+	testutil.AssertEquals(t, ledgerTestWrapper.GetBlockByNumber(5).PreviousBlockHash, lastPrunedHash)
+
+	// the chain is verified down to the recorded hash of the last pruned block
+	lowBlock, err := ledger.VerifyChain(7, 0)
+	testutil.AssertNoError(t, err, "Error while verifying a pruned chain")
+	testutil.AssertEquals(t, lowBlock, uint64(0))
+	_, err = ledger.VerifyChain(4, 0)
+	testutil.AssertSame(t, err, ErrBlockPruned)
+	ledger.blockchain.pruned.lastBlockHash = []byte("tampered")
+	lowBlock, _ = ledger.VerifyChain(7, 0)
+	testutil.AssertEquals(t, lowBlock, uint64(5))
+	ledger.blockchain.pruned.lastBlockHash = lastPrunedHash
+
+	// the pruned range is read back when the ledger is reopened
+	reopenedLedger, err := GetNewLedger()
+	testutil.AssertNoError(t, err, "Error while reopening the ledger")
+	testutil.AssertEquals(t, reopenedLedger.GetPrunedBlockCount(), uint64(5))
+	_, err = reopenedLedger.GetBlockByNumber(0)
+	testutil.AssertSame(t, err, ErrBlockPruned)
+	testutil.AssertEquals(t, reopenedLedger.blockchain.pruned.lastBlockHash, lastPrunedHash)
+}
+
+func TestPruneBlocksNotEnabled(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	for i := 0; i < 3; i++ {
+		ledger.BeginTxBatch(i)
+		tx, txID := buildTestTx(t)
+		ledger.TxBegin(txID)
+		ledger.TxFinished(txID, true)
+		ledger.CommitTxBatch(i, []*protos.Transaction{tx}, nil, []byte("proof"))
+	}
+	testutil.AssertEquals(t, ledger.GetPrunedBlockCount(), uint64(0))
+	testutil.AssertNotNil(t, ledgerTestWrapper.GetBlockByNumber(0))
+}
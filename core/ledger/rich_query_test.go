@@ -0,0 +1,116 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func TestGetQueryResultNotEnabled(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	_, err := ledgerTestWrapper.ledger.GetQueryResult("chaincode1", `{"selector":{}}`)
+	testutil.AssertSame(t, err, ErrRichQueryNotEnabled)
+}
+
+func TestGetQueryResult(t *testing.T) {
+	viper.Set("ledger.state.richQuery.enabled", true)
+	defer viper.Set("ledger.state.richQuery.enabled", false)
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	commitRichQueryTestBlock(t, ledger, 0, map[string]string{
+		"asset1": `{"owner":"alice","size":12}`,
+		"asset2": `{"owner":"bob","size":5}`,
+		"asset3": `{"owner":"alice","size":3}`,
+		"asset4": `not a json object`,
+		"asset5": `{"owner":"alice","size":40}`,
+	})
+	// asset5 is deleted and asset3 no longer belongs to alice
+	ledger.BeginTxBatch(1)
+	tx, txID := buildTestTx(t)
+	ledger.TxBegin(txID)
+	ledger.DeleteState("chaincode1", "asset5")
+	ledger.SetState("chaincode1", "asset3", []byte(`{"owner":"carol","size":3}`))
+	ledger.SetState("chaincode2", "asset1", []byte(`{"owner":"alice","size":100}`))
+	ledger.TxFinished(txID, true)
+	ledger.CommitTxBatch(1, []*protos.Transaction{tx}, nil, []byte("proof"))
+
+	testutil.AssertEquals(t, getQueryResultKeys(t, ledger, "chaincode1", `{"selector":{"owner":"alice"}}`), []string{"asset1"})
+	testutil.AssertEquals(t, getQueryResultKeys(t, ledger, "chaincode1", `{"selector":{"size":{"$lt":10}}}`), []string{"asset2", "asset3"})
+	testutil.AssertEquals(t, getQueryResultKeys(t, ledger, "chaincode1", `{"selector":{},"limit":2}`), []string{"asset1", "asset2"})
+	testutil.AssertEquals(t, getQueryResultKeys(t, ledger, "chaincode2", `{"selector":{"owner":"alice"}}`), []string{"asset1"})
+
+	_, err := ledger.GetQueryResult("chaincode1", `{"selector":{"size":{"$near":1}}}`)
+	testutil.AssertError(t, err, "Expected an error for an invalid query")
+	ledgerErr, ok := err.(*Error)
+	testutil.AssertEquals(t, ok && ledgerErr.Type() == ErrorTypeInvalidArgument, true)
+}
+
+func TestGetQueryResultIndexBuiltWhenEnabled(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	commitRichQueryTestBlock(t, ledgerTestWrapper.ledger, 0, map[string]string{
+		"asset1": `{"owner":"alice"}`,
+		"asset2": `{"owner":"bob"}`,
+	})
+
+	// the index is built from the state committed while it was disabled
+	viper.Set("ledger.state.richQuery.enabled", true)
+	ledger, err := GetNewLedger()
+	testutil.AssertNoError(t, err, "Error while constructing ledger")
+	testutil.AssertEquals(t, getQueryResultKeys(t, ledger, "chaincode1", `{"selector":{"owner":"bob"}}`), []string{"asset2"})
+
+	// turning it off clears the index, so that the changes committed meanwhile are
+	// not missed when it is turned back on
+	viper.Set("ledger.state.richQuery.enabled", false)
+	ledger, err = GetNewLedger()
+	testutil.AssertNoError(t, err, "Error while constructing ledger")
+	commitRichQueryTestBlock(t, ledger, 1, map[string]string{"asset3": `{"owner":"bob"}`})
+
+	viper.Set("ledger.state.richQuery.enabled", true)
+	defer viper.Set("ledger.state.richQuery.enabled", false)
+	ledger, err = GetNewLedger()
+	testutil.AssertNoError(t, err, "Error while constructing ledger")
+	testutil.AssertEquals(t, getQueryResultKeys(t, ledger, "chaincode1", `{"selector":{"owner":"bob"}}`), []string{"asset2", "asset3"})
+}
+
+func commitRichQueryTestBlock(t *testing.T, ledger *Ledger, blockNumber uint64, values map[string]string) {
+	ledger.BeginTxBatch(blockNumber)
+	tx, txID := buildTestTx(t)
+	ledger.TxBegin(txID)
+	for key, value := range values {
+		ledger.SetState("chaincode1", key, []byte(value))
+	}
+	ledger.TxFinished(txID, true)
+	err := ledger.CommitTxBatch(blockNumber, []*protos.Transaction{tx}, nil, []byte("proof"))
+	testutil.AssertNoError(t, err, "Error while committing block")
+}
+
+func getQueryResultKeys(t *testing.T, ledger *Ledger, chaincodeID string, query string) []string {
+	itr, err := ledger.GetQueryResult(chaincodeID, query)
+	testutil.AssertNoError(t, err, "Error while running rich query")
+	defer itr.Close()
+	var keys []string
+	for itr.Next() {
+		key, _ := itr.GetKeyValue()
+		keys = append(keys, key)
+	}
+	return keys
+}
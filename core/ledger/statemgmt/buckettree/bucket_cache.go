@@ -57,7 +57,11 @@ func (cache *bucketCache) loadAllBucketNodesFromDB() {
 	if !cache.isEnabled {
 		return
 	}
-	db, _ := db.Registry.Get(comm.DbPluginName())
+	db, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		logger.Errorf("Error while loading the bucket cache: %s", err)
+		return
+	}
 	itr := db.GetStateIterator()
 	//openchainDB := db.GetDBHandle()
 	//itr := openchainDB.GetStateCFIterator()
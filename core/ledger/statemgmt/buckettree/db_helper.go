@@ -23,7 +23,10 @@ import (
 )
 
 func fetchDataNodeFromDB(dataKey *dataKey) (*dataNode, error) {
-	openchainDB,_ := db.Registry.Get(comm.DbPluginName())
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
 	nodeBytes, err := openchainDB.GetFromState(dataKey.getEncodedBytes())
 	if err != nil {
 		return nil, err
@@ -41,7 +44,10 @@ func fetchDataNodeFromDB(dataKey *dataKey) (*dataNode, error) {
 }
 
 func fetchBucketNodeFromDB(bucketKey *bucketKey) (*bucketNode, error) {
-	openchainDB,_ := db.Registry.Get(comm.DbPluginName())
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
 	nodeBytes, err := openchainDB.GetFromState(bucketKey.getEncodedBytes())
 	if err != nil {
 		return nil, err
@@ -56,7 +62,10 @@ type rawKey []byte
 
 func fetchDataNodesFromDBFor(bucketKey *bucketKey) (dataNodes, error) {
 	logger.Debugf("Fetching from DB data nodes for bucket [%s]", bucketKey)
-	openchainDB,_ := db.Registry.Get(comm.DbPluginName())
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
 	itr := openchainDB.GetStateIterator()
 	defer itr.Close()
 	minimumDataKeyBytes := minimumPossibleDataKeyBytesFor(bucketKey)
@@ -0,0 +1,167 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	openchainUtil "github.com/hyperledger/fabric/core/util"
+)
+
+// ProofType is the type of the proofs of the bucket tree
+const ProofType = "buckettree"
+
+// A bucket tree proof holds the other key-values of the bucket of the key as Neighbours,
+// and the bucket nodes from the lowest level but one up to the root as Path. The
+// number of buckets and the grouping are recorded in Params, as they decide the bucket
+// of the key and the shape of the tree. The verifier assumes the default hash function
+// for assigning keys to buckets
+
+// GetProof - method implementation for interface 'statemgmt.HashableState'
+func (stateImpl *StateImpl) GetProof(chaincodeID string, key string) (*statemgmt.StateProof, error) {
+	dataKey := newDataKey(chaincodeID, key)
+	proof := &statemgmt.StateProof{
+		Type:        ProofType,
+		ChaincodeID: chaincodeID,
+		Key:         key,
+		Params: map[string]int{
+			ConfigNumBuckets:             conf.getNumBucketsAtLowestLevel(),
+			ConfigMaxGroupingAtEachLevel: conf.getMaxGroupingAtEachLevel(),
+		},
+	}
+	dataNodes, err := fetchDataNodesFromDBFor(dataKey.getBucketKey())
+	if err != nil {
+		return nil, err
+	}
+	for _, dataNode := range dataNodes {
+		if bytes.Equal(dataNode.getCompositeKey(), dataKey.compositeKey) {
+			proof.Value = dataNode.getValue()
+			continue
+		}
+		neighbourChaincodeID, neighbourKey := dataNode.getKeyElements()
+		proof.Neighbours = append(proof.Neighbours, &statemgmt.StateProofKeyValue{
+			ChaincodeID: neighbourChaincodeID, Key: neighbourKey, Value: dataNode.getValue()})
+	}
+	if proof.Value == nil {
+		return nil, fmt.Errorf("Key [%s] of chaincode [%s] is not set, there is no proof of its absence", key, chaincodeID)
+	}
+
+	childKey := dataKey.getBucketKey()
+	for childKey.level > 0 {
+		parentKey := childKey.getParentKey()
+		parentNode, err := fetchBucketNodeFromDB(parentKey)
+		if err != nil {
+			return nil, err
+		}
+		proofNode := &statemgmt.StateProofNode{ChildrenCryptoHashes: make(map[int][]byte)}
+		if parentNode != nil {
+			childIndex := parentKey.getChildIndex(childKey)
+			for i, childCryptoHash := range parentNode.childrenCryptoHash {
+				if i != childIndex && childCryptoHash != nil {
+					proofNode.ChildrenCryptoHashes[i] = childCryptoHash
+				}
+			}
+		}
+		proof.Path = append(proof.Path, proofNode)
+		childKey = parentKey
+	}
+	return proof, nil
+}
+
+// VerifyProof recomputes the crypto-hash of the state from a proof returned by GetProof,
+// and returns an error unless it is stateHash. It does not depend on the configuration
+// or the data of the local state
+func VerifyProof(stateHash []byte, proof *statemgmt.StateProof) error {
+	numBuckets := proof.Params[ConfigNumBuckets]
+	maxGrouping := proof.Params[ConfigMaxGroupingAtEachLevel]
+	if proof.Value == nil {
+		return fmt.Errorf("Proof has no value, the absence of a key cannot be proven")
+	}
+	if numBuckets < 2 || maxGrouping < 2 {
+		return fmt.Errorf("Invalid bucket tree configuration in proof: numBuckets=[%d], maxGroupingAtEachLevel=[%d]", numBuckets, maxGrouping)
+	}
+	proofConf := newConfig(numBuckets, maxGrouping, fnvHash)
+	if len(proof.Path) != proofConf.getLowestLevel() {
+		return fmt.Errorf("Proof has [%d] levels, expected [%d]", len(proof.Path), proofConf.getLowestLevel())
+	}
+	bucketNumber := proofBucketNumber(proofConf, proof.ChaincodeID, proof.Key)
+
+	// the lowest bucket, hashed from its key-values in the order of the keys
+	dataNodes := dataNodes{&dataNode{&dataKey{nil, statemgmt.ConstructCompositeKey(proof.ChaincodeID, proof.Key)}, proof.Value}}
+	for _, neighbour := range proof.Neighbours {
+		if neighbour.ChaincodeID == proof.ChaincodeID && neighbour.Key == proof.Key {
+			return fmt.Errorf("Key [%s] of chaincode [%s] is a neighbour of itself in proof", proof.Key, proof.ChaincodeID)
+		}
+		if proofBucketNumber(proofConf, neighbour.ChaincodeID, neighbour.Key) != bucketNumber || neighbour.Value == nil {
+			return fmt.Errorf("Key [%s] of chaincode [%s] is not a neighbour in proof", neighbour.Key, neighbour.ChaincodeID)
+		}
+		dataNodes = append(dataNodes, &dataNode{&dataKey{nil, statemgmt.ConstructCompositeKey(neighbour.ChaincodeID, neighbour.Key)}, neighbour.Value})
+	}
+	sort.Sort(dataNodes)
+	bucketHashCalculator := newBucketHashCalculator(nil)
+	for _, dataNode := range dataNodes {
+		bucketHashCalculator.addNextNode(dataNode)
+	}
+	cryptoHash := bucketHashCalculator.computeCryptoHash()
+
+	// the bucket nodes up to the root
+	for _, proofNode := range proof.Path {
+		parentBucketNumber := proofConf.computeParentBucketNumber(bucketNumber)
+		childIndex := bucketNumber - ((parentBucketNumber-1)*maxGrouping + 1)
+		childrenCryptoHash := make([][]byte, maxGrouping)
+		for i, childCryptoHash := range proofNode.ChildrenCryptoHashes {
+			if i < 0 || i >= maxGrouping || i == childIndex {
+				return fmt.Errorf("Invalid child index [%d] in proof", i)
+			}
+			childrenCryptoHash[i] = childCryptoHash
+		}
+		childrenCryptoHash[childIndex] = cryptoHash
+		cryptoHash = computeBucketNodeCryptoHash(childrenCryptoHash)
+		bucketNumber = parentBucketNumber
+	}
+	if !bytes.Equal(cryptoHash, stateHash) {
+		return fmt.Errorf("Proof does not match the state hash: computed [%x], expected [%x]", cryptoHash, stateHash)
+	}
+	return nil
+}
+
+func proofBucketNumber(proofConf *config, chaincodeID string, key string) int {
+	bucketHash := proofConf.computeBucketHash(statemgmt.ConstructCompositeKey(chaincodeID, key))
+	return int(bucketHash)%proofConf.getNumBucketsAtLowestLevel() + 1
+}
+
+// computeBucketNodeCryptoHash is bucketNode.computeCryptoHash without the global config
+func computeBucketNodeCryptoHash(childrenCryptoHash [][]byte) []byte {
+	cryptoHashContent := []byte{}
+	numChildren := 0
+	for _, childCryptoHash := range childrenCryptoHash {
+		if childCryptoHash != nil {
+			numChildren++
+			cryptoHashContent = append(cryptoHashContent, childCryptoHash...)
+		}
+	}
+	switch numChildren {
+	case 0:
+		return nil
+	case 1:
+		return cryptoHashContent
+	}
+	return openchainUtil.ComputeCryptoHash(cryptoHashContent)
+}
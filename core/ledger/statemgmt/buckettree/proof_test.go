@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestStateImpl_GetProof(t *testing.T) {
+	testDBWrapper.CleanDB(t)
+	// few buckets, so that keys share buckets
+	stateImplTestWrapper := newStateImplTestWrapperWithCustomConfig(t, 5, 2)
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 20; i++ {
+		stateDelta.Set(fmt.Sprintf("chaincodeID%d", i%3), fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	rootHash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+	for i := 0; i < 20; i++ {
+		proof, err := stateImplTestWrapper.stateImpl.GetProof(fmt.Sprintf("chaincodeID%d", i%3), fmt.Sprintf("key%d", i))
+		testutil.AssertNoError(t, err, "Error while getting proof")
+		testutil.AssertEquals(t, proof.Value, []byte(fmt.Sprintf("value%d", i)))
+		testutil.AssertNoError(t, VerifyProof(rootHash, proof), "Error while verifying proof")
+	}
+
+	// a key that is not set has no proof
+	_, err := stateImplTestWrapper.stateImpl.GetProof("chaincodeID1", "missingKey")
+	testutil.AssertError(t, err, "Proof of a key that is not set should fail")
+
+	// the proof survives serialization
+	proof, _ := stateImplTestWrapper.stateImpl.GetProof("chaincodeID1", "key1")
+	serializedProof, err := json.Marshal(proof)
+	testutil.AssertNoError(t, err, "Error while marshalling proof")
+	deserializedProof := &statemgmt.StateProof{}
+	testutil.AssertNoError(t, json.Unmarshal(serializedProof, deserializedProof), "Error while unmarshalling proof")
+	testutil.AssertNoError(t, VerifyProof(rootHash, deserializedProof), "Error while verifying deserialized proof")
+
+	// tampered proofs
+	deserializedProof.Value = []byte("anotherValue")
+	testutil.AssertError(t, VerifyProof(rootHash, deserializedProof), "Proof with a tampered value should fail")
+	proof, _ = stateImplTestWrapper.stateImpl.GetProof("chaincodeID1", "key1")
+	proof.Value = nil
+	testutil.AssertError(t, VerifyProof(rootHash, proof), "Proof that hides the value should fail")
+	proof, _ = stateImplTestWrapper.stateImpl.GetProof("chaincodeID1", "key1")
+	proof.Key = "key4"
+	testutil.AssertError(t, VerifyProof(rootHash, proof), "Proof for another key should fail")
+}
+
+func TestStateImpl_GetProofForgedAbsence(t *testing.T) {
+	testDBWrapper.CleanDB(t)
+	stateImplTestWrapper := newStateImplTestWrapperWithCustomConfig(t, 20, 2)
+	stateDelta := statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID", "k", []byte("value"), nil)
+	rootHash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+	// the hash of the only bucket, listed beside the bucket of the key, which is emptied
+	proof, err := stateImplTestWrapper.stateImpl.GetProof("chaincodeID", "k")
+	testutil.AssertNoError(t, err, "Error while getting proof")
+	proofConf := newConfig(20, 2, fnvHash)
+	bucketNumber := proofBucketNumber(proofConf, "chaincodeID", "k")
+	childIndex := bucketNumber - ((proofConf.computeParentBucketNumber(bucketNumber)-1)*2 + 1)
+	bucketHashCalculator := newBucketHashCalculator(nil)
+	bucketHashCalculator.addNextNode(&dataNode{&dataKey{nil, statemgmt.ConstructCompositeKey("chaincodeID", "k")}, []byte("value")})
+	proof.Value = nil
+	proof.Path[0].ChildrenCryptoHashes = map[int][]byte{1 - childIndex: bucketHashCalculator.computeCryptoHash()}
+	testutil.AssertError(t, VerifyProof(rootHash, proof), "Forged proof of absence should fail")
+}
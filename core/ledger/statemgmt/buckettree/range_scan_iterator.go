@@ -36,7 +36,10 @@ type RangeScanIterator struct {
 }
 
 func newRangeScanIterator(chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
-	openchainDB,_ := db.Registry.Get(comm.DbPluginName())
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
         dbItr := openchainDB.GetStateIterator()
 	//dbItr := db.GetDBHandle().GetStateCFIterator()
 	itr := &RangeScanIterator{
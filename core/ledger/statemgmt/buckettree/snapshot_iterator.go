@@ -17,10 +17,9 @@ limitations under the License.
 package buckettree
 
 import (
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
-	"github.com/hyperledger/fabric/core/comm"
 )
 
 // StateSnapshotIterator implements the interface 'statemgmt.StateSnapshotIterator'
@@ -28,11 +27,12 @@ type StateSnapshotIterator struct {
 	dbItr db.Iterator
 }
 
-func newStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (*StateSnapshotIterator, error) {
-	openchainDB,_ := db.Registry.Get(comm.DbPluginName())
+func newStateSnapshotIterator(snapshot db.Snapshot) (*StateSnapshotIterator, error) {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
 	dbItr := openchainDB.GetStateSnapshotIterator(snapshot)
-
-	//dbItr := db.GetDBHandle().GetStateCFSnapshotIterator(snapshot)
 	dbItr.Seek([]byte{0x01})
 	dbItr.Prev()
 	return &StateSnapshotIterator{dbItr}, nil
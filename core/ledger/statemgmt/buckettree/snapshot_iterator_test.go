@@ -19,6 +19,7 @@ package buckettree
 import (
 	"testing"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
@@ -42,7 +43,8 @@ func TestStateSnapshotIterator(t *testing.T) {
 	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID5", "key5"), []byte("value5"))
 
 	// take db snapeshot
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	dbSnapshot := openchainDB.GetSnapshot()
 
 	// delete keys
 	stateDelta.Delete("chaincodeID1", "key1", nil)
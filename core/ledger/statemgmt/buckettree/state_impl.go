@@ -22,9 +22,6 @@ import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
-	"github.com/hyperledger/fabric/core/db/rocksdb"
-	"github.com/hyperledger/fabric/core/comm"
 )
 
 var logger = logging.MustGetLogger("buckettree")
@@ -218,7 +215,7 @@ func computeDataNodesCryptoHash(bucketKey *bucketKey, updatedNodes dataNodes, ex
 }
 
 // AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'
-func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch db.WriteBatch) error {
 
 	if stateImpl.dataNodesDelta == nil {
 		return nil
@@ -235,35 +232,31 @@ func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.Write
 	return nil
 }
 
-func (stateImpl *StateImpl) addDataNodeChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
-	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
-	openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
+func (stateImpl *StateImpl) addDataNodeChangesForPersistence(writeBatch db.WriteBatch) {
 	affectedBuckets := stateImpl.dataNodesDelta.getAffectedBuckets()
 	for _, affectedBucket := range affectedBuckets {
 		dataNodes := stateImpl.dataNodesDelta.getSortedDataNodesFor(affectedBucket)
 		for _, dataNode := range dataNodes {
 			if dataNode.isDelete() {
 				logger.Debugf("Deleting data node key = %#v", dataNode.dataKey)
-				writeBatch.DeleteCF(openchainDB_ptr.StateCF, dataNode.dataKey.getEncodedBytes())
+				writeBatch.Delete(db.StateCF, dataNode.dataKey.getEncodedBytes())
 			} else {
 				logger.Debugf("Adding data node with value = %#v", dataNode.value)
-				writeBatch.PutCF(openchainDB_ptr.StateCF, dataNode.dataKey.getEncodedBytes(), dataNode.value)
+				writeBatch.Put(db.StateCF, dataNode.dataKey.getEncodedBytes(), dataNode.value)
 			}
 		}
 	}
 }
 
-func (stateImpl *StateImpl) addBucketNodeChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
-	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
-	openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
+func (stateImpl *StateImpl) addBucketNodeChangesForPersistence(writeBatch db.WriteBatch) {
 	secondLastLevel := conf.getLowestLevel() - 1
 	for level := secondLastLevel; level >= 0; level-- {
 		bucketNodes := stateImpl.bucketTreeDelta.getBucketNodesAt(level)
 		for _, bucketNode := range bucketNodes {
 			if bucketNode.markedForDeletion {
-				writeBatch.DeleteCF(openchainDB_ptr.StateCF, bucketNode.bucketKey.getEncodedBytes())
+				writeBatch.Delete(db.StateCF, bucketNode.bucketKey.getEncodedBytes())
 			} else {
-				writeBatch.PutCF(openchainDB_ptr.StateCF, bucketNode.bucketKey.getEncodedBytes(), bucketNode.marshal())
+				writeBatch.Put(db.StateCF, bucketNode.bucketKey.getEncodedBytes(), bucketNode.marshal())
 			}
 		}
 	}
@@ -296,7 +289,7 @@ func (stateImpl *StateImpl) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (stateImpl *StateImpl) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+func (stateImpl *StateImpl) GetStateSnapshotIterator(snapshot db.Snapshot) (statemgmt.StateSnapshotIterator, error) {
 	return newStateSnapshotIterator(snapshot)
 }
 
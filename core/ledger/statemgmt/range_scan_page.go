@@ -0,0 +1,134 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// RangeScanOptions narrow a range scan to a page of keys in lexical order
+type RangeScanOptions struct {
+	// Limit is the maximum number of key-values of the page, 0 for no limit
+	Limit int
+	// Reverse returns the keys in descending order
+	Reverse bool
+	// Bookmark is the last key of the previous page, the page starts after it.
+	// An empty bookmark starts from the beginning of the range
+	Bookmark string
+}
+
+// PagedRangeScanIterator - an implementation of interface 'RangeScanIterator' that returns
+// a page of the key-values of an underlying iterator in the order of the keys. The
+// underlying iterator is read through once, keeping no more than Limit+1 key-values, so
+// that a page of a large range takes bounded memory
+type PagedRangeScanIterator struct {
+	keyValues    []*pagedKeyValue
+	currentIndex int
+	bookmark     string
+}
+
+type pagedKeyValue struct {
+	key   string
+	value []byte
+}
+
+// NewPagedRangeScanIterator returns the page of the key-values of itr selected by the
+// options. The key-values of itr are expected to be in the range of the scan but may
+// come in any order. itr is read to the end and closed
+func NewPagedRangeScanIterator(itr RangeScanIterator, options RangeScanOptions) *PagedRangeScanIterator {
+	defer itr.Close()
+	kept := &pagedKeyValueHeap{reverse: options.Reverse}
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		if options.Bookmark != "" && !kept.before(options.Bookmark, key) {
+			continue
+		}
+		if options.Limit > 0 && kept.Len() > options.Limit {
+			// the heap holds the Limit+1 first keys of the page, with the last one on top
+			if !kept.before(key, kept.keyValues[0].key) {
+				continue
+			}
+			heap.Pop(kept)
+		}
+		heap.Push(kept, &pagedKeyValue{key, value})
+	}
+
+	sort.Sort(sort.Reverse(kept))
+	keyValues := kept.keyValues
+	pagedItr := &PagedRangeScanIterator{keyValues: keyValues, currentIndex: -1}
+	if options.Limit > 0 && len(keyValues) > options.Limit {
+		pagedItr.keyValues = keyValues[:options.Limit]
+		pagedItr.bookmark = pagedItr.keyValues[options.Limit-1].key
+	}
+	return pagedItr
+}
+
+// Next - see interface 'RangeScanIterator' for details
+func (itr *PagedRangeScanIterator) Next() bool {
+	if itr.currentIndex+1 >= len(itr.keyValues) {
+		return false
+	}
+	itr.currentIndex++
+	return true
+}
+
+// GetKeyValue - see interface 'RangeScanIterator' for details
+func (itr *PagedRangeScanIterator) GetKeyValue() (string, []byte) {
+	keyValue := itr.keyValues[itr.currentIndex]
+	return keyValue.key, keyValue.value
+}
+
+// Close - see interface 'RangeScanIterator' for details
+func (itr *PagedRangeScanIterator) Close() {
+}
+
+// GetBookmark returns the bookmark of the next page, or an empty string if this is the
+// last page of the range
+func (itr *PagedRangeScanIterator) GetBookmark() string {
+	return itr.bookmark
+}
+
+// pagedKeyValueHeap keeps the key that comes last in the order of the page on top
+type pagedKeyValueHeap struct {
+	keyValues []*pagedKeyValue
+	reverse   bool
+}
+
+// before tells whether key1 comes before key2 in the order of the page
+func (h *pagedKeyValueHeap) before(key1 string, key2 string) bool {
+	if h.reverse {
+		return key1 > key2
+	}
+	return key1 < key2
+}
+
+func (h *pagedKeyValueHeap) Len() int { return len(h.keyValues) }
+func (h *pagedKeyValueHeap) Less(i, j int) bool {
+	return h.before(h.keyValues[j].key, h.keyValues[i].key)
+}
+func (h *pagedKeyValueHeap) Swap(i, j int) {
+	h.keyValues[i], h.keyValues[j] = h.keyValues[j], h.keyValues[i]
+}
+func (h *pagedKeyValueHeap) Push(x interface{}) {
+	h.keyValues = append(h.keyValues, x.(*pagedKeyValue))
+}
+func (h *pagedKeyValueHeap) Pop() interface{} {
+	last := h.keyValues[len(h.keyValues)-1]
+	h.keyValues = h.keyValues[:len(h.keyValues)-1]
+	return last
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func collectPagedKeys(t *testing.T, delta *StateDelta, options RangeScanOptions) ([]string, string) {
+	// the state delta iterator returns the keys in the random order of a map
+	itr := NewPagedRangeScanIterator(NewStateDeltaRangeScanIterator(delta, "chaincodeID1", "", ""), options)
+	defer itr.Close()
+	var keys []string
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		testutil.AssertEquals(t, value, []byte("value-"+key))
+		keys = append(keys, key)
+	}
+	return keys, itr.GetBookmark()
+}
+
+func TestPagedRangeScanIterator(t *testing.T) {
+	delta := NewStateDelta()
+	var allKeys []string
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key%d", i)
+		delta.Set("chaincodeID1", key, []byte("value-"+key), nil)
+		allKeys = append(allKeys, key)
+	}
+
+	// no limit
+	keys, bookmark := collectPagedKeys(t, delta, RangeScanOptions{})
+	testutil.AssertEquals(t, keys, allKeys)
+	testutil.AssertEquals(t, bookmark, "")
+
+	// pages in both directions
+	for _, reverse := range []bool{false, true} {
+		var pagedKeys []string
+		bookmark := ""
+		for {
+			keys, bookmark = collectPagedKeys(t, delta, RangeScanOptions{Limit: 3, Reverse: reverse, Bookmark: bookmark})
+			testutil.AssertEquals(t, len(keys) <= 3, true)
+			pagedKeys = append(pagedKeys, keys...)
+			if bookmark == "" {
+				break
+			}
+			testutil.AssertEquals(t, bookmark, keys[len(keys)-1])
+		}
+		if reverse {
+			for i, j := 0, len(pagedKeys)-1; i < j; i, j = i+1, j-1 {
+				pagedKeys[i], pagedKeys[j] = pagedKeys[j], pagedKeys[i]
+			}
+		}
+		testutil.AssertEquals(t, pagedKeys, allKeys)
+	}
+
+	// a page that ends exactly at the end of the range has no bookmark
+	keys, bookmark = collectPagedKeys(t, delta, RangeScanOptions{Limit: 5, Bookmark: "key4"})
+	testutil.AssertEquals(t, keys, allKeys[5:])
+	testutil.AssertEquals(t, bookmark, "")
+}
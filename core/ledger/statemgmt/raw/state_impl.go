@@ -17,11 +17,11 @@ limitations under the License.
 package raw
 
 import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
-	"github.com/hyperledger/fabric/core/db/rocksdb"
-	"github.com/hyperledger/fabric/core/comm"
 )
 
 // StateImpl implements raw state management. This implementation does not support computation of crypto-hash of the state.
@@ -44,7 +44,10 @@ func (impl *StateImpl) Initialize(configs map[string]interface{}) error {
 func (impl *StateImpl) Get(chaincodeID string, key string) ([]byte, error) {
 	compositeKey := statemgmt.ConstructCompositeKey(chaincodeID, key)
 
-	openchainDB,_ := db.Registry.Get(comm.DbPluginName())
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
 	return openchainDB.GetFromState(compositeKey)
 }
 
@@ -65,23 +68,20 @@ func (impl *StateImpl) ComputeCryptoHash() ([]byte, error) {
 }
 
 // AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'
-func (impl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (impl *StateImpl) AddChangesForPersistence(writeBatch db.WriteBatch) error {
 	delta := impl.stateDelta
 	if delta == nil {
 		return nil
 	}
-	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
-	openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
-
 	updatedChaincodeIds := delta.GetUpdatedChaincodeIds(false)
 	for _, updatedChaincodeID := range updatedChaincodeIds {
 		updates := delta.GetUpdates(updatedChaincodeID)
 		for updatedKey, value := range updates {
 			compositeKey := statemgmt.ConstructCompositeKey(updatedChaincodeID, updatedKey)
 			if value.IsDeleted() {
-				writeBatch.DeleteCF(openchainDB_ptr.StateCF, compositeKey)
+				writeBatch.Delete(db.StateCF, compositeKey)
 			} else {
-				writeBatch.PutCF(openchainDB_ptr.StateCF, compositeKey, value.GetValue())
+				writeBatch.Put(db.StateCF, compositeKey, value.GetValue())
 			}
 		}
 	}
@@ -93,7 +93,7 @@ func (impl *StateImpl) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (impl *StateImpl) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+func (impl *StateImpl) GetStateSnapshotIterator(snapshot db.Snapshot) (statemgmt.StateSnapshotIterator, error) {
 	panic("Not a full-fledged state implementation. Implemented only for measuring best-case performance benchmark")
 }
 
@@ -101,3 +101,8 @@ func (impl *StateImpl) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (s
 func (impl *StateImpl) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
 	panic("Not a full-fledged state implementation. Implemented only for measuring best-case performance benchmark")
 }
+
+// GetProof - method implementation for interface 'statemgmt.HashableState'
+func (impl *StateImpl) GetProof(chaincodeID string, key string) (*statemgmt.StateProof, error) {
+	return nil, fmt.Errorf("The raw state implementation does not compute a crypto-hash of the state")
+}
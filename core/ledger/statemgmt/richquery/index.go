@@ -0,0 +1,186 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package richquery
+
+import (
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("richquery")
+
+// The document index is the local document store of the rich queries. It keeps a copy
+// of the committed values of the state that are JSON objects, in the indexes table, so
+// that a query reads the documents of a chaincode without going through the state
+// implementation. The db key of a document is
+//   prefixDocumentKey, chaincodeID, 0x00, key
+// so that the documents of a chaincode are next to each other in the order of the keys.
+// The key made of the prefix alone is present while the index covers the whole state
+var prefixDocumentKey = byte(8)
+var indexBuiltKey = []byte{prefixDocumentKey}
+
+// batchSize is the number of keys deleted in each batch when the index is cleared
+const batchSize = 1000
+
+// AddChangesForPersistence adds to writeBatch the changes of the document index that
+// follow from stateDelta. Values that are not JSON objects are not indexed
+func AddChangesForPersistence(stateDelta *statemgmt.StateDelta, writeBatch db.WriteBatch) {
+	for _, chaincodeID := range stateDelta.GetUpdatedChaincodeIds(false) {
+		for key, updatedValue := range stateDelta.GetUpdates(chaincodeID) {
+			documentKey := encodeDocumentKey(chaincodeID, key)
+			value := updatedValue.GetValue()
+			if updatedValue.IsDeleted() || decodeDocument(value) == nil {
+				writeBatch.Delete(db.IndexesCF, documentKey)
+			} else {
+				writeBatch.Put(db.IndexesCF, documentKey, value)
+			}
+		}
+	}
+}
+
+// IsBuilt tells whether the document index covers the whole state
+func IsBuilt(openchainDB db.OpenchainDB) (bool, error) {
+	value, err := openchainDB.GetFromIndexes(indexBuiltKey)
+	if err != nil {
+		return false, err
+	}
+	return value != nil, nil
+}
+
+// Build replaces the document index with the documents of the committed state of
+// stateImpl. The documents are written in a single batch once the state is read
+// through, as some datastores do not take writes while a snapshot is open
+func Build(openchainDB db.OpenchainDB, stateImpl statemgmt.HashableState) error {
+	if err := Clear(openchainDB); err != nil {
+		return err
+	}
+	writeBatch := openchainDB.NewWriteBatch()
+	defer writeBatch.Destroy()
+	documents, err := addStateDocuments(openchainDB, stateImpl, writeBatch)
+	if err != nil {
+		return err
+	}
+	writeBatch.Put(db.IndexesCF, indexBuiltKey, []byte{1})
+	if err := writeBatch.Commit(db.SyncWrite); err != nil {
+		return err
+	}
+	logger.Infof("Built the rich query document index with [%d] documents", documents)
+	return nil
+}
+
+// addStateDocuments adds the documents of the committed state to writeBatch and
+// returns their number
+func addStateDocuments(openchainDB db.OpenchainDB, stateImpl statemgmt.HashableState, writeBatch db.WriteBatch) (int, error) {
+	dbSnapshot := openchainDB.GetSnapshot()
+	defer dbSnapshot.Release()
+	itr, err := stateImpl.GetStateSnapshotIterator(dbSnapshot)
+	if err != nil {
+		return 0, err
+	}
+	defer itr.Close()
+	documents := 0
+	for itr.Next() {
+		compositeKey, value := itr.GetRawKeyValue()
+		if decodeDocument(value) == nil {
+			continue
+		}
+		writeBatch.Put(db.IndexesCF, append([]byte{prefixDocumentKey}, compositeKey...), statemgmt.Copy(value))
+		documents++
+	}
+	return documents, nil
+}
+
+// Clear removes the document index. Committing changes to the state without adding
+// the changes of the index leaves it out of date, so the index is cleared when it is
+// turned off and built again when it is turned back on
+func Clear(openchainDB db.OpenchainDB) error {
+	for {
+		// the keys are deleted batchSize at a time, with no iterator open while writing
+		var keys [][]byte
+		dbItr := openchainDB.GetIndexesIterator()
+		for dbItr.Seek(indexBuiltKey); dbItr.ValidForPrefix(indexBuiltKey) && len(keys) < batchSize; dbItr.Next() {
+			keys = append(keys, statemgmt.Copy(dbItr.KeyData()))
+		}
+		dbItr.Close()
+		if len(keys) == 0 {
+			return nil
+		}
+		writeBatch := openchainDB.NewWriteBatch()
+		for _, key := range keys {
+			writeBatch.Delete(db.IndexesCF, key)
+		}
+		err := writeBatch.Commit(db.SyncWrite)
+		writeBatch.Destroy()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// QueryIterator - an implementation of interface 'RangeScanIterator' that returns the
+// documents of a chaincode selected by a rich query, in the order of the keys
+type QueryIterator struct {
+	dbItr   db.Iterator
+	prefix  []byte
+	query   *Query
+	started bool
+	count   int
+}
+
+// NewQueryIterator returns an iterator over the documents of the chaincode selected by
+// the query
+func NewQueryIterator(openchainDB db.OpenchainDB, chaincodeID string, query *Query) *QueryIterator {
+	return &QueryIterator{dbItr: openchainDB.GetIndexesIterator(), prefix: encodeDocumentKey(chaincodeID, ""), query: query}
+}
+
+// Next - see interface 'RangeScanIterator' for details
+func (itr *QueryIterator) Next() bool {
+	if itr.query.limit > 0 && itr.count >= itr.query.limit {
+		return false
+	}
+	for {
+		if !itr.started {
+			itr.started = true
+			itr.dbItr.Seek(itr.prefix)
+		} else {
+			itr.dbItr.Next()
+		}
+		if !itr.dbItr.ValidForPrefix(itr.prefix) {
+			return false
+		}
+		if itr.query.Matches(itr.dbItr.ValueData()) {
+			itr.count++
+			return true
+		}
+	}
+}
+
+// GetKeyValue - see interface 'RangeScanIterator' for details
+func (itr *QueryIterator) GetKeyValue() (string, []byte) {
+	key := string(itr.dbItr.KeyData()[len(itr.prefix):])
+	return key, statemgmt.Copy(itr.dbItr.ValueData())
+}
+
+// Close - see interface 'RangeScanIterator' for details
+func (itr *QueryIterator) Close() {
+	itr.dbItr.Close()
+}
+
+func encodeDocumentKey(chaincodeID string, key string) []byte {
+	return append([]byte{prefixDocumentKey}, statemgmt.ConstructCompositeKey(chaincodeID, key)...)
+}
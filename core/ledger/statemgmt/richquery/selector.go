@@ -0,0 +1,307 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package richquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Query is a parsed rich query. A rich query is a JSON object of the form
+//   {"selector": {...}, "limit": 10}
+// The selector maps field paths, with nested fields separated by dots, to either a
+// value the field must equal or an object of operators, e.g.
+//   {"owner": "alice", "size": {"$gte": 10, "$lt": 20}, "$or": [{"color": "red"}, {"color": "blue"}]}
+// The operators are $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin and $exists, and
+// selectors are combined with $and and $or. A field missing from a document only
+// matches {"$exists": false}. $gt, $gte, $lt and $lte compare numbers with numbers and
+// strings with strings. The limit is optional, 0 or missing for no limit
+type Query struct {
+	condition condition
+	limit     int
+}
+
+// condition tells whether a document matches a part of a selector
+type condition func(doc map[string]interface{}) bool
+
+// fieldCondition tells whether the value of a field matches an operator. found is false
+// if the document does not have the field
+type fieldCondition func(value interface{}, found bool) bool
+
+// ParseQuery parses a rich query
+func ParseQuery(query string) (*Query, error) {
+	var parsed struct {
+		Selector map[string]interface{} `json:"selector"`
+		Limit    *int                   `json:"limit"`
+	}
+	if err := json.Unmarshal([]byte(query), &parsed); err != nil {
+		return nil, fmt.Errorf("Invalid rich query: %s", err)
+	}
+	if parsed.Selector == nil {
+		return nil, fmt.Errorf("Invalid rich query: the query has no selector")
+	}
+	q := &Query{}
+	if parsed.Limit != nil {
+		if *parsed.Limit < 0 {
+			return nil, fmt.Errorf("Invalid rich query: limit must not be negative, got %d", *parsed.Limit)
+		}
+		q.limit = *parsed.Limit
+	}
+	var err error
+	if q.condition, err = parseSelector(parsed.Selector); err != nil {
+		return nil, fmt.Errorf("Invalid rich query: %s", err)
+	}
+	return q, nil
+}
+
+// GetLimit returns the maximum number of documents of the result, 0 for no limit
+func (q *Query) GetLimit() int {
+	return q.limit
+}
+
+// Matches tells whether the value is a JSON object selected by the query
+func (q *Query) Matches(value []byte) bool {
+	doc := decodeDocument(value)
+	return doc != nil && q.condition(doc)
+}
+
+// decodeDocument returns the JSON object held by value, or nil if value is not a
+// JSON object
+func decodeDocument(value []byte) map[string]interface{} {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+func parseSelector(selector map[string]interface{}) (condition, error) {
+	var conditions []condition
+	for field, operand := range selector {
+		var c condition
+		var err error
+		switch {
+		case field == "$and" || field == "$or":
+			c, err = parseCombination(field, operand)
+		case strings.HasPrefix(field, "$"):
+			err = fmt.Errorf("unknown combination operator %s", field)
+		default:
+			c, err = parseField(field, operand)
+		}
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+	return all(conditions), nil
+}
+
+func parseCombination(operator string, operand interface{}) (condition, error) {
+	selectors, ok := operand.([]interface{})
+	if !ok || len(selectors) == 0 {
+		return nil, fmt.Errorf("%s takes a non-empty array of selectors", operator)
+	}
+	var conditions []condition
+	for _, s := range selectors {
+		selector, ok := s.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s takes a non-empty array of selectors", operator)
+		}
+		c, err := parseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+	if operator == "$and" {
+		return all(conditions), nil
+	}
+	return func(doc map[string]interface{}) bool {
+		for _, c := range conditions {
+			if c(doc) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func all(conditions []condition) condition {
+	return func(doc map[string]interface{}) bool {
+		for _, c := range conditions {
+			if !c(doc) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// parseField parses the operand of a field path, either a value to compare with or an
+// object of operators
+func parseField(field string, operand interface{}) (condition, error) {
+	path := strings.Split(field, ".")
+	var fieldConditions []fieldCondition
+	operators, ok := operand.(map[string]interface{})
+	if ok && isOperatorObject(operators) {
+		for operator, value := range operators {
+			fc, err := parseOperator(operator, value)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %s", field, err)
+			}
+			fieldConditions = append(fieldConditions, fc)
+		}
+	} else {
+		fieldConditions = append(fieldConditions, equals(operand))
+	}
+	return func(doc map[string]interface{}) bool {
+		value, found := lookup(doc, path)
+		for _, fc := range fieldConditions {
+			if !fc(value, found) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// isOperatorObject tells whether the object holds operators rather than being a value
+// to compare with
+func isOperatorObject(object map[string]interface{}) bool {
+	if len(object) == 0 {
+		return false
+	}
+	for key := range object {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+func parseOperator(operator string, operand interface{}) (fieldCondition, error) {
+	switch operator {
+	case "$eq":
+		return equals(operand), nil
+	case "$ne":
+		return func(value interface{}, found bool) bool {
+			return found && !reflect.DeepEqual(value, operand)
+		}, nil
+	case "$gt", "$gte", "$lt", "$lte":
+		if !isOrdered(operand) {
+			return nil, fmt.Errorf("%s takes a number or a string", operator)
+		}
+		return func(value interface{}, found bool) bool {
+			cmp, comparable := compare(value, operand)
+			if !found || !comparable {
+				return false
+			}
+			switch operator {
+			case "$gt":
+				return cmp > 0
+			case "$gte":
+				return cmp >= 0
+			case "$lt":
+				return cmp < 0
+			}
+			return cmp <= 0
+		}, nil
+	case "$in", "$nin":
+		values, ok := operand.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s takes an array", operator)
+		}
+		in := operator == "$in"
+		return func(value interface{}, found bool) bool {
+			if !found {
+				return false
+			}
+			for _, v := range values {
+				if reflect.DeepEqual(value, v) {
+					return in
+				}
+			}
+			return !in
+		}, nil
+	case "$exists":
+		exists, ok := operand.(bool)
+		if !ok {
+			return nil, fmt.Errorf("$exists takes a boolean")
+		}
+		return func(value interface{}, found bool) bool {
+			return found == exists
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", operator)
+}
+
+func equals(operand interface{}) fieldCondition {
+	return func(value interface{}, found bool) bool {
+		return found && reflect.DeepEqual(value, operand)
+	}
+}
+
+// lookup returns the value at the path of nested fields of doc
+func lookup(doc map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = doc
+	for _, name := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func isOrdered(value interface{}) bool {
+	switch value.(type) {
+	case float64, string:
+		return true
+	}
+	return false
+}
+
+// compare returns -1, 0 or 1 as value1 is less than, equal to or greater than value2.
+// comparable is false unless both are numbers or both are strings
+func compare(value1 interface{}, value2 interface{}) (cmp int, comparable bool) {
+	switch v1 := value1.(type) {
+	case float64:
+		v2, ok := value2.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case v1 < v2:
+			return -1, true
+		case v1 > v2:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		v2, ok := value2.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(v1, v2), true
+	}
+	return 0, false
+}
@@ -0,0 +1,86 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package richquery
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestQueryMatches(t *testing.T) {
+	doc := []byte(`{"owner":"alice","size":12,"color":"red","tags":["a","b"],"dims":{"width":3}}`)
+	testCases := []struct {
+		selector string
+		matches  bool
+	}{
+		{`{}`, true},
+		{`{"owner":"alice"}`, true},
+		{`{"owner":"bob"}`, false},
+		{`{"owner":"alice","size":12}`, true},
+		{`{"owner":"alice","size":13}`, false},
+		{`{"dims.width":3}`, true},
+		{`{"dims":{"width":3}}`, true},
+		{`{"dims.height":{"$exists":false}}`, true},
+		{`{"dims.width":{"$exists":true}}`, true},
+		{`{"tags":["a","b"]}`, true},
+		{`{"size":{"$gt":10,"$lte":12}}`, true},
+		{`{"size":{"$gte":13}}`, false},
+		{`{"size":{"$lt":"z"}}`, false},
+		{`{"owner":{"$gt":"a","$lt":"b"}}`, true},
+		{`{"owner":{"$ne":"bob"}}`, true},
+		{`{"missing":{"$ne":"bob"}}`, false},
+		{`{"color":{"$in":["blue","red"]}}`, true},
+		{`{"color":{"$nin":["blue","red"]}}`, false},
+		{`{"$or":[{"owner":"bob"},{"color":"red"}]}`, true},
+		{`{"$or":[{"owner":"bob"},{"color":"blue"}]}`, false},
+		{`{"$and":[{"owner":"alice"},{"size":{"$eq":12}}]}`, true},
+	}
+	for _, testCase := range testCases {
+		query, err := ParseQuery(`{"selector":` + testCase.selector + `}`)
+		testutil.AssertNoError(t, err, testCase.selector)
+		if query.Matches(doc) != testCase.matches {
+			t.Fatalf("Selector %s: expected match to be %t", testCase.selector, testCase.matches)
+		}
+	}
+
+	query, _ := ParseQuery(`{"selector":{}}`)
+	testutil.AssertEquals(t, query.Matches([]byte(`not json`)), false)
+	testutil.AssertEquals(t, query.Matches([]byte(`[1,2]`)), false)
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		`not json`,
+		`{"limit":1}`,
+		`{"selector":{},"limit":-1}`,
+		`{"selector":{"$nor":[{"a":1}]}}`,
+		`{"selector":{"$or":[]}}`,
+		`{"selector":{"$and":{"a":1}}}`,
+		`{"selector":{"a":{"$near":1}}}`,
+		`{"selector":{"a":{"$in":1}}}`,
+		`{"selector":{"a":{"$exists":"yes"}}}`,
+		`{"selector":{"a":{"$gt":[1]}}}`,
+	} {
+		_, err := ParseQuery(query)
+		testutil.AssertError(t, err, query)
+	}
+
+	query, err := ParseQuery(`{"selector":{"a":1},"limit":5}`)
+	testutil.AssertNoError(t, err, "Error while parsing query")
+	testutil.AssertEquals(t, query.GetLimit(), 5)
+}
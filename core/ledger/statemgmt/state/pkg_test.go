@@ -20,10 +20,14 @@ import (
 	"os"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
+	_ "github.com/hyperledger/fabric/core/db/boltdb"
+	_ "github.com/hyperledger/fabric/core/db/leveldb"
+	_ "github.com/hyperledger/fabric/core/db/memory"
+	_ "github.com/hyperledger/fabric/core/db/rocksdb"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
-	"github.com/tecbot/gorocksdb"
 )
 
 var testDBWrapper = db.NewTestDBWrapper()
@@ -55,14 +59,16 @@ func (testWrapper *stateTestWrapper) get(chaincodeID string, key string, committ
 }
 
 func (testWrapper *stateTestWrapper) getSnapshot() *StateSnapshot {
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	dbSnapshot := openchainDB.GetSnapshot()
 	stateSnapshot, err := testWrapper.state.GetSnapshot(0, dbSnapshot)
 	testutil.AssertNoError(testWrapper.t, err, "Error during creation of state snapshot")
 	return stateSnapshot
 }
 
 func (testWrapper *stateTestWrapper) persistAndClearInMemoryChanges(blockNumber uint64) {
-	writeBatch := gorocksdb.NewWriteBatch()
+	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	writeBatch := openchainDB.NewWriteBatch()
 	defer writeBatch.Destroy()
 	testWrapper.state.AddChangesForPersistence(blockNumber, writeBatch)
 	testDBWrapper.WriteToDB(testWrapper.t, writeBatch)
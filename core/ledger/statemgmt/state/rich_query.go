@@ -0,0 +1,72 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/richquery"
+	"github.com/spf13/viper"
+)
+
+// richQueryEnabledFromConfig reads 'ledger.state.richQuery.enabled'
+func richQueryEnabledFromConfig() bool {
+	return viper.GetBool("ledger.state.richQuery.enabled")
+}
+
+// initRichQueryIndex builds the document index of the rich queries from the committed
+// state if it is enabled and does not cover the state yet, and clears it if it is
+// disabled, so that it is never out of date when turned back on
+func initRichQueryIndex(enabled bool) error {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return err
+	}
+	built, err := richquery.IsBuilt(openchainDB)
+	if err != nil {
+		return err
+	}
+	if enabled && !built {
+		logger.Info("Building the rich query document index from the committed state")
+		return richquery.Build(openchainDB, stateImpl)
+	}
+	if !enabled && built {
+		logger.Info("Rich queries are disabled, clearing the document index")
+		return richquery.Clear(openchainDB)
+	}
+	return nil
+}
+
+// IsRichQueryEnabled tells whether the document index of the rich queries is kept
+func (state *State) IsRichQueryEnabled() bool {
+	return state.richQueryEnabled
+}
+
+// GetQueryResult returns an iterator over the committed values of the chaincode that
+// are JSON objects selected by the rich query, in the order of the keys
+func (state *State) GetQueryResult(chaincodeID string, query *richquery.Query) (*richquery.QueryIterator, error) {
+	if !state.richQueryEnabled {
+		return nil, fmt.Errorf("Rich queries are not enabled")
+	}
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	return richquery.NewQueryIterator(openchainDB, chaincodeID, query), nil
+}
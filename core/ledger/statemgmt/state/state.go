@@ -20,15 +20,14 @@ import (
 	"encoding/binary"
 	"fmt"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/buckettree"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/raw"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/richquery"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/trie"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
-	"github.com/hyperledger/fabric/core/db/rocksdb"
-	"github.com/hyperledger/fabric/core/comm"
 )
 
 var logger = logging.MustGetLogger("state")
@@ -54,8 +53,10 @@ type State struct {
 	currentTxStateDelta   *statemgmt.StateDelta
 	currentTxID           string
 	txStateDeltaHash      map[string][]byte
+	txStateDeltas         map[string]*statemgmt.StateDelta
 	updateStateImpl       bool
 	historyStateDeltaSize uint64
+	richQueryEnabled      bool
 }
 
 // NewState constructs a new State. This Initializes encapsulated state implementation
@@ -76,8 +77,13 @@ func NewState() *State {
 	if err != nil {
 		panic(fmt.Errorf("Error during initialization of state implementation: %s", err))
 	}
+	richQueryEnabled := richQueryEnabledFromConfig()
+	err = initRichQueryIndex(richQueryEnabled)
+	if err != nil {
+		panic(fmt.Errorf("Error during initialization of the rich query index: %s", err))
+	}
 	return &State{stateImpl, statemgmt.NewStateDelta(), statemgmt.NewStateDelta(), "", make(map[string][]byte),
-		false, uint64(deltaHistorySize)}
+		make(map[string]*statemgmt.StateDelta), false, uint64(deltaHistorySize), richQueryEnabled}
 }
 
 // TxBegin marks begin of a new tx. If a tx is already in progress, this call panics
@@ -100,6 +106,7 @@ func (state *State) TxFinish(txID string, txSuccessful bool) {
 			logger.Debugf("txFinish() for txId [%s] merging state changes", txID)
 			state.stateDelta.ApplyChanges(state.currentTxStateDelta)
 			state.txStateDeltaHash[txID] = state.currentTxStateDelta.ComputeCryptoHash()
+			state.txStateDeltas[txID] = state.currentTxStateDelta
 			state.updateStateImpl = true
 		} else {
 			state.txStateDeltaHash[txID] = nil
@@ -146,6 +153,27 @@ func (state *State) GetRangeScanIterator(chaincodeID string, startKey string, en
 		stateImplItr), nil
 }
 
+// GetRangeScanPage returns a page of the keys (and values) between startKey and endKey for a
+// chaincodeID, in lexical order of the keys or in reverse. The page starts after the bookmark
+// of the options, and the iterator gives the bookmark of the next page. The committed flag
+// has the same meaning as in GetRangeScanIterator
+func (state *State) GetRangeScanPage(chaincodeID string, startKey string, endKey string, committed bool,
+	options statemgmt.RangeScanOptions) (*statemgmt.PagedRangeScanIterator, error) {
+	// the keys of the page are on the side of the bookmark the scan moves towards
+	if options.Bookmark != "" {
+		if !options.Reverse && options.Bookmark > startKey {
+			startKey = options.Bookmark
+		} else if options.Reverse && (endKey == "" || options.Bookmark < endKey) {
+			endKey = options.Bookmark
+		}
+	}
+	itr, err := state.GetRangeScanIterator(chaincodeID, startKey, endKey, committed)
+	if err != nil {
+		return nil, err
+	}
+	return statemgmt.NewPagedRangeScanIterator(itr, options), nil
+}
+
 // Set sets state to given value for chaincodeID and key. Does not immediately writes to DB
 func (state *State) Set(chaincodeID string, key string, value []byte) error {
 	logger.Debugf("set() chaincodeID=[%s], key=[%s], value=[%#v]", chaincodeID, key, value)
@@ -257,10 +285,18 @@ func (state *State) GetTxStateDeltaHash() map[string][]byte {
 	return state.txStateDeltaHash
 }
 
+// GetTxStateDeltas returns the changes made by each successful tx since the most
+// recent call to ClearInMemoryChanges, by txID. Txs that did not change the state
+// are not included
+func (state *State) GetTxStateDeltas() map[string]*statemgmt.StateDelta {
+	return state.txStateDeltas
+}
+
 // ClearInMemoryChanges remove from memory all the changes to state
 func (state *State) ClearInMemoryChanges(changesPersisted bool) {
 	state.stateDelta = statemgmt.NewStateDelta()
 	state.txStateDeltaHash = make(map[string][]byte)
+	state.txStateDeltas = make(map[string]*statemgmt.StateDelta)
 	state.stateImpl.ClearWorkingSet(changesPersisted)
 }
 
@@ -269,15 +305,24 @@ func (state *State) getStateDelta() *statemgmt.StateDelta {
 	return state.stateDelta
 }
 
+// GetProof returns a Merkle proof of the committed value of the key against the
+// crypto-hash of the committed state
+func (state *State) GetProof(chaincodeID string, key string) (*statemgmt.StateProof, error) {
+	return state.stateImpl.GetProof(chaincodeID, key)
+}
+
 // GetSnapshot returns a snapshot of the global state for the current block. stateSnapshot.Release()
 // must be called once you are done.
-func (state *State) GetSnapshot(blockNumber uint64, dbSnapshot *gorocksdb.Snapshot) (*StateSnapshot, error) {
+func (state *State) GetSnapshot(blockNumber uint64, dbSnapshot db.Snapshot) (*StateSnapshot, error) {
 	return newStateSnapshot(blockNumber, dbSnapshot)
 }
 
 // FetchStateDeltaFromDB fetches the StateDelta corrsponding to given blockNumber
 func (state *State) FetchStateDeltaFromDB(blockNumber uint64) (*statemgmt.StateDelta, error) {
-	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
 	//openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
 
 	stateDeltaBytes, err := openchainDB.GetFromStateDelta(encodeStateDeltaKey(blockNumber))
@@ -293,25 +338,25 @@ func (state *State) FetchStateDeltaFromDB(blockNumber uint64) (*statemgmt.StateD
 }
 
 // AddChangesForPersistence adds key-value pairs to writeBatch
-func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch *gorocksdb.WriteBatch) {
+func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch db.WriteBatch) {
 	logger.Debug("state.addChangesForPersistence()...start")
 	if state.updateStateImpl {
 		state.stateImpl.PrepareWorkingSet(state.stateDelta)
 		state.updateStateImpl = false
 	}
 	state.stateImpl.AddChangesForPersistence(writeBatch)
+	if state.richQueryEnabled {
+		richquery.AddChangesForPersistence(state.stateDelta, writeBatch)
+	}
 
 	serializedStateDelta := state.stateDelta.Marshal()
-	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
-	openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
-
-	cf := openchainDB_ptr.StateDeltaCF
+	cf := db.StateDeltaCF
 	logger.Debugf("Adding state-delta corresponding to block number[%d]", blockNumber)
-	writeBatch.PutCF(cf, encodeStateDeltaKey(blockNumber), serializedStateDelta)
+	writeBatch.Put(cf, encodeStateDeltaKey(blockNumber), serializedStateDelta)
 	if blockNumber >= state.historyStateDeltaSize {
 		blockNumberToDelete := blockNumber - state.historyStateDeltaSize
 		logger.Debugf("Deleting state-delta corresponding to block number[%d]", blockNumberToDelete)
-		writeBatch.DeleteCF(cf, encodeStateDeltaKey(blockNumberToDelete))
+		writeBatch.Delete(cf, encodeStateDeltaKey(blockNumberToDelete))
 	} else {
 		logger.Debugf("Not deleting previous state-delta. Block number [%d] is smaller than historyStateDeltaSize [%d]",
 			blockNumber, state.historyStateDeltaSize)
@@ -319,6 +364,12 @@ func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch *gor
 	logger.Debug("state.addChangesForPersistence()...finished")
 }
 
+// DeleteStateDeltaForPersistence adds the deletion of the state-delta of the given
+// block number to writeBatch. This is used when the block is pruned
+func (state *State) DeleteStateDeltaForPersistence(blockNumber uint64, writeBatch db.WriteBatch) {
+	writeBatch.Delete(db.StateDeltaCF, encodeStateDeltaKey(blockNumber))
+}
+
 // ApplyStateDelta applies already prepared stateDelta to the existing state.
 // This is an in memory change only. state.CommitStateDelta must be used to
 // commit the state to the DB. This method is to be used in state transfer.
@@ -335,15 +386,17 @@ func (state *State) CommitStateDelta() error {
 		state.updateStateImpl = false
 	}
 
-	writeBatch := gorocksdb.NewWriteBatch()
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return err
+	}
+	writeBatch := openchainDB.NewWriteBatch()
 	defer writeBatch.Destroy()
 	state.stateImpl.AddChangesForPersistence(writeBatch)
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
-	openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
-
-	return openchainDB_ptr.DB.Write(opt, writeBatch)
+	if state.richQueryEnabled {
+		richquery.AddChangesForPersistence(state.stateDelta, writeBatch)
+	}
+	return writeBatch.Commit(db.AsyncWrite)
 }
 
 // DeleteState deletes ALL state keys/values from the DB. This is generally
@@ -351,12 +404,20 @@ func (state *State) CommitStateDelta() error {
 // a snapshot.
 func (state *State) DeleteState() error {
 	state.ClearInMemoryChanges(false)
-	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return err
+	}
 	//openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
 
-	err := openchainDB.DeleteState()
+	err = openchainDB.DeleteState()
 	if err != nil {
 		logger.Errorf("Error deleting state: %s", err)
+		return err
+	}
+	if state.richQueryEnabled {
+		// the documents of the deleted state are dropped from the index
+		err = richquery.Build(openchainDB, state.stateImpl)
 	}
 	return err
 }
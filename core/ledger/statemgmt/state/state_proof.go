@@ -0,0 +1,45 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/buckettree"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/trie"
+)
+
+// VerifyStateProof checks a proof returned by HashableState.GetProof against the state
+// hash of a block, with the verifier of the state implementation that produced it. It
+// returns nil if the proof shows that the key has the value of the proof. A proof
+// without a value is rejected, as the absence of a key cannot be proven. It does not
+// need a ledger, so that a client holding a trusted block can check the response of a
+// peer
+func VerifyStateProof(stateHash []byte, proof *statemgmt.StateProof) error {
+	if proof == nil {
+		return fmt.Errorf("No proof given")
+	}
+	switch proof.Type {
+	case buckettree.ProofType:
+		return buckettree.VerifyProof(stateHash, proof)
+	case trie.ProofType:
+		return trie.VerifyProof(stateHash, proof)
+	default:
+		return fmt.Errorf("Unknown state proof type [%s]", proof.Type)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+// StateProof is a Merkle proof of the value of a key against the crypto-hash of the
+// state, which is the StateHash of a block. It is returned by HashableState.GetProof
+// and holds everything needed to recompute the crypto-hash, so that it can be verified
+// without access to the state. There are no proofs of a key not being set: the
+// crypto-hash of a node does not bind its children to their positions, so the hash of
+// the node on the path of the key could be listed at another position. The proof can
+// be serialized to JSON
+type StateProof struct {
+	// Type is the name of the state implementation that produced the proof
+	Type        string `json:"type"`
+	ChaincodeID string `json:"chaincodeID"`
+	Key         string `json:"key"`
+	// Value is the value of the key
+	Value []byte `json:"value"`
+	// Neighbours are the other key-values that are hashed together with the key
+	Neighbours []*StateProofKeyValue `json:"neighbours,omitempty"`
+	// Path holds the nodes from the lowest node of the key up to the root
+	Path []*StateProofNode `json:"path"`
+	// Params are the configurations of the state implementation the proof depends on
+	Params map[string]int `json:"params,omitempty"`
+}
+
+// StateProofKeyValue is a key-value of a StateProof
+type StateProofKeyValue struct {
+	ChaincodeID string `json:"chaincodeID"`
+	Key         string `json:"key"`
+	Value       []byte `json:"value"`
+}
+
+// StateProofNode is a node on the path of a StateProof
+type StateProofNode struct {
+	// Value is the value kept in the node itself, if any
+	Value []byte `json:"value"`
+	// ChildrenCryptoHashes are the crypto-hashes of the children of the node, by index,
+	// except for the child on the path, which is recomputed by the verifier
+	ChildrenCryptoHashes map[int][]byte `json:"childrenCryptoHashes,omitempty"`
+}
@@ -0,0 +1,121 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trie
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// ProofType is the type of the proofs of the state trie
+const ProofType = "trie"
+
+// A trie proof holds the nodes on the path of the key as Path, from the node of the key
+// up to the root. The value of the node of the key is the Value of the proof
+
+// GetProof - method implementation for interface 'statemgmt.HashableState'
+func (stateTrie *StateTrie) GetProof(chaincodeID string, key string) (*statemgmt.StateProof, error) {
+	proof := &statemgmt.StateProof{Type: ProofType, ChaincodeID: chaincodeID, Key: key}
+	fullKey := newTrieKey(chaincodeID, key)
+	var nodes []*trieNode
+	for level := 0; level <= fullKey.getLevel(); level++ {
+		trieNode, err := fetchTrieNodeFromDB(proofTrieKeyAt(fullKey, level))
+		if err != nil {
+			return nil, err
+		}
+		if trieNode == nil {
+			break
+		}
+		nodes = append(nodes, trieNode)
+	}
+
+	for i := len(nodes) - 1; i >= 0; i-- {
+		trieNode := nodes[i]
+		proofNode := &statemgmt.StateProofNode{Value: trieNode.value, ChildrenCryptoHashes: make(map[int][]byte)}
+		if trieNode.getLevel() == fullKey.getLevel() {
+			proof.Value = trieNode.value
+			proofNode.Value = nil
+		}
+		pathIndex := -1
+		if i < len(nodes)-1 {
+			pathIndex = nodes[i+1].getIndexInParent()
+		}
+		for index, childCryptoHash := range trieNode.childrenCryptoHashes {
+			if index != pathIndex {
+				proofNode.ChildrenCryptoHashes[index] = childCryptoHash
+			}
+		}
+		proof.Path = append(proof.Path, proofNode)
+	}
+	if proof.Value == nil {
+		return nil, fmt.Errorf("Key [%s] of chaincode [%s] is not set, there is no proof of its absence", key, chaincodeID)
+	}
+	return proof, nil
+}
+
+// VerifyProof recomputes the crypto-hash of the state from a proof returned by GetProof,
+// and returns an error unless it is stateHash. It does not depend on the data of the
+// local state
+func VerifyProof(stateHash []byte, proof *statemgmt.StateProof) error {
+	if proof.Value == nil {
+		return fmt.Errorf("Proof has no value, the absence of a key cannot be proven")
+	}
+	fullKey := newTrieKey(proof.ChaincodeID, proof.Key)
+	deepestLevel := len(proof.Path) - 1
+	if deepestLevel != fullKey.getLevel() {
+		return fmt.Errorf("Proof has [%d] levels, the key has [%d]", len(proof.Path), fullKey.getLevel()+1)
+	}
+	var cryptoHash []byte
+	for i, proofNode := range proof.Path {
+		level := deepestLevel - i
+		trieNode := newTrieNode(proofTrieKeyAt(fullKey, level), proofNode.Value, false)
+		for index, childCryptoHash := range proofNode.ChildrenCryptoHashes {
+			trieNode.childrenCryptoHashes[index] = childCryptoHash
+		}
+		var pathIndex int
+		if level < fullKey.getLevel() {
+			pathIndex = proofTrieKeyAt(fullKey, level+1).getIndexInParent()
+			if _, ok := trieNode.childrenCryptoHashes[pathIndex]; ok {
+				return fmt.Errorf("Node at level [%d] of proof holds the hash of the child on the path", level)
+			}
+		}
+		if i == 0 {
+			if proofNode.Value != nil {
+				return fmt.Errorf("The value of the key must be the value of the proof")
+			}
+			trieNode.value = proof.Value
+		} else {
+			trieNode.childrenCryptoHashes[pathIndex] = cryptoHash
+		}
+		cryptoHash = trieNode.computeCryptoHash()
+	}
+	if !bytes.Equal(cryptoHash, stateHash) {
+		return fmt.Errorf("Proof does not match the state hash: computed [%x], expected [%x]", cryptoHash, stateHash)
+	}
+	return nil
+}
+
+// proofTrieKeyAt returns the trie key of the ancestor at the given level of a key
+func proofTrieKeyAt(fullKey *trieKey, level int) *trieKey {
+	key := &trieKey{fullKey.trieKeyImpl}
+	for key.getLevel() > level {
+		key = key.getParentTrieKey()
+	}
+	return key
+}
@@ -0,0 +1,86 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trie
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestStateTrie_GetProof(t *testing.T) {
+	testDBWrapper.CleanDB(t)
+	stateTrie := NewStateImpl()
+	stateTrieTestWrapper := &stateTrieTestWrapper{stateTrie, t}
+	stateDelta := statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	stateDelta.Set("chaincodeID1", "key2", []byte("value2"), nil)
+	stateDelta.Set("chaincodeID1", "key", []byte("value"), nil)
+	stateDelta.Set("chaincodeID2", "key3", []byte("value3"), nil)
+	rootHash := stateTrieTestWrapper.PrepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateTrieTestWrapper.PersistChangesAndResetInMemoryChanges()
+
+	for _, kv := range [][]string{{"chaincodeID1", "key1", "value1"}, {"chaincodeID1", "key2", "value2"},
+		{"chaincodeID1", "key", "value"}, {"chaincodeID2", "key3", "value3"}} {
+		proof, err := stateTrie.GetProof(kv[0], kv[1])
+		testutil.AssertNoError(t, err, "Error while getting proof")
+		testutil.AssertEquals(t, proof.Value, []byte(kv[2]))
+		testutil.AssertNoError(t, VerifyProof(rootHash, proof), "Error while verifying proof")
+	}
+
+	// keys that are not set, below and beside existing nodes, have no proofs
+	for _, kv := range [][]string{{"chaincodeID1", "key12"}, {"chaincodeID1", "ke"}, {"chaincodeID3", "key"}} {
+		_, err := stateTrie.GetProof(kv[0], kv[1])
+		testutil.AssertError(t, err, "Proof of a key that is not set should fail")
+	}
+
+	// the proof survives serialization
+	proof, _ := stateTrie.GetProof("chaincodeID1", "key1")
+	serializedProof, err := json.Marshal(proof)
+	testutil.AssertNoError(t, err, "Error while marshalling proof")
+	deserializedProof := &statemgmt.StateProof{}
+	testutil.AssertNoError(t, json.Unmarshal(serializedProof, deserializedProof), "Error while unmarshalling proof")
+	testutil.AssertNoError(t, VerifyProof(rootHash, deserializedProof), "Error while verifying deserialized proof")
+
+	// tampered proofs
+	deserializedProof.Value = []byte("anotherValue")
+	testutil.AssertError(t, VerifyProof(rootHash, deserializedProof), "Proof with a tampered value should fail")
+	proof, _ = stateTrie.GetProof("chaincodeID1", "key1")
+	proof.Value = nil
+	testutil.AssertError(t, VerifyProof(rootHash, proof), "Proof that hides the value should fail")
+	proof, _ = stateTrie.GetProof("chaincodeID1", "key1")
+	proof.Key = "key2"
+	testutil.AssertError(t, VerifyProof(rootHash, proof), "Proof for another key should fail")
+}
+
+func TestStateTrie_GetProofForgedAbsence(t *testing.T) {
+	testDBWrapper.CleanDB(t)
+	stateTrie := NewStateImpl()
+	stateTrieTestWrapper := &stateTrieTestWrapper{stateTrie, t}
+	stateDelta := statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	rootHash := stateTrieTestWrapper.PrepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateTrieTestWrapper.PersistChangesAndResetInMemoryChanges()
+
+	// the hash of the only key passes up to the root, list it beside the path of the key
+	pathIndex := proofTrieKeyAt(newTrieKey("chaincodeID1", "key1"), 1).getIndexInParent()
+	proof := &statemgmt.StateProof{Type: ProofType, ChaincodeID: "chaincodeID1", Key: "key1",
+		Path: []*statemgmt.StateProofNode{{ChildrenCryptoHashes: map[int][]byte{pathIndex + 1: rootHash}}}}
+	testutil.AssertError(t, VerifyProof(rootHash, proof), "Forged proof of absence should fail")
+}
@@ -33,7 +33,10 @@ type RangeScanIterator struct {
 }
 
 func newRangeScanIterator(chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
-	db, _ := db.Registry.Get(comm.DbPluginName())
+	db, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
 	dbItr := db.GetStateIterator()
 	//dbItr := db.GetDBHandle().GetStateCFIterator()
 	encodedStartKey := newTrieKey(chaincodeID, startKey).getEncodedBytes()
@@ -17,11 +17,9 @@ limitations under the License.
 package trie
 
 import (
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
-	//"github.com/hyperledger/fabric/core/db/rocksdb"
-	"github.com/hyperledger/fabric/core/comm"
 )
 
 // StateSnapshotIterator implements the interface 'statemgmt.StateSnapshotIterator'
@@ -31,12 +29,12 @@ type StateSnapshotIterator struct {
 	currentValue []byte
 }
 
-func newStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (*StateSnapshotIterator, error) {
-	db, _ := db.Registry.Get(comm.DbPluginName())
-	dbItr := db.GetStateSnapshotIterator(snapshot)
-	//openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
-
-	//dbItr := db.GetDBHandle().GetStateCFSnapshotIterator(snapshot)
+func newStateSnapshotIterator(snapshot db.Snapshot) (*StateSnapshotIterator, error) {
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	dbItr := openchainDB.GetStateSnapshotIterator(snapshot)
 	dbItr.SeekToFirst()
 	// skip the root key, because, the value test in Next method is misleading for root key as the value field
 	dbItr.Next()
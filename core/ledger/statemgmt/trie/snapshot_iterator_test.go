@@ -19,6 +19,7 @@ package trie
 import (
 	"testing"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
@@ -48,7 +49,8 @@ func TestStateSnapshotIterator(t *testing.T) {
 	testutil.AssertEquals(t, stateTrieTestWrapper.Get("chaincodeID6", "key6"), []byte("value6"))
 
 	// take db snapeshot
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	dbSnapshot := openchainDB.GetSnapshot()
 
 	stateDelta1 := statemgmt.NewStateDelta()
 	// delete a few keys
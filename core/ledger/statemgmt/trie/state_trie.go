@@ -22,9 +22,6 @@ import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
-	"github.com/hyperledger/fabric/core/db/rocksdb"
-	"github.com/hyperledger/fabric/core/comm"
 )
 
 var stateTrieLogger = logging.MustGetLogger("stateTrie")
@@ -143,7 +140,7 @@ func (stateTrie *StateTrie) processChangedNode(changedNode *trieNode) error {
 }
 
 // AddChangesForPersistence commits current changes to the database
-func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch db.WriteBatch) error {
 	if stateTrie.recomputeCryptoHash {
 		_, err := stateTrie.ComputeCryptoHash()
 		if err != nil {
@@ -155,23 +152,19 @@ func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch *gorocksdb.Write
 		stateTrieLogger.Info("trieDelta is nil. Not writing anything to DB")
 		return nil
 	}
-	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
-	openchainDB_ptr := openchainDB.(*rocksdb.OpenchainRocksDB)
-
-	//openchainDB := db.GetDBHandle()
 	lowestLevel := stateTrie.trieDelta.getLowestLevel()
 	for level := lowestLevel; level >= 0; level-- {
 		changedNodes := stateTrie.trieDelta.deltaMap[level]
 		for _, changedNode := range changedNodes {
 			if changedNode.markedForDeletion {
-				writeBatch.DeleteCF(openchainDB_ptr.StateCF, changedNode.trieKey.getEncodedBytes())
+				writeBatch.Delete(db.StateCF, changedNode.trieKey.getEncodedBytes())
 				continue
 			}
 			serializedContent, err := changedNode.marshal()
 			if err != nil {
 				return err
 			}
-			writeBatch.PutCF(openchainDB_ptr.StateCF, changedNode.trieKey.getEncodedBytes(), serializedContent)
+			writeBatch.Put(db.StateCF, changedNode.trieKey.getEncodedBytes(), serializedContent)
 		}
 	}
 	stateTrieLogger.Debug("Added changes to DB")
@@ -184,7 +177,7 @@ func (stateTrie *StateTrie) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (stateTrie *StateTrie) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+func (stateTrie *StateTrie) GetStateSnapshotIterator(snapshot db.Snapshot) (statemgmt.StateSnapshotIterator, error) {
 	return newStateSnapshotIterator(snapshot)
 }
 
@@ -24,7 +24,10 @@ import (
 func fetchTrieNodeFromDB(key *trieKey) (*trieNode, error) {
 	stateTrieLogger.Debugf("Enter fetchTrieNodeFromDB() for trieKey [%s]", key)
 	//openchainDB := db.GetDBHandle()
-	openchainDB, _ := db.Registry.Get(comm.DbPluginName())
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
 
 	trieNodeBytes, err := openchainDB.GetFromState(key.getEncodedBytes())
 	if err != nil {
@@ -24,6 +24,10 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/hyperledger/fabric/core/db"
+	_ "github.com/hyperledger/fabric/core/db/boltdb"
+	_ "github.com/hyperledger/fabric/core/db/leveldb"
+	_ "github.com/hyperledger/fabric/core/db/memory"
+	_ "github.com/hyperledger/fabric/core/db/rocksdb"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
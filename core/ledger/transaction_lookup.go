@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// MaxTransactionPageSize is the largest number of transactions returned by one lookup
+const MaxTransactionPageSize = 100
+
+// TransactionPage is one page of the transactions found by a lookup, in the order
+// of the chain
+type TransactionPage struct {
+	Transactions []*protos.Transaction `json:"transactions"`
+	// Bookmark is passed to the next lookup to get the following page. It is empty
+	// on the last page
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+// getTransactionsByLookupKey returns the transactions indexed under lookupPrefix that are
+// in the blocks [fromBlock, toBlock]. bookmark is "" for the first page, and the Bookmark
+// of the previous page otherwise. A limit outside (0, MaxTransactionPageSize] is taken
+// as MaxTransactionPageSize
+func (blockchain *blockchain) getTransactionsByLookupKey(lookupPrefix []byte,
+	fromBlock uint64, toBlock uint64, bookmark string, limit int) (*TransactionPage, error) {
+	if limit <= 0 || limit > MaxTransactionPageSize {
+		limit = MaxTransactionPageSize
+	}
+	page := &TransactionPage{}
+	if blockchain.getSize() == 0 {
+		return page, nil
+	}
+	if toBlock >= blockchain.getSize() {
+		toBlock = blockchain.getSize() - 1
+	}
+	if fromBlock > toBlock {
+		return page, nil
+	}
+	start := encodeTxLocation(fromBlock, 0)
+	if bookmark != "" {
+		location, err := decodeBookmark(bookmark)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Compare(location, start) > 0 {
+			start = location
+		}
+	}
+
+	err := blockchain.indexer.waitForIndexes(toBlock)
+	if err != nil {
+		return nil, err
+	}
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	itr := openchainDB.GetIndexesIterator()
+	defer itr.Close()
+
+	var block *protos.Block
+	var cachedBlockNumber uint64
+	for itr.Seek(append(lookupPrefix, start...)); itr.ValidForPrefix(lookupPrefix); itr.Next() {
+		location := itr.KeyData()[len(lookupPrefix):]
+		blockNumber, txIndex, err := decodeTxLocation(location)
+		if err != nil {
+			return nil, err
+		}
+		if blockNumber > toBlock {
+			break
+		}
+		if len(page.Transactions) == limit {
+			page.Bookmark = hex.EncodeToString(location)
+			break
+		}
+		if block == nil || blockNumber != cachedBlockNumber {
+			block, err = blockchain.getBlock(blockNumber)
+			if err != nil {
+				return nil, err
+			}
+			if block == nil {
+				return nil, fmt.Errorf("Block [%d] of an indexed transaction is missing", blockNumber)
+			}
+			cachedBlockNumber = blockNumber
+		}
+		transactions := block.GetTransactions()
+		if txIndex >= uint64(len(transactions)) {
+			return nil, fmt.Errorf("Block [%d] has no transaction at index [%d]", blockNumber, txIndex)
+		}
+		page.Transactions = append(page.Transactions, transactions[txIndex])
+	}
+	return page, nil
+}
+
+func decodeBookmark(bookmark string) ([]byte, error) {
+	location, err := hex.DecodeString(bookmark)
+	if err == nil {
+		_, _, err = decodeTxLocation(location)
+	}
+	if err != nil {
+		return nil, newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("ledger: invalid bookmark [%s]", bookmark))
+	}
+	return location, nil
+}
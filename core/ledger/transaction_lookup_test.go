@@ -0,0 +1,117 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/protos"
+)
+
+func buildTestECert(t *testing.T, enrollmentID string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutil.AssertNoError(t, err, "Error while generating a key")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: enrollmentID},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	testutil.AssertNoError(t, err, "Error while creating a certificate")
+	return cert
+}
+
+func buildTestTxForChaincode(t *testing.T, chaincodeName string, cert []byte) (*protos.Transaction, string) {
+	uuid := util.GenerateUUID()
+	tx, err := protos.NewTransaction(protos.ChaincodeID{Name: chaincodeName}, uuid, "anyfunction", []string{"param1"})
+	testutil.AssertNoError(t, err, "Error while building a transaction")
+	tx.Cert = cert
+	return tx, uuid
+}
+
+func txIDsOf(page *TransactionPage) []string {
+	txIDs := []string{}
+	for _, tx := range page.Transactions {
+		txIDs = append(txIDs, tx.Txid)
+	}
+	return txIDs
+}
+
+func TestGetTransactionsByChaincodeID(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	aliceCert := buildTestECert(t, "alice")
+	bobCert := buildTestECert(t, "bob")
+
+	// block i holds a transaction of cc1 by alice and one of cc2 by bob
+	var cc1TxIDs, cc2TxIDs []string
+	for i := 0; i < 5; i++ {
+		ledger.BeginTxBatch(i)
+		tx1, txID1 := buildTestTxForChaincode(t, "cc1", aliceCert)
+		tx2, txID2 := buildTestTxForChaincode(t, "cc2", bobCert)
+		ledger.CommitTxBatch(i, []*protos.Transaction{tx1, tx2}, nil, []byte("proof"))
+		cc1TxIDs = append(cc1TxIDs, txID1)
+		cc2TxIDs = append(cc2TxIDs, txID2)
+	}
+
+	page, err := ledger.GetTransactionsByChaincodeID("cc1", 0, 100, "", 0)
+	testutil.AssertNoError(t, err, "Error while looking up the transactions of cc1")
+	testutil.AssertEquals(t, txIDsOf(page), cc1TxIDs)
+	testutil.AssertEquals(t, page.Bookmark, "")
+
+	page, err = ledger.GetTransactionsByChaincodeID("cc2", 1, 3, "", 0)
+	testutil.AssertNoError(t, err, "Error while looking up the transactions of cc2")
+	testutil.AssertEquals(t, txIDsOf(page), cc2TxIDs[1:4])
+
+	// paging
+	page, err = ledger.GetTransactionsByChaincodeID("cc1", 0, 100, "", 2)
+	testutil.AssertNoError(t, err, "Error while looking up the first page")
+	testutil.AssertEquals(t, txIDsOf(page), cc1TxIDs[0:2])
+	testutil.AssertNotEquals(t, page.Bookmark, "")
+	page, err = ledger.GetTransactionsByChaincodeID("cc1", 0, 100, page.Bookmark, 2)
+	testutil.AssertNoError(t, err, "Error while looking up the second page")
+	testutil.AssertEquals(t, txIDsOf(page), cc1TxIDs[2:4])
+	page, err = ledger.GetTransactionsByChaincodeID("cc1", 0, 100, page.Bookmark, 2)
+	testutil.AssertNoError(t, err, "Error while looking up the last page")
+	testutil.AssertEquals(t, txIDsOf(page), cc1TxIDs[4:])
+	testutil.AssertEquals(t, page.Bookmark, "")
+
+	_, err = ledger.GetTransactionsByChaincodeID("cc1", 0, 100, "not a bookmark", 2)
+	testutil.AssertError(t, err, "An invalid bookmark should be rejected")
+
+	page, err = ledger.GetTransactionsByChaincodeID("cc3", 0, 100, "", 0)
+	testutil.AssertNoError(t, err, "Error while looking up an unknown chaincode")
+	testutil.AssertEquals(t, len(page.Transactions), 0)
+
+	// submitter
+	page, err = ledger.GetTransactionsBySubmitterCert(bobCert, 0, 100, "", 0)
+	testutil.AssertNoError(t, err, "Error while looking up the transactions of a certificate")
+	testutil.AssertEquals(t, txIDsOf(page), cc2TxIDs)
+	page, err = ledger.GetTransactionsByEnrollmentID("alice", 2, 100, "", 0)
+	testutil.AssertNoError(t, err, "Error while looking up the transactions of an enrollment ID")
+	testutil.AssertEquals(t, txIDsOf(page), cc1TxIDs[2:])
+}
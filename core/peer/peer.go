@@ -818,14 +818,20 @@ type Persistor interface {
 
 // Store enables a peer to persist the given key,value pair to the database
 func (p *Impl) Store(key string, value []byte) error {
-	db := db.GetDBHandle()
-	return db.Put(db.PersistCF, []byte(key), value)
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return err
+	}
+	return openchainDB.PutToPersistence([]byte(key), value)
 }
 
 // Load enables a peer to read the value that corresponds to the given database key
 func (p *Impl) Load(key string) ([]byte, error) {
-	db := db.GetDBHandle()
-	return db.Get(db.PersistCF, []byte(key))
+	openchainDB, err := db.Registry.Get(comm.DbPluginName())
+	if err != nil {
+		return nil, err
+	}
+	return openchainDB.GetFromPersistence([]byte(key))
 }
 
 // =============================================================================
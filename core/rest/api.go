@@ -24,6 +24,7 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger"
 	pb "github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
@@ -100,27 +101,9 @@ func (s *ServerOpenchain) GetBlockByNumber(ctx context.Context, num *pb.BlockNum
 	// calls more lightweight as the payload for these types of transactions
 	// can be very large. If the payload is needed, the caller should fetch the
 	// individual transaction.
-	blockTransactions := block.GetTransactions()
-	for _, transaction := range blockTransactions {
-		if transaction.Type == pb.Transaction_CHAINCODE_DEPLOY {
-			deploymentSpec := &pb.ChaincodeDeploymentSpec{}
-			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
-			if err != nil {
-				if !viper.GetBool("security.privacy") {
-					return nil, err
-				}
-				//if privacy is enabled, payload is encrypted and unmarshal will
-				//likely fail... given we were going to just set the CodePackage
-				//to nil anyway, just recover and continue
-				deploymentSpec = &pb.ChaincodeDeploymentSpec{}
-			}
-			deploymentSpec.CodePackage = nil
-			deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
-			if err != nil {
-				return nil, err
-			}
-			transaction.Payload = deploymentSpecBytes
-		}
+	err = removeCodePackages(block.GetTransactions())
+	if err != nil {
+		return nil, err
 	}
 
 	return block, nil
@@ -143,6 +126,11 @@ func (s *ServerOpenchain) GetBlockCount(ctx context.Context, e *google_protobuf.
 	return nil, fmt.Errorf("No blocks in blockchain.")
 }
 
+// GetIndexerStatus returns how far the indexing of the committed blocks is
+func (s *ServerOpenchain) GetIndexerStatus(ctx context.Context) *ledger.IndexerStatus {
+	return s.ledger.GetIndexerStatus()
+}
+
 // GetState returns the value for a particular chaincode ID and key
 func (s *ServerOpenchain) GetState(ctx context.Context, chaincodeID, key string) ([]byte, error) {
 	return s.ledger.GetState(chaincodeID, key, true)
@@ -162,6 +150,111 @@ func (s *ServerOpenchain) GetTransactionByID(ctx context.Context, txID string) (
 	return transaction, nil
 }
 
+// GetTransactionsByChaincodeID returns a page of the transactions of a chaincode in the
+// blocks [fromBlock, toBlock]. As for blocks, the code package of deploy transactions
+// is removed
+func (s *ServerOpenchain) GetTransactionsByChaincodeID(ctx context.Context, chaincodeID string, fromBlock, toBlock uint64, bookmark string, limit int) (*ledger.TransactionPage, error) {
+	page, err := s.ledger.GetTransactionsByChaincodeID(chaincodeID, fromBlock, toBlock, bookmark, limit)
+	if err != nil {
+		if isInvalidArgument(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Error retrieving transactions from blockchain: %s", err)
+	}
+	err = removeCodePackages(page.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetTransactionsByEnrollmentID returns a page of the transactions submitted with the
+// enrollment certificate of a user in the blocks [fromBlock, toBlock]
+func (s *ServerOpenchain) GetTransactionsByEnrollmentID(ctx context.Context, enrollmentID string, fromBlock, toBlock uint64, bookmark string, limit int) (*ledger.TransactionPage, error) {
+	page, err := s.ledger.GetTransactionsByEnrollmentID(enrollmentID, fromBlock, toBlock, bookmark, limit)
+	if err != nil {
+		if isInvalidArgument(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Error retrieving transactions from blockchain: %s", err)
+	}
+	err = removeCodePackages(page.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetQueryResult returns the committed values of a chaincode that are JSON objects
+// selected by a rich query, in the order of the keys
+func (s *ServerOpenchain) GetQueryResult(ctx context.Context, chaincodeID string, query string) ([]*pb.RangeQueryStateKeyValue, error) {
+	itr, err := s.ledger.GetQueryResult(chaincodeID, query)
+	if err != nil {
+		if isInvalidArgument(err) || isRichQueryNotEnabled(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Error querying the state: %s", err)
+	}
+	defer itr.Close()
+	var results []*pb.RangeQueryStateKeyValue
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		results = append(results, &pb.RangeQueryStateKeyValue{Key: key, Value: value})
+	}
+	return results, nil
+}
+
+// isInvalidArgument tells whether the ledger rejected the arguments of a call, such as
+// the bookmark of a transaction lookup
+func isInvalidArgument(err error) bool {
+	ledgerErr, ok := err.(*ledger.Error)
+	return ok && ledgerErr.Type() == ledger.ErrorTypeInvalidArgument
+}
+
+// isRichQueryNotEnabled tells whether the ledger does not keep the document index of
+// the rich queries
+func isRichQueryNotEnabled(err error) bool {
+	ledgerErr, ok := err.(*ledger.Error)
+	return ok && ledgerErr.Type() == ledger.ErrorTypeRichQueryNotEnabled
+}
+
+// removeCodePackages replaces the payload of deploy transactions with the deployment
+// spec without its code package
+func removeCodePackages(transactions []*pb.Transaction) error {
+	for _, transaction := range transactions {
+		if transaction.Type == pb.Transaction_CHAINCODE_DEPLOY {
+			deploymentSpec := &pb.ChaincodeDeploymentSpec{}
+			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
+			if err != nil {
+				if !viper.GetBool("security.privacy") {
+					return err
+				}
+				//if privacy is enabled, payload is encrypted and unmarshal will
+				//likely fail... given we were going to just set the CodePackage
+				//to nil anyway, just recover and continue
+				deploymentSpec = &pb.ChaincodeDeploymentSpec{}
+			}
+			deploymentSpec.CodePackage = nil
+			deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
+			if err != nil {
+				return err
+			}
+			transaction.Payload = deploymentSpecBytes
+		}
+	}
+	return nil
+}
+
+// GetDatastoreStats returns the size, the number of keys and the compaction details
+// of the datastore the ledger is kept in
+func (s *ServerOpenchain) GetDatastoreStats(ctx context.Context) (*db.Stats, error) {
+	stats, err := s.ledger.GetDatastoreStats()
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving datastore stats: %s", err)
+	}
+	return stats, nil
+}
+
 // GetPeers returns a list of all peer nodes currently connected to the target peer.
 func (s *ServerOpenchain) GetPeers(ctx context.Context, e *google_protobuf.Empty) (*pb.PeersMessage, error) {
 	return s.peerInfo.GetPeers()
@@ -23,6 +23,7 @@ import (
 	"os"
 	"testing"
 
+	_ "github.com/hyperledger/fabric/core/db/memory"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
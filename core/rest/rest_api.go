@@ -23,6 +23,7 @@ import (
 	"google/protobuf"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
@@ -42,6 +43,7 @@ import (
 	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/ledger"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -653,6 +655,15 @@ func (s *ServerOpenchainREST) GetBlockchainInfo(rw web.ResponseWriter, req *web.
 	}
 }
 
+// GetIndexerStatus returns how far the indexing of the committed blocks is, with the
+// lag and the failures of the asynchronous indexer
+func (s *ServerOpenchainREST) GetIndexerStatus(rw web.ResponseWriter, req *web.Request) {
+	status := s.server.GetIndexerStatus(context.Background())
+
+	rw.WriteHeader(http.StatusOK)
+	json.NewEncoder(rw).Encode(status)
+}
+
 // GetBlockByNumber returns the data contained within a specific block in the
 // blockchain. The genesis block is block zero.
 func (s *ServerOpenchainREST) GetBlockByNumber(rw web.ResponseWriter, req *web.Request) {
@@ -718,6 +729,118 @@ func (s *ServerOpenchainREST) GetTransactionByID(rw web.ResponseWriter, req *web
 	}
 }
 
+// GetTransactionsByChaincodeID returns a page of the transactions that deployed or
+// invoked a chaincode. The optional query parameters fromBlock and toBlock bound the
+// blocks searched, limit bounds the page size, and bookmark is the bookmark of the
+// previous page
+func (s *ServerOpenchainREST) GetTransactionsByChaincodeID(rw web.ResponseWriter, req *web.Request) {
+	chaincodeID := req.PathParams["id"]
+	s.getTransactionPage(rw, req, func(fromBlock, toBlock uint64, bookmark string, limit int) (*ledger.TransactionPage, error) {
+		return s.server.GetTransactionsByChaincodeID(context.Background(), chaincodeID, fromBlock, toBlock, bookmark, limit)
+	})
+}
+
+// queryResult is a value selected by a rich query. The value is a JSON object and is
+// written as is
+type queryResult struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// GetQueryResult returns the values of the state of a chaincode that are JSON objects
+// selected by the rich query in the request body, in the order of the keys. The peer
+// must run with 'ledger.state.richQuery.enabled'
+func (s *ServerOpenchainREST) GetQueryResult(rw web.ResponseWriter, req *web.Request) {
+	chaincodeID := req.PathParams["id"]
+	encoder := json.NewEncoder(rw)
+
+	reqBody, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: "Internal JSON error when reading request body."})
+		restLogger.Errorf("Internal JSON error when reading request body: %v", err)
+		return
+	}
+
+	results, err := s.server.GetQueryResult(context.Background(), chaincodeID, string(reqBody))
+	if err != nil {
+		switch {
+		case isInvalidArgument(err):
+			rw.WriteHeader(http.StatusBadRequest)
+		case isRichQueryNotEnabled(err):
+			rw.WriteHeader(http.StatusNotImplemented)
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			restLogger.Errorf("Error running rich query: %s", err)
+		}
+		encoder.Encode(restResult{Error: err.Error()})
+		return
+	}
+
+	queryResults := []*queryResult{}
+	for _, result := range results {
+		queryResults = append(queryResults, &queryResult{Key: result.Key, Value: json.RawMessage(result.Value)})
+	}
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(queryResults)
+}
+
+// GetTransactionsByEnrollmentID returns a page of the transactions submitted with the
+// enrollment certificate of a user, with the query parameters of GetTransactionsByChaincodeID
+func (s *ServerOpenchainREST) GetTransactionsByEnrollmentID(rw web.ResponseWriter, req *web.Request) {
+	enrollmentID := req.PathParams["id"]
+	s.getTransactionPage(rw, req, func(fromBlock, toBlock uint64, bookmark string, limit int) (*ledger.TransactionPage, error) {
+		return s.server.GetTransactionsByEnrollmentID(context.Background(), enrollmentID, fromBlock, toBlock, bookmark, limit)
+	})
+}
+
+// getTransactionPage parses the paging query parameters of a transaction lookup and
+// writes the page returned by lookup
+func (s *ServerOpenchainREST) getTransactionPage(rw web.ResponseWriter, req *web.Request,
+	lookup func(fromBlock, toBlock uint64, bookmark string, limit int) (*ledger.TransactionPage, error)) {
+	encoder := json.NewEncoder(rw)
+	query := req.URL.Query()
+
+	fromBlock := uint64(0)
+	toBlock := uint64(math.MaxUint64)
+	limit := 0
+	var err error
+	if value := query.Get("fromBlock"); value != "" {
+		fromBlock, err = strconv.ParseUint(value, 10, 64)
+	}
+	if value := query.Get("toBlock"); err == nil && value != "" {
+		toBlock, err = strconv.ParseUint(value, 10, 64)
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		encoder.Encode(restResult{Error: "fromBlock and toBlock must be integers (uint64)."})
+		return
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			rw.WriteHeader(http.StatusBadRequest)
+			encoder.Encode(restResult{Error: "limit must be a positive integer."})
+			return
+		}
+	}
+
+	page, err := lookup(fromBlock, toBlock, query.Get("bookmark"), limit)
+	if err != nil {
+		if isInvalidArgument(err) {
+			rw.WriteHeader(http.StatusBadRequest)
+		} else {
+			rw.WriteHeader(http.StatusInternalServerError)
+			restLogger.Errorf("Error retrieving transactions: %s", err)
+		}
+		encoder.Encode(restResult{Error: err.Error()})
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+	encoder.Encode(page)
+}
+
 // Deploy first builds the chaincode package and subsequently deploys it to the
 // blockchain.
 //
@@ -1710,6 +1833,26 @@ func (s *ServerOpenchainREST) GetPeers(rw web.ResponseWriter, req *web.Request)
 	}
 }
 
+// GetDatastoreStats returns the size, the number of keys of each table and the
+// compaction details of the datastore the ledger is kept in
+func (s *ServerOpenchainREST) GetDatastoreStats(rw web.ResponseWriter, req *web.Request) {
+	stats, err := s.server.GetDatastoreStats(context.Background())
+
+	encoder := json.NewEncoder(rw)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		encoder.Encode(restResult{Error: err.Error()})
+		restLogger.Errorf("Error: Querying datastore stats -- %s", err)
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder.Encode(stats)
+	}
+}
+
 // NotFound returns a custom landing page when a given hyperledger end point
 // had not been defined.
 func (s *ServerOpenchainREST) NotFound(rw web.ResponseWriter, r *web.Request) {
@@ -1730,9 +1873,13 @@ func buildOpenchainRESTRouter() *web.Router {
 	router.Delete("/registrar/:id", (*ServerOpenchainREST).DeleteEnrollmentID)
 	router.Get("/registrar/:id/ecert", (*ServerOpenchainREST).GetEnrollmentCert)
 	router.Get("/registrar/:id/tcert", (*ServerOpenchainREST).GetTransactionCert)
+	router.Get("/registrar/:id/transactions", (*ServerOpenchainREST).GetTransactionsByEnrollmentID)
 
 	router.Get("/chain", (*ServerOpenchainREST).GetBlockchainInfo)
+	router.Get("/chain/indexes", (*ServerOpenchainREST).GetIndexerStatus)
 	router.Get("/chain/blocks/:id", (*ServerOpenchainREST).GetBlockByNumber)
+	router.Get("/chain/chaincodes/:id/transactions", (*ServerOpenchainREST).GetTransactionsByChaincodeID)
+	router.Post("/chain/chaincodes/:id/query", (*ServerOpenchainREST).GetQueryResult)
 
 	// The /devops endpoint is now considered deprecated and superseded by the /chaincode endpoint
 	router.Post("/devops/deploy", (*ServerOpenchainREST).Deploy)
@@ -1746,6 +1893,8 @@ func buildOpenchainRESTRouter() *web.Router {
 
 	router.Get("/network/peers", (*ServerOpenchainREST).GetPeers)
 
+	router.Get("/datastore", (*ServerOpenchainREST).GetDatastoreStats)
+
 	// Add not found page
 	router.NotFound((*ServerOpenchainREST).NotFound)
 
@@ -29,6 +29,7 @@ import (
 
 	"golang.org/x/net/context"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/protos"
 )
@@ -322,6 +323,62 @@ func TestServerOpenchainREST_API_GetTransactionByUUID(t *testing.T) {
 	}
 }
 
+func TestServerOpenchainREST_API_GetTransactionsByChaincodeID(t *testing.T) {
+	ledger := ledger.InitTestLedger(t)
+	var txIDs []string
+	for i := 0; i < 3; i++ {
+		ledger.BeginTxBatch(i)
+		tx, err := protos.NewTransaction(protos.ChaincodeID{Name: "mycc"}, generateUUID(t), "invoke", []string{"a"})
+		if err != nil {
+			t.Fatalf("Error creating NewTransaction: %s", err)
+		}
+		ledger.CommitTxBatch(i, []*protos.Transaction{tx}, nil, []byte("dummy-proof"))
+		txIDs = append(txIDs, tx.Txid)
+	}
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	body := performHTTPGet(t, httpServer.URL+"/chain/chaincodes/mycc/transactions?fromBlock=1&limit=1")
+	var page struct {
+		Transactions []*protos.Transaction
+		Bookmark     string
+	}
+	err := json.Unmarshal(body, &page)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if len(page.Transactions) != 1 || page.Transactions[0].Txid != txIDs[1] {
+		t.Fatalf("Expected transaction %s as the first page but got %v", txIDs[1], page.Transactions)
+	}
+	if page.Bookmark == "" {
+		t.Fatalf("Expected a bookmark for the next page but got none")
+	}
+
+	body = performHTTPGet(t, httpServer.URL+"/chain/chaincodes/mycc/transactions?fromBlock=1&limit=1&bookmark="+page.Bookmark)
+	page.Bookmark = ""
+	err = json.Unmarshal(body, &page)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if len(page.Transactions) != 1 || page.Transactions[0].Txid != txIDs[2] {
+		t.Fatalf("Expected transaction %s as the second page but got %v", txIDs[2], page.Transactions)
+	}
+	if page.Bookmark != "" {
+		t.Errorf("Expected no bookmark on the last page but got %s", page.Bookmark)
+	}
+
+	for _, query := range []string{"?fromBlock=first", "?limit=0", "?bookmark=wrong"} {
+		res := parseRESTResult(t, performHTTPGet(t, httpServer.URL+"/chain/chaincodes/mycc/transactions"+query))
+		if res.Error == "" {
+			t.Errorf("Expected an error for the query %s but got none", query)
+		}
+	}
+}
+
 func TestServerOpenchainREST_API_Register(t *testing.T) {
 	os.RemoveAll(getRESTFilePath())
 	initGlobalServerOpenchain(t)
@@ -475,6 +532,59 @@ func TestServerOpenchainREST_API_GetPeers(t *testing.T) {
 	}
 }
 
+func TestServerOpenchainREST_API_GetDatastoreStats(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	body := performHTTPGet(t, httpServer.URL+"/datastore")
+	var stats db.Stats
+	err := json.Unmarshal(body, &stats)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if len(stats.Tables) != len(db.ColumnFamilies) {
+		t.Fatalf("Expected stats for %d tables but got %d", len(db.ColumnFamilies), len(stats.Tables))
+	}
+	for _, table := range stats.Tables {
+		if table.Name == db.BlockchainCF && table.Keys == 0 {
+			t.Errorf("Expected the blocks in the blockchainCF stats but got no keys")
+		}
+	}
+}
+
+func TestServerOpenchainREST_API_GetIndexerStatus(t *testing.T) {
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	initGlobalServerOpenchain(t)
+
+	// Start the HTTP REST test server
+	httpServer := httptest.NewServer(buildOpenchainRESTRouter())
+	defer httpServer.Close()
+
+	body := performHTTPGet(t, httpServer.URL+"/chain/indexes")
+	var status struct {
+		CommittedBlocks uint64 `json:"committedBlocks"`
+		IndexedBlocks   uint64 `json:"indexedBlocks"`
+		Lag             uint64 `json:"lag"`
+	}
+	err := json.Unmarshal(body, &status)
+	if err != nil {
+		t.Fatalf("Invalid JSON response: %v", err)
+	}
+	if status.CommittedBlocks != 3 || status.IndexedBlocks != 3 || status.Lag != 0 {
+		t.Errorf("Expected 3 blocks indexed with no lag but got %s", body)
+	}
+}
+
 func TestServerOpenchainREST_API_Chaincode_InvalidRequests(t *testing.T) {
 	// Construct a ledger with 3 blocks.
 	ledger := ledger.InitTestLedger(t)
@@ -25,7 +25,6 @@ import (
 
 	"crypto/x509"
 
-	"database/sql"
 
 	"github.com/hyperledger/fabric/flogging"
 	"github.com/op/go-logging"
@@ -69,13 +68,6 @@ func IsAttributeOID(oid asn1.ObjectIdentifier) bool {
 	return ACAAttribute[l-1] < oid[l-1]
 }
 
-func initializeACATables(db *sql.DB) error {
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Attributes (row INTEGER PRIMARY KEY, id VARCHAR(64), affiliation VARCHAR(64), attributeName VARCHAR(64), validFrom DATETIME, validTo DATETIME,  attributeValue BLOB)"); err != nil {
-		return err
-	}
-	return nil
-}
-
 //AttributeOwner is the struct that contains the data related with the user who owns the attribute.
 type AttributeOwner struct {
 	id          string
@@ -222,7 +214,7 @@ func (attrPair *AttributePair) ToACAAttribute() *pb.ACAAttribute {
 
 // NewACA sets up a new ACA.
 func NewACA() *ACA {
-	aca := &ACA{CA: NewCA("aca", initializeACATables)}
+	aca := &ACA{CA: NewCA("aca")}
 	flogging.LoggingInit("aca")
 	return aca
 }
@@ -280,49 +272,7 @@ func (aca *ACA) populateAttributes(attrs []*AttributePair) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	tx, dberr := aca.db.Begin()
-	if dberr != nil {
-		return dberr
-	}
-	for _, attr := range attrs {
-		if err := aca.populateAttribute(tx, attr); err != nil {
-			dberr = tx.Rollback()
-			if dberr != nil {
-				return dberr
-			}
-			return err
-		}
-	}
-	dberr = tx.Commit()
-	if dberr != nil {
-		return dberr
-	}
-	return nil
-}
-
-func (aca *ACA) populateAttribute(tx *sql.Tx, attr *AttributePair) error {
-	var count int
-	err := tx.QueryRow("SELECT count(row) AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?",
-		attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName()).Scan(&count)
-
-	if err != nil {
-		return err
-	}
-
-	if count > 0 {
-		_, err = tx.Exec("UPDATE Attributes SET validFrom = ?, validTo = ?,  attributeValue = ? WHERE  id=? AND affiliation =? AND attributeName =? AND validFrom < ?",
-			attr.GetValidFrom(), attr.GetValidTo(), attr.GetAttributeValue(), attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName(), attr.GetValidFrom())
-		if err != nil {
-			return err
-		}
-	} else {
-		_, err = tx.Exec("INSERT INTO Attributes (validFrom , validTo,  attributeValue, id, affiliation, attributeName) VALUES (?,?,?,?,?,?)",
-			attr.GetValidFrom(), attr.GetValidTo(), attr.GetAttributeValue(), attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName())
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return aca.store.PutAttributes(attrs)
 }
 
 func (aca *ACA) fetchAndPopulateAttributes(id, affiliation string) error {
@@ -339,31 +289,18 @@ func (aca *ACA) fetchAndPopulateAttributes(id, affiliation string) error {
 }
 
 func (aca *ACA) findAttribute(owner *AttributeOwner, attributeName string) (*AttributePair, error) {
-	var count int
-
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	err := aca.db.QueryRow("SELECT count(row) AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?",
-		owner.GetID(), owner.GetAffiliation(), attributeName).Scan(&count)
-	if err != nil {
-		return nil, err
-	}
-
-	if count == 0 {
+	attr, err := aca.store.GetAttribute(owner.GetID(), owner.GetAffiliation(), attributeName)
+	if err == ErrNotFound {
 		return nil, nil
 	}
-
-	var attName string
-	var attValue []byte
-	var validFrom, validTo time.Time
-	err = aca.db.QueryRow("SELECT attributeName, attributeValue, validFrom, validTo AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?",
-		owner.GetID(), owner.GetAffiliation(), attributeName).Scan(&attName, &attValue, &validFrom, &validTo)
 	if err != nil {
 		return nil, err
 	}
 
-	return &AttributePair{owner, attName, attValue, validFrom, validTo}, nil
+	return attr, nil
 }
 
 func (aca *ACA) startACAP(srv *grpc.Server) {
@@ -129,26 +129,15 @@ func TestFetchAttributes_MultipleInvocations(t *testing.T) {
 }
 
 func readAttributesFromDB(id string, affiliation string) (map[string][]byte, int, error) {
-	var attributeName string
-	var attributeValue []byte
-
-	query := "SELECT attributeName, attributeValue FROM attributes WHERE id=? AND affiliation=?"
-
-	rows, err := aca.db.Query(query, id, affiliation)
+	attrs, err := aca.store.GetAttributes(id, affiliation)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	defer rows.Close()
-
 	count := 0
 	attributesMap := make(map[string][]byte)
-	for rows.Next() {
-		err := rows.Scan(&attributeName, &attributeValue)
-		if err != nil {
-			return nil, 0, err
-		}
-		attributesMap[attributeName] = attributeValue
+	for _, attr := range attrs {
+		attributesMap[attr.GetAttributeName()] = attr.GetAttributeValue()
 		count++
 	}
 
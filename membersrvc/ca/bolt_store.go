@@ -0,0 +1,570 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+const boltStoreName = "boltdb"
+
+// The bolt store keeps one bucket per sqlite table. Certificates and TCert sets are keyed
+// by the id they belong to and a sequence number, so a prefix scan returns those of an
+// id in the order they were added, users by id, affiliation groups by name and
+// attributes by id, affiliation and name. The values are JSON documents.
+//
+// The certificates are also indexed by hash, by id and usage, and by id and timestamp,
+// the counterparts of the columns sqlite queries by. An index entry is keyed by the
+// indexed values and the sequence number of the certificate, and holds the key of the
+// certificate
+var (
+	certificatesBucket            = []byte("Certificates")
+	certificatesByHashBucket      = []byte("CertificatesByHash")
+	certificatesByUsageBucket     = []byte("CertificatesByUsage")
+	certificatesByTimestampBucket = []byte("CertificatesByTimestamp")
+	usersBucket                   = []byte("Users")
+	affiliationGroupsBucket       = []byte("AffiliationGroups")
+	attributesBucket              = []byte("Attributes")
+	certificateSetsBucket         = []byte("TCertificateSets")
+
+	boltBuckets = [][]byte{certificatesBucket, certificatesByHashBucket, certificatesByUsageBucket, certificatesByTimestampBucket,
+		usersBucket, affiliationGroupsBucket, attributesBucket, certificateSetsBucket}
+)
+
+const boltOpenTimeout = 10 * time.Second
+
+func init() {
+	RegisterStore(boltStoreName, newBoltStore)
+}
+
+// boltStore implements Store on an embedded bolt key-value file
+type boltStore struct {
+	db     *bolt.DB
+	file   *boltFile
+	closed bool
+}
+
+// A bolt file can only be opened once at a time, where sqlite lets a CA be opened
+// again while it is running. The stores of the same file share one bolt.DB, which
+// is closed with the last of them
+type boltFile struct {
+	path string
+	db   *bolt.DB
+	refs int
+}
+
+var (
+	boltFiles     = make(map[string]*boltFile)
+	boltFilesLock sync.Mutex
+)
+
+type certificatesByUsage []*CertificateRecord
+
+func (certs certificatesByUsage) Len() int           { return len(certs) }
+func (certs certificatesByUsage) Swap(i, j int)      { certs[i], certs[j] = certs[j], certs[i] }
+func (certs certificatesByUsage) Less(i, j int) bool { return certs[i].Usage < certs[j].Usage }
+
+type attributeRecord struct {
+	ID          string
+	Affiliation string
+	Name        string
+	Value       []byte
+	ValidFrom   time.Time
+	ValidTo     time.Time
+}
+
+func newBoltStore(path string) (Store, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	boltFilesLock.Lock()
+	defer boltFilesLock.Unlock()
+	if file, ok := boltFiles[path]; ok {
+		file.refs++
+		return &boltStore{db: file.db, file: file}, nil
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range boltBuckets {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	file := &boltFile{path, db, 1}
+	boltFiles[path] = file
+	return &boltStore{db: db, file: file}, nil
+}
+
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// idKey is the prefix of the keys of the records that belong to id
+func idKey(id string) []byte {
+	return []byte(id + "\x00")
+}
+
+// uint64Key appends value to prefix so that the keys sort in the order of the values
+func uint64Key(prefix []byte, value uint64) []byte {
+	return append(append([]byte(nil), prefix...), sequenceKey(value)...)
+}
+
+func usageKey(id string, usage x509.KeyUsage) []byte {
+	return uint64Key(idKey(id), uint64(usage))
+}
+
+// timestampKey flips the sign bit, so that negative timestamps sort first
+func timestampKey(id string, timestamp int64) []byte {
+	return uint64Key(idKey(id), uint64(timestamp)^(1<<63))
+}
+
+func attributeKey(id, affiliation, name string) []byte {
+	return []byte(id + "\x00" + affiliation + "\x00" + name)
+}
+
+// putSequenced stores value under prefix followed by the next sequence number of the
+// bucket, and returns the key and the sequence number
+func putSequenced(bucket *bolt.Bucket, prefix []byte, value interface{}) ([]byte, uint64, error) {
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return nil, 0, err
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, 0, err
+	}
+	key := uint64Key(prefix, seq)
+	return key, seq, bucket.Put(key, encoded)
+}
+
+// forEachSequenced calls fn, in the order of their sequence numbers, for the entries
+// of the bucket that are keyed by prefix followed by a sequence number
+func forEachSequenced(bucket *bolt.Bucket, prefix []byte, fn func(k, v []byte) error) error {
+	cursor := bucket.Cursor()
+	for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+		// a longer key with the same prefix belongs to other indexed values
+		if len(k) != len(prefix)+8 {
+			continue
+		}
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// certificateIndexes returns the index buckets of the certificates and the keys of the
+// index entries of cert. A certificate without a hash is not indexed by hash
+func certificateIndexes(tx *bolt.Tx, cert *CertificateRecord, seq uint64) map[*bolt.Bucket][]byte {
+	indexes := map[*bolt.Bucket][]byte{
+		tx.Bucket(certificatesByUsageBucket):     uint64Key(usageKey(cert.ID, cert.Usage), seq),
+		tx.Bucket(certificatesByTimestampBucket): uint64Key(timestampKey(cert.ID, cert.Timestamp), seq),
+	}
+	if len(cert.Hash) > 0 {
+		indexes[tx.Bucket(certificatesByHashBucket)] = uint64Key(cert.Hash, seq)
+	}
+	return indexes
+}
+
+func getCertificate(tx *bolt.Tx, key []byte) (*CertificateRecord, error) {
+	v := tx.Bucket(certificatesBucket).Get(key)
+	if v == nil {
+		return nil, fmt.Errorf("Certificate index refers to missing certificate [%x]", key)
+	}
+	cert := new(CertificateRecord)
+	if err := json.Unmarshal(v, cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// indexedCertificates returns, in the order they were added, the certificates whose
+// entries in index are keyed by prefix
+func (store *boltStore) indexedCertificates(index []byte, prefix []byte) ([]*CertificateRecord, error) {
+	var certs []*CertificateRecord
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return forEachSequenced(tx.Bucket(index), prefix, func(k, v []byte) error {
+			cert, err := getCertificate(tx, v)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+			return nil
+		})
+	})
+	return certs, err
+}
+
+// indexedCertificate returns the first certificate whose entry in index is keyed by prefix
+func (store *boltStore) indexedCertificate(index []byte, prefix []byte) ([]byte, error) {
+	certs, err := store.indexedCertificates(index, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, ErrNotFound
+	}
+	return certs[0].Cert, nil
+}
+
+func (store *boltStore) PutCertificate(cert *CertificateRecord) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		key, seq, err := putSequenced(tx.Bucket(certificatesBucket), idKey(cert.ID), cert)
+		if err != nil {
+			return err
+		}
+		for index, indexKey := range certificateIndexes(tx, cert, seq) {
+			if err = index.Put(indexKey, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (store *boltStore) GetCertificateByUsage(id string, usage x509.KeyUsage) ([]byte, error) {
+	return store.indexedCertificate(certificatesByUsageBucket, usageKey(id, usage))
+}
+
+func (store *boltStore) GetCertificateByTimestamp(id string, timestamp int64) ([]byte, error) {
+	return store.indexedCertificate(certificatesByTimestampBucket, timestampKey(id, timestamp))
+}
+
+func (store *boltStore) GetCertificateByHash(hash []byte) ([]byte, error) {
+	if len(hash) == 0 {
+		return nil, ErrNotFound
+	}
+	return store.indexedCertificate(certificatesByHashBucket, hash)
+}
+
+func (store *boltStore) GetCertificates(id string, timestamp int64) ([]*CertificateRecord, error) {
+	if timestamp == 0 {
+		var certs []*CertificateRecord
+		err := store.db.View(func(tx *bolt.Tx) error {
+			return forEachSequenced(tx.Bucket(certificatesBucket), idKey(id), func(k, v []byte) error {
+				cert := new(CertificateRecord)
+				if err := json.Unmarshal(v, cert); err != nil {
+					return err
+				}
+				certs = append(certs, cert)
+				return nil
+			})
+		})
+		return certs, err
+	}
+	certs, err := store.indexedCertificates(certificatesByTimestampBucket, timestampKey(id, timestamp))
+	if err == nil {
+		sort.Stable(certificatesByUsage(certs))
+	}
+	return certs, err
+}
+
+func (store *boltStore) GetCertificatesBetween(id string, start, end int64) ([]*CertificateRecord, error) {
+	var certs []*CertificateRecord
+	prefix := idKey(id)
+	last := timestampKey(id, end)
+	err := store.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(certificatesByTimestampBucket).Cursor()
+		for k, v := cursor.Seek(timestampKey(id, start)); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			if len(k) != len(last)+8 {
+				continue
+			}
+			if bytes.Compare(k[:len(last)], last) > 0 {
+				break
+			}
+			cert, err := getCertificate(tx, v)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+		return nil
+	})
+	return certs, err
+}
+
+func (store *boltStore) DeleteCertificates(id string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(certificatesBucket)
+		deleted := make(map[*bolt.Bucket][][]byte)
+		err := forEachSequenced(bucket, idKey(id), func(k, v []byte) error {
+			cert := new(CertificateRecord)
+			if err := json.Unmarshal(v, cert); err != nil {
+				return err
+			}
+			seq := binary.BigEndian.Uint64(k[len(k)-8:])
+			for index, indexKey := range certificateIndexes(tx, cert, seq) {
+				deleted[index] = append(deleted[index], indexKey)
+			}
+			deleted[bucket] = append(deleted[bucket], append([]byte(nil), k...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// a bucket must not be modified while a cursor iterates over it
+		for index, keys := range deleted {
+			for _, k := range keys {
+				if err = index.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (store *boltStore) putUser(user *UserRecord, mustExist bool) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(usersBucket)
+		exists := bucket.Get([]byte(user.ID)) != nil
+		if mustExist && !exists {
+			return ErrNotFound
+		}
+		if !mustExist && exists {
+			return errors.New("User is already registered")
+		}
+		encoded, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(user.ID), encoded)
+	})
+}
+
+func (store *boltStore) AddUser(user *UserRecord) error {
+	return store.putUser(user, false)
+}
+
+func (store *boltStore) UpdateUser(user *UserRecord) error {
+	return store.putUser(user, true)
+}
+
+func (store *boltStore) GetUser(id string) (*UserRecord, error) {
+	var user *UserRecord
+	err := store.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(usersBucket).Get([]byte(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		user = new(UserRecord)
+		return json.Unmarshal(v, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (store *boltStore) GetUsersByRole(role int) ([]*UserRecord, error) {
+	var users []*UserRecord
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+			user := new(UserRecord)
+			if err := json.Unmarshal(v, user); err != nil {
+				return err
+			}
+			if user.Role&role != 0 {
+				users = append(users, user)
+			}
+			return nil
+		})
+	})
+	return users, err
+}
+
+func (store *boltStore) DeleteUser(id string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).Delete([]byte(id))
+	})
+}
+
+func (store *boltStore) AddAffiliationGroup(name string, parentID int64) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(affiliationGroupsBucket)
+		// the ids start at 1, like the sqlite row ids, 0 is the parent of the root groups
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(&AffiliationGroupRecord{ID: int64(seq), Name: name, ParentID: parentID})
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(name), encoded)
+	})
+}
+
+func (store *boltStore) GetAffiliationGroup(name string) (*AffiliationGroupRecord, error) {
+	var group *AffiliationGroupRecord
+	err := store.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(affiliationGroupsBucket).Get([]byte(name))
+		if v == nil {
+			return ErrNotFound
+		}
+		group = new(AffiliationGroupRecord)
+		return json.Unmarshal(v, group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (store *boltStore) GetAffiliationGroups() ([]*AffiliationGroupRecord, error) {
+	var groups []*AffiliationGroupRecord
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(affiliationGroupsBucket).ForEach(func(k, v []byte) error {
+			group := new(AffiliationGroupRecord)
+			if err := json.Unmarshal(v, group); err != nil {
+				return err
+			}
+			groups = append(groups, group)
+			return nil
+		})
+	})
+	return groups, err
+}
+
+func (store *boltStore) PutAttributes(attrs []*AttributePair) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(attributesBucket)
+		for _, attr := range attrs {
+			key := attributeKey(attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName())
+			if v := bucket.Get(key); v != nil {
+				existing := new(attributeRecord)
+				if err := json.Unmarshal(v, existing); err != nil {
+					return err
+				}
+				if !existing.ValidFrom.Before(attr.GetValidFrom()) {
+					continue
+				}
+			}
+			encoded, err := json.Marshal(&attributeRecord{attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName(),
+				attr.GetAttributeValue(), attr.GetValidFrom(), attr.GetValidTo()})
+			if err != nil {
+				return err
+			}
+			if err = bucket.Put(key, encoded); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (record *attributeRecord) attributePair() *AttributePair {
+	return &AttributePair{&AttributeOwner{record.ID, record.Affiliation}, record.Name, record.Value, record.ValidFrom, record.ValidTo}
+}
+
+func (store *boltStore) GetAttribute(id, affiliation, name string) (*AttributePair, error) {
+	var attr *AttributePair
+	err := store.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(attributesBucket).Get(attributeKey(id, affiliation, name))
+		if v == nil {
+			return ErrNotFound
+		}
+		record := new(attributeRecord)
+		if err := json.Unmarshal(v, record); err != nil {
+			return err
+		}
+		attr = record.attributePair()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attr, nil
+}
+
+func (store *boltStore) GetAttributes(id, affiliation string) ([]*AttributePair, error) {
+	var attrs []*AttributePair
+	prefix := attributeKey(id, affiliation, "")
+	err := store.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(attributesBucket).Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			record := new(attributeRecord)
+			if err := json.Unmarshal(v, record); err != nil {
+				return err
+			}
+			attrs = append(attrs, record.attributePair())
+		}
+		return nil
+	})
+	return attrs, err
+}
+
+func (store *boltStore) PutCertificateSet(set *TCertSet) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		_, _, err := putSequenced(tx.Bucket(certificateSetsBucket), idKey(set.EnrollmentID), set)
+		return err
+	})
+}
+
+func (store *boltStore) GetCertificateSets(enrollmentID string) ([]*TCertSet, error) {
+	var sets []*TCertSet
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return forEachSequenced(tx.Bucket(certificateSetsBucket), idKey(enrollmentID), func(k, v []byte) error {
+			set := new(TCertSet)
+			if err := json.Unmarshal(v, set); err != nil {
+				return err
+			}
+			sets = append(sets, set)
+			return nil
+		})
+	})
+	return sets, err
+}
+
+func (store *boltStore) Close() error {
+	boltFilesLock.Lock()
+	defer boltFilesLock.Unlock()
+	if store.closed {
+		return nil
+	}
+	store.closed = true
+	store.file.refs--
+	if store.file.refs > 0 {
+		return nil
+	}
+	delete(boltFiles, store.file.path)
+	return store.db.Close()
+}
@@ -21,7 +21,6 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"database/sql"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
@@ -38,7 +37,6 @@ import (
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/flogging"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
-	_ "github.com/mattn/go-sqlite3" // This blank import is required to load sqlite3 driver
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 )
@@ -47,7 +45,7 @@ var caLogger = logging.MustGetLogger("ca")
 
 // CA is the base certificate authority.
 type CA struct {
-	db *sql.DB
+	store Store
 
 	path string
 
@@ -82,6 +80,7 @@ var (
 	caCountry      string
 	rootPath       string
 	caDir          string
+	caStore        string
 )
 
 // NewCertificateSpec creates a new certificate spec
@@ -132,6 +131,10 @@ func CacheConfiguration() {
 	caCountry = viper.GetString("pki.ca.subject.country")
 	rootPath = viper.GetString("server.rootpath")
 	caDir = viper.GetString("server.cadir")
+	caStore = viper.GetString("server.datastore")
+	if caStore == "" {
+		caStore = defaultStore
+	}
 }
 
 // GetID returns the spec's ID field/value
@@ -206,24 +209,8 @@ func (spec *CertificateSpec) GetExtensions() *[]pkix.Extension {
 	return spec.ext
 }
 
-// TableInitializer is a function type for table initialization
-type TableInitializer func(*sql.DB) error
-
-func initializeCommonTables(db *sql.DB) error {
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Certificates (row INTEGER PRIMARY KEY, id VARCHAR(64), timestamp INTEGER, usage INTEGER, cert BLOB, hash BLOB, kdfkey BLOB)"); err != nil {
-		return err
-	}
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Users (row INTEGER PRIMARY KEY, id VARCHAR(64), enrollmentId VARCHAR(100), role INTEGER, metadata VARCHAR(256), token BLOB, state INTEGER, key BLOB)"); err != nil {
-		return err
-	}
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS AffiliationGroups (row INTEGER PRIMARY KEY, name VARCHAR(64), parent INTEGER, FOREIGN KEY(parent) REFERENCES AffiliationGroups(row))"); err != nil {
-		return err
-	}
-	return nil
-}
-
 // NewCA sets up a new CA.
-func NewCA(name string, initTables TableInitializer) *CA {
+func NewCA(name string) *CA {
 	ca := new(CA)
 	flogging.LoggingInit("ca")
 	ca.path = filepath.Join(rootPath, caDir)
@@ -237,19 +224,11 @@ func NewCA(name string, initTables TableInitializer) *CA {
 	}
 
 	// open or create certificate database
-	db, err := sql.Open("sqlite3", ca.path+"/"+name+".db")
+	store, err := openStore(caStore, ca.path+"/"+name+".db")
 	if err != nil {
 		caLogger.Panic(err)
 	}
-
-	if err = db.Ping(); err != nil {
-		caLogger.Panic(err)
-	}
-
-	if err = initTables(db); err != nil {
-		caLogger.Panic(err)
-	}
-	ca.db = db
+	ca.store = store
 
 	// read or create signing key pair
 	priv, err := ca.readCAPrivateKey(name)
@@ -276,7 +255,7 @@ func NewCA(name string, initTables TableInitializer) *CA {
 
 // Stop Close closes down the CA.
 func (ca *CA) Stop() error {
-	err := ca.db.Close()
+	err := ca.store.Close()
 	if err == nil {
 		caLogger.Debug("Shutting down CA - Successfully")
 	} else {
@@ -395,7 +374,7 @@ func (ca *CA) persistCertificate(id string, timestamp int64, usage x509.KeyUsage
 	hash.Write(certRaw)
 	var err error
 
-	if _, err = ca.db.Exec("INSERT INTO Certificates (id, timestamp, usage, cert, hash, kdfkey) VALUES (?, ?, ?, ?, ?, ?)", id, timestamp, usage, certRaw, hash.Sum(nil), kdfKey); err != nil {
+	if err = ca.store.PutCertificate(&CertificateRecord{id, timestamp, usage, certRaw, hash.Sum(nil), kdfKey}); err != nil {
 		caLogger.Error(err)
 	}
 	return err
@@ -459,8 +438,7 @@ func (ca *CA) readCertificateByKeyUsage(id string, usage x509.KeyUsage) ([]byte,
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	var raw []byte
-	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND usage=?", id, usage).Scan(&raw)
+	raw, err := ca.store.GetCertificateByUsage(id, usage)
 
 	if err != nil {
 		caLogger.Debugf("readCertificateByKeyUsage() Error: %v", err)
@@ -475,32 +453,29 @@ func (ca *CA) readCertificateByTimestamp(id string, ts int64) ([]byte, error) {
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	var raw []byte
-	err := ca.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND timestamp=?", id, ts).Scan(&raw)
-
-	return raw, err
+	return ca.store.GetCertificateByTimestamp(id, ts)
 }
 
-func (ca *CA) readCertificates(id string, opt ...int64) (*sql.Rows, error) {
+func (ca *CA) readCertificates(id string, opt ...int64) ([]*CertificateRecord, error) {
 	caLogger.Debug("Reading certificatess for " + id + ".")
 
 	mutex.RLock()
 	defer mutex.RUnlock()
 
 	if len(opt) > 0 && opt[0] != 0 {
-		return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=? AND timestamp=? ORDER BY usage", id, opt[0])
+		return ca.store.GetCertificates(id, opt[0])
 	}
 
-	return ca.db.Query("SELECT cert, kdfkey FROM Certificates WHERE id=?", id)
+	return ca.store.GetCertificates(id, 0)
 }
 
-func (ca *CA) readCertificateSets(id string, start, end int64) (*sql.Rows, error) {
+func (ca *CA) readCertificateSets(id string, start, end int64) ([]*CertificateRecord, error) {
 	caLogger.Debug("Reading certificate sets for " + id + ".")
 
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	return ca.db.Query("SELECT cert, kdfKey, timestamp FROM Certificates WHERE id=? AND timestamp BETWEEN ? AND ? ORDER BY timestamp", id, start, end)
+	return ca.store.GetCertificatesBetween(id, start, end)
 }
 
 func (ca *CA) readCertificateByHash(hash []byte) ([]byte, error) {
@@ -509,11 +484,7 @@ func (ca *CA) readCertificateByHash(hash []byte) ([]byte, error) {
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	var raw []byte
-	row := ca.db.QueryRow("SELECT cert FROM Certificates WHERE hash=?", hash)
-	err := row.Scan(&raw)
-
-	return raw, err
+	return ca.store.GetCertificateByHash(hash)
 }
 
 func (ca *CA) isValidAffiliation(affiliation string) (bool, error) {
@@ -522,9 +493,12 @@ func (ca *CA) isValidAffiliation(affiliation string) (bool, error) {
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	var count int
-	var err error
-	err = ca.db.QueryRow("SELECT count(row) FROM AffiliationGroups WHERE name=?", affiliation).Scan(&count)
+	_, err := ca.store.GetAffiliationGroup(affiliation)
+	if err == ErrNotFound {
+		caLogger.Debug("Affiliation <" + affiliation + "> is INVALID.")
+
+		return false, nil
+	}
 	if err != nil {
 		caLogger.Debug("Affiliation <" + affiliation + "> is INVALID.")
 
@@ -532,7 +506,7 @@ func (ca *CA) isValidAffiliation(affiliation string) (bool, error) {
 	}
 	caLogger.Debug("Affiliation <" + affiliation + "> is VALID.")
 
-	return count == 1, nil
+	return true, nil
 }
 
 //
@@ -629,13 +603,12 @@ func (ca *CA) registerUserWithEnrollID(id string, enrollID string, role pb.Role,
 		tok = randomString(12)
 	}
 
-	var row int
-	err := ca.db.QueryRow("SELECT row FROM Users WHERE id=?", id).Scan(&row)
+	_, err := ca.store.GetUser(id)
 	if err == nil {
 		return "", errors.New("User is already registered")
 	}
 
-	_, err = ca.db.Exec("INSERT INTO Users (id, enrollmentId, token, role, metadata, state) VALUES (?, ?, ?, ?, ?, ?)", id, enrollID, tok, role, memberMetadata, 0)
+	err = ca.store.AddUser(&UserRecord{ID: id, EnrollmentID: enrollID, Token: []byte(tok), Role: int(role), Metadata: memberMetadata, State: 0})
 
 	if err != nil {
 		caLogger.Error(err)
@@ -652,25 +625,24 @@ func (ca *CA) registerAffiliationGroup(name string, parentName string) error {
 
 	caLogger.Debug("Registering affiliation group " + name + " parent " + parentName + ".")
 
-	var parentID int
-	var err error
-	var count int
-	err = ca.db.QueryRow("SELECT count(row) FROM AffiliationGroups WHERE name=?", name).Scan(&count)
-	if err != nil {
-		return err
-	}
-	if count > 0 {
+	var parentID int64
+	_, err := ca.store.GetAffiliationGroup(name)
+	if err == nil {
 		return errors.New("Affiliation group is already registered")
 	}
+	if err != ErrNotFound {
+		return err
+	}
 
 	if strings.Compare(parentName, "") != 0 {
-		err = ca.db.QueryRow("SELECT row FROM AffiliationGroups WHERE name=?", parentName).Scan(&parentID)
+		parent, err := ca.store.GetAffiliationGroup(parentName)
 		if err != nil {
 			return err
 		}
+		parentID = parent.ID
 	}
 
-	_, err = ca.db.Exec("INSERT INTO AffiliationGroups (name, parent) VALUES (?, ?)", name, parentID)
+	err = ca.store.AddAffiliationGroup(name, parentID)
 
 	if err != nil {
 		caLogger.Error(err)
@@ -688,15 +660,14 @@ func (ca *CA) deleteUser(id string) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	var row int
-	err := ca.db.QueryRow("SELECT row FROM Users WHERE id=?", id).Scan(&row)
+	_, err := ca.store.GetUser(id)
 	if err == nil {
-		_, err = ca.db.Exec("DELETE FROM Certificates Where id=?", id)
+		err = ca.store.DeleteCertificates(id)
 		if err != nil {
 			caLogger.Error(err)
 		}
 
-		_, err = ca.db.Exec("DELETE FROM Users WHERE row=?", row)
+		err = ca.store.DeleteUser(id)
 		if err != nil {
 			caLogger.Error(err)
 		}
@@ -705,23 +676,23 @@ func (ca *CA) deleteUser(id string) error {
 	return err
 }
 
-// readUser reads a token given an id
+// readUser reads a user given an id
 //
-func (ca *CA) readUser(id string) *sql.Row {
+func (ca *CA) readUser(id string) (*UserRecord, error) {
 	caLogger.Debug("Reading token for " + id + ".")
 
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	return ca.db.QueryRow("SELECT role, token, state, key, enrollmentId FROM Users WHERE id=?", id)
+	return ca.store.GetUser(id)
 }
 
 // readUsers reads users of a given Role
 //
-func (ca *CA) readUsers(role int) (*sql.Rows, error) {
+func (ca *CA) readUsers(role int) ([]*UserRecord, error) {
 	caLogger.Debug("Reading users matching role " + strconv.FormatInt(int64(role), 2) + ".")
 
-	return ca.db.Query("SELECT id, role FROM Users WHERE role&?!=0", role)
+	return ca.store.GetUsersByRole(role)
 }
 
 // readRole returns the user Role given a user id
@@ -732,29 +703,25 @@ func (ca *CA) readRole(id string) int {
 	mutex.RLock()
 	defer mutex.RUnlock()
 
-	var role int
-	ca.db.QueryRow("SELECT role FROM Users WHERE id=?", id).Scan(&role)
+	user, err := ca.store.GetUser(id)
+	if err != nil {
+		return 0
+	}
 
-	return role
+	return user.Role
 }
 
 func (ca *CA) readAffiliationGroups() ([]*AffiliationGroup, error) {
 	caLogger.Debug("Reading affilition groups.")
 
-	rows, err := ca.db.Query("SELECT row, name, parent FROM AffiliationGroups")
+	records, err := ca.store.GetAffiliationGroups()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 	groups := make(map[int64]*AffiliationGroup)
 
-	for rows.Next() {
-		group := new(AffiliationGroup)
-		var id int64
-		if e := rows.Scan(&id, &group.name, &group.parentID); e != nil {
-			return nil, err
-		}
-		groups[id] = group
+	for _, record := range records {
+		groups[record.ID] = &AffiliationGroup{name: record.Name, parentID: record.ParentID}
 	}
 
 	groupList := make([]*AffiliationGroup, len(groups))
@@ -806,12 +773,12 @@ func (ca *CA) canRegister(registrar string, newMemberRole string, newMemberMetad
 	defer mutex.RUnlock()
 
 	// Read the user metadata associated with 'registrar'
-	var registrarMetadataStr string
-	err := ca.db.QueryRow("SELECT metadata FROM Users WHERE id=?", registrar).Scan(&registrarMetadataStr)
+	user, err := ca.store.GetUser(registrar)
 	if err != nil {
 		caLogger.Debugf("CA.canRegister: db error: %s\n", err.Error())
 		return err
 	}
+	registrarMetadataStr := user.Metadata
 	caLogger.Debugf("CA.canRegister: registrar=%s, registrarMD=%s, newMemberRole=%s, newMemberMD=%s",
 		registrar, registrarMetadataStr, newMemberRole, newMemberMetadataStr)
 	// If isn't a registrar at all, then error
@@ -20,7 +20,6 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/x509"
-	"database/sql"
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/pem"
@@ -53,14 +52,10 @@ type ECA struct {
 	gRPCServer      *grpc.Server
 }
 
-func initializeECATables(db *sql.DB) error {
-	return initializeCommonTables(db)
-}
-
 // NewECA sets up a new ECA.
 //
 func NewECA() *ECA {
-	eca := &ECA{CA: NewCA("eca", initializeECATables)}
+	eca := &ECA{CA: NewCA("eca")}
 	flogging.LoggingInit("eca")
 
 	{
@@ -147,25 +147,17 @@ func (ecaa *ECAA) ReadUserSet(ctx context.Context, in *pb.ReadUserSetReq) (*pb.U
 		return nil, errors.New("Signature verification failed.")
 	}
 
-	rows, err := ecaa.eca.readUsers(int(in.Role))
+	records, err := ecaa.eca.readUsers(int(in.Role))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var users []*pb.User
-	if err == nil {
-		for rows.Next() {
-			var id string
-			var role int
-
-			err = rows.Scan(&id, &role)
-			users = append(users, &pb.User{Id: &pb.Identity{Id: id}, Role: pb.Role(role)})
-		}
-		err = rows.Err()
+	for _, record := range records {
+		users = append(users, &pb.User{Id: &pb.Identity{Id: record.ID}, Role: pb.Role(record.Role)})
 	}
 
-	return &pb.UserSet{Users: users}, err
+	return &pb.UserSet{Users: users}, nil
 }
 
 // RevokeCertificate revokes a certificate from the ECA.  Not yet implemented.
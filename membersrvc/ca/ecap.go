@@ -104,18 +104,15 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 	ecapLogger.Debug("gRPC ECAP:CreateCertificate")
 
 	// validate token
-	var tok, prev []byte
-	var role, state int
-	var enrollID string
-
 	id := in.Id.Id
-	err := ecap.eca.readUser(id).Scan(&role, &tok, &state, &prev, &enrollID)
+	user, err := ecap.eca.readUser(id)
 
 	if err != nil {
 		errMsg := "Identity lookup error: " + err.Error()
 		ecapLogger.Debug(errMsg)
 		return nil, errors.New(errMsg)
 	}
+	tok, prev, role, state, enrollID := user.Token, user.Key, user.Role, user.State, user.EnrollmentID
 	if !bytes.Equal(tok, in.Tok.Tok) {
 		ecapLogger.Debugf("id or token mismatch: id=%s", id)
 		return nil, errors.New("Identity or token does not match.")
@@ -132,8 +129,9 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		// initial request, create encryption challenge
 		tok = []byte(randomString(12))
 
+		user.Token, user.State, user.Key = tok, 1, in.Enc.Key
 		mutex.Lock()
-		_, err = ecap.eca.db.Exec("UPDATE Users SET token=?, state=?, key=? WHERE id=?", tok, 1, in.Enc.Key, id)
+		err = ecap.eca.store.UpdateUser(user)
 		mutex.Unlock()
 
 		if err != nil {
@@ -201,18 +199,19 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		eraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil, true)
 		if err != nil {
 			mutex.Lock()
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
+			ecap.eca.store.DeleteCertificates(id)
 			mutex.Unlock()
 			ecapLogger.Error(err)
 			return nil, err
 		}
 
+		user.State = 2
 		mutex.Lock()
-		_, err = ecap.eca.db.Exec("UPDATE Users SET state=? WHERE id=?", 2, id)
+		err = ecap.eca.store.UpdateUser(user)
 		mutex.Unlock()
 		if err != nil {
 			mutex.Lock()
-			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
+			ecap.eca.store.DeleteCertificates(id)
 			mutex.Unlock()
 			ecapLogger.Error(err)
 			return nil, err
@@ -246,22 +245,14 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 func (ecap *ECAP) ReadCertificatePair(ctx context.Context, in *pb.ECertReadReq) (*pb.CertPair, error) {
 	ecapLogger.Debug("gRPC ECAP:ReadCertificate")
 
-	rows, err := ecap.eca.readCertificates(in.Id.Id)
-	defer rows.Close()
+	records, err := ecap.eca.readCertificates(in.Id.Id)
 
-	hasResults := false
 	var certs [][]byte
-	if err == nil {
-		for rows.Next() {
-			hasResults = true
-			var raw []byte
-			err = rows.Scan(&raw)
-			certs = append(certs, raw)
-		}
-		err = rows.Err()
+	for _, record := range records {
+		certs = append(certs, record.Cert)
 	}
 
-	if !hasResults {
+	if len(certs) < 2 {
 		return nil, errors.New("No certificates for the given identity were found.")
 	}
 	return &pb.CertPair{Sign: certs[0], Enc: certs[1]}, err
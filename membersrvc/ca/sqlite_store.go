@@ -0,0 +1,303 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // This blank import is required to load sqlite3 driver
+)
+
+const sqliteStoreName = "sqlite3"
+
+func init() {
+	RegisterStore(sqliteStoreName, newSQLiteStore)
+}
+
+// sqliteStore implements Store on a sqlite3 database
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(path string) (Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err = initializeTables(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db}, nil
+}
+
+func initializeTables(db *sql.DB) error {
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Certificates (row INTEGER PRIMARY KEY, id VARCHAR(64), timestamp INTEGER, usage INTEGER, cert BLOB, hash BLOB, kdfkey BLOB)"); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Users (row INTEGER PRIMARY KEY, id VARCHAR(64), enrollmentId VARCHAR(100), role INTEGER, metadata VARCHAR(256), token BLOB, state INTEGER, key BLOB)"); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS AffiliationGroups (row INTEGER PRIMARY KEY, name VARCHAR(64), parent INTEGER, FOREIGN KEY(parent) REFERENCES AffiliationGroups(row))"); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Attributes (row INTEGER PRIMARY KEY, id VARCHAR(64), affiliation VARCHAR(64), attributeName VARCHAR(64), validFrom DATETIME, validTo DATETIME,  attributeValue BLOB)"); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS TCertificateSets (row INTEGER PRIMARY KEY, enrollmentID VARCHAR(64), timestamp INTEGER, nonce BLOB, kdfkey BLOB)"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (store *sqliteStore) PutCertificate(cert *CertificateRecord) error {
+	_, err := store.db.Exec("INSERT INTO Certificates (id, timestamp, usage, cert, hash, kdfkey) VALUES (?, ?, ?, ?, ?, ?)",
+		cert.ID, cert.Timestamp, cert.Usage, cert.Cert, cert.Hash, cert.KDFKey)
+	return err
+}
+
+func (store *sqliteStore) GetCertificateByUsage(id string, usage x509.KeyUsage) ([]byte, error) {
+	var raw []byte
+	err := store.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND usage=?", id, usage).Scan(&raw)
+	return raw, err
+}
+
+func (store *sqliteStore) GetCertificateByTimestamp(id string, timestamp int64) ([]byte, error) {
+	var raw []byte
+	err := store.db.QueryRow("SELECT cert FROM Certificates WHERE id=? AND timestamp=?", id, timestamp).Scan(&raw)
+	return raw, err
+}
+
+func (store *sqliteStore) GetCertificateByHash(hash []byte) ([]byte, error) {
+	var raw []byte
+	err := store.db.QueryRow("SELECT cert FROM Certificates WHERE hash=?", hash).Scan(&raw)
+	return raw, err
+}
+
+func (store *sqliteStore) GetCertificates(id string, timestamp int64) ([]*CertificateRecord, error) {
+	if timestamp != 0 {
+		return store.queryCertificates("SELECT id, timestamp, usage, cert, hash, kdfkey FROM Certificates WHERE id=? AND timestamp=? ORDER BY usage", id, timestamp)
+	}
+	return store.queryCertificates("SELECT id, timestamp, usage, cert, hash, kdfkey FROM Certificates WHERE id=? ORDER BY row", id)
+}
+
+func (store *sqliteStore) GetCertificatesBetween(id string, start, end int64) ([]*CertificateRecord, error) {
+	return store.queryCertificates("SELECT id, timestamp, usage, cert, hash, kdfkey FROM Certificates WHERE id=? AND timestamp BETWEEN ? AND ? ORDER BY timestamp", id, start, end)
+}
+
+func (store *sqliteStore) queryCertificates(query string, args ...interface{}) ([]*CertificateRecord, error) {
+	rows, err := store.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var certs []*CertificateRecord
+	for rows.Next() {
+		cert := new(CertificateRecord)
+		if err = rows.Scan(&cert.ID, &cert.Timestamp, &cert.Usage, &cert.Cert, &cert.Hash, &cert.KDFKey); err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, rows.Err()
+}
+
+func (store *sqliteStore) DeleteCertificates(id string) error {
+	_, err := store.db.Exec("DELETE FROM Certificates Where id=?", id)
+	return err
+}
+
+func (store *sqliteStore) AddUser(user *UserRecord) error {
+	_, err := store.db.Exec("INSERT INTO Users (id, enrollmentId, token, role, metadata, state, key) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		user.ID, user.EnrollmentID, user.Token, user.Role, user.Metadata, user.State, user.Key)
+	return err
+}
+
+func (store *sqliteStore) UpdateUser(user *UserRecord) error {
+	result, err := store.db.Exec("UPDATE Users SET enrollmentId=?, token=?, role=?, metadata=?, state=?, key=? WHERE id=?",
+		user.EnrollmentID, user.Token, user.Role, user.Metadata, user.State, user.Key, user.ID)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err == nil && updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (store *sqliteStore) GetUser(id string) (*UserRecord, error) {
+	user := new(UserRecord)
+	err := store.db.QueryRow("SELECT id, enrollmentId, role, metadata, token, state, key FROM Users WHERE id=?", id).
+		Scan(&user.ID, &user.EnrollmentID, &user.Role, &user.Metadata, &user.Token, &user.State, &user.Key)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (store *sqliteStore) GetUsersByRole(role int) ([]*UserRecord, error) {
+	rows, err := store.db.Query("SELECT id, enrollmentId, role, metadata, token, state, key FROM Users WHERE role&?!=0", role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*UserRecord
+	for rows.Next() {
+		user := new(UserRecord)
+		if err = rows.Scan(&user.ID, &user.EnrollmentID, &user.Role, &user.Metadata, &user.Token, &user.State, &user.Key); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (store *sqliteStore) DeleteUser(id string) error {
+	_, err := store.db.Exec("DELETE FROM Users WHERE id=?", id)
+	return err
+}
+
+func (store *sqliteStore) AddAffiliationGroup(name string, parentID int64) error {
+	_, err := store.db.Exec("INSERT INTO AffiliationGroups (name, parent) VALUES (?, ?)", name, parentID)
+	return err
+}
+
+func (store *sqliteStore) GetAffiliationGroup(name string) (*AffiliationGroupRecord, error) {
+	group := new(AffiliationGroupRecord)
+	err := store.db.QueryRow("SELECT row, name, parent FROM AffiliationGroups WHERE name=?", name).Scan(&group.ID, &group.Name, &group.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (store *sqliteStore) GetAffiliationGroups() ([]*AffiliationGroupRecord, error) {
+	rows, err := store.db.Query("SELECT row, name, parent FROM AffiliationGroups")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*AffiliationGroupRecord
+	for rows.Next() {
+		group := new(AffiliationGroupRecord)
+		if err = rows.Scan(&group.ID, &group.Name, &group.ParentID); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+func (store *sqliteStore) PutAttributes(attrs []*AttributePair) error {
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, attr := range attrs {
+		if err = putAttribute(tx, attr); err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				return rollbackErr
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func putAttribute(tx *sql.Tx, attr *AttributePair) error {
+	var count int
+	err := tx.QueryRow("SELECT count(row) AS cant FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?",
+		attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName()).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		_, err = tx.Exec("UPDATE Attributes SET validFrom = ?, validTo = ?,  attributeValue = ? WHERE  id=? AND affiliation =? AND attributeName =? AND validFrom < ?",
+			attr.GetValidFrom(), attr.GetValidTo(), attr.GetAttributeValue(), attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName(), attr.GetValidFrom())
+	} else {
+		_, err = tx.Exec("INSERT INTO Attributes (validFrom , validTo,  attributeValue, id, affiliation, attributeName) VALUES (?,?,?,?,?,?)",
+			attr.GetValidFrom(), attr.GetValidTo(), attr.GetAttributeValue(), attr.GetID(), attr.GetAffiliation(), attr.GetAttributeName())
+	}
+	return err
+}
+
+func (store *sqliteStore) GetAttribute(id, affiliation, name string) (*AttributePair, error) {
+	var attName string
+	var attValue []byte
+	var validFrom, validTo time.Time
+	err := store.db.QueryRow("SELECT attributeName, attributeValue, validFrom, validTo FROM Attributes WHERE id=? AND affiliation =? AND attributeName =?",
+		id, affiliation, name).Scan(&attName, &attValue, &validFrom, &validTo)
+	if err != nil {
+		return nil, err
+	}
+	return &AttributePair{&AttributeOwner{id, affiliation}, attName, attValue, validFrom, validTo}, nil
+}
+
+func (store *sqliteStore) GetAttributes(id, affiliation string) ([]*AttributePair, error) {
+	rows, err := store.db.Query("SELECT attributeName, attributeValue, validFrom, validTo FROM Attributes WHERE id=? AND affiliation=?", id, affiliation)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attrs []*AttributePair
+	for rows.Next() {
+		attr := &AttributePair{owner: &AttributeOwner{id, affiliation}}
+		if err = rows.Scan(&attr.attributeName, &attr.attributeValue, &attr.validFrom, &attr.validTo); err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, rows.Err()
+}
+
+func (store *sqliteStore) PutCertificateSet(set *TCertSet) error {
+	_, err := store.db.Exec("INSERT INTO TCertificateSets (enrollmentID, timestamp, nonce, kdfkey) VALUES (?, ?, ?, ?)", set.EnrollmentID, set.Ts, set.Nonce, set.Key)
+	return err
+}
+
+func (store *sqliteStore) GetCertificateSets(enrollmentID string) ([]*TCertSet, error) {
+	rows, err := store.db.Query("SELECT enrollmentID, timestamp, nonce, kdfkey FROM TCertificateSets WHERE enrollmentID=? ORDER BY row", enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sets []*TCertSet
+	for rows.Next() {
+		set := new(TCertSet)
+		if err = rows.Scan(&set.EnrollmentID, &set.Ts, &set.Nonce, &set.Key); err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	return sets, rows.Err()
+}
+
+func (store *sqliteStore) Close() error {
+	return store.db.Close()
+}
@@ -0,0 +1,160 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrNotFound is returned by a Store when no record matches a lookup. It is the
+// sql.ErrNoRows of database/sql so that the errors reported to the clients are the
+// same whatever the store
+var ErrNotFound = sql.ErrNoRows
+
+// UserRecord is a member registered with the CA
+type UserRecord struct {
+	ID           string
+	EnrollmentID string
+	Role         int
+	Metadata     string
+	Token        []byte
+	State        int
+	Key          []byte
+}
+
+// CertificateRecord is a certificate issued by the CA
+type CertificateRecord struct {
+	ID        string
+	Timestamp int64
+	Usage     x509.KeyUsage
+	Cert      []byte
+	Hash      []byte
+	KDFKey    []byte
+}
+
+// AffiliationGroupRecord is an affiliation group. ParentID is 0 for a root group
+type AffiliationGroupRecord struct {
+	ID       int64
+	Name     string
+	ParentID int64
+}
+
+// Store keeps the users, certificates, affiliation groups, attributes and TCert
+// sets of a CA. Each of ECA, TCA, ACA and TLSCA has its own Store. Lookups that do
+// not match any record return ErrNotFound
+type Store interface {
+	// PutCertificate adds a certificate
+	PutCertificate(cert *CertificateRecord) error
+	// GetCertificateByUsage returns the certificate of id with the given usage
+	GetCertificateByUsage(id string, usage x509.KeyUsage) ([]byte, error)
+	// GetCertificateByTimestamp returns the certificate of id with the given timestamp
+	GetCertificateByTimestamp(id string, timestamp int64) ([]byte, error)
+	// GetCertificateByHash returns the certificate with the given hash
+	GetCertificateByHash(hash []byte) ([]byte, error)
+	// GetCertificates returns the certificates of id in the order they were added. If
+	// timestamp is not 0 only the certificates with that timestamp are returned, in
+	// usage order
+	GetCertificates(id string, timestamp int64) ([]*CertificateRecord, error)
+	// GetCertificatesBetween returns the certificates of id with a timestamp between
+	// start and end, both included, in timestamp order
+	GetCertificatesBetween(id string, start, end int64) ([]*CertificateRecord, error)
+	// DeleteCertificates removes all the certificates of id
+	DeleteCertificates(id string) error
+
+	// AddUser adds a user. It fails if a user with the same id exists
+	AddUser(user *UserRecord) error
+	// UpdateUser replaces the user with the same id
+	UpdateUser(user *UserRecord) error
+	// GetUser returns the user with the given id
+	GetUser(id string) (*UserRecord, error)
+	// GetUsersByRole returns the users having any of the bits of role
+	GetUsersByRole(role int) ([]*UserRecord, error)
+	// DeleteUser removes the user with the given id
+	DeleteUser(id string) error
+
+	// AddAffiliationGroup adds a group under the group with the given parent id, 0
+	// for a root group
+	AddAffiliationGroup(name string, parentID int64) error
+	// GetAffiliationGroup returns the group with the given name
+	GetAffiliationGroup(name string) (*AffiliationGroupRecord, error)
+	// GetAffiliationGroups returns all the groups
+	GetAffiliationGroups() ([]*AffiliationGroupRecord, error)
+
+	// PutAttributes adds the attributes, or updates the ones already present with an
+	// older validFrom, all or nothing
+	PutAttributes(attrs []*AttributePair) error
+	// GetAttribute returns the attribute with the given name of the owner id/affiliation
+	GetAttribute(id, affiliation, name string) (*AttributePair, error)
+	// GetAttributes returns all the attributes of the owner id/affiliation
+	GetAttributes(id, affiliation string) ([]*AttributePair, error)
+
+	// PutCertificateSet adds a TCert set
+	PutCertificateSet(set *TCertSet) error
+	// GetCertificateSets returns the TCert sets of enrollmentID in the order they were added
+	GetCertificateSets(enrollmentID string) ([]*TCertSet, error)
+
+	// Close releases the store
+	Close() error
+}
+
+// StoreConstructor opens, or creates, the store kept in the file at path
+type StoreConstructor func(path string) (Store, error)
+
+// defaultStore is used when 'server.datastore' is not set
+const defaultStore = sqliteStoreName
+
+var (
+	stores     = make(map[string]StoreConstructor)
+	storesLock sync.RWMutex
+)
+
+// RegisterStore makes a store available under the given name, to be selected with
+// 'server.datastore'
+func RegisterStore(name string, constructor StoreConstructor) error {
+	storesLock.Lock()
+	defer storesLock.Unlock()
+	if _, ok := stores[name]; ok {
+		return fmt.Errorf("CA store [%s] is already registered", name)
+	}
+	stores[name] = constructor
+	return nil
+}
+
+func openStore(name string, path string) (Store, error) {
+	storesLock.RLock()
+	constructor, ok := stores[name]
+	storesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unregistered CA store: %s. Registered stores are %v", name, registeredStores())
+	}
+	return constructor(path)
+}
+
+func registeredStores() []string {
+	storesLock.RLock()
+	defer storesLock.RUnlock()
+	names := make([]string, 0, len(stores))
+	for name := range stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,291 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ca
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStores runs the same checks against every registered store
+func TestStores(t *testing.T) {
+	for _, name := range registeredStores() {
+		t.Logf("Testing store [%s]", name)
+		testStore(t, name)
+	}
+}
+
+func testStore(t *testing.T, name string) {
+	dir, err := ioutil.TempDir("", "ca-store-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.db")
+
+	store, err := openStore(name, path)
+	// the sqlite3 driver is only registered in cgo builds
+	if err != nil && name == sqliteStoreName && strings.Contains(err.Error(), "unknown driver") {
+		t.Logf("Store [%s] is not available in this build: %s", name, err)
+		return
+	}
+	if err != nil {
+		t.Fatalf("Error while opening store [%s]: %s", name, err)
+	}
+	testStoreUsers(t, store)
+	testStoreCertificates(t, store)
+	testStoreAffiliationGroups(t, store)
+	testStoreAttributes(t, store)
+	testStoreCertificateSets(t, store)
+
+	// a second store on the same file sees the same data, and the data
+	// survives closing all the stores
+	second, err := openStore(name, path)
+	if err != nil {
+		t.Fatalf("Error while opening store [%s] twice: %s", name, err)
+	}
+	store.Close()
+	if _, err = second.GetUser("alice"); err != nil {
+		t.Fatalf("Error while reading from the second store: %s", err)
+	}
+	second.Close()
+	store, err = openStore(name, path)
+	if err != nil {
+		t.Fatalf("Error while reopening store [%s]: %s", name, err)
+	}
+	defer store.Close()
+	if _, err = store.GetUser("alice"); err != nil {
+		t.Fatalf("Error while reading after reopening: %s", err)
+	}
+}
+
+func testStoreUsers(t *testing.T, store Store) {
+	if _, err := store.GetUser("alice"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for a missing user, found [%v]", err)
+	}
+	alice := &UserRecord{ID: "alice", EnrollmentID: "alice\\bank_a", Role: 1, Metadata: "{}", Token: []byte("token")}
+	if err := store.AddUser(alice); err != nil {
+		t.Fatalf("Error while adding a user: %s", err)
+	}
+	if err := store.AddUser(&UserRecord{ID: "bob", Role: 4}); err != nil {
+		t.Fatalf("Error while adding a user: %s", err)
+	}
+
+	alice.State, alice.Key = 1, []byte("key")
+	if err := store.UpdateUser(alice); err != nil {
+		t.Fatalf("Error while updating a user: %s", err)
+	}
+	if err := store.UpdateUser(&UserRecord{ID: "nobody"}); err == nil {
+		t.Fatalf("Updating a missing user should fail")
+	}
+	user, err := store.GetUser("alice")
+	if err != nil {
+		t.Fatalf("Error while reading a user: %s", err)
+	}
+	if user.EnrollmentID != alice.EnrollmentID || user.State != 1 || !bytes.Equal(user.Key, alice.Key) || !bytes.Equal(user.Token, alice.Token) {
+		t.Fatalf("Expected %+v, found %+v", alice, user)
+	}
+
+	users, err := store.GetUsersByRole(5)
+	if err != nil || len(users) != 2 {
+		t.Fatalf("Expected 2 users for role 5, found [%d], err [%v]", len(users), err)
+	}
+	users, err = store.GetUsersByRole(4)
+	if err != nil || len(users) != 1 || users[0].ID != "bob" {
+		t.Fatalf("Expected bob for role 4, found %v, err [%v]", users, err)
+	}
+
+	if err = store.DeleteUser("bob"); err != nil {
+		t.Fatalf("Error while deleting a user: %s", err)
+	}
+	if _, err = store.GetUser("bob"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for a deleted user, found [%v]", err)
+	}
+}
+
+func testStoreCertificates(t *testing.T, store Store) {
+	certs := []*CertificateRecord{
+		{"alice", 10, x509.KeyUsageDataEncipherment, []byte("enc"), []byte("hash_enc"), nil},
+		{"alice", 10, x509.KeyUsageDigitalSignature, []byte("sign"), []byte("hash_sign"), nil},
+		{"alice", 5, x509.KeyUsageKeyAgreement, []byte("tls"), []byte("hash_tls"), []byte("kdf")},
+		{"bob", 10, x509.KeyUsageDigitalSignature, []byte("bob"), []byte("hash_bob"), nil},
+		{"bob", 20, x509.KeyUsageDigitalSignature, []byte("bob_2"), []byte("hash_bob_2"), nil},
+	}
+	for _, cert := range certs {
+		if err := store.PutCertificate(cert); err != nil {
+			t.Fatalf("Error while adding a certificate: %s", err)
+		}
+	}
+
+	if raw, err := store.GetCertificateByUsage("alice", x509.KeyUsageDigitalSignature); err != nil || string(raw) != "sign" {
+		t.Fatalf("Expected [sign], found [%s], err [%v]", raw, err)
+	}
+	if raw, err := store.GetCertificateByTimestamp("alice", 5); err != nil || string(raw) != "tls" {
+		t.Fatalf("Expected [tls], found [%s], err [%v]", raw, err)
+	}
+	if raw, err := store.GetCertificateByHash([]byte("hash_bob")); err != nil || string(raw) != "bob" {
+		t.Fatalf("Expected [bob], found [%s], err [%v]", raw, err)
+	}
+	if raw, err := store.GetCertificateByHash([]byte("hash_bob_2")); err != nil || string(raw) != "bob_2" {
+		t.Fatalf("Expected [bob_2], found [%s], err [%v]", raw, err)
+	}
+	if _, err := store.GetCertificateByHash([]byte("missing")); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for a missing certificate, found [%v]", err)
+	}
+	if _, err := store.GetCertificateByHash([]byte("hash_")); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for the prefix of a hash, found [%v]", err)
+	}
+
+	assertCerts := func(found []*CertificateRecord, err error, expected ...string) {
+		if err != nil {
+			t.Fatalf("Error while reading certificates: %s", err)
+		}
+		var names []string
+		for _, cert := range found {
+			names = append(names, string(cert.Cert))
+		}
+		if strings.Join(names, ",") != strings.Join(expected, ",") {
+			t.Fatalf("Expected certificates %v, found %v", expected, names)
+		}
+	}
+	found, err := store.GetCertificates("alice", 0)
+	assertCerts(found, err, "enc", "sign", "tls")
+	found, err = store.GetCertificates("alice", 10)
+	assertCerts(found, err, "sign", "enc")
+	found, err = store.GetCertificatesBetween("alice", 0, 10)
+	assertCerts(found, err, "tls", "enc", "sign")
+	if !bytes.Equal(found[0].KDFKey, []byte("kdf")) {
+		t.Fatalf("Expected the kdf key to be kept, found [%s]", found[0].KDFKey)
+	}
+	found, err = store.GetCertificatesBetween("alice", 6, 9)
+	assertCerts(found, err)
+	found, err = store.GetCertificatesBetween("bob", 11, 30)
+	assertCerts(found, err, "bob_2")
+
+	if err = store.DeleteCertificates("alice"); err != nil {
+		t.Fatalf("Error while deleting certificates: %s", err)
+	}
+	found, err = store.GetCertificates("alice", 0)
+	assertCerts(found, err)
+	found, err = store.GetCertificates("bob", 0)
+	assertCerts(found, err, "bob", "bob_2")
+	if _, err = store.GetCertificateByHash([]byte("hash_sign")); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for a deleted certificate, found [%v]", err)
+	}
+	if _, err = store.GetCertificateByUsage("alice", x509.KeyUsageDigitalSignature); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for a deleted certificate, found [%v]", err)
+	}
+	found, err = store.GetCertificatesBetween("alice", 0, 10)
+	assertCerts(found, err)
+}
+
+func testStoreAffiliationGroups(t *testing.T, store Store) {
+	if err := store.AddAffiliationGroup("banks", 0); err != nil {
+		t.Fatalf("Error while adding a group: %s", err)
+	}
+	banks, err := store.GetAffiliationGroup("banks")
+	if err != nil || banks.ID == 0 || banks.ParentID != 0 {
+		t.Fatalf("Expected a root group with an id, found %+v, err [%v]", banks, err)
+	}
+	if err = store.AddAffiliationGroup("bank_a", banks.ID); err != nil {
+		t.Fatalf("Error while adding a group: %s", err)
+	}
+	if _, err = store.GetAffiliationGroup("bank_b"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for a missing group, found [%v]", err)
+	}
+
+	groups, err := store.GetAffiliationGroups()
+	if err != nil || len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, found [%d], err [%v]", len(groups), err)
+	}
+	for _, group := range groups {
+		if group.Name == "bank_a" && group.ParentID != banks.ID {
+			t.Fatalf("Expected bank_a under [%d], found [%d]", banks.ID, group.ParentID)
+		}
+	}
+}
+
+func testStoreAttributes(t *testing.T, store Store) {
+	owner := &AttributeOwner{"alice", "bank_a"}
+	now := time.Now().UTC().Truncate(time.Second)
+	attrs := []*AttributePair{
+		{owner, "company", []byte("ACompany"), now, now.Add(time.Hour)},
+		{owner, "position", []byte("Engineer"), now, now.Add(time.Hour)},
+		{&AttributeOwner{"alice", "bank_b"}, "company", []byte("BCompany"), now, now.Add(time.Hour)},
+	}
+	if err := store.PutAttributes(attrs); err != nil {
+		t.Fatalf("Error while adding attributes: %s", err)
+	}
+
+	// an update with an older validFrom is ignored, a newer one replaces the value
+	older := []*AttributePair{{owner, "company", []byte("Older"), now.Add(-time.Hour), now}}
+	newer := []*AttributePair{{owner, "position", []byte("Manager"), now.Add(time.Minute), now.Add(time.Hour)}}
+	if err := store.PutAttributes(older); err != nil {
+		t.Fatalf("Error while updating attributes: %s", err)
+	}
+	if err := store.PutAttributes(newer); err != nil {
+		t.Fatalf("Error while updating attributes: %s", err)
+	}
+
+	attr, err := store.GetAttribute("alice", "bank_a", "company")
+	if err != nil || string(attr.GetAttributeValue()) != "ACompany" || !attr.GetValidFrom().Equal(now) {
+		t.Fatalf("Expected [ACompany] valid from [%s], found %+v, err [%v]", now, attr, err)
+	}
+	attr, err = store.GetAttribute("alice", "bank_a", "position")
+	if err != nil || string(attr.GetAttributeValue()) != "Manager" {
+		t.Fatalf("Expected [Manager], found %+v, err [%v]", attr, err)
+	}
+	if _, err = store.GetAttribute("alice", "bank_a", "missing"); err != ErrNotFound {
+		t.Fatalf("Expected ErrNotFound for a missing attribute, found [%v]", err)
+	}
+
+	found, err := store.GetAttributes("alice", "bank_a")
+	if err != nil || len(found) != 2 {
+		t.Fatalf("Expected 2 attributes, found [%d], err [%v]", len(found), err)
+	}
+	for _, attr := range found {
+		if attr.GetID() != "alice" || attr.GetAffiliation() != "bank_a" {
+			t.Fatalf("Expected the attributes of alice/bank_a, found %s/%s", attr.GetID(), attr.GetAffiliation())
+		}
+	}
+}
+
+func testStoreCertificateSets(t *testing.T, store Store) {
+	for i, ts := range []int64{30, 10, 20} {
+		set := &TCertSet{Ts: ts, EnrollmentID: "alice", Nonce: []byte{byte(i)}, Key: []byte("key")}
+		if err := store.PutCertificateSet(set); err != nil {
+			t.Fatalf("Error while adding a TCert set: %s", err)
+		}
+	}
+	store.PutCertificateSet(&TCertSet{Ts: 40, EnrollmentID: "bob"})
+
+	sets, err := store.GetCertificateSets("alice")
+	if err != nil || len(sets) != 3 {
+		t.Fatalf("Expected 3 TCert sets, found [%d], err [%v]", len(sets), err)
+	}
+	for i, ts := range []int64{30, 10, 20} {
+		if sets[i].Ts != ts || !bytes.Equal(sets[i].Nonce, []byte{byte(i)}) {
+			t.Fatalf("Expected the TCert sets in the order they were added, found %+v at [%d]", sets[i], i)
+		}
+	}
+}
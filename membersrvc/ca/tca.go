@@ -20,7 +20,6 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/x509"
-	"database/sql"
 	"encoding/asn1"
 	"encoding/base64"
 	"errors"
@@ -70,24 +69,9 @@ type TCertSet struct {
 	Key          []byte
 }
 
-func initializeTCATables(db *sql.DB) error {
-	var err error
-
-	err = initializeCommonTables(db)
-	if err != nil {
-		return err
-	}
-
-	if _, err = db.Exec("CREATE TABLE IF NOT EXISTS TCertificateSets (row INTEGER PRIMARY KEY, enrollmentID VARCHAR(64), timestamp INTEGER, nonce BLOB, kdfkey BLOB)"); err != nil {
-		return err
-	}
-
-	return err
-}
-
 // NewTCA sets up a new TCA.
 func NewTCA(eca *ECA) *TCA {
-	tca := &TCA{NewCA("tca", initializeTCATables), eca, nil, nil, nil, nil}
+	tca := &TCA{NewCA("tca"), eca, nil, nil, nil, nil}
 	flogging.LoggingInit("tca")
 
 	err := tca.readHmacKey()
@@ -249,28 +233,14 @@ func (tca *TCA) getCertificateSets(enrollmentID string) ([]*TCertSet, error) {
 	defer mutex.RUnlock()
 
 	var sets = []*TCertSet{}
-	var err error
 
-	var rows *sql.Rows
-	rows, err = tca.retrieveCertificateSets(enrollmentID)
+	stored, err := tca.store.GetCertificateSets(enrollmentID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var enrollID string
-	var timestamp int64
-	var nonce []byte
-	var kdfKey []byte
-
-	for rows.Next() {
-		if err = rows.Scan(&enrollID, &timestamp, &nonce, &kdfKey); err != nil {
-			return nil, err
-		}
-		sets = append(sets, &TCertSet{Ts: timestamp, EnrollmentID: enrollID, Key: kdfKey})
-	}
-	if err = rows.Err(); err != nil {
-		return nil, err
+	for _, set := range stored {
+		sets = append(sets, &TCertSet{Ts: set.Ts, EnrollmentID: set.EnrollmentID, Key: set.Key})
 	}
 
 	return sets, nil
@@ -282,12 +252,8 @@ func (tca *TCA) persistCertificateSet(enrollmentID string, timestamp int64, nonc
 
 	var err error
 
-	if _, err = tca.db.Exec("INSERT INTO TCertificateSets (enrollmentID, timestamp, nonce, kdfkey) VALUES (?, ?, ?, ?)", enrollmentID, timestamp, nonce, kdfKey); err != nil {
+	if err = tca.store.PutCertificateSet(&TCertSet{Ts: timestamp, EnrollmentID: enrollmentID, Nonce: nonce, Key: kdfKey}); err != nil {
 		tcaLogger.Error(err)
 	}
 	return err
 }
-
-func (tca *TCA) retrieveCertificateSets(enrollmentID string) (*sql.Rows, error) {
-	return tca.db.Query("SELECT enrollmentID, timestamp, nonce, kdfkey FROM TCertificateSets WHERE enrollmentID=?", enrollmentID)
-}
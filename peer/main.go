@@ -36,7 +36,9 @@ import (
 	"github.com/hyperledger/fabric/peer/network"
 	"github.com/hyperledger/fabric/peer/node"
 	"github.com/hyperledger/fabric/peer/version"
-	"github.com/hyperledger/fabric/core/db"
+	_ "github.com/hyperledger/fabric/core/db/boltdb"
+	_ "github.com/hyperledger/fabric/core/db/leveldb"
+	_ "github.com/hyperledger/fabric/core/db/memory"
 	_ "github.com/hyperledger/fabric/core/db/rocksdb"
 )
 
@@ -116,26 +118,6 @@ func main() {
 
 	runtime.GOMAXPROCS(viper.GetInt("peer.gomaxprocs"))
 
-
-	//Init the db based on the configuration in core.yaml file
-
-	//Get the Datastore name that needs to be used based on core.yaml
-	dbPluginName := strings.ToLower(viper.GetString("datastore.name"))
-
-	var dbStore db.OpenchainDB
-
-
-	dbStore, err = db.Registry.Get(*dbPluginName)
-	fmt.Println(db.Registry.Registered())
-	//if err != nil {
-	//	dbStore = db.
-	//}
-	dbStore.Start()
-//when would be a good time to close. Current assumption is to close the DB call as soon as the peer process shutdown.
-	defer dbStore.Stop()
-
-
-
 	// Init the crypto layer
 	if err := crypto.Init(); err != nil {
 		panic(fmt.Errorf("Failed to initialize the crypto layer: %s", err))
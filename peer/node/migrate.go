@@ -0,0 +1,151 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	migrateFrom      string
+	migrateTo        string
+	migrateBatchSize int
+)
+
+func migrateCmd() *cobra.Command {
+	flags := nodeMigrateCmd.Flags()
+	flags.StringVar(&migrateFrom, "from", "rocksdb",
+		"Name of the datastore to copy the data from.")
+	flags.StringVar(&migrateTo, "to", "",
+		"Name of the datastore to copy the data to.")
+	flags.IntVar(&migrateBatchSize, "batch-size", db.DefaultMigrateBatchSize,
+		"Number of keys written to the target datastore per batch.")
+
+	return nodeMigrateCmd
+}
+
+var nodeMigrateCmd = &cobra.Command{
+	Use:   "migrate-db",
+	Short: "Copies the data of the peer from one datastore to another.",
+	Long: `Copies every table of the peer from one datastore to another, then checks that the
+block count, the last block hash and the state hash are the same in both. The peer must
+not be running.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return migrate(migrateFrom, migrateTo, migrateBatchSize)
+	},
+}
+
+// ledgerSummary holds the values compared between the source and the target ledgers
+type ledgerSummary struct {
+	size          uint64
+	lastBlockHash []byte
+	stateHash     []byte
+}
+
+func migrate(from string, to string, batchSize int) error {
+	if to == "" {
+		return fmt.Errorf("The target datastore must be given with --to. Registered datastores are %v", db.Registry.Registered())
+	}
+	if from == to {
+		return fmt.Errorf("The source and target datastores are the same: %s", from)
+	}
+
+	source, err := db.Registry.Open(from)
+	if err != nil {
+		return fmt.Errorf("Failed to open the source datastore: %s", err)
+	}
+	defer db.Registry.Close(from)
+	target, err := db.Registry.Open(to)
+	if err != nil {
+		return fmt.Errorf("Failed to open the target datastore: %s", err)
+	}
+	defer db.Registry.Close(to)
+
+	logger.Infof("Migrating from datastore [%s] to datastore [%s]", from, to)
+	copied, err := db.Migrate(source, target, batchSize, func(cf string, copied uint64) {
+		logger.Infof("Table [%s]: %d keys copied", cf, copied)
+	})
+	if err != nil {
+		return err
+	}
+	for _, cf := range db.ColumnFamilies {
+		fmt.Printf("%s: %d keys\n", cf, copied[cf])
+	}
+
+	sourceSummary, err := summarizeLedger(from)
+	if err != nil {
+		return fmt.Errorf("Error while reading the source ledger: %s", err)
+	}
+	targetSummary, err := summarizeLedger(to)
+	if err != nil {
+		return fmt.Errorf("Error while reading the target ledger: %s", err)
+	}
+	if sourceSummary.size != targetSummary.size {
+		return fmt.Errorf("Block count mismatch: source has %d blocks, target has %d blocks", sourceSummary.size, targetSummary.size)
+	}
+	if !bytes.Equal(sourceSummary.lastBlockHash, targetSummary.lastBlockHash) {
+		return fmt.Errorf("Last block hash mismatch: source [%x], target [%x]", sourceSummary.lastBlockHash, targetSummary.lastBlockHash)
+	}
+	if !bytes.Equal(sourceSummary.stateHash, targetSummary.stateHash) {
+		return fmt.Errorf("State hash mismatch: source [%x], target [%x]", sourceSummary.stateHash, targetSummary.stateHash)
+	}
+
+	fmt.Printf("Migrated %d blocks from %s to %s. Last block hash [%x], state hash [%x]\n",
+		targetSummary.size, from, to, targetSummary.lastBlockHash, targetSummary.stateHash)
+	return nil
+}
+
+// summarizeLedger loads the ledger kept in the named datastore, verifies its chain
+// and returns its block count, last block hash and state hash
+func summarizeLedger(name string) (*ledgerSummary, error) {
+	viper.Set("datastore.name", name)
+	if err := comm.CacheConfiguration(); err != nil {
+		return nil, err
+	}
+
+	ledgerInstance, err := ledger.GetNewLedger()
+	if err != nil {
+		return nil, err
+	}
+	summary := &ledgerSummary{size: ledgerInstance.GetBlockchainSize()}
+	if summary.size > 0 {
+		badBlock, err := ledgerInstance.VerifyChain(summary.size-1, 0)
+		if err != nil {
+			return nil, err
+		}
+		if badBlock != 0 {
+			return nil, fmt.Errorf("Chain verification failed at block %d", badBlock)
+		}
+		info, err := ledgerInstance.GetBlockchainInfo()
+		if err != nil {
+			return nil, err
+		}
+		summary.lastBlockHash = info.CurrentBlockHash
+	}
+	summary.stateHash, err = ledgerInstance.GetTempStateHash()
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
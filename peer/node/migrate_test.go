@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
+	_ "github.com/hyperledger/fabric/core/db/leveldb"
+	_ "github.com/hyperledger/fabric/core/db/memory"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func TestMigratePrunedLedger(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "fabric-migrate")
+	if err != nil {
+		t.Fatalf("Error creating a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	viper.Set("peer.fileSystemPath", tempDir)
+	viper.Set("ledger.state.deltaHistorySize", 500)
+	viper.Set("ledger.pruning.enabled", true)
+	viper.Set("ledger.pruning.retainBlocks", 3)
+	viper.Set("ledger.pruning.checkpointInterval", 2)
+	defer viper.Set("ledger.pruning.enabled", false)
+
+	viper.Set("datastore.name", "memory")
+	if err = comm.CacheConfiguration(); err != nil {
+		t.Fatalf("Error caching the configuration: %s", err)
+	}
+	if _, err = db.Registry.Open("memory"); err != nil {
+		t.Fatalf("Error opening the source datastore: %s", err)
+	}
+	source, err := ledger.GetNewLedger()
+	if err != nil {
+		t.Fatalf("Error opening the source ledger: %s", err)
+	}
+	for i := 0; i < 6; i++ {
+		txID := util.GenerateUUID()
+		tx, err := protos.NewTransaction(protos.ChaincodeID{Path: "testUrl"}, txID, "anyfunction", []string{"param1"})
+		if err != nil {
+			t.Fatalf("Error building a transaction: %s", err)
+		}
+		source.BeginTxBatch(i)
+		source.TxBegin(txID)
+		source.SetState("chaincode1", "key1", []byte{byte(i)})
+		source.TxFinished(txID, true)
+		if err = source.CommitTxBatch(i, []*protos.Transaction{tx}, nil, []byte("proof")); err != nil {
+			t.Fatalf("Error committing block %d: %s", i, err)
+		}
+	}
+	if source.GetPrunedBlockCount() == 0 {
+		t.Fatalf("Expected the source ledger to be pruned")
+	}
+
+	if err = migrate("memory", "leveldb", 2); err != nil {
+		t.Fatalf("Error migrating a pruned ledger: %s", err)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	snapshotFile       string
+	snapshotFullBlocks int
+)
+
+func snapshotCmd() *cobra.Command {
+	nodeSnapshotCmd.PersistentFlags().StringVar(&snapshotFile, "file", "",
+		"Path of the checkpoint archive.")
+	nodeSnapshotExportCmd.Flags().IntVar(&snapshotFullBlocks, "full-blocks", 0,
+		"Number of the last blocks written in full. Defaults to ledger.checkpoint.fullBlocks.")
+	nodeSnapshotCmd.AddCommand(nodeSnapshotExportCmd)
+	nodeSnapshotCmd.AddCommand(nodeSnapshotImportCmd)
+
+	return nodeSnapshotCmd
+}
+
+var nodeSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Exports or imports a checkpoint of the ledger.",
+	Long: `Exports a checkpoint archive of the ledger, or bootstraps an empty ledger from one
+without replaying the chain. The peer must not be running.`,
+}
+
+var nodeSnapshotExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Writes a checkpoint archive of the ledger.",
+	Long: `Writes the state, the block headers and the last blocks of the ledger to the
+checkpoint archive given with --file. The headers of the blocks up to the last pruned
+block without a checkpoint are left out, as they cannot be linked to the chain.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if snapshotFullBlocks != 0 {
+			viper.Set("ledger.checkpoint.fullBlocks", snapshotFullBlocks)
+		}
+		return exportSnapshot(snapshotFile)
+	},
+}
+
+var nodeSnapshotImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Installs a checkpoint archive into an empty ledger.",
+	Long: `Verifies the checkpoint archive given with --file against the state hash of its
+last block and installs it into the ledger, which must be empty. The headers of the
+blocks committed before the transactions Merkle root was introduced cannot be verified
+against their block hashes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return importSnapshot(snapshotFile)
+	},
+}
+
+func exportSnapshot(path string) error {
+	if path == "" {
+		return fmt.Errorf("The checkpoint archive must be given with --file")
+	}
+	ledgerInstance, err := openSnapshotLedger()
+	if err != nil {
+		return err
+	}
+	defer db.Registry.Close(comm.DbPluginName())
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = ledgerInstance.ExportCheckpoint(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("Failed to export a checkpoint: %s", err)
+	}
+	return printSnapshotSummary(ledgerInstance, "Exported")
+}
+
+func importSnapshot(path string) error {
+	if path == "" {
+		return fmt.Errorf("The checkpoint archive must be given with --file")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ledgerInstance, err := openSnapshotLedger()
+	if err != nil {
+		return err
+	}
+	defer db.Registry.Close(comm.DbPluginName())
+
+	if err = ledgerInstance.ImportCheckpoint(file); err != nil {
+		return fmt.Errorf("Failed to import a checkpoint: %s", err)
+	}
+	return printSnapshotSummary(ledgerInstance, "Imported")
+}
+
+func openSnapshotLedger() (*ledger.Ledger, error) {
+	if err := comm.CacheConfiguration(); err != nil {
+		return nil, err
+	}
+	if _, err := db.Registry.Open(comm.DbPluginName()); err != nil {
+		return nil, fmt.Errorf("Failed to open the datastore: %s", err)
+	}
+	ledgerInstance, err := ledger.GetNewLedger()
+	if err != nil {
+		db.Registry.Close(comm.DbPluginName())
+		return nil, err
+	}
+	return ledgerInstance, nil
+}
+
+func printSnapshotSummary(ledgerInstance *ledger.Ledger, action string) error {
+	stateHash, err := ledgerInstance.GetTempStateHash()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s a checkpoint at block %d, state hash [%x]\n", action, ledgerInstance.GetBlockchainSize()-1, stateHash)
+	return nil
+}
@@ -46,7 +46,6 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
-	"log"
 )
 
 var chaincodeDevMode bool
@@ -121,17 +120,10 @@ func serve(args []string) error {
 		logger.Infof("Privacy enabled status: false")
 	}
 
-	storename := viper.GetString("datastore.name")
-
-	dataStore, err := db.Registry.Get(storename)
-	if err != nil {
-		log.Fatalln(err)
+	if _, err := db.Registry.Open(comm.DbPluginName()); err != nil {
+		return fmt.Errorf("Failed to open the datastore: %s", err)
 	}
-
-	dataStore.Create()
-	defer dataStore.Close()
-
-	db.Start()
+	defer db.Registry.Close(comm.DbPluginName())
 
 	var opts []grpc.ServerOption
 	if comm.TLSEnabled() {
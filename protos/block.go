@@ -17,6 +17,8 @@ limitations under the License.
 package protos
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 
 	"github.com/golang/protobuf/proto"
@@ -58,8 +60,20 @@ func NewBlock(transactions []*Transaction, metadata []byte) *Block {
 	return block
 }
 
-// GetHash returns the hash of this block.
+// GetHash returns the hash of this block. The hash of a block with a
+// TransactionsMerkleRoot covers the root in place of the transactions and their
+// results, which must match the root.
 func (block *Block) GetHash() ([]byte, error) {
+	if block.TransactionsMerkleRoot != nil {
+		merkleRoot, err := block.ComputeTransactionsMerkleRoot()
+		if err != nil {
+			return nil, fmt.Errorf("Could not calculate hash of block: %s", err)
+		}
+		if !bytes.Equal(merkleRoot, block.TransactionsMerkleRoot) {
+			return nil, fmt.Errorf("Could not calculate hash of block: the transactions do not match the transactions Merkle root")
+		}
+		return hashBlockHeader(block.GetHeader())
+	}
 
 	// copy the block and remove the non-hash data
 	blockBytes, err := block.Bytes()
@@ -81,6 +95,35 @@ func (block *Block) GetHash() ([]byte, error) {
 	return hash, nil
 }
 
+// GetHeader returns a copy of this block without its transactions, their results
+// and the non-hash data. For a block with a TransactionsMerkleRoot, the hash of the
+// header is the hash of the block.
+func (block *Block) GetHeader() *Block {
+	header := *block
+	header.Transactions = nil
+	header.TransactionResults = nil
+	header.NonHashData = nil
+	return &header
+}
+
+// GetHeaderHash returns the hash of the block a header was taken from. Only blocks
+// with a TransactionsMerkleRoot can be hashed from their header, the hash of the
+// other blocks covers their transactions.
+func (block *Block) GetHeaderHash() ([]byte, error) {
+	if block.TransactionsMerkleRoot == nil {
+		return nil, fmt.Errorf("Could not calculate hash of block header: the block has no transactions Merkle root")
+	}
+	return hashBlockHeader(block.GetHeader())
+}
+
+func hashBlockHeader(header *Block) ([]byte, error) {
+	data, err := proto.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("Could not calculate hash of block: %s", err)
+	}
+	return util.ComputeCryptoHash(data), nil
+}
+
 // GetStateHash returns the stateHash stored in this block. The stateHash
 // is the value returned by state.GetHash() after running all transactions in
 // the block.
@@ -104,3 +147,207 @@ func UnmarshallBlock(blockBytes []byte) (*Block, error) {
 	}
 	return block, nil
 }
+
+// The transactions Merkle tree has a leaf per transaction of a block, the hash of the
+// transaction and of its result. Each level pairs the nodes from the left, and the odd
+// node at the end of a level moves up unchanged. The TransactionsMerkleRoot hashes the
+// number of transactions together with the top node, so that a proof cannot claim
+// another shape of the tree. Leaves, nodes and the root are hashed with distinct
+// prefixes, so that one cannot pass for another.
+const (
+	merkleLeafPrefix byte = iota
+	merkleNodePrefix
+	merkleRootPrefix
+)
+
+// SetTransactionResults records the result of each transaction of the block, matched
+// by transaction ID, and sets the TransactionsMerkleRoot over the transactions and
+// the results. A transaction without a result gets an empty one. The root of a block
+// without transactions is nil.
+func (block *Block) SetTransactionResults(transactionResults []*TransactionResult) error {
+	resultsByID := make(map[string]*TransactionResult)
+	for _, result := range transactionResults {
+		if _, ok := resultsByID[result.Txid]; !ok {
+			resultsByID[result.Txid] = result
+		}
+	}
+	block.TransactionResults = nil
+	for _, transaction := range block.Transactions {
+		result, ok := resultsByID[transaction.Txid]
+		if !ok {
+			result = &TransactionResult{Txid: transaction.Txid}
+		}
+		block.TransactionResults = append(block.TransactionResults, result)
+	}
+	merkleRoot, err := block.ComputeTransactionsMerkleRoot()
+	if err != nil {
+		return err
+	}
+	block.TransactionsMerkleRoot = merkleRoot
+	return nil
+}
+
+// ComputeTransactionsMerkleRoot computes the Merkle root over the transactions of the
+// block and their results. It returns nil for a block without transactions.
+func (block *Block) ComputeTransactionsMerkleRoot() ([]byte, error) {
+	levels, err := block.computeTransactionsMerkleLevels()
+	if err != nil || levels == nil {
+		return nil, err
+	}
+	top := levels[len(levels)-1][0]
+	return computeMerkleRoot(top, len(block.Transactions)), nil
+}
+
+// GetTransactionProof returns the proof that the transaction at the given index is in
+// this block, which is the block with the given number. The block must have a
+// TransactionsMerkleRoot.
+func (block *Block) GetTransactionProof(blockNumber uint64, index int) (*TransactionProof, error) {
+	if block.TransactionsMerkleRoot == nil {
+		return nil, fmt.Errorf("Block %d has no transactions Merkle root", blockNumber)
+	}
+	if index < 0 || index >= len(block.Transactions) {
+		return nil, fmt.Errorf("Block %d has no transaction at index %d", blockNumber, index)
+	}
+	levels, err := block.computeTransactionsMerkleLevels()
+	if err != nil {
+		return nil, err
+	}
+	proof := &TransactionProof{
+		BlockNumber:       blockNumber,
+		BlockHeader:       block.GetHeader(),
+		Index:             uint32(index),
+		TransactionCount:  uint32(len(block.Transactions)),
+		Transaction:       block.Transactions[index],
+		TransactionResult: block.TransactionResults[index],
+	}
+	position := index
+	for _, level := range levels[:len(levels)-1] {
+		sibling := position ^ 1
+		if sibling < len(level) {
+			proof.MerklePath = append(proof.MerklePath, level[sibling])
+		}
+		position /= 2
+	}
+	return proof, nil
+}
+
+// Verify checks that the transaction and the result of the proof are in the block
+// with the given hash. The block hash must come from a trusted source, such as the
+// chain of block hashes back from a block the caller trusts.
+func (proof *TransactionProof) Verify(blockHash []byte) error {
+	header := proof.GetBlockHeader()
+	if header == nil || header.TransactionsMerkleRoot == nil {
+		return fmt.Errorf("The proof has no block header with a transactions Merkle root")
+	}
+	if len(header.Transactions) != 0 || len(header.TransactionResults) != 0 || header.NonHashData != nil {
+		return fmt.Errorf("The block header of the proof holds block contents")
+	}
+	headerHash, err := hashBlockHeader(header)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(headerHash, blockHash) {
+		return fmt.Errorf("The block header of the proof does not match the block hash")
+	}
+	if proof.Transaction == nil || proof.TransactionResult == nil {
+		return fmt.Errorf("The proof has no transaction or result")
+	}
+	if proof.Index >= proof.TransactionCount {
+		return fmt.Errorf("The index %d of the proof is out of the %d transactions", proof.Index, proof.TransactionCount)
+	}
+
+	node, err := computeTransactionMerkleLeaf(proof.Transaction, proof.TransactionResult)
+	if err != nil {
+		return err
+	}
+	path := proof.MerklePath
+	position, count := int(proof.Index), int(proof.TransactionCount)
+	for count > 1 {
+		sibling := position ^ 1
+		if sibling < count {
+			if len(path) == 0 {
+				return fmt.Errorf("The Merkle path of the proof is too short")
+			}
+			if sibling < position {
+				node = computeMerkleNode(path[0], node)
+			} else {
+				node = computeMerkleNode(node, path[0])
+			}
+			path = path[1:]
+		}
+		position /= 2
+		count = (count + 1) / 2
+	}
+	if len(path) != 0 {
+		return fmt.Errorf("The Merkle path of the proof is too long")
+	}
+	if !bytes.Equal(computeMerkleRoot(node, int(proof.TransactionCount)), header.TransactionsMerkleRoot) {
+		return fmt.Errorf("The transaction of the proof does not match the transactions Merkle root")
+	}
+	return nil
+}
+
+// computeTransactionsMerkleLevels returns the levels of the transactions Merkle tree,
+// from the leaves up to the top node
+func (block *Block) computeTransactionsMerkleLevels() ([][][]byte, error) {
+	if len(block.Transactions) == 0 {
+		if len(block.TransactionResults) != 0 {
+			return nil, fmt.Errorf("Block has %d transaction results but no transactions", len(block.TransactionResults))
+		}
+		return nil, nil
+	}
+	if len(block.TransactionResults) != len(block.Transactions) {
+		return nil, fmt.Errorf("Block has %d transaction results for %d transactions", len(block.TransactionResults), len(block.Transactions))
+	}
+	level := make([][]byte, len(block.Transactions))
+	for i, transaction := range block.Transactions {
+		leaf, err := computeTransactionMerkleLeaf(transaction, block.TransactionResults[i])
+		if err != nil {
+			return nil, err
+		}
+		level[i] = leaf
+	}
+	levels := [][][]byte{level}
+	for len(level) > 1 {
+		var nextLevel [][]byte
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				nextLevel = append(nextLevel, computeMerkleNode(level[i], level[i+1]))
+			} else {
+				nextLevel = append(nextLevel, level[i])
+			}
+		}
+		levels = append(levels, nextLevel)
+		level = nextLevel
+	}
+	return levels, nil
+}
+
+func computeTransactionMerkleLeaf(transaction *Transaction, result *TransactionResult) ([]byte, error) {
+	transactionBytes, err := proto.Marshal(transaction)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal transaction: %s", err)
+	}
+	resultBytes, err := proto.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal transaction result: %s", err)
+	}
+	content := []byte{merkleLeafPrefix}
+	content = append(content, util.ComputeCryptoHash(transactionBytes)...)
+	content = append(content, util.ComputeCryptoHash(resultBytes)...)
+	return util.ComputeCryptoHash(content), nil
+}
+
+func computeMerkleNode(left []byte, right []byte) []byte {
+	content := []byte{merkleNodePrefix}
+	content = append(content, left...)
+	content = append(content, right...)
+	return util.ComputeCryptoHash(content)
+}
+
+func computeMerkleRoot(top []byte, transactionCount int) []byte {
+	content := make([]byte, 5, 5+len(top))
+	content[0] = merkleRootPrefix
+	binary.BigEndian.PutUint32(content[1:], uint32(transactionCount))
+	return util.ComputeCryptoHash(append(content, top...))
+}
@@ -18,6 +18,7 @@ package protos
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 	"time"
 
@@ -88,3 +89,69 @@ func TestBlockNonHashData(t *testing.T) {
 		t.Fatalf("Expected time2 and block2 times to be equal, but there were not")
 	}
 }
+
+func TestBlockTransactionProof(t *testing.T) {
+	for count := 1; count <= 7; count++ {
+		var transactions []*Transaction
+		var results []*TransactionResult
+		for i := 0; i < count; i++ {
+			txid := fmt.Sprintf("tx%d", i)
+			transactions = append(transactions, &Transaction{Type: Transaction_CHAINCODE_INVOKE, Txid: txid, Payload: []byte(txid)})
+			// a transaction without a result gets an empty one
+			if i != 1 {
+				results = append(results, &TransactionResult{Txid: txid, Result: []byte("result-" + txid)})
+			}
+		}
+		block := NewBlock(transactions, nil)
+		if err := block.SetTransactionResults(results); err != nil {
+			t.Fatalf("Error setting transaction results: %s", err)
+		}
+		block.NonHashData = &NonHashData{LocalLedgerCommitTimestamp: util.CreateUtcTimestamp()}
+		blockHash, err := block.GetHash()
+		if err != nil {
+			t.Fatalf("Error generating block hash: %s", err)
+		}
+		headerHash, err := block.GetHeader().GetHeaderHash()
+		if err != nil || !bytes.Equal(headerHash, blockHash) {
+			t.Fatalf("Expected the hash of the header to be the block hash")
+		}
+		if _, err = NewBlock(transactions, nil).GetHeaderHash(); err == nil {
+			t.Fatalf("Expected the header of a block without a transactions Merkle root not to be hashed")
+		}
+
+		for i := 0; i < count; i++ {
+			proof, err := block.GetTransactionProof(5, i)
+			if err != nil {
+				t.Fatalf("Error getting the proof of transaction %d of %d: %s", i, count, err)
+			}
+			proofBytes, _ := proto.Marshal(proof)
+			proof = &TransactionProof{}
+			proto.Unmarshal(proofBytes, proof)
+			if err = proof.Verify(blockHash); err != nil {
+				t.Fatalf("Error verifying the proof of transaction %d of %d: %s", i, count, err)
+			}
+
+			proof.TransactionResult = &TransactionResult{Txid: proof.Transaction.Txid, Result: []byte("forged")}
+			if proof.Verify(blockHash) == nil {
+				t.Fatalf("Expected the proof of transaction %d of %d with a forged result to fail", i, count)
+			}
+		}
+
+		proof, _ := block.GetTransactionProof(5, 0)
+		if proof.Verify(util.ComputeCryptoHash([]byte("another block"))) == nil {
+			t.Fatalf("Expected the proof against another block hash to fail")
+		}
+		if count > 1 {
+			proof.Index = 1
+			if proof.Verify(blockHash) == nil {
+				t.Fatalf("Expected the proof with another index to fail")
+			}
+		}
+
+		// transactions that do not match the root cannot be hashed
+		block.Transactions[0].Payload = []byte("tampered")
+		if _, err = block.GetHash(); err == nil {
+			t.Fatalf("Expected the hash of a block with tampered transactions to fail")
+		}
+	}
+}
@@ -92,27 +92,31 @@ func (x ChaincodeDeploymentSpec_ExecutionEnvironment) String() string {
 type ChaincodeMessage_Type int32
 
 const (
-	ChaincodeMessage_UNDEFINED               ChaincodeMessage_Type = 0
-	ChaincodeMessage_REGISTER                ChaincodeMessage_Type = 1
-	ChaincodeMessage_REGISTERED              ChaincodeMessage_Type = 2
-	ChaincodeMessage_INIT                    ChaincodeMessage_Type = 3
-	ChaincodeMessage_READY                   ChaincodeMessage_Type = 4
-	ChaincodeMessage_TRANSACTION             ChaincodeMessage_Type = 5
-	ChaincodeMessage_COMPLETED               ChaincodeMessage_Type = 6
-	ChaincodeMessage_ERROR                   ChaincodeMessage_Type = 7
-	ChaincodeMessage_GET_STATE               ChaincodeMessage_Type = 8
-	ChaincodeMessage_PUT_STATE               ChaincodeMessage_Type = 9
-	ChaincodeMessage_DEL_STATE               ChaincodeMessage_Type = 10
-	ChaincodeMessage_INVOKE_CHAINCODE        ChaincodeMessage_Type = 11
-	ChaincodeMessage_INVOKE_QUERY            ChaincodeMessage_Type = 12
-	ChaincodeMessage_RESPONSE                ChaincodeMessage_Type = 13
-	ChaincodeMessage_QUERY                   ChaincodeMessage_Type = 14
-	ChaincodeMessage_QUERY_COMPLETED         ChaincodeMessage_Type = 15
-	ChaincodeMessage_QUERY_ERROR             ChaincodeMessage_Type = 16
-	ChaincodeMessage_RANGE_QUERY_STATE       ChaincodeMessage_Type = 17
-	ChaincodeMessage_RANGE_QUERY_STATE_NEXT  ChaincodeMessage_Type = 18
-	ChaincodeMessage_RANGE_QUERY_STATE_CLOSE ChaincodeMessage_Type = 19
-	ChaincodeMessage_KEEPALIVE               ChaincodeMessage_Type = 20
+	ChaincodeMessage_UNDEFINED                 ChaincodeMessage_Type = 0
+	ChaincodeMessage_REGISTER                  ChaincodeMessage_Type = 1
+	ChaincodeMessage_REGISTERED                ChaincodeMessage_Type = 2
+	ChaincodeMessage_INIT                      ChaincodeMessage_Type = 3
+	ChaincodeMessage_READY                     ChaincodeMessage_Type = 4
+	ChaincodeMessage_TRANSACTION               ChaincodeMessage_Type = 5
+	ChaincodeMessage_COMPLETED                 ChaincodeMessage_Type = 6
+	ChaincodeMessage_ERROR                     ChaincodeMessage_Type = 7
+	ChaincodeMessage_GET_STATE                 ChaincodeMessage_Type = 8
+	ChaincodeMessage_PUT_STATE                 ChaincodeMessage_Type = 9
+	ChaincodeMessage_DEL_STATE                 ChaincodeMessage_Type = 10
+	ChaincodeMessage_INVOKE_CHAINCODE          ChaincodeMessage_Type = 11
+	ChaincodeMessage_INVOKE_QUERY              ChaincodeMessage_Type = 12
+	ChaincodeMessage_RESPONSE                  ChaincodeMessage_Type = 13
+	ChaincodeMessage_QUERY                     ChaincodeMessage_Type = 14
+	ChaincodeMessage_QUERY_COMPLETED           ChaincodeMessage_Type = 15
+	ChaincodeMessage_QUERY_ERROR               ChaincodeMessage_Type = 16
+	ChaincodeMessage_RANGE_QUERY_STATE         ChaincodeMessage_Type = 17
+	ChaincodeMessage_RANGE_QUERY_STATE_NEXT    ChaincodeMessage_Type = 18
+	ChaincodeMessage_RANGE_QUERY_STATE_CLOSE   ChaincodeMessage_Type = 19
+	ChaincodeMessage_KEEPALIVE                 ChaincodeMessage_Type = 20
+	ChaincodeMessage_GET_HISTORY_FOR_KEY       ChaincodeMessage_Type = 21
+	ChaincodeMessage_GET_HISTORY_FOR_KEY_NEXT  ChaincodeMessage_Type = 22
+	ChaincodeMessage_GET_HISTORY_FOR_KEY_CLOSE ChaincodeMessage_Type = 23
+	ChaincodeMessage_GET_QUERY_RESULT          ChaincodeMessage_Type = 24
 )
 
 var ChaincodeMessage_Type_name = map[int32]string{
@@ -137,29 +141,37 @@ var ChaincodeMessage_Type_name = map[int32]string{
 	18: "RANGE_QUERY_STATE_NEXT",
 	19: "RANGE_QUERY_STATE_CLOSE",
 	20: "KEEPALIVE",
+	21: "GET_HISTORY_FOR_KEY",
+	22: "GET_HISTORY_FOR_KEY_NEXT",
+	23: "GET_HISTORY_FOR_KEY_CLOSE",
+	24: "GET_QUERY_RESULT",
 }
 var ChaincodeMessage_Type_value = map[string]int32{
-	"UNDEFINED":               0,
-	"REGISTER":                1,
-	"REGISTERED":              2,
-	"INIT":                    3,
-	"READY":                   4,
-	"TRANSACTION":             5,
-	"COMPLETED":               6,
-	"ERROR":                   7,
-	"GET_STATE":               8,
-	"PUT_STATE":               9,
-	"DEL_STATE":               10,
-	"INVOKE_CHAINCODE":        11,
-	"INVOKE_QUERY":            12,
-	"RESPONSE":                13,
-	"QUERY":                   14,
-	"QUERY_COMPLETED":         15,
-	"QUERY_ERROR":             16,
-	"RANGE_QUERY_STATE":       17,
-	"RANGE_QUERY_STATE_NEXT":  18,
-	"RANGE_QUERY_STATE_CLOSE": 19,
-	"KEEPALIVE":               20,
+	"UNDEFINED":                 0,
+	"REGISTER":                  1,
+	"REGISTERED":                2,
+	"INIT":                      3,
+	"READY":                     4,
+	"TRANSACTION":               5,
+	"COMPLETED":                 6,
+	"ERROR":                     7,
+	"GET_STATE":                 8,
+	"PUT_STATE":                 9,
+	"DEL_STATE":                 10,
+	"INVOKE_CHAINCODE":          11,
+	"INVOKE_QUERY":              12,
+	"RESPONSE":                  13,
+	"QUERY":                     14,
+	"QUERY_COMPLETED":           15,
+	"QUERY_ERROR":               16,
+	"RANGE_QUERY_STATE":         17,
+	"RANGE_QUERY_STATE_NEXT":    18,
+	"RANGE_QUERY_STATE_CLOSE":   19,
+	"KEEPALIVE":                 20,
+	"GET_HISTORY_FOR_KEY":       21,
+	"GET_HISTORY_FOR_KEY_NEXT":  22,
+	"GET_HISTORY_FOR_KEY_CLOSE": 23,
+	"GET_QUERY_RESULT":          24,
 }
 
 func (x ChaincodeMessage_Type) String() string {
@@ -350,9 +362,16 @@ func (m *PutStateInfo) Reset()         { *m = PutStateInfo{} }
 func (m *PutStateInfo) String() string { return proto.CompactTextString(m) }
 func (*PutStateInfo) ProtoMessage()    {}
 
+// RangeQueryState queries the keys between startKey and endKey. When limit,
+// reverse or bookmark is set, the keys come in lexical order, or in reverse,
+// up to limit of them (all if 0) after the bookmark, and the response holds
+// the bookmark of the next page.
 type RangeQueryState struct {
 	StartKey string `protobuf:"bytes,1,opt,name=startKey" json:"startKey,omitempty"`
 	EndKey   string `protobuf:"bytes,2,opt,name=endKey" json:"endKey,omitempty"`
+	Limit    uint32 `protobuf:"varint,3,opt,name=limit" json:"limit,omitempty"`
+	Reverse  bool   `protobuf:"varint,4,opt,name=reverse" json:"reverse,omitempty"`
+	Bookmark string `protobuf:"bytes,5,opt,name=bookmark" json:"bookmark,omitempty"`
 }
 
 func (m *RangeQueryState) Reset()         { *m = RangeQueryState{} }
@@ -388,6 +407,7 @@ type RangeQueryStateResponse struct {
 	KeysAndValues []*RangeQueryStateKeyValue `protobuf:"bytes,1,rep,name=keysAndValues" json:"keysAndValues,omitempty"`
 	HasMore       bool                       `protobuf:"varint,2,opt,name=hasMore" json:"hasMore,omitempty"`
 	ID            string                     `protobuf:"bytes,3,opt,name=ID" json:"ID,omitempty"`
+	Bookmark      string                     `protobuf:"bytes,4,opt,name=bookmark" json:"bookmark,omitempty"`
 }
 
 func (m *RangeQueryStateResponse) Reset()         { *m = RangeQueryStateResponse{} }
@@ -401,6 +421,71 @@ func (m *RangeQueryStateResponse) GetKeysAndValues() []*RangeQueryStateKeyValue
 	return nil
 }
 
+type GetHistoryForKey struct {
+	Key string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+}
+
+func (m *GetHistoryForKey) Reset()         { *m = GetHistoryForKey{} }
+func (m *GetHistoryForKey) String() string { return proto.CompactTextString(m) }
+func (*GetHistoryForKey) ProtoMessage()    {}
+
+type GetHistoryForKeyNext struct {
+	ID string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+}
+
+func (m *GetHistoryForKeyNext) Reset()         { *m = GetHistoryForKeyNext{} }
+func (m *GetHistoryForKeyNext) String() string { return proto.CompactTextString(m) }
+func (*GetHistoryForKeyNext) ProtoMessage()    {}
+
+type GetHistoryForKeyClose struct {
+	ID string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
+}
+
+func (m *GetHistoryForKeyClose) Reset()         { *m = GetHistoryForKeyClose{} }
+func (m *GetHistoryForKeyClose) String() string { return proto.CompactTextString(m) }
+func (*GetHistoryForKeyClose) ProtoMessage()    {}
+
+// KeyModification is a value written to a key by a committed transaction.
+// txIndex is the position of the transaction in its block
+type KeyModification struct {
+	TxID        string `protobuf:"bytes,1,opt,name=txID" json:"txID,omitempty"`
+	BlockNumber uint64 `protobuf:"varint,2,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	TxIndex     uint64 `protobuf:"varint,3,opt,name=txIndex" json:"txIndex,omitempty"`
+	Value       []byte `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	IsDelete    bool   `protobuf:"varint,5,opt,name=isDelete" json:"isDelete,omitempty"`
+}
+
+func (m *KeyModification) Reset()         { *m = KeyModification{} }
+func (m *KeyModification) String() string { return proto.CompactTextString(m) }
+func (*KeyModification) ProtoMessage()    {}
+
+type GetHistoryForKeyResponse struct {
+	Modifications []*KeyModification `protobuf:"bytes,1,rep,name=modifications" json:"modifications,omitempty"`
+	HasMore       bool               `protobuf:"varint,2,opt,name=hasMore" json:"hasMore,omitempty"`
+	ID            string             `protobuf:"bytes,3,opt,name=ID" json:"ID,omitempty"`
+}
+
+func (m *GetHistoryForKeyResponse) Reset()         { *m = GetHistoryForKeyResponse{} }
+func (m *GetHistoryForKeyResponse) String() string { return proto.CompactTextString(m) }
+func (*GetHistoryForKeyResponse) ProtoMessage()    {}
+
+func (m *GetHistoryForKeyResponse) GetModifications() []*KeyModification {
+	if m != nil {
+		return m.Modifications
+	}
+	return nil
+}
+
+// GetQueryResult selects the values of the chaincode with a rich query. The
+// response is a RangeQueryStateResponse, read on with RANGE_QUERY_STATE_NEXT
+type GetQueryResult struct {
+	Query string `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+}
+
+func (m *GetQueryResult) Reset()         { *m = GetQueryResult{} }
+func (m *GetQueryResult) String() string { return proto.CompactTextString(m) }
+func (*GetQueryResult) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterEnum("protos.ConfidentialityLevel", ConfidentialityLevel_name, ConfidentialityLevel_value)
 	proto.RegisterEnum("protos.ChaincodeSpec_Type", ChaincodeSpec_Type_name, ChaincodeSpec_Type_value)
@@ -246,14 +246,20 @@ func (m *TransactionResult) GetChaincodeEvent() *ChaincodeEvent {
 // nonHashData - Data stored with the block, but not included in the blocks
 // hash. This allows this data to be different per peer or discarded without
 // impacting the blockchain.
+// transactionsMerkleRoot - The Merkle root over the transactions and their
+// results. When set, the block hash covers the root in place of the
+// transactions and transactionResults.
+// transactionResults - The results of the transactions, one per transaction.
 type Block struct {
-	Version           uint32                     `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
-	Timestamp         *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
-	Transactions      []*Transaction             `protobuf:"bytes,3,rep,name=transactions" json:"transactions,omitempty"`
-	StateHash         []byte                     `protobuf:"bytes,4,opt,name=stateHash,proto3" json:"stateHash,omitempty"`
-	PreviousBlockHash []byte                     `protobuf:"bytes,5,opt,name=previousBlockHash,proto3" json:"previousBlockHash,omitempty"`
-	ConsensusMetadata []byte                     `protobuf:"bytes,6,opt,name=consensusMetadata,proto3" json:"consensusMetadata,omitempty"`
-	NonHashData       *NonHashData               `protobuf:"bytes,7,opt,name=nonHashData" json:"nonHashData,omitempty"`
+	Version                uint32                     `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Timestamp              *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
+	Transactions           []*Transaction             `protobuf:"bytes,3,rep,name=transactions" json:"transactions,omitempty"`
+	StateHash              []byte                     `protobuf:"bytes,4,opt,name=stateHash,proto3" json:"stateHash,omitempty"`
+	PreviousBlockHash      []byte                     `protobuf:"bytes,5,opt,name=previousBlockHash,proto3" json:"previousBlockHash,omitempty"`
+	ConsensusMetadata      []byte                     `protobuf:"bytes,6,opt,name=consensusMetadata,proto3" json:"consensusMetadata,omitempty"`
+	NonHashData            *NonHashData               `protobuf:"bytes,7,opt,name=nonHashData" json:"nonHashData,omitempty"`
+	TransactionsMerkleRoot []byte                     `protobuf:"bytes,8,opt,name=transactionsMerkleRoot,proto3" json:"transactionsMerkleRoot,omitempty"`
+	TransactionResults     []*TransactionResult       `protobuf:"bytes,9,rep,name=transactionResults" json:"transactionResults,omitempty"`
 }
 
 func (m *Block) Reset()         { *m = Block{} }
@@ -281,6 +287,59 @@ func (m *Block) GetNonHashData() *NonHashData {
 	return nil
 }
 
+func (m *Block) GetTransactionResults() []*TransactionResult {
+	if m != nil {
+		return m.TransactionResults
+	}
+	return nil
+}
+
+// TransactionProof proves that a transaction and its result are in a block,
+// without the other transactions of the block.
+// blockNumber - The number of the block.
+// blockHeader - The block without its transactions, transactionResults and
+// nonHashData, whose hash is the hash of the block.
+// index - The position of the transaction in the block.
+// transactionCount - The number of transactions in the block.
+// transaction - The transaction.
+// transactionResult - The result of the transaction.
+// merklePath - The hashes of the siblings on the path from the transaction
+// to the transactionsMerkleRoot of the header, lowest first.
+type TransactionProof struct {
+	BlockNumber       uint64             `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	BlockHeader       *Block             `protobuf:"bytes,2,opt,name=blockHeader" json:"blockHeader,omitempty"`
+	Index             uint32             `protobuf:"varint,3,opt,name=index" json:"index,omitempty"`
+	TransactionCount  uint32             `protobuf:"varint,4,opt,name=transactionCount" json:"transactionCount,omitempty"`
+	Transaction       *Transaction       `protobuf:"bytes,5,opt,name=transaction" json:"transaction,omitempty"`
+	TransactionResult *TransactionResult `protobuf:"bytes,6,opt,name=transactionResult" json:"transactionResult,omitempty"`
+	MerklePath        [][]byte           `protobuf:"bytes,7,rep,name=merklePath,proto3" json:"merklePath,omitempty"`
+}
+
+func (m *TransactionProof) Reset()         { *m = TransactionProof{} }
+func (m *TransactionProof) String() string { return proto.CompactTextString(m) }
+func (*TransactionProof) ProtoMessage()    {}
+
+func (m *TransactionProof) GetBlockHeader() *Block {
+	if m != nil {
+		return m.BlockHeader
+	}
+	return nil
+}
+
+func (m *TransactionProof) GetTransaction() *Transaction {
+	if m != nil {
+		return m.Transaction
+	}
+	return nil
+}
+
+func (m *TransactionProof) GetTransactionResult() *TransactionResult {
+	if m != nil {
+		return m.TransactionResult
+	}
+	return nil
+}
+
 // Contains information about the blockchain ledger such as height, current
 // block hash, and previous block hash.
 type BlockchainInfo struct {
@@ -23,9 +23,11 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/boltdb"
+	"github.com/hyperledger/fabric/core/db/leveldb"
+	"github.com/hyperledger/fabric/core/db/rocksdb"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
-	"github.com/tecbot/gorocksdb"
 )
 
 const (
@@ -36,13 +38,23 @@ const (
 
 type detailPrinter func(data []byte)
 
+// dataStoreDirs maps the datastores this utility can read to the directory they keep
+// their files in, under 'peer.fileSystemPath'
+var dataStoreDirs = map[string]string{
+	rocksdb.Name: "db",
+	leveldb.Name: "leveldb",
+	boltdb.Name:  "boltdb",
+}
+
 func main() {
 	flagSetName := os.Args[0]
 	flagSet := flag.NewFlagSet(flagSetName, flag.ExitOnError)
 	dbDirPtr := flagSet.String("dbDir", "", "path to db dump")
+	dataStorePtr := flagSet.String("datastore", rocksdb.Name, "datastore the db dump was written by, one of rocksdb, leveldb or boltdb")
 	flagSet.Parse(os.Args[1:])
 
 	dbDir := *dbDirPtr
+	dataStoreName := *dataStorePtr
 
 	if dbDir == "" {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", flagSetName)
@@ -50,7 +62,7 @@ func main() {
 		os.Exit(3)
 	}
 	viper.Set("peer.fileSystemPath", dbDir)
-	fmt.Printf("dbDir = [%s]\n", dbDir)
+	fmt.Printf("dbDir = [%s], datastore = [%s]\n", dbDir, dataStoreName)
 
 	// check that dbDir exists
 	if _, err := os.Stat(dbDir); os.IsNotExist(err) {
@@ -58,84 +70,70 @@ func main() {
 		os.Exit(4)
 	}
 
-	if _, err := os.Stat(dbDir + "/db"); os.IsNotExist(err) {
-		fmt.Fprintln(os.Stderr, "dbDir does not contain a sub-dir named 'db'")
+	dirName, ok := dataStoreDirs[dataStoreName]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unsupported datastore [%s]\n", dataStoreName)
+		os.Exit(3)
+	}
+	if _, err := os.Stat(dbDir + "/" + dirName); os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "dbDir does not contain a sub-dir named '%s'\n", dirName)
 		os.Exit(5)
 	}
 
-	db.Start()
-	openchainDB := db.GetDBHandle()
-	defer db.Stop()
-	fmt.Println()
-	scan(openchainDB, "blockchainCF", openchainDB.BlockchainCF, blockDetailPrinter)
+	openchainDB, err := db.Registry.Open(dataStoreName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while opening the db: %s\n", err)
+		os.Exit(6)
+	}
+	defer db.Registry.Close(dataStoreName)
 	fmt.Println()
-	scan(openchainDB, "persistCF", openchainDB.PersistCF, nil)
+	scan(openchainDB.GetBlockchainIterator(), "blockchainCF", blockDetailPrinter)
 	fmt.Println()
-	printLiveFilesMetaData(openchainDB)
+	scan(openchainDB.GetPersistenceIterator(), "persistCF", nil)
 	fmt.Println()
-	printProperties(openchainDB)
+	stats, err := openchainDB.Stats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while reading the db stats: %s\n", err)
+		os.Exit(7)
+	}
+	printStats(stats)
 	fmt.Println()
 }
 
-func printLiveFilesMetaData(openchainDB *db.OpenchainDB) {
-	fmt.Println("------ Details of LiveFilesMetaData ---")
-	db := openchainDB.DB
-	liveFileMetadata := db.GetLiveFilesMetaData()
-	for _, file := range liveFileMetadata {
-		fmt.Printf("file.Name=[%s], file.Level=[%d], file.Size=[%d]\n",
-			file.Name, file.Level, file.Size)
+func printStats(stats *db.Stats) {
+	fmt.Printf("------ Details of datastore [%s] ---\n", stats.Type)
+	fmt.Printf("diskSize=[%d]\n", stats.DiskSize)
+	for _, table := range stats.Tables {
+		estimated := ""
+		if table.Estimated {
+			estimated = " (estimated)"
+		}
+		fmt.Printf("table=[%s], keys=[%d], size=[%d]%s\n", table.Name, table.Keys, table.Size, estimated)
+	}
+	if stats.Compaction != "" {
+		fmt.Printf("compaction:\n%s\n", stats.Compaction)
 	}
 }
 
-func printProperties(openchainDB *db.OpenchainDB) {
-	fmt.Println("------ Details of Properties ---")
-	db := openchainDB.DB
-	fmt.Printf("rocksdb.estimate-live-data-size:- BlockchainCF:%s, StateCF:%s, StateDeltaCF:%s, IndexesCF:%s, PersistCF:%s\n\n",
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.BlockchainCF),
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.StateCF),
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.StateDeltaCF),
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.IndexesCF),
-		db.GetPropertyCF("rocksdb.estimate-live-data-size", openchainDB.PersistCF))
-	fmt.Printf("Default:%s\n", db.GetProperty("rocksdb.estimate-live-data-size"))
-
-	fmt.Printf("rocksdb.num-live-versions:- BlockchainCF:%s, StateCF:%s, StateDeltaCF:%s, IndexesCF:%s, PersistCF:%s\n\n",
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.BlockchainCF),
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.StateCF),
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.StateDeltaCF),
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.IndexesCF),
-		db.GetPropertyCF("rocksdb.num-live-versions", openchainDB.PersistCF))
-
-	fmt.Printf("rocksdb.cfstats:\n %s %s %s %s %s\n\n",
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.BlockchainCF),
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.StateCF),
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.StateDeltaCF),
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.IndexesCF),
-		db.GetPropertyCF("rocksdb.cfstats", openchainDB.PersistCF))
-}
-
-func scan(openchainDB *db.OpenchainDB, cfName string, cf *gorocksdb.ColumnFamilyHandle, printer detailPrinter) (int, int) {
+func scan(itr db.Iterator, cfName string, printer detailPrinter) (int, int) {
 	fmt.Printf("------- Printing Key-values larger than [%d] bytes in Column family [%s]--------\n", MaxValueSize, cfName)
-	itr := openchainDB.GetIterator(cf)
 	totalKVs := 0
 	overSizeKVs := 0
 	itr.SeekToFirst()
 	for ; itr.Valid(); itr.Next() {
-		k := itr.Key()
-		v := itr.Value()
-		keyBytes := k.Data()
-		valueSize := v.Size()
+		keyBytes := itr.KeyData()
+		value := itr.ValueData()
+		valueSize := len(value)
 		totalKVs++
 		if valueSize >= MaxValueSize {
 			overSizeKVs++
 			fmt.Printf("key=[%x], valueSize=[%d]\n", keyBytes, valueSize)
 			if printer != nil {
 				fmt.Println("=== KV Details === ")
-				printer(v.Data())
+				printer(value)
 				fmt.Println("")
 			}
 		}
-		k.Free()
-		v.Free()
 	}
 	itr.Close()
 	fmt.Printf("totalKVs=[%d], overSizeKVs=[%d]\n", totalKVs, overSizeKVs)
@@ -22,8 +22,8 @@ import (
 	"testing"
 
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/rocksdb"
 	"github.com/spf13/viper"
-	"github.com/tecbot/gorocksdb"
 )
 
 func TestMain(m *testing.M) {
@@ -43,15 +43,15 @@ func TestDBStatsOversizedKV(t *testing.T) {
 	defer dbTestWrapper.CloseDB(t)
 	defer deleteTestDBDir()
 
-	openchainDB := db.GetDBHandle()
-	writeBatch := gorocksdb.NewWriteBatch()
-	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("key1"), []byte("value1"))
-	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("key2"), generateOversizedValue(0))
-	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("key3"), generateOversizedValue(100))
-	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("key4"), []byte("value4"))
+	openchainDB, _ := db.Registry.Get(rocksdb.Name)
+	writeBatch := openchainDB.NewWriteBatch()
+	writeBatch.Put(db.BlockchainCF, []byte("key1"), []byte("value1"))
+	writeBatch.Put(db.BlockchainCF, []byte("key2"), generateOversizedValue(0))
+	writeBatch.Put(db.BlockchainCF, []byte("key3"), generateOversizedValue(100))
+	writeBatch.Put(db.BlockchainCF, []byte("key4"), []byte("value4"))
 	dbTestWrapper.WriteToDB(t, writeBatch)
 
-	totalKVs, numOverSizedKVs := scan(openchainDB, "blockchainCF", openchainDB.BlockchainCF, testDetailPrinter)
+	totalKVs, numOverSizedKVs := scan(openchainDB.GetBlockchainIterator(), "blockchainCF", testDetailPrinter)
 
 	if totalKVs != 4 {
 		t.Fatalf("totalKVs is not correct. Expected [%d], found [%d]", 4, totalKVs)
@@ -66,12 +66,41 @@ func TestDBStatsOversizedKV(t *testing.T) {
 	}
 }
 
+func TestDBStatsTables(t *testing.T) {
+	dbTestWrapper := db.NewTestDBWrapper()
+	dbTestWrapper.CleanDB(t)
+	defer dbTestWrapper.CloseDB(t)
+	defer deleteTestDBDir()
+
+	openchainDB, _ := db.Registry.Get(rocksdb.Name)
+	writeBatch := openchainDB.NewWriteBatch()
+	writeBatch.Put(db.BlockchainCF, []byte("key1"), []byte("value1"))
+	writeBatch.Put(db.BlockchainCF, []byte("key2"), []byte("value2"))
+	writeBatch.Put(db.PersistCF, []byte("key3"), []byte("value3"))
+	dbTestWrapper.WriteToDB(t, writeBatch)
+
+	stats, err := openchainDB.Stats()
+	if err != nil {
+		t.Fatalf("Error while reading stats: %s", err)
+	}
+	if len(stats.Tables) != len(db.ColumnFamilies) {
+		t.Fatalf("Expected stats for [%d] tables, found [%d]", len(db.ColumnFamilies), len(stats.Tables))
+	}
+	for _, table := range stats.Tables {
+		if table.Name == db.BlockchainCF && table.Keys != 2 {
+			t.Fatalf("Expected [2] keys in blockchainCF, found [%d]", table.Keys)
+		}
+	}
+	printStats(stats)
+}
+
 func setupTestConfig() {
 	tempDir, err := ioutil.TempDir("", "db-stats-test")
 	if err != nil {
 		panic(err)
 	}
 	viper.Set("peer.fileSystemPath", tempDir)
+	viper.Set("datastore.name", rocksdb.Name)
 }
 
 func deleteTestDBDir() {